@@ -0,0 +1,207 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yarpc/yab/transport"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	errAuthTokenAndCmd = errors.New("cannot specify both --auth-token and --auth-cmd")
+	errAuthRefreshCmd  = errors.New("--auth-refresh requires --auth-cmd")
+	errNegativeRefresh = errors.New("auth refresh cannot be negative")
+	errEmptyAuthToken  = errors.New("auth command returned an empty token")
+)
+
+// _defaultAuthHeader is used if the options don't specify an auth header.
+const _defaultAuthHeader = "Authorization"
+
+// _redactedAuthToken replaces the auth token in the --dry-run output.
+const _redactedAuthToken = "<redacted>"
+
+// authTokens returns the token added to the header of each call. The token
+// is either static, or the output of a command that is re-run to get a new
+// token after the refresh interval.
+type authTokens struct {
+	header  string
+	token   string
+	cmd     string
+	refresh time.Duration
+
+	// These are overridden in tests.
+	now    func() time.Time
+	runCmd func(cmd string) (string, error)
+
+	mu      sync.Mutex
+	cached  string
+	expires time.Time
+}
+
+// newAuthTokens returns the auth tokens for the given options, or nil if
+// no auth token is configured.
+func newAuthTokens(opts TransportOptions) (*authTokens, error) {
+	if opts.AuthToken != "" && opts.AuthCmd != "" {
+		return nil, errAuthTokenAndCmd
+	}
+	if opts.AuthRefresh < 0 {
+		return nil, errNegativeRefresh
+	}
+	if opts.AuthRefresh > 0 && opts.AuthCmd == "" {
+		return nil, errAuthRefreshCmd
+	}
+	if opts.AuthToken == "" && opts.AuthCmd == "" {
+		return nil, nil
+	}
+
+	header := opts.AuthHeader
+	if header == "" {
+		header = _defaultAuthHeader
+	}
+	return &authTokens{
+		header:  header,
+		token:   opts.AuthToken,
+		cmd:     opts.AuthCmd,
+		refresh: opts.AuthRefresh,
+		now:     time.Now,
+		runCmd:  runAuthCmd,
+	}, nil
+}
+
+// get returns the current token, running the command if there is no token
+// yet, or the previous token has expired.
+func (a *authTokens) get() (string, error) {
+	if a.cmd == "" {
+		return a.token, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if a.cached != "" && (a.refresh == 0 || now.Before(a.expires)) {
+		return a.cached, nil
+	}
+
+	token, err := a.runCmd(a.cmd)
+	if err != nil {
+		return "", err
+	}
+	a.cached = token
+	a.expires = now.Add(a.refresh)
+	return token, nil
+}
+
+// runAuthCmd runs the command using the shell, and returns its output
+// without any surrounding whitespace as the token.
+func runAuthCmd(cmd string) (string, error) {
+	var stderr bytes.Buffer
+	c := exec.Command("sh", "-c", cmd)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("auth command failed: %v: %v", err, msg)
+		}
+		return "", fmt.Errorf("auth command failed: %v", err)
+	}
+
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", errEmptyAuthToken
+	}
+	return token, nil
+}
+
+// authTransport adds the auth token as a header to each call.
+type authTransport struct {
+	transport.Transport
+
+	tokens *authTokens
+}
+
+func (t authTransport) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	authReq, err := t.authRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.Transport.Call(ctx, authReq)
+}
+
+// authRequest returns a copy of the request with the auth token header. HTTP
+// sends application headers with an Rpc-Header- prefix, so the token is sent
+// as an HTTP header instead, for servers that expect a plain Authorization
+// header.
+func (t authTransport) authRequest(req *transport.Request) (*transport.Request, error) {
+	token, err := t.tokens.get()
+	if err != nil {
+		return nil, err
+	}
+	return t.withToken(req, token), nil
+}
+
+// redactedRequest is like authRequest, but the token is redacted. The token is
+// still fetched, so failures to get a token are reported.
+func (t authTransport) redactedRequest(req *transport.Request) (*transport.Request, error) {
+	if _, err := t.tokens.get(); err != nil {
+		return nil, err
+	}
+	return t.withToken(req, _redactedAuthToken), nil
+}
+
+// withToken returns a copy of the request with the given token header.
+func (t authTransport) withToken(req *transport.Request, token string) *transport.Request {
+	// The request may be shared by concurrent calls, so the headers are copied.
+	authReq := *req
+	if t.Protocol() == transport.HTTP {
+		authReq.TransportHeaders = withHeader(req.TransportHeaders, t.tokens.header, token)
+	} else {
+		authReq.Headers = withHeader(req.Headers, t.tokens.header, token)
+	}
+	return &authReq
+}
+
+// withHeader returns a copy of headers with the given header added.
+func withHeader(headers map[string]string, key, value string) map[string]string {
+	result := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		result[k] = v
+	}
+	result[key] = value
+	return result
+}
+
+func (t authTransport) Close() error {
+	if closer, ok := t.Transport.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
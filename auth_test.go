@@ -0,0 +1,304 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/encoding"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestNewAuthTokens(t *testing.T) {
+	tests := []struct {
+		msg        string
+		opts       TransportOptions
+		wantNil    bool
+		wantHeader string
+		wantErr    error
+	}{
+		{
+			msg:     "no auth",
+			wantNil: true,
+		},
+		{
+			msg:        "static token",
+			opts:       TransportOptions{AuthToken: "token", AuthHeader: "x-auth"},
+			wantHeader: "x-auth",
+		},
+		{
+			msg:        "default header",
+			opts:       TransportOptions{AuthCmd: "echo token", AuthRefresh: time.Minute},
+			wantHeader: "Authorization",
+		},
+		{
+			msg:     "token and command",
+			opts:    TransportOptions{AuthToken: "token", AuthCmd: "echo token"},
+			wantErr: errAuthTokenAndCmd,
+		},
+		{
+			msg:     "refresh without command",
+			opts:    TransportOptions{AuthToken: "token", AuthRefresh: time.Minute},
+			wantErr: errAuthRefreshCmd,
+		},
+		{
+			msg:     "negative refresh",
+			opts:    TransportOptions{AuthCmd: "echo token", AuthRefresh: -time.Minute},
+			wantErr: errNegativeRefresh,
+		},
+	}
+
+	for _, tt := range tests {
+		tokens, err := newAuthTokens(tt.opts)
+		if tt.wantErr != nil {
+			assert.Equal(t, tt.wantErr, err, "%v: unexpected error", tt.msg)
+			continue
+		}
+		require.NoError(t, err, "%v: unexpected error", tt.msg)
+		if tt.wantNil {
+			assert.Nil(t, tokens, "%v: expected no auth tokens", tt.msg)
+			continue
+		}
+		require.NotNil(t, tokens, "%v: expected auth tokens", tt.msg)
+		assert.Equal(t, tt.wantHeader, tokens.header, "%v: unexpected header", tt.msg)
+	}
+}
+
+func TestAuthTokensRefresh(t *testing.T) {
+	tests := []struct {
+		msg      string
+		refresh  time.Duration
+		advance  time.Duration
+		wantRuns int
+	}{
+		{
+			msg:      "no refresh runs the command once",
+			advance:  time.Hour,
+			wantRuns: 1,
+		},
+		{
+			msg:      "token is reused before it expires",
+			refresh:  time.Minute,
+			advance:  30 * time.Second,
+			wantRuns: 1,
+		},
+		{
+			msg:      "command is re-run after the token expires",
+			refresh:  time.Minute,
+			advance:  time.Minute,
+			wantRuns: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		now := time.Unix(1000, 0)
+		var runs int
+		tokens := &authTokens{
+			header:  "auth",
+			cmd:     "get-token",
+			refresh: tt.refresh,
+			now:     func() time.Time { return now },
+			runCmd: func(cmd string) (string, error) {
+				runs++
+				return fmt.Sprintf("%v-%v", cmd, runs), nil
+			},
+		}
+
+		token, err := tokens.get()
+		require.NoError(t, err, "%v: get failed", tt.msg)
+		assert.Equal(t, "get-token-1", token, "%v: unexpected first token", tt.msg)
+
+		now = now.Add(tt.advance)
+		_, err = tokens.get()
+		require.NoError(t, err, "%v: get failed", tt.msg)
+		assert.Equal(t, tt.wantRuns, runs, "%v: unexpected number of command runs", tt.msg)
+	}
+}
+
+func TestAuthTokensCmdError(t *testing.T) {
+	tokens := &authTokens{
+		cmd:    "get-token",
+		now:    time.Now,
+		runCmd: func(string) (string, error) { return "", errors.New("bad command") },
+	}
+	_, err := tokens.get()
+	assert.EqualError(t, err, "bad command", "Command errors should be returned")
+}
+
+func TestRunAuthCmd(t *testing.T) {
+	tests := []struct {
+		cmd     string
+		want    string
+		wantErr string
+	}{
+		{cmd: "echo '  token  '", want: "token"},
+		{cmd: "true", wantErr: errEmptyAuthToken.Error()},
+		{cmd: "echo failed >&2; exit 1", wantErr: "auth command failed: exit status 1: failed"},
+		{cmd: "exit 2", wantErr: "auth command failed: exit status 2"},
+	}
+
+	for _, tt := range tests {
+		got, err := runAuthCmd(tt.cmd)
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr, "%q: unexpected error", tt.cmd)
+			continue
+		}
+		assert.NoError(t, err, "%q: unexpected error", tt.cmd)
+		assert.Equal(t, tt.want, got, "%q: unexpected token", tt.cmd)
+	}
+}
+
+type headersTransport struct {
+	transport.Transport
+
+	protocol         transport.Protocol
+	headers          []map[string]string
+	transportHeaders []map[string]string
+}
+
+func (t *headersTransport) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	t.headers = append(t.headers, req.Headers)
+	t.transportHeaders = append(t.transportHeaders, req.TransportHeaders)
+	return &transport.Response{}, nil
+}
+
+func (t *headersTransport) Protocol() transport.Protocol {
+	return t.protocol
+}
+
+func TestAuthTransport(t *testing.T) {
+	inner := &headersTransport{protocol: transport.TChannel}
+	tokens := &authTokens{header: "auth", token: "secret"}
+	at := authTransport{Transport: inner, tokens: tokens}
+
+	req := &transport.Request{Headers: map[string]string{"foo": "bar"}}
+	_, err := at.Call(context.Background(), req)
+	require.NoError(t, err, "Call failed")
+
+	require.Len(t, inner.headers, 1, "Expected a single call")
+	assert.Equal(t, map[string]string{"foo": "bar", "auth": "secret"}, inner.headers[0], "Unexpected headers")
+	assert.Equal(t, map[string]string{"foo": "bar"}, req.Headers, "Request headers should not be modified")
+	assert.NoError(t, at.Close(), "Close should succeed without a closer")
+}
+
+func TestAuthTransportHTTP(t *testing.T) {
+	var gotAuth, gotRPCHeader string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotRPCHeader = r.Header.Get("Rpc-Header-Authorization")
+	}))
+	defer svr.Close()
+
+	opts := TransportOptions{
+		ServiceName: "svc",
+		CallerName:  "caller",
+		Peers:       []string{svr.URL},
+		authTokens:  &authTokens{header: _defaultAuthHeader, token: "Bearer secret"},
+	}
+	tp, err := getTransport(opts, encoding.JSON, opentracing.NoopTracer{}, _testLogger)
+	require.NoError(t, err, "Failed to create HTTP transport")
+
+	req := &transport.Request{Method: "method", Timeout: time.Second}
+	_, err = tp.Call(context.Background(), req)
+	require.NoError(t, err, "Call failed")
+
+	assert.Equal(t, "Bearer secret", gotAuth, "Token should be sent in the Authorization header")
+	assert.Empty(t, gotRPCHeader, "Token should not be sent as an application header")
+	assert.Empty(t, req.TransportHeaders, "Request headers should not be modified")
+}
+
+func TestRunWithOptionsAuthToken(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("echo", methods.echo())
+
+	tests := []struct {
+		msg     string
+		tOpts   TransportOptions
+		want    string
+		wantErr string
+	}{
+		{
+			msg:   "static token",
+			tOpts: TransportOptions{AuthToken: "secret", AuthHeader: "auth"},
+			want:  `"auth": "secret"`,
+		},
+		{
+			msg:   "token from command",
+			tOpts: TransportOptions{AuthCmd: "echo signed", AuthHeader: "auth"},
+			want:  `"auth": "signed"`,
+		},
+		{
+			msg:     "invalid options",
+			tOpts:   TransportOptions{AuthToken: "secret", AuthCmd: "echo signed"},
+			wantErr: errAuthTokenAndCmd.Error(),
+		},
+		{
+			msg:     "command fails",
+			tOpts:   TransportOptions{AuthCmd: "exit 1"},
+			wantErr: "auth command failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			var fatalMessage string
+			outBuf, _, out := getOutput(t)
+			testOut := out.(testOutput)
+			testOut.fatalf = func(format string, args ...interface{}) {
+				fatalMessage = fmt.Sprintf(format, args...)
+			}
+
+			tOpts := s.transportOpts()
+			tOpts.AuthToken = tt.tOpts.AuthToken
+			tOpts.AuthCmd = tt.tOpts.AuthCmd
+			tOpts.AuthHeader = tt.tOpts.AuthHeader
+			opts := Options{
+				ROpts: RequestOptions{Encoding: encoding.JSON, Procedure: "echo", RequestJSON: "{}"},
+				TOpts: tOpts,
+			}
+
+			runComplete := make(chan struct{})
+			go func() {
+				defer close(runComplete)
+				runWithOptions(opts, testOut, _testLogger)
+			}()
+			<-runComplete
+
+			if tt.wantErr != "" {
+				assert.Contains(t, fatalMessage, tt.wantErr, "Unexpected error")
+				return
+			}
+			assert.Empty(t, fatalMessage, "Call should succeed")
+			assert.Contains(t, outBuf.String(), tt.want, "Auth header should be echoed back")
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// compareBaseline prints how the current summary compares to the baseline,
+// which is a summary written by an earlier benchmark, and returns a
+// description of each metric that regressed by more than the tolerance, which
+// is a fraction of the baseline value.
+func compareBaseline(out output, baseline, current benchmarkSummary, tolerance float64) []string {
+	baselineP99, currentP99 := baseline.LatenciesMs["p99"], current.LatenciesMs["p99"]
+	out.Printf("Baseline comparison:\n")
+	out.Printf("  RPS:             %.2f -> %.2f (%v)\n", baseline.RPS, current.RPS, formatDelta(baseline.RPS, current.RPS))
+	out.Printf("  p99 latency:     %v -> %v (%v)\n", msToDuration(baselineP99), msToDuration(currentP99), formatDelta(baselineP99, currentP99))
+
+	var regressions []string
+	if current.RPS < baseline.RPS*(1-tolerance) {
+		regressions = append(regressions, fmt.Sprintf("RPS %.2f is %v compared to the baseline %.2f, exceeding the tolerance of %v%%",
+			current.RPS, formatDelta(baseline.RPS, current.RPS), baseline.RPS, tolerance*100))
+	}
+	if currentP99 > baselineP99*(1+tolerance) {
+		regressions = append(regressions, fmt.Sprintf("p99 latency %v is %v compared to the baseline %v, exceeding the tolerance of %v%%",
+			msToDuration(currentP99), formatDelta(baselineP99, currentP99), msToDuration(baselineP99), tolerance*100))
+	}
+	return regressions
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// formatDelta returns the relative change from before to after as a signed
+// percentage, e.g., +5.00%.
+func formatDelta(before, after float64) string {
+	if before == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", 100*(after-before)/before)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSummary returns a summary with the given RPS and p99 latency.
+func newTestSummary(rps, p99Ms float64) benchmarkSummary {
+	return benchmarkSummary{RPS: rps, LatenciesMs: map[string]float64{"p99": p99Ms}}
+}
+
+func TestCompareBaseline(t *testing.T) {
+	baseline := newTestSummary(1000, 10)
+	tests := []struct {
+		msg        string
+		current    benchmarkSummary
+		tolerance  float64
+		wantOut    []string
+		wantRegres []string
+	}{
+		{
+			msg:       "unchanged",
+			current:   newTestSummary(1000, 10),
+			tolerance: 0.1,
+			wantOut: []string{
+				"RPS:             1000.00 -> 1000.00 (+0.00%)",
+				"p99 latency:     10ms -> 10ms (+0.00%)",
+			},
+		},
+		{
+			msg:       "improved",
+			current:   newTestSummary(1500, 5),
+			tolerance: 0,
+			wantOut: []string{
+				"1000.00 -> 1500.00 (+50.00%)",
+				"10ms -> 5ms (-50.00%)",
+			},
+		},
+		{
+			msg:       "regressed within tolerance",
+			current:   newTestSummary(950, 10.5),
+			tolerance: 0.1,
+			wantOut: []string{
+				"1000.00 -> 950.00 (-5.00%)",
+				"10ms -> 10.5ms (+5.00%)",
+			},
+		},
+		{
+			msg:       "RPS regressed",
+			current:   newTestSummary(800, 10),
+			tolerance: 0.1,
+			wantRegres: []string{
+				"RPS 800.00 is -20.00% compared to the baseline 1000.00, exceeding the tolerance of 10%",
+			},
+		},
+		{
+			msg:       "both regressed",
+			current:   newTestSummary(800, 20),
+			tolerance: 0.1,
+			wantRegres: []string{
+				"RPS 800.00 is -20.00% compared to the baseline 1000.00, exceeding the tolerance of 10%",
+				"p99 latency 20ms is +100.00% compared to the baseline 10ms, exceeding the tolerance of 10%",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		buf, _, out := getOutput(t)
+		regressions := compareBaseline(out, baseline, tt.current, tt.tolerance)
+		assert.Equal(t, tt.wantRegres, regressions, "%v: unexpected regressions", tt.msg)
+		assert.Contains(t, buf.String(), "Baseline comparison:\n", "%v: missing header", tt.msg)
+		for _, want := range tt.wantOut {
+			assert.Contains(t, buf.String(), want, "%v: missing output", tt.msg)
+		}
+	}
+}
+
+func TestFormatDelta(t *testing.T) {
+	tests := []struct {
+		before, after float64
+		want          string
+	}{
+		{100, 110, "+10.00%"},
+		{100, 90, "-10.00%"},
+		{100, 100, "+0.00%"},
+		{0, 100, "n/a"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatDelta(tt.before, tt.after), "formatDelta(%v, %v)", tt.before, tt.after)
+	}
+}
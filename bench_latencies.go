@@ -0,0 +1,124 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// _latencyBufferSize is the number of rows that can be queued by workers
+// before they block on the writer.
+const _latencyBufferSize = 4096
+
+var _latencyHeader = []string{"timestamp", "latency_ms", "success", "error"}
+
+type latencyRow struct {
+	start   time.Time
+	latency time.Duration
+	err     error
+}
+
+// latencyRecorder writes a CSV row for every benchmark request. Rows are
+// written by a single goroutine, so workers only block if the writer
+// falls behind by more than _latencyBufferSize rows.
+type latencyRecorder struct {
+	f    *os.File
+	buf  *bufio.Writer
+	w    *csv.Writer
+	rows chan latencyRow
+	done chan struct{}
+	err  error
+}
+
+func newLatencyRecorder(path string) (*latencyRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create latencies file: %v", err)
+	}
+
+	buf := bufio.NewWriter(f)
+	r := &latencyRecorder{
+		f:    f,
+		buf:  buf,
+		w:    csv.NewWriter(buf),
+		rows: make(chan latencyRow, _latencyBufferSize),
+		done: make(chan struct{}),
+	}
+	r.err = r.w.Write(_latencyHeader)
+	go r.writeRows()
+	return r, nil
+}
+
+func (r *latencyRecorder) writeRows() {
+	defer close(r.done)
+	for row := range r.rows {
+		if r.err != nil {
+			continue
+		}
+
+		success, errMsg := "true", ""
+		if row.err != nil {
+			success, errMsg = "false", row.err.Error()
+		}
+		r.err = r.w.Write([]string{
+			row.start.UTC().Format(time.RFC3339Nano),
+			strconv.FormatFloat(float64(row.latency)/float64(time.Millisecond), 'f', 3, 64),
+			success,
+			errMsg,
+		})
+	}
+}
+
+// record queues a row for a request. It is a no-op if r is nil, so workers
+// don't need to check whether latencies are being recorded.
+func (r *latencyRecorder) record(start time.Time, latency time.Duration, err error) {
+	if r == nil {
+		return
+	}
+	r.rows <- latencyRow{start, latency, err}
+}
+
+// close waits for all queued rows to be written, and flushes them to the file.
+// It must be called after all workers have stopped recording.
+func (r *latencyRecorder) close() error {
+	close(r.rows)
+	<-r.done
+
+	r.w.Flush()
+	if r.err == nil {
+		r.err = r.w.Error()
+	}
+	if err := r.buf.Flush(); r.err == nil {
+		r.err = err
+	}
+	if err := r.f.Close(); r.err == nil {
+		r.err = err
+	}
+	if r.err != nil {
+		return fmt.Errorf("failed to write latencies file: %v", r.err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readLatencies(t *testing.T, path string) [][]string {
+	f, err := os.Open(path)
+	require.NoError(t, err, "Failed to open latencies file")
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err, "Failed to parse latencies file")
+	return rows
+}
+
+func TestLatencyRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yab-latencies")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "latencies.csv")
+	r, err := newLatencyRecorder(path)
+	require.NoError(t, err, "Failed to create latency recorder")
+
+	start := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.record(start, 1500*time.Microsecond, nil)
+	r.record(start, time.Second, errors.New("timeout, retry later"))
+	require.NoError(t, r.close(), "Failed to close latency recorder")
+
+	assert.Equal(t, [][]string{
+		{"timestamp", "latency_ms", "success", "error"},
+		{"2017-01-02T03:04:05Z", "1.500", "true", ""},
+		{"2017-01-02T03:04:05Z", "1000.000", "false", "timeout, retry later"},
+	}, readLatencies(t, path), "Unexpected latencies")
+}
+
+func TestLatencyRecorderNil(t *testing.T) {
+	var r *latencyRecorder
+	assert.NotPanics(t, func() {
+		r.record(time.Now(), time.Second, nil)
+	}, "Recording to a nil recorder should be a no-op")
+}
+
+func TestLatencyRecorderInvalidPath(t *testing.T) {
+	_, err := newLatencyRecorder(filepath.Join("testdata", "not-found", "latencies.csv"))
+	assert.Contains(t, err.Error(), "failed to create latencies file", "Unexpected error")
+}
+
+func TestBenchmarkLatenciesOut(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	dir, err := ioutil.TempDir("", "yab-latencies")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "latencies.csv")
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	_, _, out := getOutput(t)
+	runBenchmark(out, _testLogger, Options{
+		BOpts: BenchmarkOptions{
+			MaxRequests:  10,
+			Connections:  2,
+			Concurrency:  2,
+			LatenciesOut: path,
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	rows := readLatencies(t, path)
+	require.Len(t, rows, 11, "Expected a header and a row per request")
+	for _, row := range rows[1:] {
+		assert.Equal(t, "true", row[2], "Requests should succeed")
+	}
+}
@@ -21,31 +21,71 @@
 package main
 
 import (
-	"math/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/yarpc/yab/encoding"
 	"github.com/yarpc/yab/transport"
 
-	"github.com/opentracing/opentracing-go"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
 )
 
 type benchmarkMethod struct {
 	serializer encoding.Serializer
 	req        *transport.Request
+
+	// reqs are the requests that calls cycle through. If empty, req is used
+	// for all calls.
+	reqs []*transport.Request
+	next *atomic.Int64
+
+	// newRequest, if set, creates a fresh request for every call, such as
+	// when the body is rendered from a template.
+	newRequest func() (*transport.Request, error)
+
+	// requestIDs, if set, generates the request ID sent with each call.
+	requestIDs *requestIDs
+}
+
+func newBenchmarkMethod(serializer encoding.Serializer, req *transport.Request, reqs []*transport.Request) benchmarkMethod {
+	return benchmarkMethod{
+		serializer: serializer,
+		req:        req,
+		reqs:       reqs,
+		next:       atomic.NewInt64(0),
+	}
+}
+
+// nextRequest returns the request to use for the next call.
+func (m benchmarkMethod) nextRequest() (*transport.Request, error) {
+	if m.newRequest != nil {
+		return m.newRequest()
+	}
+	if len(m.reqs) == 0 {
+		return m.req, nil
+	}
+	i := m.next.Inc() - 1
+	return m.reqs[i%int64(len(m.reqs))], nil
 }
 
 // WarmTransport warms up a transport and returns it. The transport is warmed
-// up by making some number of requests through it.
-func (m benchmarkMethod) WarmTransport(opts TransportOptions, warmupRequests int) (transport.Transport, error) {
-	transport, err := getTransport(opts, m.serializer.Encoding(), opentracing.NoopTracer{})
+// up by making requests through it until the warmup in bOpts is done.
+func (m benchmarkMethod) WarmTransport(opts TransportOptions, bOpts BenchmarkOptions, logger *zap.Logger) (transport.Transport, error) {
+	transport, err := getTransport(opts, m.serializer.Encoding(), opts.benchmarkTracer(), logger)
 	if err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < warmupRequests; i++ {
-		_, err := makeRequest(transport, m.req)
+	start := time.Now()
+	for i := 0; !bOpts.warmupDone(i, time.Since(start)); i++ {
+		req, _ := m.requestIDs.withRequestID(m.req)
+		_, err := makeRequest(transport, req, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -54,56 +94,149 @@ func (m benchmarkMethod) WarmTransport(opts TransportOptions, warmupRequests int
 	return transport, nil
 }
 
-func (m benchmarkMethod) call(t transport.Transport) (time.Duration, error) {
+// call makes a single request and returns the latency and the size of the
+// response body. For oneway methods, the latency only covers sending the
+// request. If the benchmark is traced, a sample of the requests are reported
+// as children of the benchmark's span.
+func (m benchmarkMethod) call(t transport.Transport, trace *benchmarkTrace, logger *zap.Logger) (time.Duration, *transport.Response, error) {
+	req, err := m.nextRequest()
+	if err != nil {
+		return 0, nil, err
+	}
+	req, _ = m.requestIDs.withRequestID(req)
+
+	priority, spanOpts := trace.requestSpan()
 	start := time.Now()
-	res, err := makeRequest(t, m.req)
+	res, err := makeRequestWithTracePriority(t, req, priority, logger, spanOpts...)
 	duration := time.Since(start)
 
-	if err == nil {
-		err = m.serializer.CheckSuccess(res)
+	if err != nil {
+		return duration, nil, err
 	}
-	return duration, err
+	return duration, res, m.serializer.CheckSuccess(res)
 }
 
-func peerBalancer(peers []string) func(i int) string {
+func peerBalancer(peers []string, r *transport.Rand) func(i int) string {
 	numPeers := len(peers)
-	startOffset := rand.Intn(numPeers)
+	startOffset := r.Intn(numPeers)
 	return func(i int) string {
 		offset := (startOffset + i) % numPeers
 		return peers[offset]
 	}
 }
 
-// WarmTransports returns n transports that have been warmed up.
-// No requests may fail during the warmup period.
-func (m benchmarkMethod) WarmTransports(n int, tOpts TransportOptions, warmupRequests int) ([]transport.Transport, error) {
+// WarmTransports returns n transports that have been warmed up, along with
+// the peer that each transport is connected to.
+// No requests may fail during the warmup period. If any requests fail,
+// the returned error lists each peer that failed, and the peers that
+// connected successfully.
+func (m benchmarkMethod) WarmTransports(n int, tOpts TransportOptions, bOpts BenchmarkOptions, logger *zap.Logger) ([]transport.Transport, []string, error) {
 	tOpts, err := loadTransportPeers(tOpts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	peerFor := peerBalancer(tOpts.Peers)
+	peerFor := peerBalancer(tOpts.Peers, tOpts.rand)
 	transports := make([]transport.Transport, n)
+	peers := make([]string, n)
 	errs := make([]error, n)
 
 	var wg sync.WaitGroup
 	for i := range transports {
+		peers[i] = peerFor(i)
+
 		wg.Add(1)
 		go func(i int, tOpts TransportOptions) {
 			defer wg.Done()
-			tOpts.Peers = []string{peerFor(i)}
-			transports[i], errs[i] = m.WarmTransport(tOpts, warmupRequests)
+			tOpts.Peers = []string{peers[i]}
+			transports[i], errs[i] = m.WarmTransport(tOpts, bOpts, logger)
 		}(i, tOpts)
 	}
 
 	wg.Wait()
 
-	// If we hit any errors, return the first one.
-	for _, err := range errs {
-		if err != nil {
-			return nil, err
+	// Report every peer that failed, since multiple peers may be unreachable.
+	var failed []string
+	failedPeers := make(map[string]struct{})
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if _, ok := failedPeers[peers[i]]; ok {
+			continue
+		}
+		failedPeers[peers[i]] = struct{}{}
+		failed = append(failed, fmt.Sprintf("%v: %v", peers[i], err))
+	}
+	if len(failed) == 0 {
+		return transports, peers, nil
+	}
+
+	// A peer only connected successfully if none of its connections failed.
+	var connected []string
+	connectedPeers := make(map[string]struct{})
+	for _, peer := range peers {
+		if _, ok := failedPeers[peer]; ok {
+			continue
 		}
+		if _, ok := connectedPeers[peer]; ok {
+			continue
+		}
+		connectedPeers[peer] = struct{}{}
+		connected = append(connected, peer)
 	}
 
-	return transports, nil
+	msg := fmt.Sprintf("%v of %v peers failed:\n\t%v",
+		len(failed), numPeersUsed(len(tOpts.Peers), n), strings.Join(failed, "\n\t"))
+	if len(connected) > 0 {
+		msg += fmt.Sprintf("\nConnected peers: %v", strings.Join(connected, ", "))
+	}
+	return nil, nil, errors.New(msg)
+}
+
+// perCallTransport makes each call using a new transport, and so a new
+// connection, to measure the overhead of connection setup. The embedded
+// transport is only used for its protocol and tracer.
+type perCallTransport struct {
+	transport.Transport
+
+	newTransport func() (transport.Transport, error)
+}
+
+func (t perCallTransport) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	callTransport, err := t.newTransport()
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := callTransport.(io.Closer); ok {
+		defer closer.Close()
+	}
+	return callTransport.Call(ctx, req)
+}
+
+// PerCallTransports wraps the given transports so that each call dials a new
+// connection rather than reusing the transport's connections. Each call is
+// made to the same peer as the wrapped transport, as returned by WarmTransports.
+func (m benchmarkMethod) PerCallTransports(transports []transport.Transport, peers []string, tOpts TransportOptions, logger *zap.Logger) []transport.Transport {
+	wrapped := make([]transport.Transport, len(transports))
+	for i, t := range transports {
+		peerOpts := tOpts
+		peerOpts.Peers = []string{peers[i]}
+		wrapped[i] = perCallTransport{
+			Transport: t,
+			newTransport: func() (transport.Transport, error) {
+				return getTransport(peerOpts, m.serializer.Encoding(), peerOpts.benchmarkTracer(), logger)
+			},
+		}
+	}
+	return wrapped
+}
+
+// numPeersUsed returns the number of peers that connections are made to
+// when n connections are balanced across numPeers peers.
+func numPeersUsed(numPeers, n int) int {
+	if n < numPeers {
+		return n
+	}
+	return numPeers
 }
@@ -21,8 +21,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"math/rand"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -51,7 +53,7 @@ func benchmarkMethodForTest(t *testing.T, procedure string, p transport.Protocol
 	require.NoError(t, err, "Failed to serialize Thrift body")
 
 	req.Timeout = time.Second
-	return benchmarkMethod{serializer, req}
+	return newBenchmarkMethod(serializer, req, nil /* reqs */)
 }
 
 func TestBenchmarkMethodWarmTransport(t *testing.T) {
@@ -92,7 +94,7 @@ func TestBenchmarkMethodWarmTransport(t *testing.T) {
 			Peers:       []string{tt.peer},
 		}
 
-		transport, err := m.WarmTransport(tOpts, 1 /* warmupRequests */)
+		transport, err := m.WarmTransport(tOpts, BenchmarkOptions{WarmupRequests: 1}, _testLogger)
 		if tt.wantErr != "" {
 			if assert.Error(t, err, "WarmTransport should fail") {
 				assert.Contains(t, err.Error(), tt.wantErr, "Invalid error message")
@@ -143,7 +145,7 @@ func TestBenchmarkMethodCall(t *testing.T) {
 		ServiceName: "foo",
 		Peers:       []string{s.hostPort()},
 	}
-	tp, err := getTransport(tOpts, encoding.Thrift, opentracing.NoopTracer{})
+	tp, err := getTransport(tOpts, encoding.Thrift, opentracing.NoopTracer{}, _testLogger)
 	require.NoError(t, err, "Failed to get transport")
 
 	for _, tt := range tests {
@@ -152,7 +154,7 @@ func TestBenchmarkMethodCall(t *testing.T) {
 			m.req.Method = tt.reqMethod
 		}
 
-		d, err := m.call(tp)
+		d, _, err := m.call(tp, nil /* trace */, _testLogger)
 		if tt.wantErr != "" {
 			if assert.Error(t, err, "call should fail") {
 				assert.Contains(t, err.Error(), tt.wantErr, "call should return 0 duration")
@@ -165,6 +167,30 @@ func TestBenchmarkMethodCall(t *testing.T) {
 	}
 }
 
+func TestBenchmarkMethodCallRequestID(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	tOpts := TransportOptions{
+		CallerName:  "bar",
+		ServiceName: "foo",
+		Peers:       []string{s.hostPort()},
+	}
+	tp, err := getTransport(tOpts, encoding.Thrift, opentracing.NoopTracer{}, _testLogger)
+	require.NoError(t, err, "Failed to get transport")
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	m.requestIDs = newRequestIDs("bench", transport.TChannel)
+
+	for _, want := range []string{"bench-1", "bench-2"} {
+		_, res, err := m.call(tp, nil /* trace */, _testLogger)
+		require.NoError(t, err, "call should not fail")
+		assert.Equal(t, want, res.Headers[_requestIDHeader], "Unexpected request ID echoed by the server")
+	}
+	assert.Empty(t, m.req.Headers[_requestIDHeader], "Request ID should not be set on the shared request")
+}
+
 func TestPeerBalancer(t *testing.T) {
 	tests := []struct {
 		seed  int64
@@ -194,8 +220,7 @@ func TestPeerBalancer(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		rand.Seed(tt.seed)
-		peerFor := peerBalancer(tt.peers)
+		peerFor := peerBalancer(tt.peers, transport.NewRand(tt.seed))
 		for i, want := range tt.want {
 			got := peerFor(i)
 			assert.Equal(t, want, got, "peerBalancer(%v) seed %v i %v failed", tt.peers, tt.seed, i)
@@ -225,12 +250,15 @@ func TestBenchmarkMethodWarmTransportsSuccess(t *testing.T) {
 		ServiceName: "foo",
 		Peers:       serverHPs,
 	}
-	transports, err := m.WarmTransports(numServers, tOpts, 1 /* warmupRequests */)
+	transports, peers, err := m.WarmTransports(numServers, tOpts, BenchmarkOptions{WarmupRequests: 1}, _testLogger)
 	assert.NoError(t, err, "WarmTransports should not fail")
 	assert.Equal(t, numServers, len(transports), "Got unexpected number of transports")
 	for i, transport := range transports {
 		assert.NotNil(t, transport, "transports[%v] should not be nil", i)
 	}
+	sort.Strings(peers)
+	sort.Strings(serverHPs)
+	assert.Equal(t, serverHPs, peers, "Each transport should be connected to a different peer")
 
 	// Verify that each server has received one call.
 	for i, counter := range counters {
@@ -284,7 +312,7 @@ func TestBenchmarkMethodWarmTransportsError(t *testing.T) {
 			ServiceName: "foo",
 			Peers:       []string{s.hostPort()},
 		}
-		_, err := m.WarmTransports(10, tOpts, tt.warmup)
+		_, _, err := m.WarmTransports(10, tOpts, BenchmarkOptions{WarmupRequests: tt.warmup}, _testLogger)
 		if tt.wantErr {
 			assert.Error(t, err, "%v: WarmTransports should fail", msg)
 		} else {
@@ -292,3 +320,75 @@ func TestBenchmarkMethodWarmTransportsError(t *testing.T) {
 		}
 	}
 }
+
+func TestBenchmarkMethodWarmTransportsFailedPeers(t *testing.T) {
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	closedHP := testutils.GetClosedHostPort(t)
+	tOpts := TransportOptions{
+		CallerName:  "bar",
+		ServiceName: "foo",
+		Peers:       []string{s.hostPort(), closedHP},
+	}
+
+	_, _, err := m.WarmTransports(4, tOpts, BenchmarkOptions{WarmupRequests: 1}, _testLogger)
+	require.Error(t, err, "WarmTransports should fail")
+	assert.Contains(t, err.Error(), "1 of 2 peers failed:\n\t"+closedHP+": ", "Unexpected error")
+	assert.True(t, strings.HasSuffix(err.Error(), "\nConnected peers: "+s.hostPort()), "Successful peers should be reported, got %v", err)
+	assert.Equal(t, 1, strings.Count(err.Error(), "\n\t"), "Failed peers should be reported once")
+}
+
+func TestBenchmarkMethodNextRequest(t *testing.T) {
+	req := &transport.Request{Method: "req"}
+	m := newBenchmarkMethod(nil /* serializer */, req, nil /* reqs */)
+	for i := 0; i < 3; i++ {
+		got, err := m.nextRequest()
+		require.NoError(t, err, "nextRequest failed")
+		assert.Equal(t, req, got, "Expected the request to be used without a request list")
+	}
+
+	reqs := []*transport.Request{{Method: "r1"}, {Method: "r2"}, {Method: "r3"}}
+	m = newBenchmarkMethod(nil /* serializer */, reqs[0], reqs)
+	for i := 0; i < 7; i++ {
+		got, err := m.nextRequest()
+		require.NoError(t, err, "nextRequest failed")
+		assert.Equal(t, reqs[i%3], got, "Expected requests to be cycled, call %v", i)
+	}
+
+	var created int
+	m.newRequest = func() (*transport.Request, error) {
+		created++
+		return &transport.Request{Method: fmt.Sprint(created)}, nil
+	}
+	for i := 1; i <= 3; i++ {
+		got, err := m.nextRequest()
+		require.NoError(t, err, "nextRequest failed")
+		assert.Equal(t, fmt.Sprint(i), got.Method, "Expected a fresh request for call %v", i)
+	}
+
+	m.newRequest = func() (*transport.Request, error) {
+		return nil, errors.New("render failed")
+	}
+	_, _, err := m.call(nil /* transport */, nil /* trace */, _testLogger)
+	assert.EqualError(t, err, "render failed", "call should fail if the request can't be created")
+}
+
+func TestNumPeersUsed(t *testing.T) {
+	tests := []struct {
+		numPeers, numConns int
+		want               int
+	}{
+		{numPeers: 1, numConns: 10, want: 1},
+		{numPeers: 10, numConns: 10, want: 10},
+		{numPeers: 10, numConns: 4, want: 4},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, numPeersUsed(tt.numPeers, tt.numConns),
+			"numPeersUsed(%v, %v)", tt.numPeers, tt.numConns)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var _metricQuantiles = []float64{0.5, 0.9, 0.95, 0.99, 0.999, 1.0}
+
+// methodMetrics are the benchmark results for a single procedure.
+type methodMetrics struct {
+	procedure string
+	state     *benchmarkState
+}
+
+// writeBenchmarkMetrics writes the benchmark results to path in the
+// Prometheus text exposition format. The file is written to a temporary file
+// first and renamed, so a textfile collector never reads a partial file.
+func writeBenchmarkMetrics(path, service string, methods []methodMetrics, total time.Duration) error {
+	var buf bytes.Buffer
+	writeMetrics(&buf, service, methods, total)
+
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics file: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write metrics file: %v", err)
+	}
+	return nil
+}
+
+func writeMetrics(w io.Writer, service string, methods []methodMetrics, total time.Duration) {
+	labels := make([]string, len(methods))
+	for i, m := range methods {
+		labels[i] = fmt.Sprintf(`service="%v",procedure="%v"`, escapeLabel(service), escapeLabel(m.procedure))
+	}
+
+	writeMetricHeader(w, "yab_benchmark_requests_total", "counter", "The total number of requests made by the benchmark.")
+	for i, m := range methods {
+		fmt.Fprintf(w, "yab_benchmark_requests_total{%v} %v\n", labels[i], m.state.totalRequests)
+	}
+
+	writeMetricHeader(w, "yab_benchmark_errors_total", "counter", "The number of failed requests made by the benchmark.")
+	for i, m := range methods {
+		fmt.Fprintf(w, "yab_benchmark_errors_total{%v} %v\n", labels[i], m.state.totalErrors)
+	}
+
+	writeMetricHeader(w, "yab_benchmark_latency_seconds", "summary", "The latency of successful requests made by the benchmark.")
+	for i, m := range methods {
+		s := m.state
+		sort.Sort(byDuration(s.latencies))
+
+		var sum time.Duration
+		for _, l := range s.latencies {
+			sum += l
+		}
+
+		for _, q := range _metricQuantiles {
+			fmt.Fprintf(w, "yab_benchmark_latency_seconds{%v,quantile=\"%v\"} %v\n", labels[i], q, s.getQuantile(q).Seconds())
+		}
+		fmt.Fprintf(w, "yab_benchmark_latency_seconds_sum{%v} %v\n", labels[i], sum.Seconds())
+		fmt.Fprintf(w, "yab_benchmark_latency_seconds_count{%v} %v\n", labels[i], len(s.latencies))
+	}
+
+	writeMetricHeader(w, "yab_benchmark_duration_seconds", "gauge", "The total duration of the benchmark.")
+	fmt.Fprintf(w, "yab_benchmark_duration_seconds{service=\"%v\"} %v\n", escapeLabel(service), total.Seconds())
+}
+
+func writeMetricHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(w, "# TYPE %v %v\n", name, metricType)
+}
+
+var _labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value for the Prometheus text format.
+func escapeLabel(v string) string {
+	return _labelReplacer.Replace(v)
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/statsd"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBenchmarkMetrics(t *testing.T) {
+	state := newBenchmarkState(statsd.Noop)
+	for _, l := range []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond} {
+		state.recordLatency(l)
+	}
+	state.recordError(errors.New("failed"))
+
+	dir, err := ioutil.TempDir("", "metrics")
+	require.NoError(t, err, "Failed to create temporary directory")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "yab.prom")
+	metrics := []methodMetrics{{"Svc::method", state}}
+	require.NoError(t, writeBenchmarkMetrics(path, `s"vc`, metrics, 2*time.Second), "Failed to write metrics")
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read metrics file")
+
+	labels := `service="s\"vc",procedure="Svc::method"`
+	want := []string{
+		"# TYPE yab_benchmark_requests_total counter\n",
+		"yab_benchmark_requests_total{" + labels + "} 4\n",
+		"# TYPE yab_benchmark_errors_total counter\n",
+		"yab_benchmark_errors_total{" + labels + "} 1\n",
+		"# TYPE yab_benchmark_latency_seconds summary\n",
+		"yab_benchmark_latency_seconds{" + labels + `,quantile="0.5"} 0.002` + "\n",
+		"yab_benchmark_latency_seconds{" + labels + `,quantile="1"} 0.003` + "\n",
+		"yab_benchmark_latency_seconds_sum{" + labels + "} 0.006\n",
+		"yab_benchmark_latency_seconds_count{" + labels + "} 3\n",
+		`yab_benchmark_duration_seconds{service="s\"vc"} 2` + "\n",
+	}
+	for _, w := range want {
+		assert.Contains(t, string(contents), w, "Metrics file missing line")
+	}
+
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err), "Temporary file should be removed")
+
+	err = writeBenchmarkMetrics(filepath.Join(dir, "missing", "yab.prom"), "svc", metrics, time.Second)
+	assert.Error(t, err, "Writing to a missing directory should fail")
+}
+
+func TestEscapeLabel(t *testing.T) {
+	tests := []struct {
+		v    string
+		want string
+	}{
+		{`plain`, `plain`},
+		{`a"b`, `a\"b`},
+		{`a\b`, `a\\b`},
+		{"a\nb", `a\nb`},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, escapeLabel(tt.v), "escapeLabel(%q)", tt.v)
+	}
+}
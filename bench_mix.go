@@ -0,0 +1,180 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+
+	"github.com/yarpc/yab/transport"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	errEmptyMix      = errors.New("benchmark mix does not contain any methods")
+	errMixAndRequest = errors.New("cannot specify a method, request body or request list with --mix, they are specified in the mix")
+)
+
+// mixEntry is a method and request body in a benchmark mix file.
+type mixEntry struct {
+	Method  string      `yaml:"method"`
+	Weight  int         `yaml:"weight"`
+	Request interface{} `yaml:"request"`
+}
+
+// body returns the request body for the entry. Strings are used as is, while
+// other values are converted to JSON, which every encoding accepts.
+func (e mixEntry) body() ([]byte, error) {
+	switch v := e.Request.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(v), nil
+	}
+	return json.Marshal(jsonValue(e.Request))
+}
+
+// jsonValue converts the maps returned by the YAML parser, which have
+// interface{} keys, to maps that can be marshalled to JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = jsonValue(val)
+		}
+		return l
+	}
+	return v
+}
+
+// readBenchmarkMix reads a YAML or JSON list of methods, their weights and
+// request bodies.
+func readBenchmarkMix(file string) ([]mixEntry, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open benchmark mix: %v", err)
+	}
+
+	var entries []mixEntry
+	if err := yaml.Unmarshal(contents, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse benchmark mix: %v", err)
+	}
+	if len(entries) == 0 {
+		return nil, errEmptyMix
+	}
+
+	for i, e := range entries {
+		if e.Method == "" {
+			return nil, fmt.Errorf("entry %v: method is required", i+1)
+		}
+		if e.Weight <= 0 {
+			return nil, fmt.Errorf("entry %v: weight must be positive, got %v", i+1, e.Weight)
+		}
+	}
+	return entries, nil
+}
+
+// benchmarkMix is a weighted list of methods that benchmark calls pick from.
+type benchmarkMix struct {
+	names   []string
+	methods []benchmarkMethod
+
+	// cumulative is the running total of the weights, used to pick a method.
+	cumulative []int
+}
+
+func singleMethodMix(m benchmarkMethod) benchmarkMix {
+	return benchmarkMix{
+		names:      []string{""},
+		methods:    []benchmarkMethod{m},
+		cumulative: []int{1},
+	}
+}
+
+// newBenchmarkMix serializes the request for each entry in the mix up front.
+func newBenchmarkMix(entries []mixEntry, protocol transport.Protocol, headers map[string]string, opts Options) (benchmarkMix, error) {
+	var mix benchmarkMix
+	total := 0
+	for i, e := range entries {
+		rOpts := opts.ROpts
+		rOpts.Procedure = e.Method
+
+		serializer, err := NewSerializer(rOpts)
+		if err != nil {
+			return mix, fmt.Errorf("entry %v: %v", i+1, err)
+		}
+		serializer = withTransportSerializer(protocol, serializer, rOpts)
+
+		body, err := e.body()
+		if err != nil {
+			return mix, fmt.Errorf("entry %v: %v", i+1, err)
+		}
+		if body, err = expandEnv(body, opts.ROpts.AllowMissingEnv); err != nil {
+			return mix, fmt.Errorf("entry %v: %v", i+1, err)
+		}
+
+		req, err := serializer.Request(body)
+		if err != nil {
+			return mix, fmt.Errorf("entry %v: %v", i+1, err)
+		}
+		if req, err = prepareRequest(req, headers, opts); err != nil {
+			return mix, fmt.Errorf("entry %v: %v", i+1, err)
+		}
+
+		total += e.Weight
+		mix.names = append(mix.names, e.Method)
+		m := newBenchmarkMethod(serializer, req, nil)
+		m.requestIDs = opts.ROpts.requestIDs
+		mix.methods = append(mix.methods, m)
+		mix.cumulative = append(mix.cumulative, total)
+	}
+	return mix, nil
+}
+
+// pick returns the index of a method, chosen randomly based on the weights.
+func (m benchmarkMix) pick() int {
+	if len(m.methods) == 1 {
+		return 0
+	}
+	r := rand.Intn(m.cumulative[len(m.cumulative)-1])
+	return sort.SearchInts(m.cumulative, r+1)
+}
+
+// weight returns the percentage of calls that use the method at index i.
+func (m benchmarkMix) weight(i int) float64 {
+	prev := 0
+	if i > 0 {
+		prev = m.cumulative[i-1]
+	}
+	return 100 * float64(m.cumulative[i]-prev) / float64(m.cumulative[len(m.cumulative)-1])
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/yarpc/yab/encoding"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBenchmarkMix(t *testing.T) {
+	tests := []struct {
+		msg      string
+		contents string
+		want     []mixEntry
+		wantErr  string
+	}{
+		{
+			msg:      "YAML list",
+			contents: "- method: KV::Get\n  weight: 70\n  request: {key: foo}\n- method: KV::Set\n  weight: 30\n",
+			want: []mixEntry{
+				{Method: "KV::Get", Weight: 70, Request: map[interface{}]interface{}{"key": "foo"}},
+				{Method: "KV::Set", Weight: 30},
+			},
+		},
+		{
+			msg:      "JSON list",
+			contents: `[{"method": "KV::Get", "weight": 1, "request": "raw"}]`,
+			want:     []mixEntry{{Method: "KV::Get", Weight: 1, Request: "raw"}},
+		},
+		{
+			msg:      "empty list",
+			contents: "[]",
+			wantErr:  errEmptyMix.Error(),
+		},
+		{
+			msg:      "missing method",
+			contents: "- weight: 1\n",
+			wantErr:  "entry 1: method is required",
+		},
+		{
+			msg:      "zero weight",
+			contents: "- method: KV::Get\n  weight: 1\n- method: KV::Set\n  weight: 0\n",
+			wantErr:  "entry 2: weight must be positive, got 0",
+		},
+		{
+			msg:      "invalid YAML",
+			contents: "{",
+			wantErr:  "failed to parse benchmark mix",
+		},
+	}
+
+	for _, tt := range tests {
+		file := writeFile(t, "mix", tt.contents)
+		defer os.Remove(file)
+
+		got, err := readBenchmarkMix(file)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, tt.msg)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, tt.msg) {
+			assert.Equal(t, tt.want, got, tt.msg)
+		}
+	}
+
+	_, err := readBenchmarkMix("/fake/file")
+	assert.Contains(t, err.Error(), "failed to open benchmark mix", "Missing file should fail")
+}
+
+func TestMixEntryBody(t *testing.T) {
+	tests := []struct {
+		request interface{}
+		want    string
+	}{
+		{request: nil, want: ""},
+		{request: "raw body", want: "raw body"},
+		{
+			request: map[interface{}]interface{}{
+				"key":  "foo",
+				"list": []interface{}{map[interface{}]interface{}{1: true}},
+			},
+			want: `{"key":"foo","list":[{"1":true}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := mixEntry{Request: tt.request}.body()
+		if assert.NoError(t, err, "body(%v) failed", tt.request) {
+			assert.Equal(t, tt.want, string(got), "body(%v) mismatch", tt.request)
+		}
+	}
+}
+
+func TestBenchmarkMixPick(t *testing.T) {
+	mix := benchmarkMix{
+		methods:    make([]benchmarkMethod, 2),
+		cumulative: []int{3, 4},
+	}
+	assert.Equal(t, 75.0, mix.weight(0), "Unexpected weight for first method")
+	assert.Equal(t, 25.0, mix.weight(1), "Unexpected weight for second method")
+
+	const n = 10000
+	picked := make([]int, 2)
+	for i := 0; i < n; i++ {
+		picked[mix.pick()]++
+	}
+	assert.InDelta(t, 0.75, float64(picked[0])/n, 0.05, "First method should be picked 75%% of the time")
+}
+
+func TestRunMixBenchmark(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	fooCalls, fooHandler := methods.counter()
+	s.register(fooMethod, fooHandler)
+	defaultCalls, defaultHandler := methods.counter()
+	s.register("Simple::withDefault", defaultHandler)
+
+	opts := Options{
+		ROpts: RequestOptions{
+			Encoding:   encoding.Thrift,
+			ThriftFile: validThrift,
+		},
+		BOpts: BenchmarkOptions{
+			MaxRequests: 100,
+			Connections: 1,
+		},
+		TOpts: s.transportOpts(),
+	}
+
+	mix, err := newBenchmarkMix([]mixEntry{
+		{Method: fooMethod, Weight: 3},
+		{Method: "Simple::withDefault", Weight: 1},
+	}, transport.TChannel, nil /* headers */, opts)
+	require.NoError(t, err, "Failed to create benchmark mix")
+
+	buf, _, out := getOutput(t)
+	runMixBenchmark(out, _testLogger, opts, mix)
+
+	assert.EqualValues(t, 100, fooCalls.Load()+defaultCalls.Load(), "Unexpected number of calls")
+	assert.NotZero(t, fooCalls.Load(), "Expected calls to %v", fooMethod)
+	assert.NotZero(t, defaultCalls.Load(), "Expected calls to Simple::withDefault")
+
+	bufStr := buf.String()
+	assert.Contains(t, bufStr, "Total requests:    100\n", "Summary should include all methods")
+	assert.Contains(t, bufStr, "Methods:\n  Simple::foo (75%): ", "Missing summary for Simple::foo")
+	assert.Contains(t, bufStr, "\n  Simple::withDefault (25%): ", "Missing summary for Simple::withDefault")
+}
+
+func TestNewBenchmarkMixInvalidRequest(t *testing.T) {
+	opts := Options{
+		ROpts: RequestOptions{
+			Encoding:   encoding.Thrift,
+			ThriftFile: validThrift,
+		},
+	}
+
+	_, err := newBenchmarkMix([]mixEntry{
+		{Method: fooMethod, Weight: 1},
+		{Method: fooMethod, Weight: 1, Request: map[interface{}]interface{}{"f1": 1}},
+	}, transport.TChannel, nil /* headers */, opts)
+	if assert.Error(t, err, "Invalid request in the mix should fail") {
+		assert.Contains(t, err.Error(), "entry 2: ", "Error should include the entry")
+	}
+}
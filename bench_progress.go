@@ -0,0 +1,77 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// benchmarkProgress tracks the requests made across all workers so that
+// progress can be reported while the benchmark is running.
+type benchmarkProgress struct {
+	requests atomic.Int64
+	errors   atomic.Int64
+
+	// With --ramp-up, the number of workers that have started is reported
+	// until all targetWorkers workers are active.
+	workers       atomic.Int64
+	targetWorkers int
+}
+
+func (p *benchmarkProgress) workerStarted() {
+	p.workers.Inc()
+}
+
+func (p *benchmarkProgress) record(err error) {
+	p.requests.Inc()
+	if err != nil {
+		p.errors.Inc()
+	}
+}
+
+// report prints the progress to stderr every interval until stop is closed.
+func (p *benchmarkProgress) report(out output, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	last := start
+	var lastRequests int64
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			requests := p.requests.Load()
+			rps := float64(requests-lastRequests) / now.Sub(last).Seconds()
+			elapsed := now.Sub(start) / time.Millisecond * time.Millisecond
+			var workers string
+			if active := p.workers.Load(); active < int64(p.targetWorkers) {
+				workers = fmt.Sprintf(", Workers: %v/%v", active, p.targetWorkers)
+			}
+			out.Warnf("[%v] Requests: %v, RPS: %.2f, Errors: %v%v\n", elapsed, requests, rps, p.errors.Load(), workers)
+			last, lastRequests = now, requests
+		}
+	}
+}
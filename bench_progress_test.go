@@ -0,0 +1,96 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/tchannel-go/testutils"
+)
+
+func TestBenchmarkProgressReport(t *testing.T) {
+	var p benchmarkProgress
+	p.record(nil)
+	p.record(nil)
+	p.record(errors.New("failed"))
+
+	lines := make(chan string, 10)
+	out := testOutput{
+		warnf: func(format string, args ...interface{}) {
+			select {
+			case lines <- fmt.Sprintf(format, args...):
+			default:
+			}
+		},
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.report(out, 10*time.Millisecond, stop)
+	}()
+
+	select {
+	case line := <-lines:
+		assert.Contains(t, line, "Requests: 3,", "progress should include the number of requests")
+		assert.Contains(t, line, "Errors: 1\n", "progress should include the number of errors")
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Errorf("timed out waiting for progress")
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Errorf("report did not stop")
+	}
+}
+
+func TestBenchmarkProgressReportRampUp(t *testing.T) {
+	p := benchmarkProgress{targetWorkers: 4}
+	p.workerStarted()
+
+	lines := make(chan string, 10)
+	out := testOutput{
+		warnf: func(format string, args ...interface{}) {
+			select {
+			case lines <- fmt.Sprintf(format, args...):
+			default:
+			}
+		},
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.report(out, 10*time.Millisecond, stop)
+
+	select {
+	case line := <-lines:
+		assert.Contains(t, line, "Errors: 0, Workers: 1/4\n", "progress should include the active workers while ramping up")
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Errorf("timed out waiting for progress")
+	}
+}
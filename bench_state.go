@@ -22,6 +22,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"time"
 
@@ -36,6 +37,17 @@ type benchmarkState struct {
 	totalSuccess  int
 	totalRequests int
 	latencies     []time.Duration
+
+	// Response sizes are tracked for successful requests.
+	numResponses  int
+	responseBytes int64
+	minResponse   int
+	maxResponse   int
+
+	// Compressed responses track the size before and after decompression.
+	numCompressed     int
+	compressedBytes   int64
+	decompressedBytes int64
 }
 
 func newBenchmarkState(statter statsd.Client) *benchmarkState {
@@ -69,6 +81,39 @@ func (s *benchmarkState) merge(other *benchmarkState) {
 	s.totalErrors += other.totalErrors
 	s.totalSuccess += other.totalSuccess
 	s.totalRequests += other.totalRequests
+
+	if other.numResponses > 0 {
+		if s.numResponses == 0 || other.minResponse < s.minResponse {
+			s.minResponse = other.minResponse
+		}
+		if other.maxResponse > s.maxResponse {
+			s.maxResponse = other.maxResponse
+		}
+		s.numResponses += other.numResponses
+		s.responseBytes += other.responseBytes
+	}
+	s.numCompressed += other.numCompressed
+	s.compressedBytes += other.compressedBytes
+	s.decompressedBytes += other.decompressedBytes
+}
+
+func (s *benchmarkState) recordResponseSize(size int) {
+	if s.numResponses == 0 || size < s.minResponse {
+		s.minResponse = size
+	}
+	if size > s.maxResponse {
+		s.maxResponse = size
+	}
+	s.numResponses++
+	s.responseBytes += int64(size)
+}
+
+// recordCompressedSize records the size of a compressed response before and
+// after it was decompressed.
+func (s *benchmarkState) recordCompressedSize(compressed, decompressed int) {
+	s.numCompressed++
+	s.compressedBytes += int64(compressed)
+	s.decompressedBytes += int64(decompressed)
 }
 
 func (s *benchmarkState) recordLatency(d time.Duration) {
@@ -86,6 +131,11 @@ func (s *benchmarkState) printLatencies(out output) {
 	for _, quantile := range []float64{0.5, 0.9, 0.95, 0.99, 0.999, 0.9995, 1.0} {
 		out.Printf("  %.4f: %v\n", quantile, s.getQuantile(quantile))
 	}
+
+	stats := s.getLatencyStats()
+	out.Printf("  min:    %v\n", stats.min)
+	out.Printf("  mean:   %v\n", stats.mean)
+	out.Printf("  stddev: %v\n", stats.stddev)
 }
 
 func (s *benchmarkState) printErrors(out output) {
@@ -97,8 +147,86 @@ func (s *benchmarkState) printErrors(out output) {
 		v := s.errors[k]
 		out.Printf("  %4d: %v\n", v, k)
 	}
-	out.Printf("Total errors: %v\n", s.totalErrors)
-	out.Printf("Error rate: %.4f%%\n", 100*float32(s.totalErrors)/float32(s.totalRequests))
+}
+
+// printSummary prints the request totals, error count and throughput for a
+// benchmark that ran for the given duration.
+func (s *benchmarkState) printSummary(out output, total time.Duration) {
+	var errorRate float64
+	if s.totalRequests > 0 {
+		errorRate = 100 * float64(s.totalErrors) / float64(s.totalRequests)
+	}
+
+	out.Printf("Elapsed time:      %v\n", (total / time.Millisecond * time.Millisecond))
+	out.Printf("Total requests:    %v\n", s.totalRequests)
+	out.Printf("Total errors:      %v\n", s.totalErrors)
+	out.Printf("Error rate:        %.4f%%\n", errorRate)
+	out.Printf("RPS:               %.2f\n", float64(s.totalRequests)/total.Seconds())
+}
+
+// printResponseSizes prints the average, min and max size of successful
+// responses, using human-readable units unless raw is set. If any responses
+// were compressed, the compression ratio of those responses is also printed.
+func (s *benchmarkState) printResponseSizes(out output, raw bool) {
+	if s.numResponses == 0 {
+		return
+	}
+
+	avg := s.responseBytes / int64(s.numResponses)
+	out.Printf("Avg response size: %v\n", formatBytes(avg, raw))
+	out.Printf("Min response size: %v\n", formatBytes(int64(s.minResponse), raw))
+	out.Printf("Max response size: %v\n", formatBytes(int64(s.maxResponse), raw))
+	if s.numCompressed > 0 && s.compressedBytes > 0 {
+		ratio := float64(s.decompressedBytes) / float64(s.compressedBytes)
+		out.Printf("Compression ratio: %.2f (%v of %v responses compressed)\n", ratio, s.numCompressed, s.numResponses)
+	}
+}
+
+// printMethodSummary prints a single line summary of the results for a
+// method in a benchmark that uses multiple methods, or for a peer in a
+// benchmark that uses multiple peers.
+func (s *benchmarkState) printMethodSummary(out output, name string) {
+	sort.Sort(byDuration(s.latencies))
+	out.Printf("  %v: %v requests, %v errors, p50: %v, p99: %v, max: %v\n",
+		name, s.totalRequests, s.totalErrors, s.getQuantile(0.5), s.getQuantile(0.99), s.getQuantile(1.0))
+}
+
+// checkThresholds returns a description of each threshold that the results
+// violate. A nil maxErrorRate or a zero maxP99 disables that threshold.
+func (s *benchmarkState) checkThresholds(maxErrorRate *float64, maxP99 time.Duration) []string {
+	var violations []string
+	if maxErrorRate != nil && s.totalRequests > 0 {
+		errorRate := float64(s.totalErrors) / float64(s.totalRequests)
+		if errorRate > *maxErrorRate {
+			violations = append(violations, fmt.Sprintf("error rate %.4f exceeds --max-error-rate %v", errorRate, *maxErrorRate))
+		}
+	}
+	if maxP99 > 0 {
+		sort.Sort(byDuration(s.latencies))
+		if p99 := s.getQuantile(0.99); p99 > maxP99 {
+			violations = append(violations, fmt.Sprintf("p99 latency %v exceeds --max-p99 %v", p99, maxP99))
+		}
+	}
+	return violations
+}
+
+// mergeStatesByPeer merges the states of the workers that used each peer.
+// Workers are started concurrency at a time for each connection, so worker i
+// uses the peer for connection i / concurrency. The states are not modified.
+func mergeStatesByPeer(statter statsd.Client, peers []string, states [][]*benchmarkState, concurrency int) map[string]*benchmarkState {
+	byPeer := make(map[string]*benchmarkState)
+	for i, workerStates := range states {
+		peer := peers[i/concurrency]
+		peerState, ok := byPeer[peer]
+		if !ok {
+			peerState = newBenchmarkState(statter)
+			byPeer[peer] = peerState
+		}
+		for _, s := range workerStates {
+			peerState.merge(s)
+		}
+	}
+	return byPeer
 }
 
 func (s *benchmarkState) getQuantile(q float64) time.Duration {
@@ -129,6 +257,40 @@ func (s *benchmarkState) getQuantile(q float64) time.Duration {
 	return time.Duration(float64(s.latencies[leftIdx])*leftBias + float64(s.latencies[rightIdx])*rightBias)
 }
 
+// latencyStats describes the distribution of latencies. Unlike quantiles,
+// the mean and standard deviation help spot bimodal distributions. The max
+// is not included, since it's the 1.0 quantile.
+type latencyStats struct {
+	min    time.Duration
+	mean   time.Duration
+	stddev time.Duration
+}
+
+// getLatencyStats computes the latency stats in a single pass over the
+// latencies, using Welford's algorithm for the standard deviation to avoid
+// the precision lost by summing squares.
+func (s *benchmarkState) getLatencyStats() latencyStats {
+	var (
+		stats    latencyStats
+		mean, m2 float64
+	)
+	for i, d := range s.latencies {
+		if i == 0 || d < stats.min {
+			stats.min = d
+		}
+
+		delta := float64(d) - mean
+		mean += delta / float64(i+1)
+		m2 += delta * (float64(d) - mean)
+	}
+
+	if n := len(s.latencies); n > 0 {
+		stats.mean = time.Duration(mean)
+		stats.stddev = time.Duration(math.Sqrt(m2 / float64(n)))
+	}
+	return stats
+}
+
 type byDuration []time.Duration
 
 func (p byDuration) Len() int           { return len(p) }
@@ -29,6 +29,7 @@ import (
 	"github.com/yarpc/yab/statsd"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBenchmarkStateErrors(t *testing.T) {
@@ -112,11 +113,15 @@ func TestBenchmarkStateLatencies(t *testing.T) {
 		"0.9990: 9.99ms",
 		"0.9995: 9.995ms",
 		"1.0000: 10ms",
+		"min:    0s",
+		"mean:   5ms",
+		"stddev: 2.88704ms",
 	}
 	bufStr := buf.String()
 	for _, msg := range expected {
 		assert.Contains(t, bufStr, msg, "Latency output missing")
 	}
+	assert.NotContains(t, bufStr, "max:", "The max latency is printed as the 1.0000 quantile")
 
 	assert.Equal(t, map[string]int{
 		"success": len(latencies),
@@ -164,6 +169,177 @@ func TestBenchmarkStateMergeLatencies(t *testing.T) {
 	}
 }
 
+func TestBenchmarkStateLatencyStats(t *testing.T) {
+	tests := []struct {
+		msg       string
+		latencies []time.Duration
+		want      latencyStats
+	}{
+		{
+			msg:  "no latencies",
+			want: latencyStats{},
+		},
+		{
+			msg:       "single latency",
+			latencies: []time.Duration{3 * time.Millisecond},
+			want: latencyStats{
+				min:  3 * time.Millisecond,
+				mean: 3 * time.Millisecond,
+			},
+		},
+		{
+			msg: "bimodal latencies",
+			latencies: []time.Duration{
+				9 * time.Millisecond,
+				time.Millisecond,
+				9 * time.Millisecond,
+				time.Millisecond,
+			},
+			want: latencyStats{
+				min:    time.Millisecond,
+				mean:   5 * time.Millisecond,
+				stddev: 4 * time.Millisecond,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		state := newBenchmarkState(statsd.Noop)
+		for _, l := range tt.latencies {
+			state.recordLatency(l)
+		}
+		assert.Equal(t, tt.want, state.getLatencyStats(), "Unexpected stats for %v", tt.msg)
+	}
+}
+
+func TestMergeStatesByPeer(t *testing.T) {
+	// 2 connections with 2 concurrent workers, and 2 methods per worker.
+	const concurrency = 2
+	peers := []string{"peer1", "peer2"}
+	states := make([][]*benchmarkState, len(peers)*concurrency)
+	for i := range states {
+		states[i] = []*benchmarkState{newBenchmarkState(statsd.Noop), newBenchmarkState(statsd.Noop)}
+		for j := 0; j <= i; j++ {
+			states[i][0].recordLatency(time.Millisecond)
+		}
+		states[i][1].recordError(errors.New("failed"))
+	}
+
+	byPeer := mergeStatesByPeer(statsd.Noop, peers, states, concurrency)
+	require.Equal(t, 2, len(byPeer), "Unexpected number of peers")
+
+	// peer1 is used by workers 0 and 1, and peer2 by workers 2 and 3.
+	assert.Equal(t, 1+2+2, byPeer["peer1"].totalRequests, "peer1 request count mismatch")
+	assert.Equal(t, 2, byPeer["peer1"].totalErrors, "peer1 error count mismatch")
+	assert.Equal(t, 3+4+2, byPeer["peer2"].totalRequests, "peer2 request count mismatch")
+	assert.Equal(t, 2, byPeer["peer2"].totalErrors, "peer2 error count mismatch")
+	assert.Equal(t, 1, states[0][0].totalRequests, "Worker states should not be modified")
+}
+
+func TestBenchmarkStateSummary(t *testing.T) {
+	tests := []struct {
+		msg       string
+		successes int
+		errors    int
+		want      []string
+	}{
+		{
+			msg: "no requests",
+			want: []string{
+				"Total requests:    0\n",
+				"Total errors:      0\n",
+				"Error rate:        0.0000%\n",
+				"RPS:               0.00\n",
+			},
+		},
+		{
+			msg:       "successes only",
+			successes: 10,
+			want: []string{
+				"Elapsed time:      2s\n",
+				"Total requests:    10\n",
+				"Total errors:      0\n",
+				"Error rate:        0.0000%\n",
+				"RPS:               5.00\n",
+			},
+		},
+		{
+			msg:       "successes and errors",
+			successes: 6,
+			errors:    2,
+			want: []string{
+				"Total requests:    8\n",
+				"Total errors:      2\n",
+				"Error rate:        25.0000%\n",
+				"RPS:               4.00\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			state := newBenchmarkState(statsd.Noop)
+			for i := 0; i < tt.successes; i++ {
+				state.recordLatency(time.Millisecond)
+			}
+			for i := 0; i < tt.errors; i++ {
+				state.recordError(errors.New("failed"))
+			}
+
+			buf, _, out := getOutput(t)
+			state.printSummary(out, 2*time.Second)
+			for _, want := range tt.want {
+				assert.Contains(t, buf.String(), want, "Summary output missing")
+			}
+		})
+	}
+}
+
+func TestBenchmarkStateResponseSizes(t *testing.T) {
+	state1 := newBenchmarkState(statsd.Noop)
+	state2 := newBenchmarkState(statsd.Noop)
+	state3 := newBenchmarkState(statsd.Noop)
+
+	state1.recordResponseSize(2048)
+	state1.recordResponseSize(4096)
+	state2.recordResponseSize(1024)
+	state1.recordCompressedSize(512, 2048)
+	state2.recordCompressedSize(256, 1024)
+
+	// Merging a state without responses should not reset the min size.
+	state1.merge(state2)
+	state1.merge(state3)
+
+	tests := []struct {
+		raw  bool
+		want string
+	}{
+		{
+			want: "Avg response size: 2.3 KiB\n" +
+				"Min response size: 1.0 KiB\n" +
+				"Max response size: 4.0 KiB\n" +
+				"Compression ratio: 4.00 (2 of 3 responses compressed)\n",
+		},
+		{
+			raw: true,
+			want: "Avg response size: 2389 B\n" +
+				"Min response size: 1024 B\n" +
+				"Max response size: 4096 B\n" +
+				"Compression ratio: 4.00 (2 of 3 responses compressed)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		buf, _, out := getOutput(t)
+		state1.printResponseSizes(out, tt.raw)
+		assert.Equal(t, tt.want, buf.String(), "Response sizes mismatch for raw %v", tt.raw)
+	}
+
+	buf, _, out := getOutput(t)
+	state3.printResponseSizes(out, false)
+	assert.Empty(t, buf.String(), "No sizes should be printed without responses")
+}
+
 func TestErrorToMessage(t *testing.T) {
 	tests := []struct {
 		err  error
@@ -0,0 +1,164 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+// _summaryVersion is the version of the --summary-json format. It is only
+// incremented for incompatible changes, new fields may be added at any time.
+const _summaryVersion = 1
+
+// benchmarkSummary is the complete summary of a benchmark written by
+// --summary-json. Durations are in milliseconds.
+type benchmarkSummary struct {
+	Version       int                `json:"version"`
+	Config        benchmarkConfig    `json:"config"`
+	DurationMs    float64            `json:"durationMs"`
+	TotalRequests int                `json:"totalRequests"`
+	TotalErrors   int                `json:"totalErrors"`
+	ErrorRate     float64            `json:"errorRate"`
+	RPS           float64            `json:"rps"`
+	LatenciesMs   map[string]float64 `json:"latenciesMs"`
+	Errors        map[string]int     `json:"errors"`
+	Methods       []methodSummary    `json:"methods,omitempty"`
+	Peers         []methodSummary    `json:"peers,omitempty"`
+}
+
+// benchmarkConfig is the configuration used to run the benchmark.
+type benchmarkConfig struct {
+	Service          string  `json:"service"`
+	Procedure        string  `json:"procedure"`
+	Connections      int     `json:"connections"`
+	Concurrency      int     `json:"concurrency"`
+	ReuseConnections bool    `json:"reuseConnections"`
+	MaxRequests      int     `json:"maxRequests"`
+	MaxDurationMs    float64 `json:"maxDurationMs"`
+	MaxRPS           int     `json:"maxRPS"`
+}
+
+// methodSummary is the summary for a single method or peer in a benchmark
+// that uses multiple methods or peers.
+type methodSummary struct {
+	Name          string             `json:"name"`
+	TotalRequests int                `json:"totalRequests"`
+	TotalErrors   int                `json:"totalErrors"`
+	LatenciesMs   map[string]float64 `json:"latenciesMs"`
+}
+
+// _summaryQuantiles are the latency quantiles in the summary, keyed by the
+// name used in the JSON output.
+var _summaryQuantiles = map[string]float64{
+	"p50":   0.5,
+	"p90":   0.9,
+	"p95":   0.95,
+	"p99":   0.99,
+	"p999":  0.999,
+	"p9995": 0.9995,
+	"max":   1.0,
+}
+
+// summaryLatencies returns the latency quantiles, along with the min, mean
+// and standard deviation of the latencies. The max is the quantile 1.0.
+func summaryLatencies(s *benchmarkState) map[string]float64 {
+	sort.Sort(byDuration(s.latencies))
+	latencies := make(map[string]float64, len(_summaryQuantiles)+3)
+	for name, q := range _summaryQuantiles {
+		latencies[name] = durationToMs(s.getQuantile(q))
+	}
+
+	stats := s.getLatencyStats()
+	latencies["min"] = durationToMs(stats.min)
+	latencies["mean"] = durationToMs(stats.mean)
+	latencies["stddev"] = durationToMs(stats.stddev)
+	return latencies
+}
+
+func newMethodSummary(name string, s *benchmarkState) methodSummary {
+	return methodSummary{
+		Name:          name,
+		TotalRequests: s.totalRequests,
+		TotalErrors:   s.totalErrors,
+		LatenciesMs:   summaryLatencies(s),
+	}
+}
+
+func newBenchmarkSummary(config benchmarkConfig, s *benchmarkState, total time.Duration) benchmarkSummary {
+	var errorRate, rps float64
+	if s.totalRequests > 0 {
+		errorRate = float64(s.totalErrors) / float64(s.totalRequests)
+	}
+	if total > 0 {
+		rps = float64(s.totalRequests) / total.Seconds()
+	}
+
+	return benchmarkSummary{
+		Version:       _summaryVersion,
+		Config:        config,
+		DurationMs:    durationToMs(total),
+		TotalRequests: s.totalRequests,
+		TotalErrors:   s.totalErrors,
+		ErrorRate:     errorRate,
+		RPS:           rps,
+		LatenciesMs:   summaryLatencies(s),
+		Errors:        s.errors,
+	}
+}
+
+func durationToMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// writeBenchmarkSummary writes the summary to path as indented JSON. It's used
+// for both --summary-json and --baseline-out.
+func writeBenchmarkSummary(path string, summary benchmarkSummary) error {
+	bs, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %v", err)
+	}
+	if err := ioutil.WriteFile(path, append(bs, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write summary file: %v", err)
+	}
+	return nil
+}
+
+// readBenchmarkSummary reads a summary written by writeBenchmarkSummary, such
+// as a --baseline.
+func readBenchmarkSummary(path string) (benchmarkSummary, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return benchmarkSummary{}, fmt.Errorf("failed to read summary file: %v", err)
+	}
+
+	var summary benchmarkSummary
+	if err := json.Unmarshal(bs, &summary); err != nil {
+		return benchmarkSummary{}, fmt.Errorf("failed to parse summary file %v: %v", path, err)
+	}
+	if summary.Version != _summaryVersion {
+		return benchmarkSummary{}, fmt.Errorf("unsupported summary file version %v, expected %v", summary.Version, _summaryVersion)
+	}
+	return summary, nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/statsd"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBenchmarkSummary(t *testing.T) {
+	state := newBenchmarkState(statsd.Noop)
+	for _, l := range []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond} {
+		state.recordLatency(l)
+	}
+	state.recordError(errors.New("failed"))
+
+	config := benchmarkConfig{
+		Service:          "svc",
+		Procedure:        "Svc::method",
+		Connections:      2,
+		Concurrency:      1,
+		ReuseConnections: true,
+		MaxRequests:      4,
+	}
+	summary := newBenchmarkSummary(config, state, 2*time.Second)
+	summary.Peers = []methodSummary{newMethodSummary("1.1.1.1:1", state)}
+
+	dir, err := ioutil.TempDir("", "summary")
+	require.NoError(t, err, "Failed to create temporary directory")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "summary.json")
+	require.NoError(t, writeBenchmarkSummary(path, summary), "Failed to write summary")
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read summary file")
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(contents, &got), "Summary should be valid JSON")
+	assert.EqualValues(t, 1, got["version"], "Unexpected version")
+	assert.EqualValues(t, 2000, got["durationMs"], "Unexpected duration")
+	assert.EqualValues(t, 4, got["totalRequests"], "Unexpected total requests")
+	assert.EqualValues(t, 1, got["totalErrors"], "Unexpected total errors")
+	assert.EqualValues(t, 0.25, got["errorRate"], "Unexpected error rate")
+	assert.EqualValues(t, 2, got["rps"], "Unexpected RPS")
+	assert.Equal(t, map[string]interface{}{"failed": float64(1)}, got["errors"], "Unexpected errors")
+	_, hasMethods := got["methods"]
+	assert.False(t, hasMethods, "Methods should be omitted for a single method")
+
+	latencies, ok := got["latenciesMs"].(map[string]interface{})
+	require.True(t, ok, "Missing latencies")
+	assert.EqualValues(t, 2, latencies["p50"], "Unexpected p50")
+	assert.EqualValues(t, 3, latencies["max"], "Unexpected max")
+	assert.EqualValues(t, 1, latencies["min"], "Unexpected min")
+	assert.EqualValues(t, 2, latencies["mean"], "Unexpected mean")
+	assert.InDelta(t, 0.8165, latencies["stddev"], 0.0001, "Unexpected stddev")
+	assert.Len(t, latencies, len(_summaryQuantiles)+3, "Unexpected quantiles and stats")
+
+	assert.Equal(t, map[string]interface{}{
+		"service":          "svc",
+		"procedure":        "Svc::method",
+		"connections":      float64(2),
+		"concurrency":      float64(1),
+		"reuseConnections": true,
+		"maxRequests":      float64(4),
+		"maxDurationMs":    float64(0),
+		"maxRPS":           float64(0),
+	}, got["config"], "Unexpected config")
+
+	peers, ok := got["peers"].([]interface{})
+	require.True(t, ok, "Missing peers")
+	require.Len(t, peers, 1, "Unexpected peers")
+	assert.Equal(t, "1.1.1.1:1", peers[0].(map[string]interface{})["name"], "Unexpected peer name")
+
+	err = writeBenchmarkSummary(filepath.Join(dir, "missing", "summary.json"), summary)
+	assert.Error(t, err, "Writing to a missing directory should fail")
+
+	read, err := readBenchmarkSummary(path)
+	require.NoError(t, err, "Failed to read summary")
+	assert.Equal(t, summary, read, "Summary should round trip")
+}
+
+func TestReadBenchmarkSummaryErrors(t *testing.T) {
+	tests := []struct {
+		msg      string
+		contents string
+		wantErr  string
+	}{
+		{
+			msg:     "missing file",
+			wantErr: "failed to read summary file",
+		},
+		{
+			msg:      "invalid JSON",
+			contents: "{",
+			wantErr:  "failed to parse summary file",
+		},
+		{
+			msg:      "unsupported version",
+			contents: `{"version": 2}`,
+			wantErr:  "unsupported summary file version 2",
+		},
+		{
+			msg:      "missing version",
+			contents: `{"rps": 100}`,
+			wantErr:  "unsupported summary file version 0",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "summary")
+	require.NoError(t, err, "Failed to create temporary directory")
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, "summary.json")
+		os.Remove(path)
+		if tt.contents != "" {
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.contents), 0644), "%v: failed to write file", tt.msg)
+		}
+
+		_, err := readBenchmarkSummary(path)
+		if assert.Error(t, err, "%v: expected error", tt.msg) {
+			assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"math/rand"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// _benchmarkSpanName is the operation name of the span for a benchmark.
+const _benchmarkSpanName = "benchmark"
+
+// benchmarkTrace traces a benchmark using a span for the whole benchmark,
+// with child spans for a sample of the benchmark's requests.
+type benchmarkTrace struct {
+	span       opentracing.Span
+	sampleRate float64
+}
+
+// newBenchmarkTrace starts the span for a benchmark of the given procedure.
+// It returns nil if the benchmark isn't traced.
+func newBenchmarkTrace(tracer opentracing.Tracer, sampleRate float64, procedure string) *benchmarkTrace {
+	if tracer == nil || sampleRate <= 0 {
+		return nil
+	}
+
+	span := tracer.StartSpan(_benchmarkSpanName)
+	span.SetTag("procedure", procedure)
+	return &benchmarkTrace{span: span, sampleRate: sampleRate}
+}
+
+// requestSpan returns the trace priority and span options for a benchmark
+// request. Sampled requests are children of the benchmark's span, while other
+// requests are not reported.
+func (t *benchmarkTrace) requestSpan() (uint16, []opentracing.StartSpanOption) {
+	if t == nil || rand.Float64() >= t.sampleRate {
+		return 0, nil
+	}
+	return 1, []opentracing.StartSpanOption{opentracing.ChildOf(t.span.Context())}
+}
+
+// finish finishes the benchmark's span, tagging it with the number of
+// requests and errors.
+func (t *benchmarkTrace) finish(requests, errors int) {
+	if t == nil {
+		return
+	}
+	t.span.SetTag("requests", requests)
+	t.span.SetTag("errors", errors)
+	t.span.Finish()
+}
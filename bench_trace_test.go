@@ -0,0 +1,80 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestBenchmarkTraceDisabled(t *testing.T) {
+	tracer, closer := getTestTracer("test")
+	defer closer.Close()
+
+	assert.Nil(t, newBenchmarkTrace(nil, 1, "procedure"), "Expected no trace without a tracer")
+	assert.Nil(t, newBenchmarkTrace(tracer, 0, "procedure"), "Expected no trace without sampling")
+
+	var trace *benchmarkTrace
+	priority, spanOpts := trace.requestSpan()
+	assert.Equal(t, uint16(0), priority, "Requests should not be sampled without a trace")
+	assert.Empty(t, spanOpts, "Requests should not have a parent without a trace")
+	trace.finish(1, 0)
+}
+
+func TestBenchmarkTraceSampling(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), reporter)
+	defer closer.Close()
+
+	trace := newBenchmarkTrace(tracer, 1, "procedure")
+	require.NotNil(t, trace, "Expected trace")
+
+	priority, spanOpts := trace.requestSpan()
+	assert.Equal(t, uint16(1), priority, "Requests should be sampled")
+	child := tracer.StartSpan("request", spanOpts...)
+	child.Finish()
+
+	trace.finish(1, 0)
+	spans := reporter.GetSpans()
+	require.Len(t, spans, 2, "Expected request and benchmark spans")
+
+	benchmarkSpan := spans[1].(*jaeger.Span)
+	assert.Equal(t, _benchmarkSpanName, benchmarkSpan.OperationName(), "Unexpected benchmark span name")
+	assert.Equal(t, benchmarkSpan.Context().(jaeger.SpanContext).SpanID(), child.Context().(jaeger.SpanContext).ParentID(),
+		"Request span should be a child of the benchmark span")
+}
+
+func TestBenchmarkTraceSampleRate(t *testing.T) {
+	trace := newBenchmarkTrace(opentracing.NoopTracer{}, 0.5, "procedure")
+	require.NotNil(t, trace, "Expected trace")
+
+	var sampled int
+	for i := 0; i < 1000; i++ {
+		if priority, _ := trace.requestSpan(); priority > 0 {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 500, sampled, 150, "Expected about half of the requests to be sampled")
+}
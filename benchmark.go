@@ -22,13 +22,16 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/yarpc/yab/limiter"
+	"github.com/yarpc/yab/sorted"
 	"github.com/yarpc/yab/statsd"
 	"github.com/yarpc/yab/transport"
 
@@ -36,8 +39,18 @@ import (
 )
 
 var (
-	errNegativeDuration = errors.New("duration cannot be negative")
-	errNegativeMaxReqs  = errors.New("max requests cannot be negative")
+	errNegativeDuration    = errors.New("duration cannot be negative")
+	errNegativeMaxReqs     = errors.New("max requests cannot be negative")
+	errNegativeRPS         = errors.New("RPS cannot be negative")
+	errNegativeConnections = errors.New("connections cannot be negative")
+	errNegativeConcurrency = errors.New("concurrency cannot be negative")
+	errNegativeInterval    = errors.New("interval cannot be negative")
+	errInvalidErrorRate    = errors.New("max error rate must be between 0 and 1")
+	errNegativeMaxP99      = errors.New("max p99 cannot be negative")
+	errNegativeRampUp      = errors.New("ramp up cannot be negative")
+	errNegativeBaselineTol = errors.New("baseline tolerance cannot be negative")
+	errNegativeWarmupDur   = errors.New("warmup duration cannot be negative")
+	errInvalidTraceSample  = errors.New("trace sample rate must be between 0 and 1")
 )
 
 // setGoMaxProcs sets runtime.GOMAXPROCS if the option is set
@@ -58,6 +71,15 @@ func (o BenchmarkOptions) getNumConnections(goMaxProcs int) int {
 	return goMaxProcs * 2
 }
 
+func (o BenchmarkOptions) getConcurrency() int {
+	if o.Concurrency > 0 {
+		return o.Concurrency
+	}
+
+	// Each connection needs at least one worker to make calls.
+	return 1
+}
+
 func (o BenchmarkOptions) validate() error {
 	if o.MaxDuration < 0 {
 		return errNegativeDuration
@@ -65,10 +87,52 @@ func (o BenchmarkOptions) validate() error {
 	if o.MaxRequests < 0 {
 		return errNegativeMaxReqs
 	}
+	if o.RPS < 0 {
+		return errNegativeRPS
+	}
+	if o.Connections < 0 {
+		return errNegativeConnections
+	}
+	if o.Concurrency < 0 {
+		return errNegativeConcurrency
+	}
+	if o.Interval < 0 {
+		return errNegativeInterval
+	}
+	if o.MaxErrorRate != nil && (*o.MaxErrorRate < 0 || *o.MaxErrorRate > 1) {
+		return errInvalidErrorRate
+	}
+	if o.MaxP99 < 0 {
+		return errNegativeMaxP99
+	}
+	if o.RampUp < 0 {
+		return errNegativeRampUp
+	}
+	if o.BaselineTol < 0 {
+		return errNegativeBaselineTol
+	}
+	if o.WarmupDuration < 0 {
+		return errNegativeWarmupDur
+	}
+	if o.TraceSampling < 0 || o.TraceSampling > 1 {
+		return errInvalidTraceSample
+	}
 
 	return nil
 }
 
+// warmupDone returns whether a warmup that has made the given number of
+// requests over the elapsed time is complete. Without a duration, the warmup
+// makes exactly WarmupRequests requests. With a duration, the warmup ends when
+// the duration has elapsed, or once WarmupRequests requests are made if
+// --warmup was specified, whichever happens first.
+func (o BenchmarkOptions) warmupDone(requests int, elapsed time.Duration) bool {
+	if o.WarmupDuration <= 0 {
+		return requests >= o.WarmupRequests
+	}
+	return elapsed >= o.WarmupDuration || (o.explicitWarmup && requests >= o.WarmupRequests)
+}
+
 func (o BenchmarkOptions) enabled() bool {
 	// By default, benchmarks are disabled. At least MaxDuration or MaxRequests
 	// should not be 0 for the benchmark to start.
@@ -77,9 +141,43 @@ func (o BenchmarkOptions) enabled() bool {
 	return o.MaxDuration != 0 || o.MaxRequests != 0
 }
 
-func runWorker(t transport.Transport, m benchmarkMethod, s *benchmarkState, run *limiter.Run, logger *zap.Logger) {
+// rampUpDelay returns how long worker i of n waits before starting, so that
+// the number of active workers increases linearly over the ramp up period.
+func rampUpDelay(rampUp time.Duration, i, n int) time.Duration {
+	if rampUp <= 0 || n <= 1 {
+		return 0
+	}
+	return rampUp * time.Duration(i) / time.Duration(n)
+}
+
+// waitForRampUp waits for a worker's ramp up delay, and returns false if the
+// run is stopped before the worker starts.
+func waitForRampUp(run *limiter.Run, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-run.Done():
+		return false
+	}
+}
+
+// runWorker makes calls until the run ends, picking a method from the mix for
+// each call. states holds the state for each method in the mix.
+func runWorker(t transport.Transport, mix benchmarkMix, trace *benchmarkTrace, states []*benchmarkState, p *benchmarkProgress, l *latencyRecorder, run *limiter.Run, logger *zap.Logger) {
 	for cur := run; cur.More(); {
-		latency, err := m.call(t)
+		i := mix.pick()
+		s := states[i]
+
+		start := time.Now()
+		latency, res, err := mix.methods[i].call(t, trace, logger)
+		p.record(err)
+		l.record(start, latency, err)
 		if err != nil {
 			s.recordError(err)
 			// TODO: Add information about which peer specifically failed.
@@ -88,10 +186,20 @@ func runWorker(t transport.Transport, m benchmarkMethod, s *benchmarkState, run
 		}
 
 		s.recordLatency(latency)
+		s.recordResponseSize(len(res.Body))
+		if compressed, ok := res.TransportFields[transport.CompressedSizeField].(int); ok {
+			s.recordCompressedSize(compressed, len(res.Body))
+		}
 	}
 }
 
 func runBenchmark(out output, logger *zap.Logger, allOpts Options, m benchmarkMethod) {
+	runMixBenchmark(out, logger, allOpts, singleMethodMix(m))
+}
+
+// runMixBenchmark runs a benchmark where each call uses a method picked from
+// the mix. The summary includes a breakdown per method if there are multiple.
+func runMixBenchmark(out output, logger *zap.Logger, allOpts Options, mix benchmarkMix) {
 	opts := allOpts.BOpts
 
 	if err := opts.validate(); err != nil {
@@ -101,6 +209,17 @@ func runBenchmark(out output, logger *zap.Logger, allOpts Options, m benchmarkMe
 		return
 	}
 
+	// Read the baseline before the benchmark so a missing or invalid baseline
+	// fails without waiting for the benchmark to complete.
+	var baseline *benchmarkSummary
+	if opts.Baseline != "" {
+		b, err := readBenchmarkSummary(opts.Baseline)
+		if err != nil {
+			out.Fatalf("Failed to load benchmark baseline: %v\n", err)
+		}
+		baseline = &b
+	}
+
 	if opts.RPS > 0 && opts.MaxDuration > 0 {
 		// The RPS * duration in seconds may cap opts.MaxRequests.
 		rpsMax := int(float64(opts.RPS) * opts.MaxDuration.Seconds())
@@ -109,47 +228,95 @@ func runBenchmark(out output, logger *zap.Logger, allOpts Options, m benchmarkMe
 		}
 	}
 
+	tOpts, err := loadTransportPeers(allOpts.TOpts)
+	if err != nil {
+		out.Fatalf("Failed to load peers for benchmark: %v", err)
+	}
+
 	goMaxProcs := opts.setGoMaxProcs()
 	numConns := opts.getNumConnections(goMaxProcs)
-	out.Printf("Benchmark parameters:\n")
-	out.Printf("  CPUs:            %v\n", goMaxProcs)
-	out.Printf("  Connections:     %v\n", numConns)
-	out.Printf("  Concurrency:     %v\n", opts.Concurrency)
-	out.Printf("  Max requests:    %v\n", opts.MaxRequests)
-	out.Printf("  Max duration:    %v\n", opts.MaxDuration)
-	out.Printf("  Max RPS:         %v\n", opts.RPS)
+	concurrency := opts.getConcurrency()
+	if !allOpts.ROpts.Quiet {
+		out.Printf("Benchmark parameters:\n")
+		out.Printf("  CPUs:            %v\n", goMaxProcs)
+		out.Printf("  Peers:           %v\n", numPeersUsed(len(tOpts.Peers), numConns))
+		out.Printf("  Connections:     %v\n", numConns)
+		out.Printf("  Concurrency:     %v\n", concurrency)
+		out.Printf("  Max requests:    %v\n", opts.MaxRequests)
+		out.Printf("  Max duration:    %v\n", opts.MaxDuration)
+		out.Printf("  Max RPS:         %v\n", opts.RPS)
+		out.Printf("  Ramp up:         %v\n", opts.RampUp)
+	}
 
 	// Warm up number of connections.
 	logger.Debug("Warming up connections.", zap.Int("numConns", numConns))
-	connections, err := m.WarmTransports(numConns, allOpts.TOpts, opts.WarmupRequests)
+	// The first method is used to warm up the connections.
+	connections, peers, err := mix.methods[0].WarmTransports(numConns, tOpts, opts, logger)
 	if err != nil {
 		out.Fatalf("Failed to warmup connections for benchmark: %v", err)
 	}
+	reuseConns := opts.ReuseConns.valueOr(true)
+	if !reuseConns {
+		connections = mix.methods[0].PerCallTransports(connections, peers, tOpts, logger)
+	}
 
 	statter, err := statsd.NewClient(logger, opts.StatsdHostPort, allOpts.TOpts.ServiceName, allOpts.ROpts.Procedure)
 	if err != nil {
 		out.Fatalf("Failed to create statsd client for benchmark: %v", err)
 	}
 
+	var latencies *latencyRecorder
+	if opts.LatenciesOut != "" {
+		latencies, err = newLatencyRecorder(opts.LatenciesOut)
+		if err != nil {
+			out.Fatalf("Failed to record latencies for benchmark: %v", err)
+		}
+	}
+
 	var wg sync.WaitGroup
-	states := make([]*benchmarkState, len(connections)*opts.Concurrency)
+	states := make([][]*benchmarkState, len(connections)*concurrency)
 	for i := range states {
-		states[i] = newBenchmarkState(statter)
+		states[i] = make([]*benchmarkState, len(mix.methods))
+		for j := range states[i] {
+			states[i][j] = newBenchmarkState(statter)
+		}
 	}
 
 	run := limiter.New(opts.MaxRequests, opts.RPS, opts.MaxDuration)
-	stopOnInterrupt(out, run)
+	stopInterrupts := stopOnInterrupt(out, run)
 
 	logger.Info("Benchmark starting.", zap.Any("options", opts))
+	progress := &benchmarkProgress{}
+	if opts.RampUp > 0 {
+		progress.targetWorkers = len(states)
+	}
+	stopProgress := make(chan struct{})
+	var progressWG sync.WaitGroup
+	if opts.Interval > 0 {
+		progressWG.Add(1)
+		go func() {
+			defer progressWG.Done()
+			progress.report(out, opts.Interval, stopProgress)
+		}()
+	}
+
+	trace := newBenchmarkTrace(tOpts.tracer, opts.TraceSampling, allOpts.ROpts.Procedure)
 	start := time.Now()
 	for i, c := range connections {
-		for j := 0; j < opts.Concurrency; j++ {
-			state := states[i*opts.Concurrency+j]
+		for j := 0; j < concurrency; j++ {
+			workerStates := states[i*concurrency+j]
+			// Workers are started across all connections before any connection
+			// gets another concurrent call.
+			delay := rampUpDelay(opts.RampUp, j*len(connections)+i, len(states))
 
 			wg.Add(1)
 			go func(c transport.Transport) {
 				defer wg.Done()
-				runWorker(c, m, state, run, logger)
+				if !waitForRampUp(run, delay) {
+					return
+				}
+				progress.workerStarted()
+				runWorker(c, mix, trace, workerStates, progress, latencies, run, logger)
 			}(c)
 		}
 	}
@@ -159,10 +326,34 @@ func runBenchmark(out output, logger *zap.Logger, allOpts Options, m benchmarkMe
 	// Wait for all the worker goroutines to end.
 	wg.Wait()
 	total := time.Since(start)
-	// Merge all the states into 0
-	overall := states[0]
-	for _, s := range states[1:] {
-		overall.merge(s)
+	stopInterrupts()
+	close(stopProgress)
+	progressWG.Wait()
+	if latencies != nil {
+		// Workers have stopped, including after an interrupt, so all rows can be flushed.
+		if err := latencies.close(); err != nil {
+			out.Warnf("%v\n", err)
+		}
+	}
+	// The breakdown by peer must be merged first, since merging the methods
+	// below modifies the states of the first worker.
+	peerStates := mergeStatesByPeer(statter, peers, states, concurrency)
+
+	// Merge the states of all workers for each method, and then merge the
+	// methods for the overall results.
+	methodStates := make([]*benchmarkState, len(mix.methods))
+	for j := range methodStates {
+		methodStates[j] = states[0][j]
+		for _, s := range states[1:] {
+			methodStates[j].merge(s[j])
+		}
+	}
+	overall := methodStates[0]
+	if len(methodStates) > 1 {
+		overall = newBenchmarkState(statter)
+		for _, s := range methodStates {
+			overall.merge(s)
+		}
 	}
 
 	logger.Info("Benchmark complete.",
@@ -170,24 +361,121 @@ func runBenchmark(out output, logger *zap.Logger, allOpts Options, m benchmarkMe
 		zap.Int("totalRequests", overall.totalRequests),
 		zap.Time("startTime", start),
 	)
+	// The span is finished before the results are checked, so it's reported
+	// even if the benchmark fails.
+	trace.finish(overall.totalRequests, overall.totalErrors)
 
 	overall.printErrors(out)
 	overall.printLatencies(out)
+	overall.printSummary(out, total)
+	overall.printResponseSizes(out, allOpts.ROpts.BytesRaw)
+	out.Printf("Connections:       %v\n", len(connections))
+	out.Printf("Concurrency:       %v\n", concurrency)
+	out.Printf("Reuse connections: %v\n", reuseConns)
+
+	if len(mix.methods) > 1 {
+		out.Printf("Methods:\n")
+		for j, s := range methodStates {
+			s.printMethodSummary(out, fmt.Sprintf("%v (%.0f%%)", mix.names[j], mix.weight(j)))
+		}
+	}
+
+	// A single bad host is easier to spot with a breakdown of each peer.
+	if len(peerStates) > 1 {
+		out.Printf("Peers:\n")
+		for _, peer := range sorted.MapKeys(peerStates) {
+			peerStates[peer].printMethodSummary(out, peer)
+		}
+	}
+
+	if opts.MetricsOut != "" {
+		metrics := []methodMetrics{{allOpts.ROpts.Procedure, overall}}
+		if len(mix.methods) > 1 {
+			metrics = make([]methodMetrics, len(methodStates))
+			for j, s := range methodStates {
+				metrics[j] = methodMetrics{mix.names[j], s}
+			}
+		}
+		if err := writeBenchmarkMetrics(opts.MetricsOut, allOpts.TOpts.ServiceName, metrics, total); err != nil {
+			out.Fatalf("Failed to write benchmark metrics: %v\n", err)
+		}
+	}
+
+	// The same summary is written by --summary-json and --baseline-out, and
+	// compared against the --baseline.
+	config := benchmarkConfig{
+		Service:          allOpts.TOpts.ServiceName,
+		Procedure:        allOpts.ROpts.Procedure,
+		Connections:      len(connections),
+		Concurrency:      concurrency,
+		ReuseConnections: reuseConns,
+		MaxRequests:      opts.MaxRequests,
+		MaxDurationMs:    durationToMs(opts.MaxDuration),
+		MaxRPS:           opts.RPS,
+	}
+	summary := newBenchmarkSummary(config, overall, total)
+	if len(mix.methods) > 1 {
+		for j, s := range methodStates {
+			summary.Methods = append(summary.Methods, newMethodSummary(mix.names[j], s))
+		}
+	}
+	if len(peerStates) > 1 {
+		for _, peer := range sorted.MapKeys(peerStates) {
+			summary.Peers = append(summary.Peers, newMethodSummary(peer, peerStates[peer]))
+		}
+	}
+	if opts.SummaryJSON != "" {
+		if err := writeBenchmarkSummary(opts.SummaryJSON, summary); err != nil {
+			out.Fatalf("Failed to write benchmark summary: %v\n", err)
+		}
+	}
+	if opts.BaselineOut != "" {
+		if err := writeBenchmarkSummary(opts.BaselineOut, summary); err != nil {
+			out.Fatalf("Failed to write benchmark baseline: %v\n", err)
+		}
+	}
+
+	if violations := overall.checkThresholds(opts.MaxErrorRate, opts.MaxP99); len(violations) > 0 {
+		out.Fatalf("Benchmark failed thresholds:\n  %v\n", strings.Join(violations, "\n  "))
+	}
 
-	out.Printf("Elapsed time:      %v\n", (total / time.Millisecond * time.Millisecond))
-	out.Printf("Total requests:    %v\n", overall.totalRequests)
-	out.Printf("RPS:               %.2f\n", float64(overall.totalRequests)/total.Seconds())
+	if baseline != nil {
+		if regressions := compareBaseline(out, *baseline, summary, opts.BaselineTol); len(regressions) > 0 {
+			out.Fatalf("Benchmark regressed compared to the baseline:\n  %v\n", strings.Join(regressions, "\n  "))
+		}
+	}
 }
 
-// stopOnInterrupt sets up a signal that will trigger the run to stop.
-func stopOnInterrupt(out output, r *limiter.Run) {
+// stopOnInterrupt sets up a signal that will trigger the run to stop, so the
+// summary is printed for the requests made so far. A second interrupt exits
+// immediately. The returned function stops handling interrupts.
+func stopOnInterrupt(out output, r *limiter.Run) func() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 
-	go func() {
-		<-c
-		// Preceding newline since Ctrl-C will be printed inline.
-		out.Printf("\n!!Benchmark interrupted!!\n")
-		r.Stop()
-	}()
+	done := make(chan struct{})
+	go handleInterrupts(out, r, c, done)
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
+
+func handleInterrupts(out output, r *limiter.Run, c <-chan os.Signal, done <-chan struct{}) {
+	select {
+	case <-c:
+	case <-done:
+		return
+	}
+
+	// Preceding newline since Ctrl-C will be printed inline.
+	out.Printf("\n!!Benchmark interrupted!!\n")
+	out.Warnf("Waiting for in-flight requests to complete, interrupt again to exit immediately.\n")
+	r.Stop()
+
+	select {
+	case <-c:
+		out.Fatalf("\n!!Benchmark aborted!!\n")
+	case <-done:
+	}
 }
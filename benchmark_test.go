@@ -21,14 +21,22 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/yarpc/yab/limiter"
 	"github.com/yarpc/yab/transport"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
 	"github.com/uber/tchannel-go/testutils"
 	"go.uber.org/atomic"
 )
@@ -91,6 +99,9 @@ func TestBenchmark(t *testing.T) {
 		bufStr := buf.String()
 		assert.Contains(t, bufStr, "Max RPS")
 		assert.NotContains(t, bufStr, "Errors")
+		assert.Contains(t, bufStr, "Connections:       50\n", "%v: summary missing connections", tt.msg)
+		assert.Contains(t, bufStr, "Concurrency:       2\n", "%v: summary missing concurrency", tt.msg)
+		assert.Contains(t, bufStr, "Reuse connections: true\n", "%v: summary missing connection reuse", tt.msg)
 
 		if tt.want != 0 {
 			assert.EqualValues(t, tt.want, requests.Load(),
@@ -107,6 +118,323 @@ func TestBenchmark(t *testing.T) {
 	}
 }
 
+func TestBenchmarkQuiet(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	buf, _, out := getOutput(t)
+	runBenchmark(out, _testLogger, Options{
+		ROpts: RequestOptions{Quiet: true},
+		BOpts: BenchmarkOptions{
+			MaxRequests: 10,
+			Connections: 1,
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	bufStr := buf.String()
+	assert.NotContains(t, bufStr, "Benchmark parameters", "quiet should not print parameters")
+	assert.Contains(t, bufStr, "Total requests:    10\n", "quiet should print the summary")
+}
+
+func TestBenchmarkPeerBreakdown(t *testing.T) {
+	tests := []struct {
+		msg           string
+		numServers    int
+		wantBreakdown bool
+	}{
+		{
+			msg:        "single peer",
+			numServers: 1,
+		},
+		{
+			msg:           "multiple peers",
+			numServers:    2,
+			wantBreakdown: true,
+		},
+	}
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	for _, tt := range tests {
+		var peers []string
+		for i := 0; i < tt.numServers; i++ {
+			s := newServer(t)
+			defer s.shutdown()
+			s.register(fooMethod, methods.echo())
+			peers = append(peers, s.hostPort())
+		}
+
+		buf, _, out := getOutput(t)
+		runBenchmark(out, _testLogger, Options{
+			BOpts: BenchmarkOptions{
+				MaxRequests:    20,
+				Connections:    4,
+				WarmupRequests: 1,
+			},
+			TOpts: TransportOptions{
+				CallerName:  "bar",
+				ServiceName: "foo",
+				Peers:       peers,
+			},
+		}, m)
+
+		bufStr := buf.String()
+		if !tt.wantBreakdown {
+			assert.NotContains(t, bufStr, "Peers:\n", "%v: unexpected peer breakdown", tt.msg)
+			continue
+		}
+
+		assert.Contains(t, bufStr, "Peers:\n", "%v: missing peer breakdown", tt.msg)
+		for _, peer := range peers {
+			assert.Contains(t, bufStr, "  "+peer+": ", "%v: missing peer %v", tt.msg, peer)
+		}
+	}
+}
+
+func TestBenchmarkNoReuseConnections(t *testing.T) {
+	var requests atomic.Int32
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.errorIf(func() bool {
+		requests.Inc()
+		return false
+	}))
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+
+	buf, _, out := getOutput(t)
+	runBenchmark(out, _testLogger, Options{
+		BOpts: BenchmarkOptions{
+			MaxRequests:    20,
+			Connections:    2,
+			WarmupRequests: 1,
+			ReuseConns:     optionalBool{set: true, value: false},
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	bufStr := buf.String()
+	assert.NotContains(t, bufStr, "Errors")
+	assert.Contains(t, bufStr, "Total requests:    20\n")
+	assert.Contains(t, bufStr, "Reuse connections: false\n", "summary missing connection reuse")
+	assert.EqualValues(t, 22, requests.Load(), "unexpected number of requests including warmup")
+}
+
+func TestBenchmarkTracing(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), reporter)
+	defer closer.Close()
+
+	tOpts := s.transportOpts()
+	tOpts.tracer = tracer
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	_, _, out := getOutput(t)
+	runBenchmark(out, _testLogger, Options{
+		BOpts: BenchmarkOptions{
+			MaxRequests:    10,
+			Connections:    1,
+			WarmupRequests: 5,
+			TraceSampling:  1,
+		},
+		TOpts: tOpts,
+	}, m)
+
+	var benchmarkSpan *jaeger.Span
+	for _, span := range reporter.GetSpans() {
+		if span := span.(*jaeger.Span); span.OperationName() == _benchmarkSpanName {
+			benchmarkSpan = span
+		}
+	}
+	require.NotNil(t, benchmarkSpan, "Benchmark span was not reported")
+
+	// Warmup requests are not sampled, so only the benchmark requests are
+	// children of the benchmark span.
+	benchmarkID := benchmarkSpan.Context().(jaeger.SpanContext).SpanID()
+	var children int
+	for _, span := range reporter.GetSpans() {
+		if span.Context().(jaeger.SpanContext).ParentID() == benchmarkID {
+			children++
+		}
+	}
+	assert.Equal(t, 10, children, "Expected a child span for each benchmark request")
+}
+
+func TestBenchmarkWarmupDuration(t *testing.T) {
+	var requests atomic.Int32
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.errorIf(func() bool {
+		requests.Inc()
+		return false
+	}))
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+
+	buf, _, out := getOutput(t)
+	start := time.Now()
+	runBenchmark(out, _testLogger, Options{
+		BOpts: BenchmarkOptions{
+			MaxRequests:    10,
+			Connections:    1,
+			WarmupRequests: 10,
+			WarmupDuration: 50 * time.Millisecond,
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	assert.True(t, time.Since(start) >= 50*time.Millisecond, "Benchmark should wait for the warmup duration")
+	assert.Contains(t, buf.String(), "Total requests:    10\n", "Warmup requests should be excluded from the results")
+	assert.True(t, requests.Load() > 10, "Expected warmup requests in addition to the benchmark requests")
+}
+
+func TestBenchmarkRampUp(t *testing.T) {
+	var requests atomic.Int32
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.errorIf(func() bool {
+		requests.Inc()
+		return false
+	}))
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	buf, _, out := getOutput(t)
+	runBenchmark(out, _testLogger, Options{
+		BOpts: BenchmarkOptions{
+			MaxDuration: 300 * time.Millisecond,
+			RPS:         100,
+			Connections: 2,
+			Concurrency: 2,
+			RampUp:      200 * time.Millisecond,
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	bufStr := buf.String()
+	assert.Contains(t, bufStr, "Ramp up:         200ms\n", "parameters missing ramp up")
+	assert.NotContains(t, bufStr, "Errors")
+	assert.True(t, requests.Load() > 0, "benchmark should make requests")
+}
+
+func TestBenchmarkRampUpMaxRequests(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	buf, _, out := getOutput(t)
+	start := time.Now()
+	runBenchmark(out, _testLogger, Options{
+		BOpts: BenchmarkOptions{
+			MaxRequests: 5,
+			Connections: 2,
+			Concurrency: 2,
+			RampUp:      time.Minute,
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	// Workers that haven't started stop waiting once the requests are used up.
+	assert.True(t, time.Since(start) < testutils.Timeout(10*time.Second), "Benchmark should not wait for the ramp up")
+	assert.Contains(t, buf.String(), "Total requests:    5\n", "Unexpected number of requests")
+}
+
+func TestRampUpDelay(t *testing.T) {
+	tests := []struct {
+		rampUp time.Duration
+		i, n   int
+		want   time.Duration
+	}{
+		{rampUp: 0, i: 3, n: 4, want: 0},
+		{rampUp: time.Second, i: 0, n: 1, want: 0},
+		{rampUp: time.Second, i: 0, n: 4, want: 0},
+		{rampUp: time.Second, i: 1, n: 4, want: 250 * time.Millisecond},
+		{rampUp: time.Second, i: 3, n: 4, want: 750 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, rampUpDelay(tt.rampUp, tt.i, tt.n),
+			"rampUpDelay(%v, %v, %v)", tt.rampUp, tt.i, tt.n)
+	}
+}
+
+func TestWaitForRampUp(t *testing.T) {
+	run := limiter.New(0 /* maxRequests */, 0 /* rps */, 0 /* maxDuration */)
+	assert.True(t, waitForRampUp(run, 0), "no delay should start immediately")
+	assert.True(t, waitForRampUp(run, time.Millisecond), "worker should start after the delay")
+
+	run.Stop()
+	assert.False(t, waitForRampUp(run, time.Hour), "worker should not start after the run is stopped")
+}
+
+func TestBenchmarkOptionsGetConcurrency(t *testing.T) {
+	tests := []struct {
+		concurrency int
+		want        int
+	}{
+		{concurrency: 0, want: 1},
+		{concurrency: 1, want: 1},
+		{concurrency: 8, want: 8},
+	}
+
+	for _, tt := range tests {
+		opts := BenchmarkOptions{Concurrency: tt.concurrency}
+		assert.Equal(t, tt.want, opts.getConcurrency(), "getConcurrency for %v", tt.concurrency)
+	}
+}
+
+func TestBenchmarkOptionsWarmupDone(t *testing.T) {
+	tests := []struct {
+		msg      string
+		opts     BenchmarkOptions
+		requests int
+		elapsed  time.Duration
+		want     bool
+	}{
+		{msg: "no warmup", want: true},
+		{msg: "count not reached", opts: BenchmarkOptions{WarmupRequests: 10}, requests: 5, elapsed: time.Hour},
+		{msg: "count reached", opts: BenchmarkOptions{WarmupRequests: 10}, requests: 10, want: true},
+		{msg: "duration not elapsed", opts: BenchmarkOptions{WarmupRequests: 10, WarmupDuration: 5 * time.Second}, requests: 100, elapsed: time.Second},
+		{msg: "duration elapsed", opts: BenchmarkOptions{WarmupRequests: 10, WarmupDuration: 5 * time.Second}, requests: 1, elapsed: 5 * time.Second, want: true},
+		{
+			msg:      "explicit count reached before duration",
+			opts:     BenchmarkOptions{WarmupRequests: 10, WarmupDuration: 5 * time.Second, explicitWarmup: true},
+			requests: 10,
+			elapsed:  time.Second,
+			want:     true,
+		},
+		{
+			msg:      "duration elapsed before explicit count",
+			opts:     BenchmarkOptions{WarmupRequests: 10, WarmupDuration: 5 * time.Second, explicitWarmup: true},
+			requests: 5,
+			elapsed:  5 * time.Second,
+			want:     true,
+		},
+		{
+			msg:      "neither complete",
+			opts:     BenchmarkOptions{WarmupRequests: 10, WarmupDuration: 5 * time.Second, explicitWarmup: true},
+			requests: 5,
+			elapsed:  time.Second,
+		},
+		{
+			msg:  "explicit 0 warmup with a duration",
+			opts: BenchmarkOptions{WarmupDuration: 5 * time.Second, explicitWarmup: true},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got := tt.opts.warmupDone(tt.requests, tt.elapsed)
+		assert.Equal(t, tt.want, got, "%v: unexpected result", tt.msg)
+	}
+}
+
 func TestRunBenchmarkErrors(t *testing.T) {
 	tests := []struct {
 		opts    BenchmarkOptions
@@ -124,6 +452,73 @@ func TestRunBenchmarkErrors(t *testing.T) {
 			},
 			wantErr: "duration cannot be negative",
 		},
+		{
+			opts: BenchmarkOptions{
+				RPS: -1,
+			},
+			wantErr: "RPS cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				Connections: -1,
+			},
+			wantErr: "connections cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				Concurrency: -1,
+			},
+			wantErr: "concurrency cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				Interval: -time.Second,
+			},
+			wantErr: "interval cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxErrorRate: errorRate(1.5),
+			},
+			wantErr: "max error rate must be between 0 and 1",
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxP99: -time.Second,
+			},
+			wantErr: "max p99 cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				RampUp: -time.Second,
+			},
+			wantErr: "ramp up cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				BaselineTol: -0.1,
+			},
+			wantErr: "baseline tolerance cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				WarmupDuration: -time.Second,
+			},
+			wantErr: "warmup duration cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				TraceSampling: 1.5,
+			},
+			wantErr: "trace sample rate must be between 0 and 1",
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests: 1,
+				Baseline:    "/fake/file",
+			},
+			wantErr: "Failed to load benchmark baseline: failed to read summary file",
+		},
 	}
 
 	for _, tt := range tests {
@@ -149,3 +544,248 @@ func TestRunBenchmarkErrors(t *testing.T) {
 		assert.Contains(t, fatalMessage, tt.wantErr, "Missing error for %+v", tt.opts)
 	}
 }
+
+func errorRate(r float64) *float64 {
+	return &r
+}
+
+func TestBenchmarkThresholds(t *testing.T) {
+	tests := []struct {
+		msg     string
+		opts    BenchmarkOptions
+		wantErr []string
+	}{
+		{
+			msg:  "no thresholds",
+			opts: BenchmarkOptions{},
+		},
+		{
+			msg:  "error rate within threshold",
+			opts: BenchmarkOptions{MaxErrorRate: errorRate(0.5)},
+		},
+		{
+			msg:     "error rate exceeds threshold",
+			opts:    BenchmarkOptions{MaxErrorRate: errorRate(0.1)},
+			wantErr: []string{"error rate 0.5000 exceeds --max-error-rate 0.1"},
+		},
+		{
+			msg:  "p99 within threshold",
+			opts: BenchmarkOptions{MaxP99: time.Minute},
+		},
+		{
+			msg:  "both thresholds exceeded",
+			opts: BenchmarkOptions{MaxErrorRate: errorRate(0), MaxP99: time.Nanosecond},
+			wantErr: []string{
+				"Benchmark failed thresholds:",
+				"error rate 0.5000 exceeds --max-error-rate 0",
+				"exceeds --max-p99 1ns",
+			},
+		},
+	}
+
+	var requests atomic.Int32
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.errorIf(func() bool {
+		return requests.Inc()%2 == 0
+	}))
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	for _, tt := range tests {
+		var fatalMessage string
+		out := &testOutput{
+			Buffer: &bytes.Buffer{},
+			fatalf: func(msg string, args ...interface{}) {
+				fatalMessage = fmt.Sprintf(msg, args...)
+			},
+		}
+
+		opts := tt.opts
+		opts.MaxRequests = 10
+		opts.Connections = 1
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runBenchmark(out, _testLogger, Options{BOpts: opts, TOpts: s.transportOpts()}, m)
+		}()
+		wg.Wait()
+
+		if len(tt.wantErr) == 0 {
+			assert.Empty(t, fatalMessage, "%v: benchmark should pass", tt.msg)
+			continue
+		}
+		for _, want := range tt.wantErr {
+			assert.Contains(t, fatalMessage, want, "%v: missing violation", tt.msg)
+		}
+	}
+}
+
+func TestBenchmarkBaseline(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+
+	dir, err := ioutil.TempDir("", "baseline")
+	require.NoError(t, err, "Failed to create temporary directory")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
+
+	run := func(opts BenchmarkOptions) (string, string) {
+		var fatalMessage string
+		out := &testOutput{
+			Buffer: &bytes.Buffer{},
+			fatalf: func(msg string, args ...interface{}) {
+				fatalMessage = fmt.Sprintf(msg, args...)
+			},
+		}
+		opts.MaxRequests = 10
+		opts.Connections = 1
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runBenchmark(out, _testLogger, Options{BOpts: opts, TOpts: s.transportOpts()}, m)
+		}()
+		wg.Wait()
+		return out.String(), fatalMessage
+	}
+
+	_, fatalMessage := run(BenchmarkOptions{BaselineOut: path})
+	require.Empty(t, fatalMessage, "Benchmark with --baseline-out should pass")
+
+	baseline, err := readBenchmarkSummary(path)
+	require.NoError(t, err, "Failed to read baseline")
+	assert.Equal(t, 10, baseline.TotalRequests, "Unexpected requests in baseline")
+	assert.True(t, baseline.RPS > 0, "Baseline should have a positive RPS")
+
+	got, fatalMessage := run(BenchmarkOptions{Baseline: path, BaselineTol: 1000})
+	assert.Empty(t, fatalMessage, "Benchmark within the tolerance should pass")
+	assert.Contains(t, got, "Baseline comparison:", "Missing baseline comparison")
+
+	baseline.RPS = 1e12
+	require.NoError(t, writeBenchmarkSummary(path, baseline), "Failed to write baseline")
+	_, fatalMessage = run(BenchmarkOptions{Baseline: path, BaselineTol: 0.1})
+	assert.Contains(t, fatalMessage, "Benchmark regressed compared to the baseline:", "Missing regression")
+	assert.Contains(t, fatalMessage, "compared to the baseline 1000000000000.00", "Missing RPS regression")
+}
+
+func TestBenchmarkSummaryJSON(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+
+	dir, err := ioutil.TempDir("", "summary")
+	require.NoError(t, err, "Failed to create temporary directory")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "summary.json")
+
+	_, _, out := getOutput(t)
+	runBenchmark(out, _testLogger, Options{
+		ROpts: RequestOptions{Procedure: fooMethod},
+		BOpts: BenchmarkOptions{
+			MaxRequests: 10,
+			Connections: 2,
+			Concurrency: 3,
+			SummaryJSON: path,
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read summary file")
+
+	var summary benchmarkSummary
+	require.NoError(t, json.Unmarshal(contents, &summary), "Failed to decode summary")
+	assert.Equal(t, _summaryVersion, summary.Version, "Unexpected version")
+	assert.Equal(t, 10, summary.TotalRequests, "Unexpected total requests")
+	assert.Equal(t, benchmarkConfig{
+		Service:          "foo",
+		Procedure:        fooMethod,
+		Connections:      2,
+		Concurrency:      3,
+		ReuseConnections: true,
+		MaxRequests:      10,
+	}, summary.Config, "Unexpected config")
+	assert.True(t, summary.RPS > 0, "RPS should be positive")
+	assert.Empty(t, summary.Peers, "Peers should be omitted for a single peer")
+}
+
+func TestHandleInterrupts(t *testing.T) {
+	tests := []struct {
+		msg        string
+		interrupts int
+		wantFatal  string
+	}{
+		{
+			msg:        "single interrupt stops the run",
+			interrupts: 1,
+		},
+		{
+			msg:        "second interrupt aborts",
+			interrupts: 2,
+			wantFatal:  "!!Benchmark aborted!!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			var outBuf, warnBuf, fatalBuf bytes.Buffer
+			out := testOutput{
+				Buffer: &outBuf,
+				warnf: func(format string, args ...interface{}) {
+					fmt.Fprintf(&warnBuf, format, args...)
+				},
+				fatalf: func(format string, args ...interface{}) {
+					fmt.Fprintf(&fatalBuf, format, args...)
+				},
+			}
+
+			run := limiter.New(0, 0, 0)
+			c := make(chan os.Signal)
+			done := make(chan struct{})
+			finished := make(chan struct{})
+			go func() {
+				defer close(finished)
+				handleInterrupts(out, run, c, done)
+			}()
+
+			for i := 0; i < tt.interrupts; i++ {
+				c <- os.Interrupt
+			}
+			if tt.wantFatal == "" {
+				close(done)
+			}
+
+			select {
+			case <-finished:
+			case <-time.After(testutils.Timeout(time.Second)):
+				t.Fatalf("timed out waiting for interrupt handling")
+			}
+
+			assert.False(t, run.More(), "Run should be stopped after an interrupt")
+			assert.Contains(t, outBuf.String(), "!!Benchmark interrupted!!", "Missing interrupt message")
+			assert.Contains(t, warnBuf.String(), "interrupt again to exit immediately", "Missing hint to force exit")
+			if tt.wantFatal != "" {
+				assert.Contains(t, fatalBuf.String(), tt.wantFatal, "Unexpected fatal message")
+			} else {
+				assert.Empty(t, fatalBuf.String(), "Single interrupt should not be fatal")
+			}
+		})
+	}
+}
+
+func TestHandleInterruptsDone(t *testing.T) {
+	_, _, out := getOutput(t)
+	run := limiter.New(0, 0, 0)
+	done := make(chan struct{})
+	close(done)
+
+	// Without an interrupt, the handler returns once the benchmark is done.
+	handleInterrupts(out, run, make(chan os.Signal), done)
+	assert.True(t, run.More(), "Run should not be stopped without an interrupt")
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/yarpc/yab/encoding"
+)
+
+// bodyFragmentRegexp matches a key:value body fragment. The value must follow
+// the colon directly, so YAML bodies such as "key: value" are not fragments.
+var bodyFragmentRegexp = regexp.MustCompile(`(?s)^([A-Za-z_][A-Za-z0-9_]*):(\S.*)$`)
+
+// isBodyFragment returns whether the positional argument is a key:value
+// fragment of the request body.
+func isBodyFragment(arg string) bool {
+	return bodyFragmentRegexp.MatchString(arg)
+}
+
+// usesSchema returns whether the encoding has a schema that object bodies,
+// such as those from key:value fragments, are converted to.
+func usesSchema(e encoding.Encoding) bool {
+	return e == encoding.Thrift || e == encoding.Protobuf
+}
+
+// bodyFromFragments returns a JSON object with a field for each key:value
+// fragment. Values that are valid JSON are used as is, e.g., count:5 or
+// user:{"name":"me"}, and any other value is used as a string, e.g., name:me.
+func bodyFromFragments(fragments []string) (string, error) {
+	var buf bytes.Buffer
+	seen := make(map[string]struct{}, len(fragments))
+
+	buf.WriteString("{")
+	for i, fragment := range fragments {
+		match := bodyFragmentRegexp.FindStringSubmatch(fragment)
+		if match == nil {
+			return "", fmt.Errorf("argument %q is not a key:value body fragment, and body fragments cannot be mixed with other positional arguments", fragment)
+		}
+
+		key, value := match[1], match[2]
+		if _, ok := seen[key]; ok {
+			return "", fmt.Errorf("body fragment key %q specified multiple times", key)
+		}
+		seen[key] = struct{}{}
+
+		// json.Unmarshal validates the value before copying it.
+		var valueJSON json.RawMessage
+		if err := json.Unmarshal([]byte(value), &valueJSON); err != nil {
+			// The key and value are plain strings, so marshalling cannot fail.
+			valueJSON, _ = json.Marshal(value)
+		}
+
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		keyJSON, _ := json.Marshal(key)
+		buf.Write(keyJSON)
+		buf.WriteString(":")
+		buf.Write(valueJSON)
+	}
+	buf.WriteString("}")
+
+	return buf.String(), nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBodyFragment(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want bool
+	}{
+		{"name:me", true},
+		{`user:{"name": "me"}`, true},
+		{"list:[1, 2]", true},
+		{"key: value", false},
+		{"key:", false},
+		{`{"name": "me"}`, false},
+		{`"quoted:value"`, false},
+		{"1key:value", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isBodyFragment(tt.arg), "isBodyFragment(%q)", tt.arg)
+	}
+}
+
+func TestBodyFromFragments(t *testing.T) {
+	tests := []struct {
+		msg       string
+		fragments []string
+		want      string
+		wantErr   string
+	}{
+		{
+			msg:       "string values",
+			fragments: []string{"name:me", "greeting:hello world"},
+			want:      `{"name":"me","greeting":"hello world"}`,
+		},
+		{
+			msg:       "JSON values",
+			fragments: []string{"count:5", "enabled:true", `id:"123"`, `user:{"name": "me"}`, "list:[1, 2]"},
+			want:      `{"count":5,"enabled":true,"id":"123","user":{"name": "me"},"list":[1, 2]}`,
+		},
+		{
+			msg:       "duplicate key",
+			fragments: []string{"name:me", "name:you"},
+			wantErr:   `body fragment key "name" specified multiple times`,
+		},
+		{
+			msg:       "mixed with a JSON body",
+			fragments: []string{"name:me", `{"count": 5}`},
+			wantErr:   `argument "{\"count\": 5}" is not a key:value body fragment`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := bodyFromFragments(tt.fragments)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, tt.msg)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, tt.msg) {
+			assert.JSONEq(t, tt.want, got, tt.msg)
+		}
+	}
+}
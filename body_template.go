@@ -0,0 +1,150 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"text/template"
+
+	"go.uber.org/atomic"
+)
+
+const _randStringChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+const (
+	// _maxRepeatCount limits the count for repeat and seq, so a mistyped
+	// count fails rather than exhausting memory.
+	_maxRepeatCount = 1000000
+
+	// _maxRepeatSize limits the size of the output of repeat in bytes.
+	_maxRepeatSize = 64 << 20
+)
+
+// bodyTemplate is a request body that is parsed once as a Go text/template,
+// and rendered for each request.
+type bodyTemplate struct {
+	tmpl *template.Template
+	next *atomic.Int64
+}
+
+// bodyTemplateData is the data available to a body template.
+type bodyTemplateData struct {
+	// Index is the index of the request, starting at 0.
+	Index int64
+}
+
+var bodyTemplateFuncs = template.FuncMap{
+	"randInt":    randInt,
+	"randString": randString,
+	"uuid":       randUUID,
+	"repeat":     repeat,
+	"seq":        seq,
+}
+
+func newBodyTemplate(body []byte) (*bodyTemplate, error) {
+	tmpl, err := template.New("body").Funcs(bodyTemplateFuncs).Parse(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse body template: %v", err)
+	}
+	return &bodyTemplate{tmpl: tmpl, next: atomic.NewInt64(0)}, nil
+}
+
+// render renders the body for the next request.
+func (t *bodyTemplate) render() ([]byte, error) {
+	data := bodyTemplateData{Index: t.next.Inc() - 1}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render body template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// randInt returns a random integer in [min, max).
+func randInt(min, max int) (int, error) {
+	if max <= min {
+		return 0, fmt.Errorf("randInt max %v must be greater than min %v", max, min)
+	}
+	return min + rand.Intn(max-min), nil
+}
+
+// randString returns a random alphanumeric string of length n.
+func randString(n int) string {
+	bs := make([]byte, n)
+	for i := range bs {
+		bs[i] = _randStringChars[rand.Intn(len(_randStringChars))]
+	}
+	return string(bs)
+}
+
+// repeat returns n copies of element separated by commas, so a list can be
+// filled with copies of an element, e.g., [{{repeat 3 `{"id": 1}`}}].
+func repeat(n int, element string) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("repeat count %v cannot be negative", n)
+	}
+	if n > _maxRepeatCount {
+		return "", fmt.Errorf("repeat count %v cannot be more than %v", n, _maxRepeatCount)
+	}
+
+	const sep = ", "
+	if size := n * (len(element) + len(sep)); size > _maxRepeatSize {
+		return "", fmt.Errorf("repeat output of %v bytes cannot be more than %v bytes", size, _maxRepeatSize)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(n * (len(element) + len(sep)))
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(sep)
+		}
+		buf.WriteString(element)
+	}
+	return buf.String(), nil
+}
+
+// seq returns the integers from 0 to n-1, so a template can range over them
+// to generate list elements that differ, e.g., using the index as an ID.
+func seq(n int) ([]int, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("seq count %v cannot be negative", n)
+	}
+	if n > _maxRepeatCount {
+		return nil, fmt.Errorf("seq count %v cannot be more than %v", n, _maxRepeatCount)
+	}
+
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s, nil
+}
+
+// randUUID returns a random version 4 UUID.
+func randUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyTemplate(t *testing.T) {
+	tests := []struct {
+		msg       string
+		body      string
+		wantErr   string
+		wantMatch string
+	}{
+		{
+			msg:       "no template actions",
+			body:      `{"name": "alice"}`,
+			wantMatch: `^\{"name": "alice"\}$`,
+		},
+		{
+			msg:       "index",
+			body:      `{"id": {{.Index}}}`,
+			wantMatch: `^\{"id": 0\}$`,
+		},
+		{
+			msg:       "random helpers",
+			body:      `{"n": {{randInt 5 10}}, "s": "{{randString 8}}", "u": "{{uuid}}"}`,
+			wantMatch: `^\{"n": [5-9], "s": "[a-zA-Z0-9]{8}", "u": "[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}"\}$`,
+		},
+		{
+			msg:       "repeat",
+			body:      `{"items": [{{repeat 3 ` + "`" + `{"id": 1}` + "`" + `}}]}`,
+			wantMatch: `^\{"items": \[\{"id": 1\}, \{"id": 1\}, \{"id": 1\}\]\}$`,
+		},
+		{
+			msg:       "repeat none",
+			body:      `{"items": [{{repeat 0 "1"}}]}`,
+			wantMatch: `^\{"items": \[\]\}$`,
+		},
+		{
+			msg:       "seq",
+			body:      `[{{range $i := seq 3}}{{if $i}}, {{end}}{"id": {{$i}}}{{end}}]`,
+			wantMatch: `^\[\{"id": 0\}, \{"id": 1\}, \{"id": 2\}\]$`,
+		},
+		{
+			msg:     "negative repeat",
+			body:    `{{repeat -1 "1"}}`,
+			wantErr: "repeat count -1 cannot be negative",
+		},
+		{
+			msg:     "repeat count overflows",
+			body:    `{{repeat 9223372036854775807 "1"}}`,
+			wantErr: "repeat count 9223372036854775807 cannot be more than 1000000",
+		},
+		{
+			msg:     "repeat count too large",
+			body:    `{{repeat 1000001 "1"}}`,
+			wantErr: "repeat count 1000001 cannot be more than 1000000",
+		},
+		{
+			msg:     "repeat output too large",
+			body:    `{{repeat 1000000 (randString 100)}}`,
+			wantErr: "repeat output of 102000000 bytes cannot be more than 67108864 bytes",
+		},
+		{
+			msg:     "seq count too large",
+			body:    `{{range seq 1000001}}{{end}}`,
+			wantErr: "seq count 1000001 cannot be more than 1000000",
+		},
+		{
+			msg:     "negative seq",
+			body:    `{{range seq -1}}{{end}}`,
+			wantErr: "seq count -1 cannot be negative",
+		},
+		{
+			msg:     "invalid template",
+			body:    `{"id": {{.Index}`,
+			wantErr: "failed to parse body template",
+		},
+		{
+			msg:     "unknown function",
+			body:    `{{nope}}`,
+			wantErr: "failed to parse body template",
+		},
+		{
+			msg:     "unknown field",
+			body:    `{{.Missing}}`,
+			wantErr: "failed to render body template",
+		},
+		{
+			msg:     "invalid randInt range",
+			body:    `{{randInt 5 5}}`,
+			wantErr: "randInt max 5 must be greater than min 5",
+		},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := newBodyTemplate([]byte(tt.body))
+		if err == nil {
+			var got []byte
+			got, err = tmpl.render()
+			if err == nil {
+				assert.Regexp(t, regexp.MustCompile(tt.wantMatch), string(got), "%v: unexpected body", tt.msg)
+			}
+		}
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "%v: expected failure", tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+			}
+			continue
+		}
+		assert.NoError(t, err, "%v: unexpected failure", tt.msg)
+	}
+}
+
+func TestBodyTemplateIndex(t *testing.T) {
+	tmpl, err := newBodyTemplate([]byte("{{.Index}}"))
+	require.NoError(t, err, "Failed to parse template")
+
+	for i := 0; i < 5; i++ {
+		got, err := tmpl.render()
+		require.NoError(t, err, "Failed to render template")
+		assert.Equal(t, strconv.Itoa(i), string(got), "Index should increase for each render")
+	}
+}
+
+func TestBodyTemplateRepeatLarge(t *testing.T) {
+	tmpl, err := newBodyTemplate([]byte(`{"items": [{{repeat 1000 "{\"id\": 1}"}}]}`))
+	require.NoError(t, err, "Failed to parse template")
+
+	got, err := tmpl.render()
+	require.NoError(t, err, "Failed to render template")
+
+	var body struct {
+		Items []map[string]int `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(got, &body), "Rendered body should be valid JSON")
+	assert.Len(t, body.Items, 1000, "Unexpected number of items")
+}
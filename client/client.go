@@ -0,0 +1,204 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package client makes requests using yab's encodings and transports. It is
+// intended for Go programs, such as test harnesses, that want to make the same
+// requests as yab without running the CLI.
+//
+// Serializers are created using the encoding package, e.g., encoding.NewThrift,
+// and transports using the transport package, e.g., transport.NewTChannel.
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yarpc/yab/encoding"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/opentracing/opentracing-go"
+	opentracing_ext "github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+// _defaultTimeout matches the default --timeout used by the CLI.
+const _defaultTimeout = time.Second
+
+var (
+	errNilTransport  = errors.New("transport must be specified")
+	errNilSerializer = errors.New("serializer must be specified")
+)
+
+// Options are the optional fields set on a request.
+type Options struct {
+	// TargetService is the name of the service being called.
+	TargetService string
+
+	// ShardKey is used by TChannel to route the request.
+	ShardKey string
+
+	// Headers are the application headers sent with the request.
+	Headers map[string]string
+
+	// TransportHeaders are transport-specific headers sent with the request.
+	TransportHeaders map[string]string
+
+	// Baggage is propagated as tracing baggage.
+	Baggage map[string]string
+
+	// Timeout for the call. Defaults to 1 second.
+	Timeout time.Duration
+}
+
+// Tracing configures the span started for a call.
+type Tracing struct {
+	// SamplingPriority is set on the span. A priority of 0 leaves the
+	// sampling decision to the tracer.
+	SamplingPriority uint16
+
+	// SpanOptions are used when starting the span.
+	SpanOptions []opentracing.StartSpanOption
+}
+
+// Response is the result of a successful call.
+type Response struct {
+	// Body is the decoded response body. It is nil for oneway requests.
+	Body interface{}
+
+	// Raw is the response returned by the transport.
+	Raw *transport.Response
+}
+
+// ApplicationError is returned when the call succeeds, but the response
+// indicates a failure, such as a Thrift exception. The decoded response is
+// still returned with the error.
+type ApplicationError struct {
+	Err error
+}
+
+func (e ApplicationError) Error() string {
+	return fmt.Sprintf("response contains an exception: %v", e.Err)
+}
+
+// Call serializes the given body, makes a request using the given transport,
+// and decodes the response.
+func Call(ctx context.Context, t transport.Transport, s encoding.Serializer, body []byte, opts Options) (*Response, error) {
+	if t == nil {
+		return nil, errNilTransport
+	}
+	if s == nil {
+		return nil, errNilSerializer
+	}
+
+	req, err := s.Request(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize request: %v", err)
+	}
+
+	req, err = Prepare(ctx, req, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %v", err)
+	}
+
+	res, err := Do(ctx, t, req, Tracing{SamplingPriority: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to make call: %v", err)
+	}
+
+	response := &Response{Raw: res}
+	if req.Oneway {
+		return response, nil
+	}
+
+	response.Body, err = s.Response(res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if err := s.CheckSuccess(res); err != nil {
+		return response, ApplicationError{err}
+	}
+	return response, nil
+}
+
+// Prepare sets the given options on the request, and then applies any
+// interceptors registered using transport.RegisterInterceptor.
+func Prepare(ctx context.Context, req *transport.Request, opts Options) (*transport.Request, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = _defaultTimeout
+	}
+	req.TargetService = opts.TargetService
+	req.ShardKey = opts.ShardKey
+	req.Headers = opts.Headers
+	req.TransportHeaders = opts.TransportHeaders
+	req.Baggage = opts.Baggage
+	req.Timeout = timeout
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return transport.ApplyInterceptor(ctx, req)
+}
+
+// Do makes a single call using a prepared request, bounded by the request's
+// timeout. If the transport has a tracer, the call is traced, and the trace ID
+// is added to the response's transport fields.
+func Do(ctx context.Context, t transport.Transport, req *transport.Request, tracing Tracing) (*transport.Response, error) {
+	ctx, cancel := tchannel.NewContextBuilder(req.Timeout).SetParentContext(ctx).Build()
+	defer cancel()
+
+	var span opentracing.Span
+	if tracer := t.Tracer(); tracer != nil {
+		span = tracer.StartSpan(req.Method, tracing.SpanOptions...)
+		opentracing_ext.SamplingPriority.Set(span, tracing.SamplingPriority)
+		for k, v := range req.Baggage {
+			span = span.SetBaggageItem(k, v)
+		}
+		ctx = opentracing.ContextWithSpan(ctx, span)
+	}
+
+	res, err := t.Call(ctx, req)
+	if span != nil {
+		// Spans must be finished to be reported to the tracing backend.
+		span.Finish()
+		if err == nil {
+			addTraceID(res, span)
+		}
+	}
+	return res, err
+}
+
+// addTraceID adds the Jaeger trace ID to the response if the transport
+// did not already add one, so the call can be found in the tracing backend.
+func addTraceID(res *transport.Response, span opentracing.Span) {
+	sc, ok := span.Context().(jaeger.SpanContext)
+	if !ok || res == nil {
+		return
+	}
+	if _, ok := res.TransportFields["trace"]; ok {
+		return
+	}
+	if res.TransportFields == nil {
+		res.TransportFields = make(map[string]interface{})
+	}
+	res.TransportFields["trace"] = sc.TraceID().String()
+}
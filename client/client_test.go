@@ -0,0 +1,241 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/encoding"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
+	"golang.org/x/net/context"
+)
+
+// echoTransport returns the request body as the response, or err if set.
+type echoTransport struct {
+	err    error
+	last   *transport.Request
+	tracer opentracing.Tracer
+}
+
+func (t *echoTransport) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	t.last = req
+	if t.err != nil {
+		return nil, t.err
+	}
+	return &transport.Response{Body: req.Body}, nil
+}
+
+func (t *echoTransport) Protocol() transport.Protocol { return transport.TChannel }
+
+func (t *echoTransport) Tracer() opentracing.Tracer { return t.tracer }
+
+// failingSerializer is a JSON serializer where every response is a failure.
+type failingSerializer struct {
+	encoding.Serializer
+}
+
+func (failingSerializer) CheckSuccess(*transport.Response) error {
+	return errors.New("bad request")
+}
+
+func TestCall(t *testing.T) {
+	tests := []struct {
+		msg        string
+		transport  *echoTransport
+		serializer encoding.Serializer
+		body       string
+		opts       Options
+		wantBody   interface{}
+		wantErr    string
+	}{
+		{
+			msg:        "success",
+			transport:  &echoTransport{},
+			serializer: encoding.NewJSON("method"),
+			body:       `{"k": "v"}`,
+			wantBody:   map[string]interface{}{"k": "v"},
+		},
+		{
+			msg:        "invalid request",
+			transport:  &echoTransport{},
+			serializer: encoding.NewJSON("method"),
+			body:       `{`,
+			wantErr:    "failed to serialize request",
+		},
+		{
+			msg:        "transport error",
+			transport:  &echoTransport{err: errors.New("connection refused")},
+			serializer: encoding.NewJSON("method"),
+			body:       `{}`,
+			wantErr:    "failed to make call: connection refused",
+		},
+		{
+			msg:        "application error",
+			transport:  &echoTransport{},
+			serializer: failingSerializer{encoding.NewJSON("method")},
+			body:       `{"k": "v"}`,
+			wantBody:   map[string]interface{}{"k": "v"},
+			wantErr:    "response contains an exception: bad request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			res, err := Call(context.Background(), tt.transport, tt.serializer, []byte(tt.body), tt.opts)
+			if tt.wantBody != nil {
+				require.NotNil(t, res, "Expected a response")
+				assert.Equal(t, tt.wantBody, res.Body, "Response body mismatch")
+			}
+			if tt.wantErr != "" {
+				require.Error(t, err, "Call should fail")
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error")
+				return
+			}
+			assert.NoError(t, err, "Call failed")
+		})
+	}
+}
+
+func TestCallOptions(t *testing.T) {
+	tests := []struct {
+		msg         string
+		opts        Options
+		wantTimeout time.Duration
+	}{
+		{
+			msg:         "default timeout",
+			wantTimeout: time.Second,
+		},
+		{
+			msg: "options are set on the request",
+			opts: Options{
+				TargetService: "svc",
+				ShardKey:      "shard",
+				Headers:       map[string]string{"h": "v"},
+				Baggage:       map[string]string{"b": "v"},
+				Timeout:       time.Minute,
+			},
+			wantTimeout: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			et := &echoTransport{}
+			_, err := Call(context.Background(), et, encoding.NewJSON("method"), []byte("{}"), tt.opts)
+			require.NoError(t, err, "Call failed")
+
+			assert.Equal(t, tt.wantTimeout, et.last.Timeout, "Timeout mismatch")
+			assert.Equal(t, tt.opts.TargetService, et.last.TargetService, "Target service mismatch")
+			assert.Equal(t, tt.opts.ShardKey, et.last.ShardKey, "Shard key mismatch")
+			assert.Equal(t, tt.opts.Headers, et.last.Headers, "Headers mismatch")
+			assert.Equal(t, tt.opts.Baggage, et.last.Baggage, "Baggage mismatch")
+		})
+	}
+}
+
+func TestCallMissingArgs(t *testing.T) {
+	_, err := Call(context.Background(), nil, encoding.NewJSON("method"), nil, Options{})
+	assert.Equal(t, errNilTransport, err)
+
+	_, err = Call(context.Background(), &echoTransport{}, nil, nil, Options{})
+	assert.Equal(t, errNilSerializer, err)
+}
+
+type methodInterceptor struct {
+	method string
+}
+
+func (ri methodInterceptor) Apply(_ context.Context, req *transport.Request) (*transport.Request, error) {
+	req.Method = ri.method
+	return req, nil
+}
+
+func TestPrepareAppliesInterceptors(t *testing.T) {
+	restore := transport.RegisterInterceptor(methodInterceptor{method: "intercepted"})
+	defer restore()
+
+	req, err := Prepare(context.Background(), &transport.Request{Method: "method"}, Options{TargetService: "svc"})
+	require.NoError(t, err, "Prepare failed")
+	assert.Equal(t, "intercepted", req.Method, "Interceptor was not applied")
+	assert.Equal(t, "svc", req.TargetService, "Target service mismatch")
+	assert.Equal(t, time.Second, req.Timeout, "Expected default timeout")
+}
+
+func TestDoAddsTraceID(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+
+	et := &echoTransport{tracer: tracer}
+	req := &transport.Request{Method: "method", Timeout: time.Second}
+	res, err := Do(context.Background(), et, req, Tracing{SamplingPriority: 1})
+	require.NoError(t, err, "Do failed")
+	assert.NotEmpty(t, res.TransportFields["trace"], "Expected trace ID in transport fields")
+}
+
+func TestAddTraceID(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	span := tracer.StartSpan("method")
+	traceID := span.Context().(jaeger.SpanContext).TraceID().String()
+
+	tests := []struct {
+		msg  string
+		res  *transport.Response
+		want map[string]interface{}
+	}{
+		{
+			msg:  "no transport fields",
+			res:  &transport.Response{},
+			want: map[string]interface{}{"trace": traceID},
+		},
+		{
+			msg: "transport fields without trace",
+			res: &transport.Response{
+				TransportFields: map[string]interface{}{"statusCode": 200},
+			},
+			want: map[string]interface{}{"statusCode": 200, "trace": traceID},
+		},
+		{
+			msg: "transport already set trace",
+			res: &transport.Response{
+				TransportFields: map[string]interface{}{"trace": "abc"},
+			},
+			want: map[string]interface{}{"trace": "abc"},
+		},
+	}
+
+	for _, tt := range tests {
+		addTraceID(tt.res, span)
+		assert.Equal(t, tt.want, tt.res.TransportFields, tt.msg)
+	}
+
+	noopRes := &transport.Response{}
+	addTraceID(noopRes, opentracing.NoopTracer{}.StartSpan("method"))
+	assert.Nil(t, noopRes.TransportFields, "noop spans should not add a trace")
+}
@@ -36,12 +36,210 @@ Service::Method.
 
 	$ yab -p localhost:9787 kv -t kv.thrift -m KeyValue::Count -r '{}'
 
+Methods inherited from a parent service using "extends" can be called using
+the child service name. If only one service has a method, the service name can
+be left out, and yab will list the candidates if the method name is ambiguous.
+
+To list the services and methods defined in a Thrift file, along with their
+signatures, use --method-list:
+
+	$ yab -t kv.thrift --method-list
+
+If the Thrift file isn't available, --list-methods-remote lists the services
+and methods advertised by a running server instead. It calls the server's
+Meta::thriftIDL endpoint, which returns the server's Thrift IDL, so the service
+must be specified. If the server doesn't support introspection, yab fails and
+the Thrift file must be specified instead:
+
+	$ yab -p localhost:9787 kv --list-methods-remote
+
 You can also use positional arguments to specify the method and body:
 
 	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count '{}'
 
+To avoid quoting a whole JSON body, the body of a Thrift or Protobuf request
+can instead be specified as key:value fragments after the method, which are
+merged into an object. JSON and raw bodies are always sent as is. Values
+that are valid JSON, such as numbers, booleans, quoted strings and objects, are
+used as is, and any other value is sent as a string:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Set key:hello value:world
+
+Since the value must follow the colon directly, a YAML body such as 'key: value'
+is still treated as the body, and fragments cannot be mixed with a positional
+headers or body argument.
+
+To make Protobuf requests, specify a .proto file using --proto and pass the
+fully qualified service name and method as package.Service::Method. The request
+is specified as JSON, and is typically sent to a gRPC server:
+
+	$ yab -p grpc://localhost:5435 kv --proto kv.proto -m kv.KeyValue::Get -r '{"key": "hello"}'
+
+Imports in the .proto file are resolved relative to its directory, and
+additional import paths can be specified using --proto-import-path.
+
+Services without an IDL can be called using the JSON or raw encodings, which
+send the request body as is. The encoding defaults to JSON if the method
+doesn't contain "::" and no Thrift or .proto file is specified, and can be set
+using -e or --encoding. A Thrift or .proto file cannot be used with these
+encodings, though a Thrift file from the defaults, such as a config file, is
+ignored with a warning:
+
+	$ yab -p localhost:9787 kv -e json -m getValue -r '{"key": "hello"}'
+
+For services with no IDL at all, --no-thrift uses the JSON encoding unless
+--encoding raw is specified, even if the method contains "::", and ignores any
+Thrift file, such as one set in .yab.ini or a profile. The request body is
+validated as JSON, and the response is printed as generic JSON:
+
+	$ yab -p localhost:9787 kv --no-thrift KeyValue::get -r '{"key": "hello"}'
+
+If the Thrift file includes files that are not relative to the including file,
+specify the directories to search for includes using --thrift-path, which can be
+repeated:
+
+	$ yab -p localhost:9787 kv -t idl/kv/kv.thrift --thrift-path idl/shared KeyValue::Count '{}'
+
+The Thrift IDL can be read from stdin by specifying "-" as the Thrift file.
+Since the IDL has no path, includes are only searched for in --thrift-path,
+and the request body and headers cannot also be read from stdin:
+
+	$ generate-idl | yab -p localhost:9787 kv -t - KeyValue::Count '{}'
+
+Enums in Thrift responses are printed using the name of the enum value. To
+print the integer values instead, pass --numeric-enums.
+
+JSON numbers lose precision above 2^53, so large i64 values, such as IDs, can
+be specified as strings in requests, e.g., {"id": "1234567890123456789"}, and
+i64 values in responses are printed as strings. To print them as numbers
+instead, pass --no-i64-as-string.
+
+If the server's Thrift file differs from the local one, such as during a
+rolling deployment, some response fields may fail to decode. By default, the
+whole response fails, but with --lenient-decode, fields that fail to decode are
+replaced with a note describing the error, and the rest of the response is
+still printed:
+
+	$ yab -p localhost:9787 kv -t kv.thrift --lenient-decode KeyValue::Get '{"key": "foo"}'
+
+Legacy Thrift files may contain definitions that fail to compile, even though
+the methods being called are valid. With --thrift-no-validate, definitions that
+fail to compile, and any definitions that depend on them, are ignored, and a
+warning is printed to stderr for each:
+
+	$ yab -p localhost:9787 kv -t legacy.thrift --thrift-no-validate KeyValue::Count '{}'
+
 The TChannel health endpoint can be hit without specifying a Thrift file
-by passing --health.
+by passing --health. The health check is made against the service specified
+using --service (or the first positional argument), so it's possible to check
+the health of a specific service in a process that hosts multiple services:
+
+	$ yab -p localhost:9787 kv --health
+
+Calls that fail with a transport error, such as a connection failure or a
+timeout, can be retried using --retry-limit. Use --retry-backoff to wait
+between attempts, where the wait doubles after each retry:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count --retry-limit 3 --retry-backoff 100ms
+
+Application errors such as Thrift exceptions, and errors returned by the
+server such as HTTP status codes, are not retried. To bound the
+total time spent on a call, including all retries and backoffs, use
+--overall-timeout. Each attempt is still limited by --timeout, but never runs
+past the overall deadline:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count --retry-limit 5 --timeout 1s --overall-timeout 3s
+
+If the Thrift file annotates a method with its timeout, e.g.,
+i64 Count() (timeout = "500ms"), --method-timeout-from-spec uses it as the
+timeout for each request. A timeout specified using --timeout or a template
+takes precedence, and methods without the annotation use the default timeout:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count --method-timeout-from-spec
+
+If a Thrift method returns one of its declared exceptions, the exception is
+printed as the response body, but yab reports a failure and exits with a
+non-zero exit code. The failure names the exception field and type, along with
+the decoded exception fields. Use --ignore-exceptions to treat exceptions as successful
+responses.
+
+By default, yab makes a single request and prints the response. Use --count
+to make multiple requests one after another, printing each response:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count --count 5
+
+Requests are only made concurrently when benchmark options such as -n or -d
+are specified, and --count cannot be combined with them.
+
+Use --quiet to make requests without printing the response, which is useful
+when only the exit status matters. Failures are still reported. When
+benchmarking, --quiet only prints the benchmark results.
+
+To capture a response, such as a large response or a golden file for tests,
+use --output to write the response to a file instead of stdout. Any missing
+parent directories are created, and the file is written in the selected output
+format, or as is with --raw-output. Failures are still printed, and when
+benchmarking, only the initial response is written to the file:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get -r '{"key": "foo"}' --output testdata/get.json
+
+To replay a captured Thrift request exactly, --request-raw sends the
+pre-serialized request body in the given file as is. The Thrift file is still
+used to decode the response. The body must include the Thrift envelope if
+the transport uses envelopes, as HTTP does by default:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get --request-raw captured.bin
+
+For smoke tests, --check-only makes the call and only checks whether it
+succeeded, without decoding the response. Nothing is printed on success, and
+yab exits with a non-zero status if the call fails or returns an exception.
+This can be combined with --count for repeated probes:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count --check-only --count 3
+
+To check the contents of the response, --expect path=value fails unless the
+value at the path in the response body equals the given value. Paths use the
+same syntax as --select. Strings are compared as is, while other values are
+compared as JSON. --expect can be repeated, and yab lists every expectation
+that wasn't met:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get -r '{"key": "a"}' --expect result=hello
+
+When writing to a terminal, warnings are printed in yellow and failures in red.
+Colors are disabled when the output is redirected, when --no-color is passed,
+or when the NO_COLOR environment variable is set.
+
+To diagnose failing calls, -v logs the selected peers, connection attempts,
+failed calls and retries, including how long each took, to stderr. Use -vv to
+also log successful calls:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count -v
+
+To find calls in server logs, --request-id-prefix sends an x-request-id header
+of the form <prefix>-<n> with each call, where n starts at 1 and is incremented
+for each call, including benchmark calls. Retries of a call use the same ID.
+HTTP calls send it as an X-Request-Id HTTP header rather than as an
+application header, which would be prefixed with Rpc-Header-.
+The request ID is printed with the response as "requestId", and included when
+calls are logged, so a slow benchmark call logged with -vv can be found on the
+server:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count --request-id-prefix debug --count 3
+
+Use --select to print only part of the response body. The path is a list of
+field names and list indexes separated by dots, and strings are printed
+without quotes. If the path does not exist, nothing is printed and yab exits
+with a non-zero exit code:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get -r '{"key": "foo"}' --select result
+
+To check the size of a response, --show-size prints the size of the response
+body as "size". Sizes are shown in KiB or MiB when large, use --bytes-raw to
+print the size as an exact number of bytes.
+
+Oneway Thrift methods are sent without waiting for a response, and yab prints
+an acknowledgement instead of a response body. When benchmarking a oneway
+method, the latency only measures the time taken to send the request.
 
 Thrift requests can be specified as JSON or YAML. For example, for a method
 defined as:
@@ -63,6 +261,45 @@ Or it can be loaded from a file using -f or --file:
 
 	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get --file req.yaml
 
+Simple requests can be specified without JSON by repeating --field name=value.
+Values are converted to the type of the Thrift argument, so count=5 is sent as
+an integer, while string arguments are always sent as is, even if they look
+like a number. Lists, sets, maps and structs are specified as JSON, and yab
+fails if a value doesn't match the type of its argument:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get --field key=hello
+
+Thrift arguments can also be passed by position using --thrift-method-args,
+which takes a JSON or YAML array of the arguments in the order they are declared
+in the Thrift file. Trailing arguments can be omitted, and null arguments are
+left unset. A request body can also be an array of arguments:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Set --thrift-method-args '["hello", "world"]'
+
+A request can be checked against the method spec without making a call by
+passing --validate. yab reports any unknown or missing required fields, and
+exits with a non-zero status if the request is invalid:
+
+	$ yab -t kv.thrift -m KeyValue::Get --file req.yaml --validate
+
+To help construct a request, --describe prints a JSON template of the request
+for a Thrift method. Each field has a placeholder describing its type and
+whether it's required or optional, e.g., "<i32, required>", which can be
+replaced with a value before using the template as the request. Nested structs
+have a "$type" field describing the struct, which is ignored in requests, so
+it does not need to be removed:
+
+	$ yab -t kv.thrift -m KeyValue::Get --describe > req.json
+
+To see exactly what would be sent, --dry-run prints the method, headers and
+the serialized request body as base64 and a hex dump, without making a call.
+The body is serialized for the transport of the peer, since some transports
+use a different format, such as Thrift envelopes. The headers include the auth
+token header if one is configured, with the token redacted so it doesn't end up
+in terminals and logs, unless --dry-run-show-auth is passed:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get -r '{"key": "hello"}' --dry-run
+
 Request options can also be specified in a YAML file, e.g., get.yab:
 
 	service: kv
@@ -73,7 +310,11 @@ Request options can also be specified in a YAML file, e.g., get.yab:
 	  key: hello
 
 The above YAML file represents the same request as the command above, and can
-be run using yab -y get.yab.
+be run using yab -y get.yab, or yab --request-file get.yab. The file can also be
+written in JSON, and options passed on the command line override the values in
+the file, so call definitions can be checked in and reused:
+
+	$ yab --request-file calls/get.json --peer localhost:9788 -H tenant:test
 
 You can make the request by directly executing the file (./get.yab) if you
 add a shebang and mark the file as executable:
@@ -111,11 +352,53 @@ the command line:
 
 	$ ./set.yab -A key:hello -A value:world
 
+Application headers can be specified as a key:value pair using -H or --header,
+which can be repeated to pass multiple headers:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get \
+	    -H auth:token -H tenant:test -r '{"key": "hello"}'
+
+Headers can also be specified as JSON or YAML using --headers or --headers-file.
+If the same key is specified multiple times, the last value is used, and values
+passed using -H override values passed using --headers or --headers-file.
+
+Any application headers in the response are printed under "headers". If the
+response can't be decoded, the response headers are included in the error, as
+they often contain the reason for the failure.
+
+References to environment variables in the form ${VAR} are replaced with the
+value of the environment variable before the request body is parsed. The value
+is used as is, so it must be valid in the context of the JSON or YAML body.
+Unset variables are an error, unless --allow-missing-env is specified, which
+replaces them with an empty string:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get -r '{"key": "${TENANT}"}'
+
 Binary data can be specified in one of many ways:
 	* As a string or an array of bytes: "data" or [100, 97, 116, 97]
 	* As base64: {"base64": "ZGF0YQ=="}
 	* Loaded from a file: {"file": "data.bin"}
 
+Binary fields in responses are printed as base64 strings. To send a binary
+value from a previous response, wrap it as {"base64": "..."}, since plain
+strings are used as the raw bytes. Invalid base64 data is rejected.
+
+Thrift maps with string, number, bool or enum keys are specified as JSON
+objects, with the key written as a string, e.g., {"1": "one"} for a
+map<i32, string>. Maps with struct or container keys are written as a list of
+key-value items, which is how they are printed in responses:
+
+	[{"key": {"id": 1}, "value": "one"}]
+
+Any map can be specified as a list of items.
+
+With --thrift-const-refs, constants defined in the Thrift file can be
+referenced by name using a string of the form "@Name", or "@include.Name" for
+constants in an included file. Strings that don't match a constant are sent as
+is, and a leading "@@" is sent as a single "@":
+
+	$ yab -p localhost:9787 -t users.thrift users Users::Create --thrift-const-refs -r '{"user": "@DefaultUser"}'
+
 Examples:
 
 	$ yab -p localhost:9787 -t kv.thrift kv -m KeyValue::Set \
@@ -123,6 +406,31 @@ Examples:
 
 	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Set \
 	    -r '{"key": "hello", "value": {"file": "data.bin"}}'
+
+By default, responses are printed as indented JSON. To make the output easier
+to consume in scripts, use --format json, which prints the response as a single
+line of JSON. Failures are also printed as a JSON object on stderr, with the
+error, the stage that failed (parsing, transport, serialization, application,
+or output) and the method:
+
+	{"error":"Failed while making call: ...","stage":"transport","method":"KeyValue::Get"}
+
+Combined with --count, the output is newline-delimited JSON that can be
+streamed to tools like jq, and each line also includes the sequence number of
+the request ("seq") and the latency in milliseconds ("latencyMs"). --ndjson is
+an alias for --format json:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Count --count 10 --ndjson | jq .latencyMs
+
+For flat responses, --format table prints the top-level fields of the response
+as an aligned table of names and values, which is easier to scan. If a Thrift
+method returns a struct, the fields of the struct are printed. Nested values
+are printed as a single line of JSON:
+
+	$ yab -p localhost:9787 -t users.thrift users Users::get -r '{"id": 1}' --format table
+	id     1
+	name   alice
+	roles  ["admin","dev"]
 `
 
 const _transportOptsDesc = `Configures the network transport used to make requests.
@@ -142,23 +450,178 @@ For HTTP endpoints, specify the URL as the peer,
 
 The Thrift-encoded body will be POSTed to the specified URL.
 
-Multiple peers can be specified using a peer list using -P or --peer-list.
-When making a single request, a single peer from this list is selected randomly.
-When benchmarking, connections will be established in a round-robin fashion,
-starting with a random peer.
+HTTP services listening on a Unix domain socket can be called by specifying
+the path of the socket using the unix scheme:
+
+	$ yab -p unix:///var/run/kv.sock [options]
+
+TChannel connections can use TLS by passing --tls. The server certificate is
+verified using the system CA certificates, or the CA certificates in --tls-ca.
+A client certificate can be specified using --tls-cert and --tls-key:
+
+	$ yab -p svc.example.com:9787 --tls --tls-ca ca.pem [options]
+
+Server verification can be disabled in development environments using
+--tls-no-verify.
+
+Multiple peers can be specified by repeating -p or --peer, or using a peer list
+using -P or --peer-list. A peer list can be a JSON or YAML list, or a file with
+a host:port per line, where blank lines and comments are ignored. A comment
+starts with a # at the start of a line or after whitespace.
+Peers specified using --peer are merged with the peers in the peer list.
 
 	$ yab --peer-list hosts.json [options]
+
+Peers can be discovered using DNS SRV records, such as those served by Consul
+or for Kubernetes headless services, by specifying srv://name as a peer. The
+records are resolved once at startup, and yab fails if they have no targets.
+Targets are called using TChannel, unless a scheme is specified:
+
+	$ yab -p srv://_thrift._tcp.kv.service.consul [options]
+	$ yab -p srv://_http._tcp.kv.service.consul?scheme=http [options]
+
+In environments with a TChannel relay, such as Hyperbahn, a call can be routed
+by service name alone. If no peers are specified, calls are sent to the relays
+specified using --relay, which is usually set in defaults.ini:
+
+	[transport]
+	relay = 10.0.0.1:21300
+
+	$ yab kv KeyValue::Count '{}'
+
+To call the same service in different environments, such as dev, staging and
+prod, define a profile for each environment in the config file. A profile
+can set any option, such as the peers, caller name, TLS options and headers,
+and is selected using --profile. Peers in the profile replace the peers in the
+rest of the config file, and flags override the options in the profile:
+
+	[profiles.prod]
+	peer-list = "/etc/yab/prod-hosts.json"
+	caller = prod-caller
+	tls = true
+	header = env:prod
+
+	$ yab --profile prod kv KeyValue::Count '{}'
+
+IPv6 addresses must be enclosed in brackets, as in URLs, so they can be
+separated from the port, e.g. -p [::1]:9787 or -p http://[::1]:8080.
+
+When making a single HTTP request, a single peer is selected randomly. TChannel
+requests are sent to a peer selected by TChannel's peer selection.
+When benchmarking, connections will be established in a round-robin fashion,
+starting with a random peer, and each connection only sends requests to a
+single peer.
+
+Random peer selection can be reproduced by specifying the same --seed:
+
+	$ yab --peer-list hosts.json --seed 42 [options]
+
+Use --peer-strategy to choose how requests are distributed across multiple
+peers: random picks a random peer for each request, roundrobin cycles through
+the peers in order, and fanout sends each request to every peer. With fanout,
+yab waits for all peers to respond and prints the first successful response,
+and the request only fails if every peer fails, which is useful for warming
+caches. When benchmarking, each connection still sends requests to a single
+peer.
+
+	$ yab --peer-list hosts.json --peer-strategy fanout [options]
+
+The deadline of each call, set using --timeout, is propagated to the server so
+it can stop working on requests that have timed out. TChannel and gRPC send the
+deadline as part of the call, while HTTP calls send it using the Context-TTL-MS
+header, which can be disabled for servers that reject it using
+--no-deadline-header.
+
+Use --compress to request gzip compressed responses from HTTP peers. Compressed
+responses are decompressed before they are decoded, and the compressed size is
+included in the output. Responses that are not compressed are used as is. When
+benchmarking, the compression ratio of compressed responses is reported.
+
+By default, connecting to a peer may take up to the call timeout. To fail fast
+when a peer is down, limit the time spent connecting using --dial-timeout.
+Failures to connect are reported separately from failed calls:
+
+	$ yab -p localhost:9787 --dial-timeout 100ms [options]
+
+On hosts with multiple network interfaces, use --bind-address to choose the
+local IP address that connections to TChannel and HTTP peers are made from,
+e.g., to match firewall rules. Connecting fails if the address can't be used:
+
+	$ yab -p 10.0.0.5:9787 --bind-address 10.0.0.2 [options]
+
+To check whether a problem is caused by DNS or by the service, --resolve
+connects to a host using the given IP address instead of resolving it, like
+curl's --resolve. The original host name is still used to verify TLS
+certificates and in the HTTP Host header:
+
+	$ yab -p https://kv.example.com --resolve kv.example.com=10.0.0.5 [options]
+
+Services that authenticate callers can be called by sending an auth token in
+a header of each call, which defaults to Authorization and can be changed using
+--auth-header. HTTP calls send the token as an HTTP header, rather than as an
+application header with the Rpc-Header- prefix. The token is either specified
+using --auth-token, or is the output of a shell command specified using
+--auth-cmd, such as a command that signs a token for the caller. For long
+benchmarks, --auth-refresh re-runs the command to get a new token before the
+previous token expires:
+
+	$ yab -p localhost:9787 kv --auth-token "$TOKEN" [options]
+	$ yab -p localhost:9787 kv --auth-cmd "sign-token --caller yab" --auth-refresh 5m [options]
+
+TChannel calls set the arg scheme ("as" header) based on the encoding. Servers
+that expect a different value can be called by overriding it using
+--arg-scheme, which is equivalent to the -T as=<scheme> transport option. yab
+fails if both are specified with different values:
+
+	$ yab -p localhost:9787 --arg-scheme thrift-struct [options]
+
+Trace context can be propagated to the server using --jaeger, or its alias
+--trace, which starts a root span for each request. The trace ID is printed
+with the response. To report the spans so they show up in the tracing backend,
+specify the address of a Jaeger agent:
+
+	$ yab -p localhost:9787 --jaeger-agent localhost:6831 [options]
+
+When there is no Jaeger agent, such as when running outside the service mesh,
+spans can be sent directly to a Jaeger collector over HTTP using
+--tracing-endpoint, which takes the collector's host:port or a full URL. Spans
+are flushed before yab exits, including when a call fails:
+
+	$ yab -p localhost:9787 --tracing-endpoint collector:14268 [options]
+
+When spans are reported, benchmarks are traced using a span for the whole
+benchmark, with a child span for a sample of the benchmark requests. The
+fraction of requests that are sampled is set using --trace-sample-rate, which
+defaults to 0.01. Warmup requests are never sampled.
+
+Baggage, such as keys used to toggle feature flags, can be propagated using
+--baggage (or -B) as a key=value or key:value pair, which can be repeated. With
+--jaeger, baggage is attached to the span. Otherwise, it is sent as Jaeger
+baggage headers, uberctx-<key>, as HTTP headers, gRPC metadata or TChannel
+tracing headers, so servers that use Jaeger still see the baggage:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get --baggage flag=on [options]
+
+TChannel calls using the raw encoding have no headers to propagate baggage in,
+so they fail if baggage is specified.
 `
 
 const _benchmarkOptsDesc = `Configures benchmarking, which is disabled by default.
 
 By default, yab will only make a single request. To enable benchmarking,
-specify the maximum duration for the benchmark by passing -d or --max-duration.
+specify the maximum duration for the benchmark by passing -d, --max-duration
+or --duration.
 
 yab will make requests until either the maximum requests (-n or --max-requests)
 or the maximum duration is reached.
 
 You can control the rate at which yab makes requests using the --rps flag.
+The rate limit is shared by all connections and concurrent calls, so the
+total rate across all of them is approximately the specified RPS.
+
+If the benchmark is interrupted using Ctrl-C, yab stops making new requests,
+waits for in-flight requests to complete, and prints the results for the
+requests that were made. Interrupt again to exit immediately.
 
 An example benchmark command might be:
 
@@ -167,10 +630,132 @@ An example benchmark command might be:
 This would make requests at 1000 RPS until either the maximum number of
 requests (100,000) or the maximum duration (10 seconds) is reached.
 
-By default, yab will create multiple connections (defaulting to the number of
-CPUs on the machine), but will only have one concurrent call per connection.
-The number of connections and concurrent calls per connection can be controlled
-using --connections and --concurrency.
+Sending the same request repeatedly may hit caches in the service. To send a
+variety of requests, use --request-list with a file containing a JSON array of
+request bodies, or a request body per line. All requests are serialized before
+the benchmark starts, and each call uses the next request in the list:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get --request-list keys.json -n 10000
+
+Alternatively, --template treats the request body as a Go text/template which
+is parsed once and rendered for every request. The template can use .Index,
+the index of the request, and the helpers randInt, randString and uuid:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::Get --template -r '{"key": "key-{{.Index}}"}' -n 10000
+
+For bulk endpoints, the repeat helper fills a list with copies of an element,
+separated by commas, and seq returns the integers from 0 to n-1 to range over
+when each element should differ. Both accept counts of up to 1,000,000, and
+repeat fails if its output would be larger than 64 MiB:
+
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::SetMany --template -r '{"items": [{{repeat 1000 "{\"key\": \"k\"}"}}]}'
+	$ yab -p localhost:9787 -t kv.thrift kv KeyValue::SetMany --template -r '{"items": [{{range $i := seq 1000}}{{if $i}},{{end}}{"key": "k{{$i}}"}{{end}}]}'
+
+By default, yab will create multiple connections (defaulting to twice the
+number of CPUs on the machine), but will only have one concurrent call per
+connection. The number of connections and concurrent calls per connection can
+be controlled separately using --connections and --concurrency, so the total
+number of in-flight calls is the product of the two.
+
+Starting every worker at once can cause a burst of load at the start of the
+benchmark. Use --ramp-up to start the workers gradually over a period, so the
+number of active workers increases linearly until every connection and
+concurrent call is in use. The ramp up period is part of --max-duration, and
+--interval reports the number of active workers until the ramp up completes:
+
+	$ yab -p localhost:9787 moe --health -d 1m --connections 10 --concurrency 5 --ramp-up 10s
+
+Before the benchmark starts, each connection is warmed up by making --warmup
+requests (10 by default), so connection setup is not included in the measured
+time. Warmup requests are not included in the reported statistics, and the
+benchmark is aborted if any warmup request fails, since that usually indicates
+the connection is unusable. The error lists every peer that failed, and the
+peers that connected successfully.
+
+Servers that need longer to reach a steady state, such as those with lazily
+populated caches or JIT compilation, can be warmed up for a duration instead.
+With --warmup-duration, each connection is warmed up for the full duration,
+unless --warmup is also specified, in the args or a config file, in which case
+warmup stops once the duration elapses or --warmup requests have been made,
+whichever comes first:
+
+	$ yab -p localhost:9787 moe --health -d 1m --warmup-duration 30s
+
+To measure the overhead of connection setup, pass --reuse-connection=false to
+dial a new connection for every call, rather than reusing the warmed up
+connections. The latency of each call then includes connecting to the peer.
+
+When the benchmark completes, yab prints any errors, the latency quantiles
+(including p50, p90, p99, p99.9 and the max) computed from the latency of
+every successful request, along with the min, mean and standard deviation of
+the latencies, which help spot bimodal latencies that quantiles can hide,
+followed by a summary of the total requests, the error
+count and rate, the achieved RPS, the connections and concurrency used, and
+whether connections were reused. When connections are made to multiple peers,
+the summary also breaks down the requests, errors and latencies of each peer,
+which helps find a single slow or failing host.
+
+For long benchmarks, --interval prints the progress to stderr periodically,
+including the requests completed, the RPS since the last update and the number
+of errors, while the final results are still printed to stdout:
+
+	$ yab -p localhost:9787 moe --health -d 10m --interval 5s
+
+To analyze the results further, --latencies-out writes a CSV file with a row
+for every request, containing the start time, the latency in milliseconds,
+whether the request succeeded, and the error for failed requests. The file is
+written in the background and flushed when the benchmark completes or is
+interrupted:
+
+	$ yab -p localhost:9787 moe --health -d 10s --latencies-out latencies.csv
+
+To report the results to a monitoring system, --metrics-out writes the request
+and error counts, and a summary of the latencies, in the Prometheus text
+format. The file is replaced atomically, so it can be read by a node_exporter
+textfile collector:
+
+	$ yab -p localhost:9787 moe --health -d 10s --metrics-out /var/lib/node_exporter/yab.prom
+
+For automation, --summary-json writes the complete summary as JSON, including
+the benchmark configuration, the duration, the latency quantiles and stats, the
+RPS, and the count of each error. The file has a "version" field that only
+changes for incompatible changes to the format:
+
+	$ yab -p localhost:9787 moe --health -d 10s --summary-json summary.json
+
+The summary also includes the average, minimum and maximum size of successful
+responses. Sizes are shown in KiB or MiB when large, use --bytes-raw to print
+them as an exact number of bytes.
+
+To benchmark a mix of methods, specify a YAML or JSON file with a list of
+methods, their weights and request bodies using --mix. Each call picks a
+method based on the weights, and the summary includes the results for each
+method. The first method is used for the initial request and warmup:
+
+	$ cat mix.yaml
+	- method: KeyValue::Get
+	  weight: 70
+	  request: {key: foo}
+	- method: KeyValue::Set
+	  weight: 30
+	  request: {key: foo, value: bar}
+
+	$ yab -p localhost:9787 -t kv.thrift kv --mix mix.yaml -d 10s
+
+To use a benchmark as a regression gate, --max-error-rate and --max-p99 make
+yab exit with a non-zero status if the fraction of failed requests or the p99
+latency exceeds the threshold. Each threshold that was violated is reported
+after the summary:
+
+	$ yab -p localhost:9787 moe --health -d 30s --max-error-rate 0.01 --max-p99 50ms
+
+To compare against an earlier run, --baseline-out writes the summary in the same
+JSON format as --summary-json, and --baseline reads it back to print the change
+in RPS and p99 latency. yab exits with a non-zero status if either regresses by
+more than --baseline-tolerance, which defaults to 0.1 (10%):
+
+	$ yab -p localhost:9787 moe --health -d 30s --baseline-out baseline.json
+	$ yab -p localhost:9787 moe --health -d 30s --baseline baseline.json
 `
 
 /* vim: set tabstop=8:softtabstop=8:shiftwidth=8:noexpandtab */
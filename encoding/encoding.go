@@ -56,12 +56,15 @@ const (
 	JSON                Encoding = "json"
 	Thrift              Encoding = "thrift"
 	Raw                 Encoding = "raw"
+	Protobuf            Encoding = "proto"
 )
 
 var (
 	errNilEncoding = errors.New("cannot Unmarshal into nil Encoding")
 	// ErrHealthThriftOnly is returned if the user specifies an unsupported encoding with --health.
 	ErrHealthThriftOnly = errors.New("--health can only be used with Thrift")
+	// ErrThriftIDLThriftOnly is returned if the user specifies an unsupported encoding with --list-methods-remote.
+	ErrThriftIDLThriftOnly = errors.New("--list-methods-remote can only be used with Thrift")
 )
 
 func (e Encoding) String() string {
@@ -75,7 +78,7 @@ func (e *Encoding) UnmarshalText(text []byte) error {
 	}
 
 	switch s := strings.ToLower(string(text)); s {
-	case "", "json", "thrift", "raw":
+	case "", "json", "thrift", "raw", "proto":
 		*e = Encoding(s)
 		return nil
 	default:
@@ -93,12 +96,24 @@ func (e Encoding) GetHealth() (Serializer, error) {
 	switch e {
 	case UnspecifiedEncoding, Thrift:
 		method, spec := getHealthSpec()
-		return thriftSerializer{method, spec, defaultOpts}, nil
+		return thriftSerializer{method, spec, defaultOpts, nil /* warnings */, nil /* module */}, nil
 	default:
 		return nil, ErrHealthThriftOnly
 	}
 }
 
+// GetThriftIDL returns a serializer for the Meta::thriftIDL endpoint, which
+// returns the Thrift IDL files of the server.
+func (e Encoding) GetThriftIDL() (Serializer, error) {
+	switch e {
+	case UnspecifiedEncoding, Thrift:
+		method, spec := getThriftIDLSpec()
+		return thriftSerializer{method, spec, defaultOpts, nil /* warnings */, nil /* module */}, nil
+	default:
+		return nil, ErrThriftIDLThriftOnly
+	}
+}
+
 type jsonSerializer struct {
 	methodName string
 }
@@ -32,6 +32,10 @@ func TestEncodingUnmarshal(t *testing.T) {
 			input: "RAW",
 			want:  Raw,
 		},
+		{
+			input: "proto",
+			want:  Protobuf,
+		},
 		{
 			input:   "unknown",
 			wantErr: fmt.Errorf(`unknown encoding: "unknown"`),
@@ -85,6 +89,7 @@ func TestEncodingGetHealth(t *testing.T) {
 		{Thrift, true},
 		{Raw, false},
 		{JSON, false},
+		{Protobuf, false},
 	}
 
 	for _, tt := range tests {
@@ -95,6 +100,14 @@ func TestEncodingGetHealth(t *testing.T) {
 		} else {
 			assert.Error(t, err, "%v.GetHealth should fail", tt.encoding)
 		}
+
+		thriftIDL, err := tt.encoding.GetThriftIDL()
+		if tt.success {
+			assert.NoError(t, err, "%v.GetThriftIDL should succeed", tt.encoding)
+			assert.NotNil(t, thriftIDL, "%v.GetThriftIDL should succeed", tt.encoding)
+		} else {
+			assert.Error(t, err, "%v.GetThriftIDL should fail", tt.encoding)
+		}
 	}
 }
 
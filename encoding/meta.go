@@ -60,8 +60,9 @@ service Meta {
 `
 
 const (
-	metaService  = "Meta"
-	healthMethod = "health"
+	metaService     = "Meta"
+	healthMethod    = "health"
+	thriftIDLMethod = "thriftIDL"
 )
 
 var (
@@ -94,3 +95,7 @@ func getMetaService() *compile.ServiceSpec {
 func getHealthSpec() (string, *compile.FunctionSpec) {
 	return metaService + "::" + healthMethod, getMetaService().Functions[healthMethod]
 }
+
+func getThriftIDLSpec() (string, *compile.FunctionSpec) {
+	return metaService + "::" + thriftIDLMethod, getMetaService().Functions[thriftIDLMethod]
+}
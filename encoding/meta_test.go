@@ -39,4 +39,11 @@ func TestMetaSpec(t *testing.T) {
 		require.NotNil(t, spec, "Got nil health spec")
 		assert.Equal(t, 0, len(spec.ArgsSpec), "Health method")
 	}, "Failed to get health spec")
+
+	assert.NotPanics(t, func() {
+		name, spec := getThriftIDLSpec()
+		assert.Equal(t, "Meta::thriftIDL", name, "Method name mismatch")
+		require.NotNil(t, spec, "Got nil thriftIDL spec")
+		assert.Equal(t, 0, len(spec.ArgsSpec), "thriftIDL method should have no arguments")
+	}, "Failed to get thriftIDL spec")
 }
@@ -0,0 +1,171 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encoding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/yarpc/yab/thrift"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// ErrSpecifyProtoFile is returned if no proto file is specified
+// for a Protobuf request.
+var ErrSpecifyProtoFile = errors.New("specify a proto file using --proto")
+
+type protoSerializer struct {
+	methodName string
+	method     *desc.MethodDescriptor
+}
+
+// NewProtobuf returns a Protobuf serializer for a method defined in the given
+// proto file. Imports are resolved relative to the proto file's directory,
+// followed by the given import paths.
+func NewProtobuf(protoFile string, importPaths []string, methodName string) (Serializer, error) {
+	if protoFile == "" {
+		return nil, ErrSpecifyProtoFile
+	}
+	if isFileMissing(protoFile) {
+		return nil, fmt.Errorf("cannot find proto file: %q", protoFile)
+	}
+
+	parser := protoparse.Parser{
+		ImportPaths: append([]string{filepath.Dir(protoFile)}, importPaths...),
+	}
+	parsed, err := parser.ParseFiles(filepath.Base(protoFile))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse proto file: %v", err)
+	}
+
+	protoSvc, protoMethod, err := thrift.SplitMethod(methodName)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := findProtoService(parsed[0], protoSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := findProtoMethod(service, protoMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return protoSerializer{methodName, method}, nil
+}
+
+func (e protoSerializer) Encoding() Encoding {
+	return Protobuf
+}
+
+// Request converts the JSON input to the method's request message, and
+// marshals it to the Protobuf binary format.
+func (e protoSerializer) Request(input []byte) (*transport.Request, error) {
+	msg := dynamic.NewMessage(e.method.GetInputType())
+	if len(input) > 0 {
+		if err := msg.UnmarshalJSON(input); err != nil {
+			return nil, fmt.Errorf("could not convert JSON to %v: %v", e.method.GetInputType().GetFullyQualifiedName(), err)
+		}
+	}
+
+	bs, err := msg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Protobuf message to bytes: %v", err)
+	}
+
+	return &transport.Request{
+		Method: e.methodName,
+		Body:   bs,
+	}, nil
+}
+
+func (e protoSerializer) Response(res *transport.Response) (interface{}, error) {
+	msg := dynamic.NewMessage(e.method.GetOutputType())
+	if err := msg.Unmarshal(res.Body); err != nil {
+		return nil, fmt.Errorf("could not parse %v from response: %v", e.method.GetOutputType().GetFullyQualifiedName(), err)
+	}
+
+	bs, err := msg.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(bs, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// CheckSuccess verifies that the response is a valid response message.
+// Protobuf responses do not contain application errors, these are returned
+// by the transport instead.
+func (e protoSerializer) CheckSuccess(res *transport.Response) error {
+	_, err := e.Response(res)
+	return err
+}
+
+func findProtoService(file *desc.FileDescriptor, svcName string) (*desc.ServiceDescriptor, error) {
+	var available []string
+	for _, svc := range file.GetServices() {
+		if svc.GetFullyQualifiedName() == svcName || svc.GetName() == svcName {
+			return svc, nil
+		}
+		available = append(available, svc.GetFullyQualifiedName())
+	}
+	sort.Strings(available)
+
+	errMsg := "no proto service specified, specify --method package.Service::Method"
+	if svcName != "" {
+		errMsg = fmt.Sprintf("could not find service %q", svcName)
+	}
+	return nil, notFoundError{errMsg + ", available services:", available}
+}
+
+func findProtoMethod(service *desc.ServiceDescriptor, methodName string) (*desc.MethodDescriptor, error) {
+	if method := service.FindMethodByName(methodName); method != nil {
+		if method.IsClientStreaming() || method.IsServerStreaming() {
+			return nil, fmt.Errorf("streaming method %q is not supported", methodName)
+		}
+		return method, nil
+	}
+
+	var available []string
+	for _, method := range service.GetMethods() {
+		available = append(available, method.GetName())
+	}
+	sort.Strings(available)
+
+	errMsg := "no proto method specified, specify --method package.Service::Method"
+	if methodName != "" {
+		errMsg = fmt.Sprintf("could not find method %q in %q", methodName, service.GetFullyQualifiedName())
+	}
+	return nil, notFoundError{errMsg + ", available methods:", available}
+}
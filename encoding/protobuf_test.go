@@ -0,0 +1,132 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encoding
+
+import (
+	"testing"
+
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validProto = "../testdata/protobuf/simple.proto"
+
+func TestNewProtobufSerializer(t *testing.T) {
+	tests := []struct {
+		desc   string
+		file   string
+		method string
+		errMsg string
+	}{
+		{
+			desc:   "No proto file specified",
+			errMsg: "specify a proto file",
+		},
+		{
+			desc:   "Proto file can't be found",
+			file:   "/fake/file.proto",
+			errMsg: "cannot find proto file",
+		},
+		{
+			desc:   "Proto file can't be parsed",
+			file:   "../testdata/invalid.json",
+			errMsg: "could not parse proto file",
+		},
+		{
+			desc:   "No service specified",
+			file:   validProto,
+			errMsg: "available services:\n\tyab.test.Simple",
+		},
+		{
+			desc:   "Invalid service name",
+			file:   validProto,
+			method: "yab.test.Unknown::Foo",
+			errMsg: `could not find service "yab.test.Unknown"`,
+		},
+		{
+			desc:   "Invalid method name",
+			file:   validProto,
+			method: "yab.test.Simple::Unknown",
+			errMsg: "available methods:\n\tFoo\n\tStream",
+		},
+		{
+			desc:   "Streaming method",
+			file:   validProto,
+			method: "yab.test.Simple::Stream",
+			errMsg: "streaming method",
+		},
+		{
+			desc:   "Valid method using the fully qualified service name",
+			file:   validProto,
+			method: "yab.test.Simple::Foo",
+		},
+		{
+			desc:   "Valid method using the service name",
+			file:   validProto,
+			method: "Simple::Foo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := NewProtobuf(tt.file, nil /* importPaths */, tt.method)
+			if tt.errMsg != "" {
+				require.Error(t, err, "NewProtobuf should fail")
+				assert.Contains(t, err.Error(), tt.errMsg, "Unexpected error")
+				return
+			}
+
+			require.NoError(t, err, "NewProtobuf failed")
+			assert.Equal(t, Protobuf, got.Encoding(), "Encoding mismatch")
+		})
+	}
+}
+
+func TestProtobufRequestResponse(t *testing.T) {
+	serializer, err := NewProtobuf(validProto, nil /* importPaths */, "yab.test.Simple::Foo")
+	require.NoError(t, err, "Failed to create serializer")
+
+	req, err := serializer.Request([]byte(`{"name": "foo", "count": 2}`))
+	require.NoError(t, err, "Failed to serialize request")
+	assert.Equal(t, "yab.test.Simple::Foo", req.Method, "Method mismatch")
+	// Field 1 (name) with "foo", followed by field 2 (count) with 2.
+	assert.Equal(t, []byte{0x0a, 3, 'f', 'o', 'o', 0x10, 2}, req.Body, "Body mismatch")
+
+	_, err = serializer.Request([]byte(`{"unknown": 1}`))
+	assert.Error(t, err, "Request with unknown field should fail")
+
+	emptyReq, err := serializer.Request(nil)
+	require.NoError(t, err, "Failed to serialize empty request")
+	assert.Empty(t, emptyReq.Body, "Empty request should have an empty body")
+
+	res := &transport.Response{Body: []byte{0x0a, 2, 'h', 'i'}}
+	got, err := serializer.Response(res)
+	require.NoError(t, err, "Failed to deserialize response")
+	assert.Equal(t, map[string]interface{}{"greeting": "hi"}, got, "Response mismatch")
+	assert.NoError(t, serializer.CheckSuccess(res), "CheckSuccess failed")
+
+	invalidRes := &transport.Response{Body: []byte{0x0a, 10}}
+	_, err = serializer.Response(invalidRes)
+	assert.Error(t, err, "Response with invalid body should fail")
+	assert.Error(t, serializer.CheckSuccess(invalidRes), "CheckSuccess should fail")
+}
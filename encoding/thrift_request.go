@@ -21,9 +21,13 @@
 package encoding
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/yarpc/yab/sorted"
 	"github.com/yarpc/yab/thrift"
@@ -31,6 +35,8 @@ import (
 	"github.com/yarpc/yab/unmarshal"
 
 	"go.uber.org/thriftrw/compile"
+	"go.uber.org/thriftrw/wire"
+	"gopkg.in/yaml.v2"
 )
 
 const _multiplexedSeparator = ":"
@@ -47,18 +53,48 @@ type thriftSerializer struct {
 	methodName string
 	spec       *compile.FunctionSpec
 	opts       thrift.Options
+
+	// warnings are the errors for definitions in the Thrift file that were
+	// ignored since they failed to compile.
+	warnings []error
+
+	// module is the parsed Thrift file, used to resolve references to
+	// constants when they are enabled.
+	module *compile.Module
 }
 
-// NewThrift returns a Thrift serializer.
-func NewThrift(thriftFile, methodName string, multiplexed bool) (Serializer, error) {
+// NewThrift returns a Thrift serializer. Includes in the Thrift file that are
+// not found relative to the including file are searched for in includePaths.
+func NewThrift(thriftFile, methodName string, multiplexed bool, includePaths ...string) (Serializer, error) {
+	return newThrift(thriftFile, methodName, multiplexed, false /* lenient */, includePaths)
+}
+
+// NewLenientThrift is like NewThrift, but definitions in the Thrift file that
+// fail to compile are ignored, so methods that only use valid definitions can
+// still be called. The serializer's Warnings method returns an error for each
+// ignored definition.
+func NewLenientThrift(thriftFile, methodName string, multiplexed bool, includePaths ...string) (Serializer, error) {
+	return newThrift(thriftFile, methodName, multiplexed, true /* lenient */, includePaths)
+}
+
+func newThrift(thriftFile, methodName string, multiplexed, lenient bool, includePaths []string) (Serializer, error) {
 	if thriftFile == "" {
 		return nil, ErrSpecifyThriftFile
 	}
-	if isFileMissing(thriftFile) {
+	if thriftFile != thrift.StdinFile && isFileMissing(thriftFile) {
 		return nil, fmt.Errorf("cannot find Thrift file: %q", thriftFile)
 	}
 
-	parsed, err := thrift.Parse(thriftFile)
+	var (
+		parsed   *compile.Module
+		warnings []error
+		err      error
+	)
+	if lenient {
+		parsed, warnings, err = thrift.ParseLenient(thriftFile, includePaths...)
+	} else {
+		parsed, err = thrift.Parse(thriftFile, includePaths...)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not parse Thrift file: %v", err)
 	}
@@ -68,6 +104,19 @@ func NewThrift(thriftFile, methodName string, multiplexed bool) (Serializer, err
 		return nil, err
 	}
 
+	// A bare method name can be used if the name isn't a service, and only
+	// one service has a method with that name.
+	if _, isService := parsed.Services[thriftSvc]; !isService && thriftMethod == "" && thriftSvc != "" {
+		fullMethod, err := findServiceMethod(parsed, thriftSvc)
+		if err != nil {
+			return nil, err
+		}
+		if fullMethod != "" {
+			methodName = fullMethod
+			thriftSvc, thriftMethod, _ = thrift.SplitMethod(methodName)
+		}
+	}
+
 	service, err := findService(parsed, thriftSvc)
 	if err != nil {
 		return nil, err
@@ -83,7 +132,13 @@ func NewThrift(thriftFile, methodName string, multiplexed bool) (Serializer, err
 		opts.EnvelopeMethodPrefix = thriftSvc + _multiplexedSeparator
 	}
 
-	return thriftSerializer{methodName, spec, opts}, nil
+	return thriftSerializer{methodName, spec, opts, warnings, parsed}, nil
+}
+
+// Warnings returns an error for each definition in the Thrift file that was
+// ignored since it failed to compile.
+func (e thriftSerializer) Warnings() []error {
+	return e.warnings
 }
 
 func (e thriftSerializer) Encoding() Encoding {
@@ -91,12 +146,12 @@ func (e thriftSerializer) Encoding() Encoding {
 }
 
 func (e thriftSerializer) Request(input []byte) (*transport.Request, error) {
-	reqMap, err := unmarshal.YAML(input)
+	req, err := thriftRequestValue(input)
 	if err != nil {
 		return nil, err
 	}
 
-	reqBytes, err := thrift.RequestToBytes(e.spec, reqMap, e.opts)
+	reqBytes, err := thrift.RequestToBytes(e.spec, req, e.opts)
 	if err != nil {
 		return nil, err
 	}
@@ -104,10 +159,40 @@ func (e thriftSerializer) Request(input []byte) (*transport.Request, error) {
 	return &transport.Request{
 		Method: e.methodName,
 		Body:   reqBytes,
+		Oneway: e.spec.OneWay,
 	}, nil
 }
 
+// thriftRequestValue unmarshals a request body, which is either an object of
+// argument names, or an array of the arguments in declaration order.
+func thriftRequestValue(input []byte) (interface{}, error) {
+	reqMap, err := unmarshal.YAML(input)
+	if err == nil {
+		return reqMap, nil
+	}
+
+	var args []interface{}
+	if yaml.Unmarshal(input, &args) != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// RawRequest returns a request that uses the given pre-serialized body as is,
+// without encoding it using the method spec.
+func (e thriftSerializer) RawRequest(body []byte) *transport.Request {
+	return &transport.Request{
+		Method: e.methodName,
+		Body:   body,
+		Oneway: e.spec.OneWay,
+	}
+}
+
 func (e thriftSerializer) Response(res *transport.Response) (interface{}, error) {
+	if e.spec.OneWay {
+		// Oneway methods do not have a response to decode.
+		return nil, nil
+	}
 	return thrift.ResponseBytesToMap(e.spec, res.Body, e.opts)
 }
 
@@ -125,15 +210,125 @@ func findService(parsed *compile.Module, svcName string) (*compile.ServiceSpec,
 }
 
 func (e thriftSerializer) CheckSuccess(res *transport.Response) error {
+	if e.spec.OneWay {
+		return nil
+	}
 	return thrift.CheckSuccess(e.spec, res.Body, e.opts)
 }
 
+// DescribeRequest returns a template of the request arguments for the method.
+func (e thriftSerializer) DescribeRequest() interface{} {
+	return thrift.RequestTemplate(e.spec)
+}
+
+// FieldValue converts a value specified as a string to the type of the
+// argument with the given name, e.g., "5" is converted to an integer for an
+// i32 argument, while strings are used as is. Structs and containers are
+// specified as JSON. Values for unknown arguments are returned as is, so
+// they're reported when the request is serialized.
+func (e thriftSerializer) FieldValue(name, value string) (interface{}, error) {
+	for _, f := range e.spec.ArgsSpec {
+		if f.Name == name {
+			parsed, err := fieldValue(compile.RootTypeSpec(f.Type), value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for %v argument %q: %v", value, f.Type.ThriftName(), name, err)
+			}
+			return parsed, nil
+		}
+	}
+	return value, nil
+}
+
+func fieldValue(spec compile.TypeSpec, value string) (interface{}, error) {
+	if _, ok := spec.(*compile.EnumSpec); ok {
+		// Enums are specified using the name of the item.
+		return value, nil
+	}
+
+	switch spec.TypeCode() {
+	case wire.TBinary:
+		return value, nil
+	case wire.TBool:
+		return strconv.ParseBool(value)
+	case wire.TI8:
+		return strconv.ParseInt(value, 10, 8)
+	case wire.TI16:
+		return strconv.ParseInt(value, 10, 16)
+	case wire.TI32:
+		return strconv.ParseInt(value, 10, 32)
+	case wire.TI64:
+		return strconv.ParseInt(value, 10, 64)
+	case wire.TDouble:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return fieldJSON(value)
+	}
+}
+
+// fieldJSON parses a struct or container value, which must be a single JSON
+// value. Numbers are kept as is, so large i64 values don't lose precision.
+func fieldJSON(value string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return parsed, nil
+}
+
+// Annotations returns the annotations of the Thrift method.
+func (e thriftSerializer) Annotations() map[string]string {
+	return e.spec.Annotations
+}
+
+// IsOneway returns whether the Thrift method is a oneway method.
+func (e thriftSerializer) IsOneway() bool {
+	return e.spec.OneWay
+}
+
 func (e thriftSerializer) WithoutEnvelopes() Serializer {
 	// We're modifying a copy of e.
 	e.opts.UseEnvelopes = false
 	return e
 }
 
+// WithNumericEnums returns a serializer that returns enums in responses
+// as integers rather than names.
+func (e thriftSerializer) WithNumericEnums() Serializer {
+	// We're modifying a copy of e.
+	e.opts.NumericEnums = true
+	return e
+}
+
+// WithI64AsString returns a serializer that returns i64 values in responses
+// as strings.
+func (e thriftSerializer) WithI64AsString() Serializer {
+	// We're modifying a copy of e.
+	e.opts.I64AsString = true
+	return e
+}
+
+// WithConstRefs returns a serializer that replaces strings of the form
+// "@Name" in requests with the value of the constant from the Thrift file.
+func (e thriftSerializer) WithConstRefs() Serializer {
+	// We're modifying a copy of e.
+	e.opts.Module = e.module
+	return e
+}
+
+// WithLenientDecode returns a serializer that replaces fields in responses
+// that fail to decode with a note describing the error.
+func (e thriftSerializer) WithLenientDecode() Serializer {
+	// We're modifying a copy of e.
+	e.opts.LenientDecode = true
+	return e
+}
+
 func findMethod(service *compile.ServiceSpec, methodName string) (*compile.FunctionSpec, error) {
 	functions := service.Functions
 
@@ -159,6 +354,28 @@ func findMethod(service *compile.ServiceSpec, methodName string) (*compile.Funct
 	return nil, notFoundError{errMsg + ", available methods:", available}
 }
 
+// findServiceMethod returns the Service::Method name for the service that
+// has the given method, including inherited methods. An empty string is
+// returned if no service has the method, and an error if multiple services do.
+func findServiceMethod(parsed *compile.Module, methodName string) (string, error) {
+	var candidates []string
+	for _, svcName := range sorted.MapKeys(parsed.Services) {
+		if _, err := findMethod(parsed.Services[svcName], methodName); err == nil {
+			candidates = append(candidates, svcName+"::"+methodName)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", nil
+	case 1:
+		return candidates[0], nil
+	default:
+		errMsg := fmt.Sprintf("method %q is defined by multiple services, specify --method Service::Method, candidates:", methodName)
+		return "", notFoundError{errMsg, candidates}
+	}
+}
+
 func isFileMissing(f string) bool {
 	_, err := os.Stat(f)
 	return os.IsNotExist(err)
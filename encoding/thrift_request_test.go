@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/yarpc/yab/internal/thrifttest"
+	"github.com/yarpc/yab/transport"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -79,6 +80,11 @@ func TestNewThriftSerializer(t *testing.T) {
 			file:   validThrift,
 			method: fooMethod,
 		},
+		{
+			desc:   "Valid Thrift file and bare method name",
+			file:   validThrift,
+			method: "foo",
+		},
 		{
 			desc:        "Valid Thrift file and method name multiplexed",
 			file:        validThrift,
@@ -130,6 +136,16 @@ func TestRequest(t *testing.T) {
 			method: "withDefault",
 			bs:     nil,
 		},
+		{
+			desc:   "Valid positional arguments",
+			method: "withDefault",
+			bs:     []byte(`[[4, 5]]`),
+		},
+		{
+			desc:   "Too many positional arguments",
+			bs:     []byte(`["x"]`),
+			errMsg: "foo accepts 0 arguments, got 1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -155,6 +171,144 @@ func TestRequest(t *testing.T) {
 	}
 }
 
+func TestOnewayRequest(t *testing.T) {
+	serializer, err := NewThrift(validThrift, "Simple::fire", false /* multiplexed */)
+	require.NoError(t, err, "Failed to create serializer")
+
+	req, err := serializer.Request(nil)
+	require.NoError(t, err, "Failed to serialize oneway request")
+	assert.True(t, req.Oneway, "Request should be oneway")
+
+	res := &transport.Response{}
+	assert.NoError(t, serializer.CheckSuccess(res), "Oneway requests should always succeed")
+	got, err := serializer.Response(res)
+	assert.NoError(t, err, "Oneway response should not be decoded")
+	assert.Nil(t, got, "Oneway response should be empty")
+
+	foo, err := NewThrift(validThrift, fooMethod, false /* multiplexed */)
+	require.NoError(t, err, "Failed to create serializer")
+	fooReq, err := foo.Request(nil)
+	require.NoError(t, err, "Failed to serialize request")
+	assert.False(t, fooReq.Oneway, "Request should not be oneway")
+}
+
+func TestRawRequest(t *testing.T) {
+	serializer, err := NewThrift(validThrift, "Simple::fire", false /* multiplexed */)
+	require.NoError(t, err, "Failed to create serializer")
+
+	rr, ok := serializer.(interface {
+		RawRequest([]byte) *transport.Request
+	})
+	require.True(t, ok, "Thrift serializer should support raw requests")
+
+	body := []byte{1, 2, 3}
+	req := rr.RawRequest(body)
+	assert.Equal(t, body, req.Body, "Raw request body should be used as is")
+	assert.Equal(t, "Simple::fire", req.Method, "Unexpected method")
+	assert.True(t, req.Oneway, "Request should be oneway")
+}
+
+func TestThriftAnnotations(t *testing.T) {
+	tests := []struct {
+		method string
+		want   map[string]string
+	}{
+		{method: "Simple::slow", want: map[string]string{"timeout": "50ms"}},
+		{method: fooMethod},
+	}
+
+	for _, tt := range tests {
+		serializer, err := NewThrift(validThrift, tt.method, false /* multiplexed */)
+		require.NoError(t, err, "Failed to create serializer for %v", tt.method)
+
+		a, ok := serializer.(interface {
+			Annotations() map[string]string
+		})
+		require.True(t, ok, "Thrift serializer should support annotations")
+		if tt.want == nil {
+			assert.Empty(t, a.Annotations(), "%v should have no annotations", tt.method)
+			continue
+		}
+		assert.Equal(t, tt.want, a.Annotations(), "Unexpected annotations for %v", tt.method)
+	}
+}
+
+func TestWithNumericEnums(t *testing.T) {
+	serializer, err := NewThrift(validThrift, "Simple::getStatus", false /* multiplexed */)
+	require.NoError(t, err, "Failed to create serializer")
+	serializer = serializer.(thriftSerializer).WithoutEnvelopes()
+
+	res := &transport.Response{
+		Body: []byte{
+			0x08, 0x00, 0x00, // type = i32 | id = 0
+			0x00, 0x00, 0x00, 0x02, // ACTIVE
+			0x00, // end of struct
+		},
+	}
+
+	got, err := serializer.Response(res)
+	require.NoError(t, err, "Failed to decode response")
+	assert.Equal(t, map[string]interface{}{"result": "ACTIVE"}, got, "Enum should be decoded by name")
+
+	serializer = serializer.(thriftSerializer).WithNumericEnums()
+	got, err = serializer.Response(res)
+	require.NoError(t, err, "Failed to decode response")
+	assert.Equal(t, map[string]interface{}{"result": int32(2)}, got, "Enum should be decoded as an integer")
+}
+
+func TestWithLenientDecode(t *testing.T) {
+	serializer, err := NewThrift(validThrift, "Simple::getStatus", false /* multiplexed */)
+	require.NoError(t, err, "Failed to create serializer")
+	serializer = serializer.(thriftSerializer).WithoutEnvelopes()
+
+	res := &transport.Response{
+		Body: []byte{
+			0x0B, 0x00, 0x00, // type = string | id = 0
+			0x00, 0x00, 0x00, 0x01, 'a', // "a"
+			0x00, // end of struct
+		},
+	}
+
+	_, err = serializer.Response(res)
+	assert.Error(t, err, "Response with the wrong type should fail to decode")
+
+	serializer = serializer.(thriftSerializer).WithLenientDecode()
+	got, err := serializer.Response(res)
+	require.NoError(t, err, "Failed to decode response")
+	assert.Equal(t, map[string]interface{}{
+		"result": "<failed to decode: type specified in Thrift field as TI32, got TBinary>",
+	}, got, "Field that fails to decode should be replaced with a note")
+}
+
+func TestWithConstRefs(t *testing.T) {
+	module := thrifttest.Parse(t, `
+		const string DefaultID = "abc"
+
+		service Test {
+			void test(1: string id)
+		}
+	`)
+	svc, err := module.LookupService("Test")
+	require.NoError(t, err, "Failed to find service")
+	serializer := thriftSerializer{
+		methodName: "Test::test",
+		spec:       svc.Functions["test"],
+		opts:       defaultOpts,
+		module:     module,
+	}
+
+	want, err := serializer.Request([]byte(`{"id": "abc"}`))
+	require.NoError(t, err, "Failed to serialize request")
+
+	got, err := serializer.Request([]byte(`{"id": "@DefaultID"}`))
+	require.NoError(t, err, "Failed to serialize request")
+	assert.NotEqual(t, want.Body, got.Body, "References should not be expanded by default")
+
+	got, err = serializer.WithConstRefs().Request([]byte(`{"id": "@DefaultID"}`))
+	require.NoError(t, err, "Failed to serialize request with constant references")
+	assert.Equal(t, want.Body, got.Body, "Constant reference should serialize to the constant's value")
+}
+
 func TestFindServiceFound(t *testing.T) {
 	parsed := thrifttest.Parse(t, `
     service Foo {}
@@ -262,6 +416,68 @@ func TestFindMethod(t *testing.T) {
 	}
 }
 
+func TestFindServiceMethod(t *testing.T) {
+	parsed := thrifttest.Parse(t, `
+		service Foo {
+			void f1()
+			void m1()
+		}
+
+		service S1 {
+			void m1()
+		}
+
+		service S2 extends S1 {
+			void m2()
+		}
+
+		service S3 extends S2 {
+			void m3()
+		}
+	`)
+
+	tests := []struct {
+		method string
+		want   string
+		errMsg string
+	}{
+		{method: "f1", want: "Foo::f1"},
+		{method: "m3", want: "S3::m3"},
+		{method: "unknown", want: ""},
+		{
+			method: "m2",
+			errMsg: "method \"m2\" is defined by multiple services, specify --method Service::Method, candidates:\n\tS2::m2\n\tS3::m2",
+		},
+		{
+			method: "m1",
+			errMsg: "candidates:\n\tFoo::m1\n\tS1::m1\n\tS2::m1\n\tS3::m1",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := findServiceMethod(parsed, tt.method)
+		if tt.errMsg != "" {
+			if assert.Error(t, err, "findServiceMethod(%v) should fail", tt.method) {
+				assert.Contains(t, err.Error(), tt.errMsg, "findServiceMethod(%v) got unexpected error", tt.method)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, "findServiceMethod(%v) should not fail", tt.method) {
+			assert.Equal(t, tt.want, got, "findServiceMethod(%v) mismatch", tt.method)
+		}
+	}
+}
+
+func TestBareMethodName(t *testing.T) {
+	serializer, err := NewThrift(validThrift, "foo", false /* multiplexed */)
+	require.NoError(t, err, "NewThrift failed")
+
+	req, err := serializer.Request(nil)
+	require.NoError(t, err, "Request failed")
+	assert.Equal(t, "Simple::foo", req.Method, "Bare method should resolve to Service::Method")
+}
+
 func TestWithoutEnvelopes(t *testing.T) {
 	tests := []struct {
 		desc             string
@@ -0,0 +1,104 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// expectation checks that the value at a path in the response body equals
+// an expected value, specified as path=value.
+type expectation struct {
+	path string
+	want string
+}
+
+// parseExpectation parses an expectation such as "status=OK" or
+// "result.items[0].count=3".
+func parseExpectation(expr string) (expectation, error) {
+	i := strings.IndexByte(expr, '=')
+	if i < 0 {
+		return expectation{}, fmt.Errorf("invalid expectation %q, must be of the form path=value", expr)
+	}
+
+	e := expectation{path: expr[:i], want: expr[i+1:]}
+	if _, err := splitSelectPath(e.path); err != nil {
+		return expectation{}, fmt.Errorf("invalid expectation %q: %v", expr, err)
+	}
+	return e, nil
+}
+
+// parseExpectations parses each of the given expectations.
+func parseExpectations(exprs []string) ([]expectation, error) {
+	expectations := make([]expectation, len(exprs))
+	for i, expr := range exprs {
+		e, err := parseExpectation(expr)
+		if err != nil {
+			return nil, err
+		}
+		expectations[i] = e
+	}
+	return expectations, nil
+}
+
+// check returns a description of the mismatch if the value at the path in
+// the response doesn't equal the expected value, or an empty string if it
+// does. Strings are compared as is, while other values are compared as JSON,
+// so the expected value can be a number, bool, list or object.
+func (e expectation) check(responseMap interface{}) string {
+	got, err := selectPath(responseMap, e.path)
+	if err != nil {
+		return fmt.Sprintf("%v: expected %v, but %v", e.path, e.want, err)
+	}
+	if s, ok := got.(string); ok && s == e.want {
+		return ""
+	}
+
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		return fmt.Sprintf("%v: expected %v, but failed to convert value to JSON: %v", e.path, e.want, err)
+	}
+
+	// Numbers are decoded as is so that large i64 values don't lose precision.
+	var want interface{}
+	decoder := json.NewDecoder(strings.NewReader(e.want))
+	decoder.UseNumber()
+	if err := decoder.Decode(&want); err == nil && !decoder.More() {
+		if wantJSON, err := json.Marshal(want); err == nil && string(wantJSON) == string(gotJSON) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%v: expected %v, got %s", e.path, e.want, gotJSON)
+}
+
+// checkExpectations returns a description of each expectation that the
+// response doesn't meet.
+func checkExpectations(responseMap interface{}, expectations []expectation) []string {
+	var mismatches []string
+	for _, e := range expectations {
+		if mismatch := e.check(responseMap); mismatch != "" {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpectations(t *testing.T) {
+	tests := []struct {
+		exprs   []string
+		want    []expectation
+		wantErr string
+	}{
+		{
+			exprs: []string{"status=OK", "result.items[0].count=3"},
+			want: []expectation{
+				{path: "status", want: "OK"},
+				{path: "result.items[0].count", want: "3"},
+			},
+		},
+		{
+			exprs: []string{"name="},
+			want:  []expectation{{path: "name", want: ""}},
+		},
+		{
+			exprs: []string{"query=a=b"},
+			want:  []expectation{{path: "query", want: "a=b"}},
+		},
+		{
+			exprs:   []string{"status"},
+			wantErr: `invalid expectation "status", must be of the form path=value`,
+		},
+		{
+			exprs:   []string{"=OK"},
+			wantErr: `invalid expectation "=OK": select path cannot be empty`,
+		},
+		{
+			exprs:   []string{"items[0=OK"},
+			wantErr: "unbalanced brackets",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := parseExpectations(tt.exprs)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "parseExpectations(%v) should fail", tt.exprs) {
+				assert.Contains(t, err.Error(), tt.wantErr, "parseExpectations(%v) unexpected error", tt.exprs)
+			}
+			continue
+		}
+
+		require.NoError(t, err, "parseExpectations(%v) failed", tt.exprs)
+		assert.Equal(t, tt.want, got, "parseExpectations(%v) mismatch", tt.exprs)
+	}
+}
+
+func TestCheckExpectations(t *testing.T) {
+	response := map[string]interface{}{
+		"status": "OK",
+		"result": map[string]interface{}{
+			"count":   int64(3),
+			"enabled": true,
+			"big":     int64(9007199254740993),
+			"items":   []interface{}{"a", "b"},
+		},
+		"quoted": "3",
+	}
+
+	tests := []struct {
+		msg   string
+		exprs []string
+		want  []string
+	}{
+		{
+			msg:   "string",
+			exprs: []string{"status=OK"},
+		},
+		{
+			msg:   "number, bool and list are compared as JSON",
+			exprs: []string{"result.count=3", "result.enabled=true", `result.items=["a", "b"]`},
+		},
+		{
+			msg:   "large i64 keeps precision",
+			exprs: []string{"result.big=9007199254740993"},
+		},
+		{
+			msg:   "string that looks like a number",
+			exprs: []string{"quoted=3"},
+		},
+		{
+			msg:   "all expectations must be met",
+			exprs: []string{"status=OK", "result.count=4", "result.items[1]=c"},
+			want: []string{
+				"result.count: expected 4, got 3",
+				`result.items[1]: expected c, got "b"`,
+			},
+		},
+		{
+			msg:   "missing path",
+			exprs: []string{"result.name=foo"},
+			want:  []string{`result.name: expected foo, but "result.name" not found in response`},
+		},
+		{
+			msg:   "trailing content is not ignored",
+			exprs: []string{"result.count=3 4"},
+			want:  []string{"result.count: expected 3 4, got 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		expectations, err := parseExpectations(tt.exprs)
+		require.NoError(t, err, "%v: parseExpectations failed", tt.msg)
+		assert.Equal(t, tt.want, checkExpectations(response, expectations), "%v: unexpected mismatches", tt.msg)
+	}
+}
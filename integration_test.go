@@ -65,6 +65,7 @@ var integrationTests = []struct {
 	{
 		call:    1,
 		wantRes: `"notFound": {}`,
+		wantErr: "Response contains an exception",
 	},
 	{
 		call:    5,
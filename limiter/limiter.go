@@ -51,7 +51,20 @@ func (r *Run) More() bool {
 	if r.requestsLeft.Load() >= 0 {
 		r.limiter.Take(r.cancel)
 	}
-	return r.requestsLeft.Dec() >= 0
+	if r.requestsLeft.Dec() >= 0 {
+		return true
+	}
+
+	// The requests are used up, so stop the run to close Done.
+	r.Stop()
+	return false
+}
+
+// Done returns a channel that is closed when the run is stopped, either by
+// Stop, when the maximum duration elapses, or once More has returned false
+// since the maximum number of requests were made.
+func (r *Run) Done() <-chan struct{} {
+	return r.cancel
 }
 
 // Stop will ensure that all future calls to More return false.
@@ -63,6 +63,41 @@ func TestStop(t *testing.T) {
 	}
 }
 
+func TestDone(t *testing.T) {
+	run := New(0 /* maxRequests */, 0 /* rps */, time.Millisecond)
+
+	select {
+	case <-run.Done():
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Errorf("Done should be closed after the timeout")
+	}
+	assert.False(t, run.More(), "Fail after Done is closed")
+
+	// Stopping an already stopped run should not panic.
+	run.Stop()
+}
+
+func TestDoneAfterMaxRequests(t *testing.T) {
+	run := New(2 /* maxRequests */, 0 /* rps */, 0 /* maxDuration */)
+	for i := 0; i < 2; i++ {
+		assert.True(t, run.More(), "Request %v should succeed", i)
+	}
+
+	select {
+	case <-run.Done():
+		t.Errorf("Done should not be closed before More returns false")
+	default:
+	}
+
+	assert.False(t, run.More(), "Fail after the max requests")
+	select {
+	case <-run.Done():
+	default:
+		t.Errorf("Done should be closed once the max requests are made")
+	}
+	assert.False(t, run.More(), "Fail after Done is closed")
+}
+
 func TestTimeout(t *testing.T) {
 	run := New(1000 /* maxRequests */, 1000 /* rps */, time.Millisecond)
 	assert.True(t, run.More(), "Succeed within the timeout")
@@ -21,37 +21,77 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	"github.com/yarpc/yab/client"
 	"github.com/yarpc/yab/encoding"
 	"github.com/yarpc/yab/peerprovider"
 	"github.com/yarpc/yab/plugin"
+	"github.com/yarpc/yab/sorted"
+	"github.com/yarpc/yab/thrift"
 	"github.com/yarpc/yab/transport"
 
 	"github.com/casimir/xdg-go"
 	"github.com/jessevdk/go-flags"
 	"github.com/opentracing/opentracing-go"
-	opentracing_ext "github.com/opentracing/opentracing-go/ext"
 	"github.com/uber/jaeger-client-go"
-	"github.com/uber/tchannel-go"
+	jaeger_transport "github.com/uber/jaeger-client-go/transport"
+	"go.uber.org/thriftrw/compile"
 	"go.uber.org/zap"
+	"golang.org/x/net/context"
 )
 
 var (
 	errHealthAndProcedure = errors.New("cannot specify procedure and use --health")
+	errHealthAndOneway    = errors.New("cannot use --health with a oneway method, the health endpoint requires a response")
+	errHealthNoService    = errors.New("specify the service to health check using --service, since a process may host multiple services")
+	errNegativeCount      = errors.New("count cannot be negative")
+	errNegativeOverall    = errors.New("overall timeout cannot be negative")
+	errNegativeDial       = errors.New("dial timeout cannot be negative")
+	errNegativeRetryLimit = errors.New("retry limit cannot be negative")
+	errCountAndBenchmark  = errors.New("cannot use --count with benchmark options, use --max-requests instead")
+	errBlankCallerName    = errors.New("caller name cannot be blank")
+	errSelectAndRaw       = errors.New("cannot use --select with raw output")
+	errCheckOnlyAndOutput = errors.New("cannot use --check-only with --select or raw output, since the response is not printed")
+	errExpectAndRaw       = errors.New("cannot use --expect with --check-only or raw output, since the response is not decoded")
+	errDescribeNotThrift  = errors.New("--describe is only supported for Thrift methods")
+	errThriftAndBodyStdin = errors.New("cannot read both the Thrift file and the request body or headers from stdin")
+	errRemoteAndProcedure = errors.New("cannot specify procedure or use --health with --list-methods-remote")
+	errRemoteNoService    = errors.New("specify the service to list methods for using --service, since a process may host multiple services")
+	errRequestRawAndBody  = errors.New("cannot use --request-raw with another request body, --field, --request-list, --template or --mix")
+	errRequestRawThrift   = errors.New("--request-raw is only supported for Thrift methods")
+	errSpecTimeoutThrift  = errors.New("--method-timeout-from-spec is only supported for Thrift methods")
+	errMethodArgsThrift   = errors.New("--thrift-method-args is only supported for Thrift methods")
 
 	// map of caller names we do not want to be used.
 	warningCallerNames = map[string]struct{}{"tcurl": struct{}{}}
 	blockedCallerNames = map[string]struct{}{}
 )
 
+// _localConfigFile is the config file that is used from the current directory,
+// which allows defaults to be specified per project.
+const _localConfigFile = ".yab.ini"
+
+// _timeoutAnnotation is the Thrift annotation used by --method-timeout-from-spec.
+const _timeoutAnnotation = "timeout"
+
+// _jaegerCollectorPath is the path that a Jaeger collector accepts spans on.
+const _jaegerCollectorPath = "/api/traces"
+
 func findGroup(parser *flags.Parser, group string) *flags.Group {
 	if g := parser.Group.Find(group); g != nil {
 		return g
@@ -119,9 +159,18 @@ yab includes a full man page (man yab), which is also available online: http://y
 `
 
 	// Read defaults if they're available, before we change the group names.
-	if err := parseDefaultConfigs(parser); err != nil {
+	// 0 warmup requests is valid, so use a negative value to tell if the
+	// config specifies the number of warmup requests.
+	configFile, profile := configFromArgs(args)
+	opts.BOpts.WarmupRequests = -1
+	if err := parseDefaultConfigs(parser, opts, configFile, profile); err != nil {
 		return nil, fmt.Errorf("error reading defaults: %v", err)
 	}
+	if opts.BOpts.WarmupRequests >= 0 {
+		opts.BOpts.explicitWarmup = true
+	} else {
+		opts.BOpts.WarmupRequests = _defaultWarmupRequests
+	}
 
 	// Check if the first argument is a yab template. This is to support using
 	// yab as a shebang, since flags aren't supported in shebangs.
@@ -158,9 +207,11 @@ yab includes a full man page (man yab), which is also available online: http://y
 		return opts, err
 	}
 	setEncodingOptions(opts)
+	setDurationOptions(opts)
+	setOutputOptions(opts)
 
 	if opts.DisplayVersion {
-		out.Printf("yab version %v\n", versionString)
+		out.Printf("%s", buildInfo())
 		return opts, errExit
 	}
 
@@ -176,6 +227,15 @@ Default options can be specified in a ~/.config/yab/defaults.ini file (or ~/Libr
 
 	[benchmark]
 	warmup = 10
+
+A .yab.ini file in the current directory takes precedence over the defaults.ini file, which allows defaults to be specified per project. A specific file can be used instead by passing --config path/to/defaults.ini.
+
+The config file can also define named profiles, such as one for each environment, which are selected using --profile prod. Each profile is a section containing options from any group, which override the rest of the config file:
+
+	[profiles.prod]
+	peer-list = "/path/to/prod/hosts.json"
+	caller = prod-caller
+	tls = true
 `
 		parser.LongDescription = toGroff(parser.LongDescription)
 		parser.WriteManPage(out)
@@ -185,6 +245,18 @@ Default options can be specified in a ~/.config/yab/defaults.ini file (or ~/Libr
 	fromPositional(remaining, 0, &opts.TOpts.ServiceName)
 	fromPositional(remaining, 1, &opts.ROpts.Procedure)
 
+	// The request body can also be specified as key:value fragments, as
+	// [service] [method] [key:value]... Fragments are only used with encodings
+	// that have a schema, since JSON and raw bodies are sent as is.
+	if len(remaining) > 2 && isBodyFragment(remaining[2]) && usesSchema(detectEncoding(opts.ROpts)) {
+		body, err := bodyFromFragments(remaining[2:])
+		if err != nil {
+			return opts, err
+		}
+		opts.ROpts.RequestJSON = body
+		return opts, nil
+	}
+
 	// We support both:
 	// [service] [method] [request]
 	// [service] [method] [headers] [request]
@@ -223,6 +295,11 @@ func parseAndRun(out output) {
 // contain the peer specified in the args.
 func overrideDefaults(defaults *Options, args []string) error {
 	argsParser, argsOnly := newParser()
+	// Clear the default timeout, so we can tell if the args specify a timeout.
+	argsOnly.ROpts.Timeout = 0
+	// 0 warmup requests is valid, so use a negative value to tell if the args
+	// specify the number of warmup requests.
+	argsOnly.BOpts.WarmupRequests = -1
 	argsParser.ParseArgs(args)
 
 	// If there's a YAML request specified, read that now.
@@ -240,17 +317,49 @@ func overrideDefaults(defaults *Options, args []string) error {
 		defaults.TOpts.Peers = nil
 	}
 
+	// A request file specified in args overrides any request body from the template.
+	if argsOnly.ROpts.RequestFile != "" {
+		defaults.ROpts.RequestJSON = ""
+	}
+
+	// A timeout specified in args overrides the method's timeout from the Thrift file.
+	if argsOnly.ROpts.Timeout != 0 {
+		defaults.ROpts.explicitTimeout = true
+	}
+
+	// Only a Thrift file specified in args is rejected with encodings that
+	// don't use it, a Thrift file from the defaults is ignored with a warning.
+	if argsOnly.ROpts.ThriftFile != "" {
+		defaults.ROpts.explicitThrift = true
+	}
+
+	// The built-in number of warmup requests is ignored with --warmup-duration,
+	// unlike a number of warmup requests from the config or args.
+	if argsOnly.BOpts.WarmupRequests >= 0 {
+		defaults.BOpts.explicitWarmup = true
+	}
+
 	return nil
 }
 
+// configFromArgs returns the config file specified using --config, and the
+// profile specified using --profile. The defaults must be read before the args
+// are parsed, so the args are parsed separately to find the config file.
+func configFromArgs(args []string) (configFile, profile string) {
+	argsParser, argsOnly := newParser()
+	argsParser.ParseArgs(args)
+	return argsOnly.ConfigFile, argsOnly.Profile
+}
+
 // findBestConfigFile finds the best config file to use. An empty string will be
 // returned if no config file should be used.
 func findBestConfigFile() string {
 	app := xdg.App{Name: "yab"}
 
-	// Find the best config path to use, preferring the user's config path and
-	// falling back to the system config path.
-	configPaths := []string{app.ConfigPath("defaults.ini")}
+	// Find the best config path to use, preferring a config file in the current
+	// directory, then the user's config path and falling back to the system
+	// config path.
+	configPaths := []string{_localConfigFile, app.ConfigPath("defaults.ini")}
 	configPaths = append(configPaths, app.SystemConfigPaths("defaults.ini")...)
 	var configFile string
 	for _, path := range configPaths {
@@ -262,19 +371,42 @@ func findBestConfigFile() string {
 	return configFile
 }
 
-// parseDefaultConfigs reads defaults from ~/.config/yab/defaults.ini if they're
-// available.
-func parseDefaultConfigs(parser *flags.Parser) error {
-	configFile := findBestConfigFile()
+// parseDefaultConfigs reads defaults from the given config file, or from the
+// best config file, such as ~/.config/yab/defaults.ini, if there is one.
+// If a profile is specified, the options of the profile are read on top of
+// the defaults.
+func parseDefaultConfigs(parser *flags.Parser, opts *Options, configFile, profile string) error {
+	if configFile == "" {
+		configFile = findBestConfigFile()
+	}
 	if configFile == "" {
+		if profile != "" {
+			return fmt.Errorf("profile %q not found, no config file was found", profile)
+		}
 		return nil // no defaults file was found
 	}
 
-	iniParser := flags.NewIniParser(parser)
-	if err := iniParser.ParseFile(configFile); err != nil {
+	contents, err := ioutil.ReadFile(configFile)
+	if err != nil {
 		return fmt.Errorf("couldn't read %v: %v", configFile, err)
 	}
 
+	split := splitProfiles(contents)
+	if err := parseIni(parser, configFile, split.defaults); err != nil {
+		return fmt.Errorf("couldn't read %v: %v", configFile, err)
+	}
+
+	if profile == "" {
+		return nil
+	}
+	profileContents, ok := split.profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %v, available profiles: %v", profile, configFile, sorted.MapKeys(split.profiles))
+	}
+	if err := applyProfile(parser, opts, configFile, profileContents); err != nil {
+		return fmt.Errorf("couldn't read profile %q in %v: %v", profile, configFile, err)
+	}
+
 	return nil
 }
 
@@ -286,134 +418,858 @@ func runWithOptions(opts Options, out output, logger *zap.Logger) {
 		return
 	}
 
+	rOpts, err := withoutThrift(opts.ROpts)
+	if err != nil {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", err)
+	}
+	opts.ROpts = withoutDefaultThrift(rOpts, out)
+
+	if opts.ROpts.ThriftMethodList {
+		if err := listThriftMethods(out, opts.ROpts); err != nil {
+			out.Fatalf("Failed to list methods: %v\n", err)
+		}
+		return
+	}
+
+	if opts.ROpts.OutputFormat == outputFormatJSON {
+		out = jsonOutput{out, opts.ROpts.Procedure}
+	} else if colorEnabled(out, opts.ROpts.NoColor) {
+		out = colorOutput{out}
+	}
+
+	if opts.ROpts.RequestList != "" && (opts.ROpts.RequestJSON != "" || opts.ROpts.RequestFile != "") {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errRequestAndList)
+	}
+	if opts.ROpts.Select != "" && (opts.ROpts.RawOutput || opts.ROpts.RawOutputHex) {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errSelectAndRaw)
+	}
+	if opts.ROpts.CheckOnly && (opts.ROpts.Select != "" || opts.ROpts.RawOutput || opts.ROpts.RawOutputHex) {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errCheckOnlyAndOutput)
+	}
+	if len(opts.ROpts.Expect) > 0 && (opts.ROpts.CheckOnly || opts.ROpts.RawOutput || opts.ROpts.RawOutputHex) {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errExpectAndRaw)
+	}
+	if _, err := parseExpectations(opts.ROpts.Expect); err != nil {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", err)
+	}
+	if opts.ROpts.ThriftFile == thrift.StdinFile && readsStdin(opts.ROpts) {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errThriftAndBodyStdin)
+	}
+
+	// The random peer selection used by the HTTP transport and when
+	// distributing benchmark connections across peers uses its own source,
+	// so seeding it doesn't affect other users of math/rand.
+	opts.TOpts.rand = transport.NewRand(opts.TOpts.getSeed())
+
 	reqInput, err := getRequestInput(opts.ROpts.RequestJSON, opts.ROpts.RequestFile)
 	if err != nil {
-		out.Fatalf("Failed while loading body input: %v\n", err)
+		stageFatalf(out, stageParsing, "Failed while loading body input: %v\n", err)
+	}
+	if opts.ROpts.ThriftMethodArgs != "" {
+		if len(reqInput) > 0 || len(opts.ROpts.Fields) > 0 || opts.ROpts.RequestList != "" {
+			stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errMethodArgsAndBody)
+		}
+		reqInput = []byte(opts.ROpts.ThriftMethodArgs)
+	}
+	reqInput, err = expandEnv(reqInput, opts.ROpts.AllowMissingEnv)
+	if err != nil {
+		stageFatalf(out, stageParsing, "Failed while loading body input: %v\n", err)
 	}
 
 	headers, err := getHeaders(opts.ROpts.HeadersJSON, opts.ROpts.HeadersFile, opts.ROpts.Headers)
 	if err != nil {
-		out.Fatalf("Failed while loading headers input: %v\n", err)
+		stageFatalf(out, stageParsing, "Failed while loading headers input: %v\n", err)
+	}
+
+	if opts.ROpts.RequestRaw != "" {
+		if len(reqInput) > 0 || len(opts.ROpts.Fields) > 0 || opts.ROpts.RequestList != "" || opts.ROpts.BodyTemplate || opts.BOpts.Mix != "" {
+			stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errRequestRawAndBody)
+		}
+	}
+
+	// With --mix, the method and request body for the initial request are
+	// taken from the first entry in the mix.
+	var mix []mixEntry
+	if opts.BOpts.Mix != "" {
+		if opts.ROpts.Procedure != "" || len(reqInput) > 0 || opts.ROpts.RequestList != "" || len(opts.ROpts.Fields) > 0 {
+			stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errMixAndRequest)
+		}
+		mix, err = readBenchmarkMix(opts.BOpts.Mix)
+		if err != nil {
+			stageFatalf(out, stageParsing, "Failed while loading benchmark mix: %v\n", err)
+		}
+		opts.ROpts.Procedure = mix[0].Method
+		if reqInput, err = mix[0].body(); err == nil {
+			reqInput, err = expandEnv(reqInput, opts.ROpts.AllowMissingEnv)
+		}
+		if err != nil {
+			stageFatalf(out, stageParsing, "Failed while loading benchmark mix: %v\n", err)
+		}
 	}
 
 	serializer, err := NewSerializer(opts.ROpts)
 	if err != nil {
-		out.Fatalf("Failed while parsing input: %v\n", err)
+		stageFatalf(out, stageParsing, "Failed while parsing input: %v\n", err)
+	}
+	if w, ok := serializer.(warner); ok {
+		printWarnings(out, w.Warnings())
+	}
+	if opts.ROpts.ThriftMethodArgs != "" && serializer.Encoding() != encoding.Thrift {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errMethodArgsThrift)
+	}
+
+	// A timeout specified by the user takes precedence over the method's
+	// timeout in the Thrift file.
+	if opts.ROpts.ThriftSpecTimeout && !opts.ROpts.explicitTimeout {
+		timeout, err := specTimeout(serializer)
+		if err != nil {
+			stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", err)
+		}
+		if timeout > 0 {
+			opts.ROpts.Timeout = timeMillisFlag(timeout)
+		}
+	}
+
+	if len(opts.ROpts.Fields) > 0 {
+		if len(reqInput) > 0 || opts.ROpts.RequestList != "" {
+			stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errFieldsAndBody)
+		}
+		reqInput, err = getFieldsInput(opts.ROpts.Fields, serializer)
+		if err != nil {
+			stageFatalf(out, stageParsing, "Failed while loading body input: %v\n", err)
+		}
+	}
+
+	// With --template, a fresh request body is rendered for each request,
+	// starting with the initial request.
+	var bodyTmpl *bodyTemplate
+	if opts.ROpts.BodyTemplate {
+		if opts.ROpts.RequestList != "" || len(mix) > 0 {
+			stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errTemplateAndList)
+		}
+		if bodyTmpl, err = newBodyTemplate(reqInput); err == nil {
+			reqInput, err = bodyTmpl.render()
+		}
+		if err != nil {
+			stageFatalf(out, stageParsing, "Failed while loading body input: %v\n", err)
+		}
+	}
+
+	if opts.ROpts.Validate {
+		validateRequest(out, serializer, reqInput)
+		return
+	}
+	if opts.ROpts.Describe {
+		describeRequest(out, serializer)
+		return
+	}
+
+	if opts.ROpts.Count < 0 {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errNegativeCount)
+	}
+	if opts.ROpts.OverallTimeout < 0 {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errNegativeOverall)
+	}
+	if opts.TOpts.DialTimeout < 0 {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errNegativeDial)
+	}
+	if opts.ROpts.RetryLimit < 0 {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errNegativeRetryLimit)
+	}
+	if opts.ROpts.Count > 0 && opts.BOpts.enabled() {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errCountAndBenchmark)
+	}
+
+	// The health endpoint is per-service, so the health check is made against
+	// the service specified by the user.
+	if opts.ROpts.Health && opts.TOpts.ServiceName == "" {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errHealthNoService)
+	}
+	if opts.ROpts.ThriftRemoteMethods && opts.TOpts.ServiceName == "" {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errRemoteNoService)
 	}
 
 	if opts.TOpts.CallerName != "" {
+		if strings.TrimSpace(opts.TOpts.CallerName) == "" {
+			stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errBlankCallerName)
+		}
 		if _, ok := warningCallerNames[opts.TOpts.CallerName]; ok {
 			// TODO: when logger is hooked up this should use the WARN level message
 			out.Warnf("WARNING: Deprecated caller name: %q Please change the caller name as it will be blocked in the next release.\n", opts.TOpts.CallerName)
 		}
 		if _, ok := blockedCallerNames[opts.TOpts.CallerName]; ok {
-			out.Fatalf("Disallowed caller name: %v", opts.TOpts.CallerName)
+			stageFatalf(out, stageParsing, "Disallowed caller name: %v", opts.TOpts.CallerName)
 		}
 		if opts.BOpts.enabled() {
-			out.Fatalf("Cannot override caller name when running benchmarks\n")
+			stageFatalf(out, stageParsing, "Cannot override caller name when running benchmarks\n")
 		}
 	} else {
-		opts.TOpts.CallerName = "yab-" + os.Getenv("USER")
+		opts.TOpts.CallerName = defaultCallerName()
 	}
 
 	tracer, closer := getTracer(opts, out)
 	if closer != nil {
-		defer closer.Close()
+		var once sync.Once
+		flush := func() { once.Do(func() { closer.Close() }) }
+		defer flush()
+
+		// Deferred functions are not run when exiting on a failure, so the
+		// output flushes any spans that have not been reported first.
+		out = flushOutput{out, flush}
+	}
+	if opts.TOpts.reportsSpans() {
+		opts.TOpts.tracer = tracer
+	}
+
+	// The auth tokens are shared by the transports used for benchmarks.
+	opts.TOpts.authTokens, err = newAuthTokens(opts.TOpts)
+	if err != nil {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", err)
 	}
 
 	// transport abstracts the underlying wire protocol used to make the call.
-	transport, err := getTransport(opts.TOpts, serializer.Encoding(), tracer)
+	transport, err := getTransport(opts.TOpts, serializer.Encoding(), tracer, logger)
 	if err != nil {
-		out.Fatalf("Failed while parsing options: %v\n", err)
+		stageFatalf(out, stageTransport, "Failed while parsing options: %v\n", err)
 	}
 
+	opts.ROpts.requestIDs = newRequestIDs(opts.ROpts.RequestIDPrefix, transport.Protocol())
+
 	serializer = withTransportSerializer(transport.Protocol(), serializer, opts.ROpts)
 
 	// req is the transport.Request that will be used to make a call.
-	req, err := serializer.Request(reqInput)
+	var req *transport.Request
+	if opts.ROpts.RequestRaw != "" {
+		req, err = rawRequest(serializer, opts.ROpts.RequestRaw)
+	} else {
+		req, err = serializer.Request(reqInput)
+	}
 	if err != nil {
-		out.Fatalf("Failed while parsing request input: %v\n", err)
+		stageFatalf(out, stageSerialization, "Failed while parsing request input: %v\n", err)
 	}
 	req, err = prepareRequest(req, headers, opts)
 	if err != nil {
-		out.Fatalf("Failed while preparing the request: %v\n", err)
+		stageFatalf(out, stageSerialization, "Failed while preparing the request: %v\n", err)
 	}
 
-	// Only make the request if the user hasn't specified 0 warmup.
-	if !(opts.BOpts.enabled() && opts.BOpts.WarmupRequests == 0) {
-		makeInitialRequest(out, transport, serializer, req)
+	var newRequest func() (*transport.Request, error)
+	if bodyTmpl != nil {
+		newRequest = func() (*transport.Request, error) {
+			body, err := bodyTmpl.render()
+			if err != nil {
+				return nil, err
+			}
+			req, err := serializer.Request(body)
+			if err != nil {
+				return nil, err
+			}
+			return prepareRequest(req, headers, opts)
+		}
 	}
 
-	runBenchmark(out, logger, opts, benchmarkMethod{
-		serializer: serializer,
-		req:        req,
-	})
+	// Benchmarks cycle through the requests in the request list, which are
+	// serialized up front. The first request is used for the initial request.
+	var reqList []*transport.Request
+	if opts.ROpts.RequestList != "" {
+		reqList, err = loadRequestList(serializer, headers, opts)
+		if err != nil {
+			stageFatalf(out, stageSerialization, "Failed while loading request list: %v\n", err)
+		}
+		req = reqList[0]
+	}
+
+	if opts.ROpts.DryRun {
+		// The auth header is added by the transport, so it's added here to
+		// print the request as it would be sent. The token is redacted unless
+		// the user asks for it.
+		if at, ok := transport.(authTransport); ok {
+			authRequest := at.redactedRequest
+			if opts.ROpts.DryRunShowAuth {
+				authRequest = at.authRequest
+			}
+			if req, err = authRequest(req); err != nil {
+				stageFatalf(out, stageTransport, "Failed to get auth token: %v\n", err)
+			}
+		}
+		printDryRun(out, req, opts.ROpts.OutputFormat)
+		return
+	}
+	if opts.ROpts.ThriftRemoteMethods {
+		listRemoteMethods(out, transport, serializer, req, opts.ROpts, logger)
+		return
+	}
+
+	// With --quiet, responses are not printed, but failures are still reported.
+	// With --output, responses are written to the file even if --quiet is set.
+	responseOut := out
+	if opts.ROpts.OutputFile != "" {
+		fileOut, err := newFileOutput(out, opts.ROpts.OutputFile)
+		if err != nil {
+			stageFatalf(out, stageOutput, "Failed to open output file: %v\n", err)
+		}
+		defer fileOut.Close()
+		responseOut = fileOut
+	} else if opts.ROpts.Quiet {
+		responseOut = quietOutput{out}
+	}
+
+	// Only make the request if the benchmark warms up connections.
+	if !(opts.BOpts.enabled() && opts.BOpts.warmupDone(0, 0)) {
+		makeInitialRequest(responseOut, transport, serializer, req, opts.ROpts, 1 /* seq */, logger)
+	}
+
+	// Any additional requests specified using --count are made sequentially.
+	for i := 2; i <= opts.ROpts.Count; i++ {
+		if newRequest != nil {
+			if req, err = newRequest(); err != nil {
+				stageFatalf(out, stageSerialization, "Failed while preparing the request: %v\n", err)
+			}
+		}
+		makeInitialRequest(responseOut, transport, serializer, req, opts.ROpts, i, logger)
+	}
+
+	if len(mix) > 0 {
+		benchMix, err := newBenchmarkMix(mix, transport.Protocol(), headers, opts)
+		if err != nil {
+			stageFatalf(out, stageSerialization, "Failed while loading benchmark mix: %v\n", err)
+		}
+		runMixBenchmark(out, logger, opts, benchMix)
+		return
+	}
+
+	m := newBenchmarkMethod(serializer, req, reqList)
+	m.newRequest = newRequest
+	m.requestIDs = opts.ROpts.requestIDs
+	runBenchmark(out, logger, opts, m)
+}
+
+// loadRequestList serializes each request body in the request list.
+func loadRequestList(serializer encoding.Serializer, headers map[string]string, opts Options) ([]*transport.Request, error) {
+	bodies, err := getRequestList(opts.ROpts.RequestList)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]*transport.Request, len(bodies))
+	for i, body := range bodies {
+		body, err = expandEnv(body, opts.ROpts.AllowMissingEnv)
+		if err != nil {
+			return nil, fmt.Errorf("request %v: %v", i+1, err)
+		}
+
+		req, err := serializer.Request(body)
+		if err != nil {
+			return nil, fmt.Errorf("request %v: %v", i+1, err)
+		}
+		if reqs[i], err = prepareRequest(req, headers, opts); err != nil {
+			return nil, fmt.Errorf("request %v: %v", i+1, err)
+		}
+	}
+	return reqs, nil
+}
+
+// readsStdin returns whether the request body or headers are read from stdin.
+func readsStdin(rOpts RequestOptions) bool {
+	for _, input := range []string{rOpts.RequestJSON, rOpts.RequestFile, rOpts.HeadersJSON, rOpts.HeadersFile} {
+		if input == "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRequest serializes the request input without making a call, and
+// reports whether the input is a valid request for the method.
+func validateRequest(out output, serializer encoding.Serializer, reqInput []byte) {
+	if _, err := serializer.Request(reqInput); err != nil {
+		stageFatalf(out, stageSerialization, "Request is invalid: %v\n", err)
+	}
+	out.Printf("Request is valid\n")
+}
+
+// describeRequest prints a JSON template of the request for the method.
+func describeRequest(out output, serializer encoding.Serializer) {
+	d, ok := serializer.(describer)
+	if !ok {
+		stageFatalf(out, stageParsing, "Failed while parsing options: %v\n", errDescribeNotThrift)
+	}
+
+	bs, err := json.MarshalIndent(d.DescribeRequest(), "", "  ")
+	if err != nil {
+		stageFatalf(out, stageSerialization, "Failed to convert request template to JSON: %v\n", err)
+	}
+	out.Printf("%s\n", bs)
+}
+
+// listThriftMethods prints the signature of every method in each service
+// defined in the Thrift file.
+func listThriftMethods(out output, rOpts RequestOptions) error {
+	if rOpts.ThriftFile == "" {
+		return encoding.ErrSpecifyThriftFile
+	}
+
+	var (
+		parsed   *compile.Module
+		warnings []error
+		err      error
+	)
+	if rOpts.ThriftNoValidate {
+		parsed, warnings, err = thrift.ParseLenient(rOpts.ThriftFile, rOpts.ThriftIncludePaths...)
+	} else {
+		parsed, err = thrift.Parse(rOpts.ThriftFile, rOpts.ThriftIncludePaths...)
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse Thrift file: %v", err)
+	}
+	printWarnings(out, warnings)
+	printThriftMethods(out, parsed)
+	return nil
+}
+
+// listRemoteMethods calls the server's Meta::thriftIDL endpoint, and prints
+// the signature of every method in each service defined in the returned IDL.
+func listRemoteMethods(out output, t transport.Transport, serializer encoding.Serializer, req *transport.Request, rOpts RequestOptions, logger *zap.Logger) {
+	response, err := makeRequestWithRetries(t, req, rOpts, logger)
+	if dialErr, ok := asDialError(err); ok {
+		stageFatalf(out, stageTransport, "Failed while connecting to %v: %v\n", dialErr.Addr, dialErr.Err)
+	}
+	if err != nil {
+		stageFatalf(out, stageTransport, "Failed to list remote methods, the server may not support introspection using Meta::thriftIDL, so specify the Thrift file using --thrift: %v\n", err)
+	}
+
+	responseMap, err := serializer.Response(response)
+	if err != nil {
+		stageFatalf(out, stageSerialization, "Failed while parsing response: %v\n", err)
+	}
+	idls, entryPoint, err := thriftIDLsFromResponse(responseMap)
+	if err != nil {
+		stageFatalf(out, stageSerialization, "Failed while parsing response: %v\n", err)
+	}
+
+	parsed, err := thrift.ParseIDLs(idls, entryPoint)
+	if err != nil {
+		stageFatalf(out, stageSerialization, "Failed to parse the Thrift IDL returned by the server: %v\n", err)
+	}
+	printThriftMethods(out, parsed)
+}
+
+// thriftIDLsFromResponse returns the IDL files and the entry point from a
+// decoded Meta::thriftIDL response.
+func thriftIDLsFromResponse(responseMap interface{}) (map[string]string, string, error) {
+	response, _ := responseMap.(map[string]interface{})
+	result, ok := response["result"].(map[string]interface{})
+	if !ok {
+		return nil, "", fmt.Errorf("missing result in response: %v", responseMap)
+	}
+
+	entryPoint, _ := result["entryPoint"].(string)
+	if entryPoint == "" {
+		return nil, "", errors.New("server did not return the entry point of its Thrift IDL")
+	}
+
+	files, _ := result["idls"].(map[string]interface{})
+	idls := make(map[string]string, len(files))
+	for name, contents := range files {
+		s, ok := contents.(string)
+		if !ok {
+			return nil, "", fmt.Errorf("unexpected contents for IDL %q: %v", name, contents)
+		}
+		idls[name] = s
+	}
+	return idls, entryPoint, nil
+}
+
+// printThriftMethods prints the signature of every method in each service
+// defined in the Thrift module.
+func printThriftMethods(out output, parsed *compile.Module) {
+	for _, svcName := range sorted.MapKeys(parsed.Services) {
+		svc := parsed.Services[svcName]
+		if svc.Parent != nil {
+			out.Printf("service %v extends %v\n", svc.Name, svc.Parent.Name)
+		} else {
+			out.Printf("service %v\n", svc.Name)
+		}
+
+		for _, fName := range sorted.MapKeys(svc.Functions) {
+			out.Printf("  %v\n", thrift.FunctionSignature(svc.Functions[fName]))
+		}
+	}
+}
+
+// printDryRun prints the request that would be sent, including a dump of the
+// serialized body, so it can be compared against captured traffic.
+func printDryRun(out output, req *transport.Request, format string) {
+	if format == outputFormatJSON {
+		// The body is encoded as base64 by encoding/json.
+		bs, err := json.Marshal(map[string]interface{}{
+			"service":          req.TargetService,
+			"method":           req.Method,
+			"headers":          req.Headers,
+			"baggage":          req.Baggage,
+			"transportHeaders": req.TransportHeaders,
+			"shardKey":         req.ShardKey,
+			"oneway":           req.Oneway,
+			"body":             req.Body,
+		})
+		if err != nil {
+			stageFatalf(out, stageSerialization, "Failed to convert request to JSON: %v\n", err)
+		}
+		out.Printf("%s\n", bs)
+		return
+	}
+
+	out.Printf("Service: %v\n", req.TargetService)
+	out.Printf("Method:  %v\n", req.Method)
+	if req.ShardKey != "" {
+		out.Printf("Shard key: %v\n", req.ShardKey)
+	}
+	if req.Oneway {
+		out.Printf("Oneway:  true\n")
+	}
+	printDryRunHeaders(out, "Headers", req.Headers)
+	printDryRunHeaders(out, "Baggage", req.Baggage)
+	printDryRunHeaders(out, "Transport headers", req.TransportHeaders)
+	out.Printf("Body:    %v bytes\n", len(req.Body))
+	if len(req.Body) > 0 {
+		out.Printf("Base64:  %v\n", base64.StdEncoding.EncodeToString(req.Body))
+		out.Printf("%s", hex.Dump(req.Body))
+	}
+}
+
+func printDryRunHeaders(out output, name string, headers map[string]string) {
+	if len(headers) == 0 {
+		return
+	}
+	out.Printf("%v:\n", name)
+	for _, k := range sorted.MapKeys(headers) {
+		out.Printf("  %v: %v\n", k, headers[k])
+	}
+}
+
+type warner interface {
+	Warnings() []error
+}
+
+// printWarnings prints each warning to stderr.
+func printWarnings(out output, warnings []error) {
+	for _, w := range warnings {
+		out.Warnf("WARNING: %v\n", w)
+	}
+}
+
+type describer interface {
+	DescribeRequest() interface{}
 }
 
 type noEnveloper interface {
 	WithoutEnvelopes() encoding.Serializer
 }
 
+type numericEnumer interface {
+	WithNumericEnums() encoding.Serializer
+}
+
+type i64Stringer interface {
+	WithI64AsString() encoding.Serializer
+}
+
+type lenientDecoder interface {
+	WithLenientDecode() encoding.Serializer
+}
+
+type constReferencer interface {
+	WithConstRefs() encoding.Serializer
+}
+
+type annotator interface {
+	Annotations() map[string]string
+}
+
+// specTimeout returns the timeout in the annotations of the method, or 0 if
+// the method has no timeout annotation.
+func specTimeout(serializer encoding.Serializer) (time.Duration, error) {
+	a, ok := serializer.(annotator)
+	if !ok {
+		return 0, errSpecTimeoutThrift
+	}
+
+	value, ok := a.Annotations()[_timeoutAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	timeout, err := parseDurationMillis(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid %v annotation %q, must be a positive duration", _timeoutAnnotation, value)
+	}
+	return timeout, nil
+}
+
+type rawRequester interface {
+	RawRequest(body []byte) *transport.Request
+}
+
+// rawRequest returns a request that uses the pre-serialized request body in
+// the given file as is.
+func rawRequest(serializer encoding.Serializer, file string) (*transport.Request, error) {
+	rr, ok := serializer.(rawRequester)
+	if !ok {
+		return nil, errRequestRawThrift
+	}
+
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read raw request body: %v", err)
+	}
+	return rr.RawRequest(body), nil
+}
+
+// defaultCallerName returns the caller name used when one isn't specified,
+// which includes the current user if it's known.
+func defaultCallerName() string {
+	if user := os.Getenv("USER"); user != "" {
+		return "yab-" + user
+	}
+	return "yab"
+}
+
 func getTracer(opts Options, out output) (opentracing.Tracer, io.Closer) {
 	var (
 		tracer opentracing.Tracer = opentracing.NoopTracer{}
 		closer io.Closer
 	)
-	if opts.TOpts.Jaeger && !opts.TOpts.NoJaeger {
-		tracer, closer = jaeger.NewTracer(opts.TOpts.CallerName, jaeger.NewConstSampler(true), jaeger.NewNullReporter())
-	} else if len(opts.ROpts.Baggage) > 0 {
-		out.Fatalf("To propagate baggage, you must opt-into a tracing client, i.e., --jaeger")
+	jaegerEnabled := opts.TOpts.Jaeger || opts.TOpts.Trace || opts.TOpts.reportsSpans()
+	if jaegerEnabled && !opts.TOpts.NoJaeger {
+		if opts.TOpts.JaegerAgent != "" && opts.TOpts.TracingEndpoint != "" {
+			out.Fatalf("Cannot use both --jaeger-agent and --tracing-endpoint\n")
+		}
+
+		reporter := jaeger.NewNullReporter()
+		if opts.TOpts.JaegerAgent != "" {
+			sender, err := jaeger.NewUDPTransport(opts.TOpts.JaegerAgent, 0 /* maxPacketSize */)
+			if err != nil {
+				out.Fatalf("Failed to create Jaeger agent reporter: %v\n", err)
+			}
+			reporter = jaeger.NewRemoteReporter(sender)
+		}
+		if opts.TOpts.TracingEndpoint != "" {
+			url, err := tracingEndpointURL(opts.TOpts.TracingEndpoint)
+			if err != nil {
+				out.Fatalf("Failed to create tracing endpoint reporter: %v\n", err)
+			}
+			reporter = jaeger.NewRemoteReporter(jaeger_transport.NewHTTPTransport(url))
+		}
+		tracer, closer = jaeger.NewTracer(opts.TOpts.CallerName, jaeger.NewConstSampler(true), reporter)
 	}
 	return tracer, closer
 }
 
+// tracingEndpointURL returns the URL that spans are sent to for the given
+// --tracing-endpoint, which is either the host:port of a Jaeger collector,
+// or a full URL.
+func tracingEndpointURL(endpoint string) (string, error) {
+	if strings.Contains(endpoint, "://") {
+		return endpoint, nil
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return "", fmt.Errorf("invalid tracing endpoint %q: %v", endpoint, err)
+	}
+	return "http://" + endpoint + _jaegerCollectorPath, nil
+}
+
 // withTransportSerializer may modify the serializer for the transport used.
 // E.g. Thrift payloads are not enveloped when used with TChannel or gRPC.
 func withTransportSerializer(p transport.Protocol, s encoding.Serializer, rOpts RequestOptions) encoding.Serializer {
-	switch {
-	case (p == transport.TChannel || p == transport.GRPC) && s.Encoding() == encoding.Thrift,
-		rOpts.ThriftDisableEnvelopes:
-		s = s.(noEnveloper).WithoutEnvelopes()
+	// Envelopes are only used by Thrift, so Thrift options are ignored
+	// for other encodings such as raw and JSON, which don't need a spec.
+	if ne, ok := s.(noEnveloper); ok && disableEnvelopes(p, s, rOpts) {
+		s = ne.WithoutEnvelopes()
+	}
+	if ne, ok := s.(numericEnumer); ok && rOpts.ThriftNumericEnums {
+		s = ne.WithNumericEnums()
+	}
+	// i64 values are printed as strings by default, since JSON numbers
+	// lose precision above 2^53.
+	if is, ok := s.(i64Stringer); ok && !rOpts.ThriftNoI64AsString {
+		s = is.WithI64AsString()
+	}
+	if ld, ok := s.(lenientDecoder); ok && rOpts.ThriftLenientDecode {
+		s = ld.WithLenientDecode()
+	}
+	if cr, ok := s.(constReferencer); ok && rOpts.ThriftConstRefs {
+		s = cr.WithConstRefs()
 	}
 	return s
 }
 
+// disableEnvelopes returns whether Thrift envelopes are disabled, either
+// using --disable-thrift-envelope, or since Thrift over TChannel and gRPC
+// doesn't use envelopes.
+func disableEnvelopes(p transport.Protocol, s encoding.Serializer, rOpts RequestOptions) bool {
+	if rOpts.ThriftDisableEnvelopes {
+		return true
+	}
+	return (p == transport.TChannel || p == transport.GRPC) && s.Encoding() == encoding.Thrift
+}
+
 // makeRequest makes a request using the given transport.
-func makeRequest(t transport.Transport, request *transport.Request) (*transport.Response, error) {
-	return makeRequestWithTracePriority(t, request, 0)
+func makeRequest(t transport.Transport, request *transport.Request, logger *zap.Logger) (*transport.Response, error) {
+	return makeRequestWithTracePriority(t, request, 0, logger)
+}
+
+// makeRequestWithTracePriority makes a single call. Failed calls are logged
+// at the info level, and successful calls at the debug level, since
+// benchmarks make a large number of calls. The span for the call is started
+// using the given span options.
+func makeRequestWithTracePriority(t transport.Transport, request *transport.Request, trace uint16, logger *zap.Logger, spanOpts ...opentracing.StartSpanOption) (*transport.Response, error) {
+	start := time.Now()
+	res, err := client.Do(context.Background(), t, request, client.Tracing{
+		SamplingPriority: trace,
+		SpanOptions:      spanOpts,
+	})
+	fields := []zap.Field{
+		zap.String("method", request.Method),
+		zap.Duration("timeout", request.Timeout),
+		zap.Duration("duration", time.Since(start)),
+	}
+	if id, ok := requestIDOf(request); ok {
+		fields = append(fields, zap.String("requestID", id))
+	}
+	if err != nil {
+		logger.Info("Call failed.", append(fields, zap.Error(err))...)
+	} else {
+		logger.Debug("Call succeeded.", fields...)
+	}
+	return res, err
 }
 
-func makeRequestWithTracePriority(t transport.Transport, request *transport.Request, trace uint16) (*transport.Response, error) {
-	ctx, cancel := tchannel.NewContext(request.Timeout)
-	defer cancel()
+// makeRequestWithRetries makes a request, retrying calls that fail with a
+// retryable transport error up to the retry limit. Application errors are part
+// of a successful response, so they are never retried, and neither are errors
+// returned by the server, such as HTTP status codes.
+//
+// If an overall timeout is set, it bounds the total time spent across all
+// attempts and backoffs, and each attempt's timeout is capped to the time
+// remaining.
+func makeRequestWithRetries(t transport.Transport, request *transport.Request, rOpts RequestOptions, logger *zap.Logger) (*transport.Response, error) {
+	var deadline time.Time
+	if rOpts.OverallTimeout > 0 {
+		deadline = time.Now().Add(rOpts.OverallTimeout)
+	}
+
+	backoff := rOpts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		attemptReq := request
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if request.Timeout <= 0 || remaining < request.Timeout {
+				capped := *request
+				capped.Timeout = remaining
+				attemptReq = &capped
+			}
+		}
+
+		res, err := makeRequestWithTracePriority(t, attemptReq, 1, logger)
+		if err == nil {
+			return res, nil
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return nil, overallTimeoutError(rOpts.OverallTimeout, attempt+1, err)
+		}
+		if attempt >= rOpts.RetryLimit || !transport.IsRetryable(err) {
+			return res, err
+		}
+
+		if backoff > 0 && !deadline.IsZero() && time.Until(deadline) <= backoff {
+			return nil, overallTimeoutError(rOpts.OverallTimeout, attempt+1, err)
+		}
 
-	if tracer := t.Tracer(); tracer != nil {
-		span := tracer.StartSpan(request.Method)
-		opentracing_ext.SamplingPriority.Set(span, trace)
-		for k, v := range request.Baggage {
-			span = span.SetBaggageItem(k, v)
+		logger.Info("Retrying call.",
+			zap.Int("attempt", attempt+2),
+			zap.Int("retryLimit", rOpts.RetryLimit),
+			zap.Duration("backoff", backoff),
+		)
+		if backoff > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
 		}
-		ctx = opentracing.ContextWithSpan(ctx, span)
 	}
+}
+
+// overallTimeoutError distinguishes running out of overall time from a
+// single attempt timing out.
+func overallTimeoutError(timeout time.Duration, attempts int, lastErr error) error {
+	return fmt.Errorf("overall timeout of %v exceeded after %v attempt(s), last error: %v", timeout, attempts, lastErr)
+}
 
-	return t.Call(ctx, request)
+// asDialError returns whether err is a failure to connect to a peer.
+func asDialError(err error) (*transport.DialError, bool) {
+	dialErr, ok := err.(*transport.DialError)
+	return dialErr, ok
 }
 
-func makeInitialRequest(out output, transport transport.Transport, serializer encoding.Serializer, req *transport.Request) {
-	response, err := makeRequestWithTracePriority(transport, req, 1)
+// makeInitialRequest makes a request and prints the response. seq is the
+// sequence number of the request, starting at 1, when multiple requests are made.
+func makeInitialRequest(out output, transport transport.Transport, serializer encoding.Serializer, req *transport.Request, rOpts RequestOptions, seq int, logger *zap.Logger) {
+	// Retries of the call use the same request ID.
+	req, requestID := rOpts.requestIDs.withRequestID(req)
+
+	start := time.Now()
+	response, err := makeRequestWithRetries(transport, req, rOpts, logger)
+	latency := time.Since(start)
+	if dialErr, ok := asDialError(err); ok {
+		stageFatalf(out, stageTransport, "Failed while connecting to %v: %v\n", dialErr.Addr, dialErr.Err)
+	}
 	if err != nil {
-		out.Fatalf("Failed while making call: %v\n", err)
+		stageFatalf(out, stageTransport, "Failed while making call: %v\n", err)
 	}
 
-	// responseMap converts the Thrift bytes response to a map.
-	responseMap, err := serializer.Response(response)
-	if err != nil {
-		out.Fatalf("Failed while parsing response: %v\n", err)
+	if rOpts.CheckOnly {
+		// The response is not decoded, so a response that can't be decoded
+		// doesn't fail an otherwise successful call.
+		checkResponseSuccess(out, serializer, req, response, rOpts)
+		return
 	}
 
-	// Print the initial output body.
-	outSerialized := map[string]interface{}{
-		"body": responseMap,
+	if rOpts.RawOutputHex {
+		out.Printf("%s\n", hex.EncodeToString(response.Body))
+		return
+	}
+	if rOpts.RawOutput {
+		if _, err := out.Write(response.Body); err != nil {
+			stageFatalf(out, stageOutput, "Failed to write response: %v\n", err)
+		}
+		return
+	}
+
+	var responseMap interface{}
+	outSerialized := make(map[string]interface{})
+	if req.Oneway {
+		// Oneway methods have no response, so acknowledge that the request was sent.
+		outSerialized["oneway"] = true
+	} else {
+		// responseMap converts the Thrift bytes response to a map.
+		responseMap, err = serializer.Response(response)
+		if err != nil {
+			// The response headers often contain the reason for an unexpected
+			// response, so include them in the failure.
+			if len(response.Headers) > 0 {
+				headers, _ := json.Marshal(response.Headers)
+				stageFatalf(out, stageSerialization, "Failed while parsing response: %v\nResponse headers: %s\n", err, headers)
+			}
+			stageFatalf(out, stageSerialization, "Failed while parsing response: %v\n", err)
+		}
+
+		// Print the initial output body.
+		outSerialized["body"] = responseMap
+		if rOpts.ShowSize {
+			// The size is human readable, unless --bytes-raw asks for the
+			// exact number of bytes.
+			if rOpts.BytesRaw {
+				outSerialized["size"] = len(response.Body)
+			} else {
+				outSerialized["size"] = formatBytes(int64(len(response.Body)), false /* raw */)
+			}
+		}
 	}
 	if len(response.Headers) > 0 {
 		outSerialized["headers"] = response.Headers
@@ -421,11 +1277,129 @@ func makeInitialRequest(out output, transport transport.Transport, serializer en
 	for k, v := range response.TransportFields {
 		outSerialized[k] = v
 	}
-	bs, err := json.MarshalIndent(outSerialized, "", "  ")
+	if requestID != "" {
+		outSerialized["requestId"] = requestID
+	}
+	if rOpts.Select != "" {
+		printSelected(out, responseMap, rOpts)
+	} else if rOpts.OutputFormat == outputFormatTable {
+		if req.Oneway {
+			responseMap = map[string]interface{}{"oneway": true}
+		}
+		printTable(out, responseMap)
+	} else if rOpts.OutputFormat == outputFormatJSON {
+		// With --count, each line includes the sequence number and latency
+		// so the output can be consumed as a stream by tools like jq.
+		if rOpts.Count > 1 {
+			outSerialized["seq"] = seq
+			outSerialized["latencyMs"] = float64(latency) / float64(time.Millisecond)
+		}
+
+		bs, err := json.Marshal(outSerialized)
+		if err != nil {
+			stageFatalf(out, stageSerialization, "Failed to convert map to JSON: %v\nMap: %+v\n", err, responseMap)
+		}
+		out.Printf("%s\n", bs)
+	} else {
+		bs, err := json.MarshalIndent(outSerialized, "", "  ")
+		if err != nil {
+			stageFatalf(out, stageSerialization, "Failed to convert map to JSON: %v\nMap: %+v\n", err, responseMap)
+		}
+		out.Printf("%s\n\n", bs)
+	}
+
+	// Exceptions are printed as the response body, but are reported as a
+	// failure so that scripts can rely on the exit code.
+	checkResponseSuccess(out, serializer, req, response, rOpts)
+	checkResponseExpectations(out, responseMap, rOpts)
+}
+
+// checkResponseExpectations fails if the response body doesn't meet every
+// --expect expectation, listing each expectation that wasn't met.
+func checkResponseExpectations(out output, responseMap interface{}, rOpts RequestOptions) {
+	// The expectations are validated before any calls are made.
+	expectations, _ := parseExpectations(rOpts.Expect)
+	if mismatches := checkExpectations(responseMap, expectations); len(mismatches) > 0 {
+		stageFatalf(out, stageApplication, "Response did not meet expectations:\n  %v\n", strings.Join(mismatches, "\n  "))
+	}
+}
+
+// checkResponseSuccess fails if the response is an exception, unless
+// exceptions are ignored.
+func checkResponseSuccess(out output, serializer encoding.Serializer, req *transport.Request, response *transport.Response, rOpts RequestOptions) {
+	if req.Oneway || rOpts.IgnoreExceptions {
+		return
+	}
+	if err := serializer.CheckSuccess(response); err != nil {
+		stageFatalf(out, stageApplication, "Response contains an exception: %v\n", err)
+	}
+}
+
+// printSelected prints only the part of the response at the --select path.
+// Strings are printed as is so they can be used directly in scripts.
+func printSelected(out output, responseMap interface{}, rOpts RequestOptions) {
+	selected, err := selectPath(responseMap, rOpts.Select)
+	if err != nil {
+		stageFatalf(out, stageSerialization, "Failed to select from response: %v\n", err)
+	}
+
+	if s, ok := selected.(string); ok {
+		out.Printf("%s\n", s)
+		return
+	}
+
+	var bs []byte
+	if rOpts.OutputFormat == outputFormatJSON {
+		bs, err = json.Marshal(selected)
+	} else {
+		bs, err = json.MarshalIndent(selected, "", "  ")
+	}
+	if err != nil {
+		stageFatalf(out, stageSerialization, "Failed to convert selected value to JSON: %v\nValue: %+v\n", err, selected)
+	}
+	out.Printf("%s\n", bs)
+}
+
+// printTable prints the top-level fields of the response body as an aligned
+// table of names and values. Strings are printed as is, while other values,
+// including nested objects and lists, are printed as single line JSON.
+func printTable(out output, responseMap interface{}) {
+	fields, ok := responseMap.(map[string]interface{})
+
+	// Thrift return values are in the result field, so a returned struct is
+	// printed using its own fields.
+	if result, isStruct := fields["result"].(map[string]interface{}); isStruct && len(fields) == 1 {
+		fields = result
+	}
+
+	if ok && len(fields) == 0 {
+		return
+	}
+	if !ok {
+		// Responses without fields, such as raw or oneway responses, are
+		// printed as a single value.
+		out.Printf("%s\n", tableValue(responseMap))
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0 /* minwidth */, 0 /* tabwidth */, 2 /* padding */, ' ', 0 /* flags */)
+	for _, k := range sorted.MapKeys(fields) {
+		fmt.Fprintf(w, "%v\t%s\n", k, tableValue(fields[k]))
+	}
+	if err := w.Flush(); err != nil {
+		out.Fatalf("Failed to write response: %v\n", err)
+	}
+}
+
+func tableValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	bs, err := json.Marshal(v)
 	if err != nil {
-		out.Fatalf("Failed to convert map to JSON: %v\nMap: %+v\n", err, responseMap)
+		return fmt.Sprint(v)
 	}
-	out.Printf("%s\n\n", bs)
+	return string(bs)
 }
 
 // isYabTemplate is currently very conservative, it requires a file that exists
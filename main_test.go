@@ -22,11 +22,15 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -42,6 +46,9 @@ import (
 	"github.com/uber/tchannel-go/thrift"
 	"go.uber.org/thriftrw/protocol"
 	"go.uber.org/thriftrw/wire"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -102,6 +109,228 @@ func TestRunWithOptions(t *testing.T) {
 			},
 			errMsg: "while parsing request input",
 		},
+		{
+			desc: "Validate valid request without peers",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  fooMethod,
+					Validate:   true,
+				},
+			},
+			wants: []string{"Request is valid\n"},
+		},
+		{
+			desc: "Validate request with unknown field",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:  validThrift,
+					Procedure:   fooMethod,
+					RequestJSON: `{"f1": 1}`,
+					Validate:    true,
+				},
+			},
+			errMsg: "Request is invalid",
+		},
+		{
+			desc: "Fields with a request body",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:  validThrift,
+					Procedure:   fooMethod,
+					RequestJSON: `{}`,
+					Fields:      []string{"f1=1"},
+				},
+			},
+			errMsg: errFieldsAndBody.Error(),
+		},
+		{
+			desc: "Validate request built from positional arguments",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:       validThrift,
+					Procedure:        "Simple::withDefault",
+					ThriftMethodArgs: `[[4, 5]]`,
+					Validate:         true,
+				},
+			},
+			wants: []string{"Request is valid\n"},
+		},
+		{
+			desc: "Validate request with too many positional arguments",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:       validThrift,
+					Procedure:        fooMethod,
+					ThriftMethodArgs: `[1]`,
+					Validate:         true,
+				},
+			},
+			errMsg: "Request is invalid",
+		},
+		{
+			desc: "Positional arguments with a request body",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:       validThrift,
+					Procedure:        fooMethod,
+					RequestJSON:      `{}`,
+					ThriftMethodArgs: `[]`,
+				},
+			},
+			errMsg: errMethodArgsAndBody.Error(),
+		},
+		{
+			desc: "Positional arguments for JSON encoding",
+			opts: Options{
+				ROpts: RequestOptions{
+					Encoding:         encoding.JSON,
+					Procedure:        "foo",
+					ThriftMethodArgs: `[]`,
+				},
+			},
+			errMsg: errMethodArgsThrift.Error(),
+		},
+		{
+			desc: "Validate request built from fields with the wrong type",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  "Simple::withDefault",
+					Fields:     []string{"values=1"},
+					Validate:   true,
+				},
+			},
+			errMsg: "Request is invalid",
+		},
+		{
+			desc: "Check only with select",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  fooMethod,
+					CheckOnly:  true,
+					Select:     "result",
+				},
+			},
+			errMsg: errCheckOnlyAndOutput.Error(),
+		},
+		{
+			desc: "Describe request without peers",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  "Simple::withDefault",
+					Describe:   true,
+				},
+			},
+			wants: []string{"{\n  \"values\": [\n    \"<i32>\"\n  ]\n}\n"},
+		},
+		{
+			desc: "Describe request for JSON encoding",
+			opts: Options{
+				ROpts: RequestOptions{
+					Encoding:  encoding.JSON,
+					Procedure: "foo",
+					Describe:  true,
+				},
+			},
+			errMsg: errDescribeNotThrift.Error(),
+		},
+		{
+			desc: "List Thrift methods",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:       validThrift,
+					ThriftMethodList: true,
+				},
+			},
+			wants: []string{
+				"service Simple\n",
+				"  i32 bar()\n",
+				"  oneway void fire()\n",
+				"  void foo()\n",
+				"  void thriftEx() throws (1: ThriftException ex)\n",
+			},
+		},
+		{
+			desc: "List Thrift methods without a Thrift file",
+			opts: Options{
+				ROpts: RequestOptions{ThriftMethodList: true},
+			},
+			errMsg: encoding.ErrSpecifyThriftFile.Error(),
+		},
+		{
+			desc: "List Thrift methods with --no-thrift",
+			opts: Options{
+				ROpts: RequestOptions{ThriftFile: validThrift, NoThrift: true, ThriftMethodList: true},
+			},
+			errMsg: encoding.ErrSpecifyThriftFile.Error(),
+		},
+		{
+			desc: "No Thrift with the Thrift encoding",
+			opts: Options{
+				ROpts: RequestOptions{Encoding: encoding.Thrift, NoThrift: true, Procedure: fooMethod},
+			},
+			errMsg: errNoThriftEncoding.Error(),
+		},
+		{
+			desc: "Negative count",
+			opts: Options{
+				ROpts: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod, Count: -1},
+			},
+			errMsg: errNegativeCount.Error(),
+		},
+		{
+			desc: "Negative overall timeout",
+			opts: Options{
+				ROpts: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod, OverallTimeout: -time.Second},
+			},
+			errMsg: errNegativeOverall.Error(),
+		},
+		{
+			desc: "Negative dial timeout",
+			opts: Options{
+				ROpts: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod},
+				TOpts: TransportOptions{DialTimeout: -time.Second},
+			},
+			errMsg: errNegativeDial.Error(),
+		},
+		{
+			desc: "Negative retry limit",
+			opts: Options{
+				ROpts: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod, RetryLimit: -1},
+			},
+			errMsg: errNegativeRetryLimit.Error(),
+		},
+		{
+			desc: "Count with benchmark options",
+			opts: Options{
+				ROpts: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod, Count: 2},
+				BOpts: BenchmarkOptions{MaxRequests: 10},
+			},
+			errMsg: errCountAndBenchmark.Error(),
+		},
+		{
+			desc: "Health without a service",
+			opts: Options{
+				ROpts: RequestOptions{Health: true},
+				TOpts: TransportOptions{
+					Peers: []string{"1.1.1.1:1"},
+				},
+			},
+			errMsg: errHealthNoService.Error(),
+		},
+		{
+			desc: "List remote methods without a service",
+			opts: Options{
+				ROpts: RequestOptions{ThriftRemoteMethods: true},
+				TOpts: TransportOptions{
+					Peers: []string{"1.1.1.1:1"},
+				},
+			},
+			errMsg: errRemoteNoService.Error(),
+		},
 		{
 			desc: "Invalid host:port, fail to make request",
 			opts: Options{
@@ -111,139 +340,1421 @@ func TestRunWithOptions(t *testing.T) {
 					Peers:       []string{closedHP},
 				},
 			},
-			errMsg: "Failed while making call",
+			errMsg: "Failed while connecting to " + closedHP,
+		},
+		{
+			desc: "Fail to convert response, bar is non-void",
+			opts: Options{
+				ROpts: validRequestOpts,
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, []byte{1, 1})},
+				},
+			},
+			errMsg: "Failed while parsing response",
+		},
+		{
+			desc: "Fail to convert response, failure includes response headers",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:  validThrift,
+					Procedure:   fooMethod,
+					HeadersJSON: `{"reason": "overloaded"}`,
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, []byte{1, 1})},
+				},
+			},
+			errMsg: `Response headers: {"reason":"overloaded"}`,
+		},
+		{
+			desc: "Response headers are printed",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:  validThrift,
+					Procedure:   fooMethod,
+					HeadersJSON: `{"reason": "overloaded"}`,
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+				},
+			},
+			wants: []string{
+				`"headers": {`,
+				`"reason": "overloaded"`,
+			},
+		},
+		{
+			desc: "Raw output skips decoding the response",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  fooMethod,
+					RawOutput:  true,
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, []byte{1, 1})},
+				},
+			},
+			wants: []string{"\x01\x01"},
+		},
+		{
+			desc: "Hex output skips decoding the response",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:   validThrift,
+					Procedure:    fooMethod,
+					RawOutputHex: true,
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, []byte{1, 1})},
+				},
+			},
+			wants: []string{"0101\n"},
+		},
+		{
+			desc: "Fail due to timeout",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  fooMethod,
+					Timeout:    timeMillisFlag(time.Nanosecond),
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+				},
+			},
+			errMsg: "timeout",
+		},
+		{
+			desc: "Success",
+			opts: Options{
+				ROpts: validRequestOpts,
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+				},
+			},
+			wants: []string{
+				"{}",
+				`"ok": true`,
+				`"trace": "`,
+			},
+		},
+		{
+			desc: "Success with size",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  fooMethod,
+					ShowSize:   true,
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+				},
+			},
+			wants: []string{
+				`"ok": true`,
+				`"size": "1 B"`,
+			},
+		},
+		{
+			desc: "Success with raw size",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  fooMethod,
+					ShowSize:   true,
+					BytesRaw:   true,
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+				},
+			},
+			wants: []string{
+				`"ok": true`,
+				`"size": 1`,
+			},
+		},
+		{
+			desc: "Oneway method does not decode the response",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  "Simple::fire",
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, "Simple::fire", nil)},
+				},
+			},
+			wants: []string{
+				`"oneway": true`,
+				`"trace": "`,
+			},
+		},
+		{
+			desc: "JSON format success",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:   validThrift,
+					Procedure:    fooMethod,
+					OutputFormat: outputFormatJSON,
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+				},
+			},
+			wants: []string{
+				`{"body":{},"ok":true,"trace":"`,
+			},
+		},
+		{
+			desc: "JSON format failure",
+			opts: Options{
+				ROpts: RequestOptions{
+					Procedure:    fooMethod,
+					OutputFormat: outputFormatJSON,
+				},
+			},
+			errMsg: `"stage":"parsing","method":"Simple::foo"`,
+		},
+		{
+			desc: "JSON format output file failure",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:   validThrift,
+					Procedure:    fooMethod,
+					OutputFormat: outputFormatJSON,
+					OutputFile:   filepath.Join("main.go", "response.json"),
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+				},
+			},
+			errMsg: `not a directory","stage":"output"`,
+		},
+		{
+			desc: "No errors or warnings with a valid callername",
+			opts: Options{
+				ROpts: validRequestOpts,
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+					CallerName:  "valid-caller-name",
+				},
+			},
+			wants: []string{
+				"{}",
+				`"ok": true`,
+				`"trace": "`,
+			},
+		},
+		{
+			desc: "Warn on caller names from the warning map",
+			opts: Options{
+				ROpts: validRequestOpts,
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+					CallerName:  "testWarningCallerName",
+				},
+			},
+			warnMsg: "WARNING: Deprecated caller name: \"testWarningCallerName\" Please change the caller name",
+			wants: []string{
+				"{}",
+				`"ok": true`,
+				`"trace": "`,
+			},
+		},
+		{
+			desc: "Quiet still reports failures",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  fooMethod,
+					Quiet:      true,
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{closedHP},
+				},
+			},
+			errMsg: "Failed while connecting to " + closedHP,
+		},
+		{
+			desc: "Unset environment variable in the request body",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:  validThrift,
+					Procedure:   fooMethod,
+					RequestJSON: `{"key": "${YAB_TEST_UNSET_VARIABLE}"}`,
+				},
+			},
+			errMsg: "environment variables are not set: YAB_TEST_UNSET_VARIABLE",
+		},
+		{
+			desc: "Request body and request list",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:  validThrift,
+					Procedure:   fooMethod,
+					RequestJSON: "{}",
+					RequestList: "requests.json",
+				},
+			},
+			errMsg: errRequestAndList.Error(),
+		},
+		{
+			desc: "Select with raw output",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile: validThrift,
+					Procedure:  fooMethod,
+					Select:     "result",
+					RawOutput:  true,
+				},
+			},
+			errMsg: errSelectAndRaw.Error(),
+		},
+		{
+			desc: "Invalid request in request list",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:  validThrift,
+					Procedure:   fooMethod,
+					RequestList: writeFile(t, "requests", "{}\n{\"unknown\": 1}\n"),
+				},
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+				},
+			},
+			errMsg: "Failed while loading request list: request 2:",
+		},
+		{
+			desc: "Thrift file and request body from stdin",
+			opts: Options{
+				ROpts: RequestOptions{
+					ThriftFile:  "-",
+					Procedure:   fooMethod,
+					RequestJSON: "-",
+				},
+			},
+			errMsg: errThriftAndBodyStdin.Error(),
+		},
+		{
+			desc: "Blank caller name",
+			opts: Options{
+				ROpts: validRequestOpts,
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+					CallerName:  "  ",
+				},
+			},
+			errMsg: errBlankCallerName.Error(),
+		},
+		{
+			desc: "Fail on caller names from the blocking map",
+			opts: Options{
+				ROpts: validRequestOpts,
+				TOpts: TransportOptions{
+					ServiceName: "foo",
+					Peers:       []string{echoServer(t, fooMethod, nil)},
+					CallerName:  "testBlockedCallerName",
+				},
+			},
+			errMsg: "Disallowed caller name: testBlockedCallerName",
+		},
+	}
+
+	var errBuf bytes.Buffer
+	var warnBuf bytes.Buffer
+	var outBuf bytes.Buffer
+	out := testOutput{
+		Buffer: &outBuf,
+		warnf: func(format string, args ...interface{}) {
+			warnBuf.WriteString(fmt.Sprintf(format, args...))
+		},
+		fatalf: func(format string, args ...interface{}) {
+			errBuf.WriteString(fmt.Sprintf(format, args...))
+		},
+	}
+
+	for _, tt := range tests {
+		errBuf.Reset()
+		warnBuf.Reset()
+		outBuf.Reset()
+
+		runComplete := make(chan struct{})
+		// runWithOptions expects Fatalf to kill the process, so we run it in a
+		// new goroutine and testoutput.Fatalf will only exit the goroutine.
+		go func() {
+			defer close(runComplete)
+			runWithOptions(tt.opts, out, _testLogger)
+		}()
+
+		<-runComplete
+
+		if tt.errMsg != "" {
+			assert.Empty(t, outBuf.String(), "%v: should have no output", tt.desc)
+			assert.Contains(t, errBuf.String(), tt.errMsg, "%v: Invalid error", tt.desc)
+			continue
+		}
+
+		if tt.warnMsg != "" {
+			assert.Contains(t, warnBuf.String(), tt.warnMsg)
+		} else {
+			assert.Empty(t, warnBuf.String(), "%v: should have no warnings", tt.desc)
+		}
+
+		assert.Empty(t, errBuf.String(), "%v: should not error", tt.desc)
+		for _, want := range tt.wants {
+			assert.Contains(t, outBuf.String(), want, "%v: expected output", tt.desc)
+		}
+	}
+}
+
+func TestRunWithOptionsCount(t *testing.T) {
+	tests := []struct {
+		count     int
+		wantCalls int32
+	}{
+		{count: 0, wantCalls: 1},
+		{count: 1, wantCalls: 1},
+		{count: 3, wantCalls: 3},
+	}
+
+	for _, tt := range tests {
+		s := newServer(t)
+		defer s.shutdown()
+		counter, handler := methods.counter()
+		s.register(fooMethod, handler)
+
+		outBuf, _, out := getOutput(t)
+		opts := Options{
+			ROpts: RequestOptions{
+				ThriftFile: validThrift,
+				Procedure:  fooMethod,
+				Count:      tt.count,
+			},
+			TOpts: s.transportOpts(),
+		}
+
+		runComplete := make(chan struct{})
+		go func() {
+			defer close(runComplete)
+			runWithOptions(opts, out, _testLogger)
+		}()
+		<-runComplete
+
+		assert.EqualValues(t, tt.wantCalls, counter.Load(), "count %v: unexpected number of calls", tt.count)
+		assert.Equal(t, int(tt.wantCalls), strings.Count(outBuf.String(), `"ok": true`),
+			"count %v: expected a response per call", tt.count)
+	}
+}
+
+func TestRunWithOptionsNDJSON(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	outBuf, _, out := getOutput(t)
+	opts := Options{
+		ROpts: RequestOptions{
+			ThriftFile:   validThrift,
+			Procedure:    fooMethod,
+			OutputFormat: outputFormatJSON,
+			Count:        3,
+		},
+		TOpts: s.transportOpts(),
+	}
+
+	runComplete := make(chan struct{})
+	go func() {
+		defer close(runComplete)
+		runWithOptions(opts, out, _testLogger)
+	}()
+	<-runComplete
+
+	lines := strings.Split(strings.TrimSpace(outBuf.String()), "\n")
+	require.Len(t, lines, 3, "Expected a line per response")
+	for i, line := range lines {
+		var got map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &got), "Failed to unmarshal line %v", i)
+		assert.EqualValues(t, i+1, got["seq"], "Unexpected sequence number for line %v", i)
+		assert.Contains(t, got, "latencyMs", "Missing latency for line %v", i)
+	}
+}
+
+func TestRunWithOptionsRequestIDPrefix(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	outBuf, _, out := getOutput(t)
+	opts := Options{
+		ROpts: RequestOptions{
+			ThriftFile:      validThrift,
+			Procedure:       fooMethod,
+			OutputFormat:    outputFormatJSON,
+			Count:           3,
+			RequestIDPrefix: "yab-test",
+		},
+		TOpts: s.transportOpts(),
+	}
+
+	runComplete := make(chan struct{})
+	go func() {
+		defer close(runComplete)
+		runWithOptions(opts, out, _testLogger)
+	}()
+	<-runComplete
+
+	lines := strings.Split(strings.TrimSpace(outBuf.String()), "\n")
+	require.Len(t, lines, 3, "Expected a line per response")
+	for i, line := range lines {
+		var got struct {
+			RequestID string            `json:"requestId"`
+			Headers   map[string]string `json:"headers"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &got), "Failed to unmarshal line %v", i)
+
+		want := fmt.Sprintf("yab-test-%v", i+1)
+		assert.Equal(t, want, got.RequestID, "Unexpected request ID in output for line %v", i)
+		assert.Equal(t, want, got.Headers[_requestIDHeader], "Unexpected request ID sent for line %v", i)
+	}
+}
+
+func TestRunWithOptionsNoThrift(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	outBuf, _, out := getOutput(t)
+	opts := Options{
+		ROpts: RequestOptions{
+			// The Thrift file, e.g., from the config file, is ignored, and the
+			// procedure is not treated as a Thrift method.
+			ThriftFile:  validThrift,
+			NoThrift:    true,
+			Procedure:   fooMethod,
+			RequestJSON: `{"key": "value", "count": 12345678901234567890}`,
+		},
+		TOpts: s.transportOpts(),
+	}
+
+	runComplete := make(chan struct{})
+	go func() {
+		defer close(runComplete)
+		runWithOptions(opts, out, _testLogger)
+	}()
+	<-runComplete
+
+	assert.Contains(t, outBuf.String(), `"key": "value"`, "Response should be printed as JSON")
+	assert.Contains(t, outBuf.String(), `"count": 12345678901234567890`, "Numbers should not lose precision")
+}
+
+func TestRunWithOptionsRequestRaw(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("Simple::bar", methods.echo())
+
+	// The raw request is echoed back, so it's sent as is if the response
+	// contains the result, since the bar method has no arguments.
+	resultBytes := []byte{
+		8,    /* i32 */
+		0, 0, /* field ID */
+		0, 0, 0, 42, /* value */
+		0, /* STOP */
+	}
+	dir, err := ioutil.TempDir("", "yab-request-raw")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+	rawFile := filepath.Join(dir, "request.bin")
+	require.NoError(t, ioutil.WriteFile(rawFile, resultBytes, 0644), "Failed to write raw request")
+
+	tests := []struct {
+		msg     string
+		rOpts   RequestOptions
+		want    string
+		wantErr string
+	}{
+		{
+			msg:   "raw request is sent as is",
+			rOpts: RequestOptions{ThriftFile: validThrift, Procedure: "Simple::bar", RequestRaw: rawFile},
+			want:  `"result": 42`,
+		},
+		{
+			msg:     "raw request with a request body",
+			rOpts:   RequestOptions{ThriftFile: validThrift, Procedure: "Simple::bar", RequestRaw: rawFile, RequestJSON: "{}"},
+			wantErr: errRequestRawAndBody.Error(),
+		},
+		{
+			msg:     "raw request file not found",
+			rOpts:   RequestOptions{ThriftFile: validThrift, Procedure: "Simple::bar", RequestRaw: filepath.Join(dir, "missing.bin")},
+			wantErr: "failed to read raw request body",
+		},
+		{
+			msg:     "raw request is Thrift only",
+			rOpts:   RequestOptions{Encoding: encoding.JSON, Procedure: "Simple::bar", RequestRaw: rawFile},
+			wantErr: errRequestRawThrift.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			var errBuf, outBuf bytes.Buffer
+			out := testOutput{
+				Buffer: &outBuf,
+				fatalf: func(format string, args ...interface{}) {
+					errBuf.WriteString(fmt.Sprintf(format, args...))
+				},
+			}
+			opts := Options{
+				ROpts: tt.rOpts,
+				TOpts: s.transportOpts(),
+			}
+
+			runComplete := make(chan struct{})
+			go func() {
+				defer close(runComplete)
+				runWithOptions(opts, out, _testLogger)
+			}()
+			<-runComplete
+
+			if tt.wantErr != "" {
+				assert.Contains(t, errBuf.String(), tt.wantErr, "Unexpected error")
+				return
+			}
+			assert.Empty(t, errBuf.String(), "Raw request should succeed")
+			assert.Contains(t, outBuf.String(), tt.want, "Unexpected response")
+		})
+	}
+}
+
+func TestSpecTimeout(t *testing.T) {
+	tests := []struct {
+		msg     string
+		method  string
+		json    bool
+		want    time.Duration
+		wantErr string
+	}{
+		{
+			msg:    "timeout annotation",
+			method: "Simple::slow",
+			want:   50 * time.Millisecond,
+		},
+		{
+			msg:    "no annotation",
+			method: fooMethod,
+		},
+		{
+			msg:     "invalid annotation",
+			method:  "Simple::badTimeout",
+			wantErr: `invalid timeout annotation "soon"`,
+		},
+		{
+			msg:     "not Thrift",
+			method:  "echo",
+			json:    true,
+			wantErr: errSpecTimeoutThrift.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		rOpts := RequestOptions{ThriftFile: validThrift, Procedure: tt.method}
+		if tt.json {
+			rOpts = RequestOptions{Encoding: encoding.JSON, Procedure: tt.method}
+		}
+		serializer, err := NewSerializer(rOpts)
+		require.NoError(t, err, "%v: failed to create serializer", tt.msg)
+
+		got, err := specTimeout(serializer)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "%v: expected error", tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+			}
+			continue
+		}
+		assert.NoError(t, err, "%v: unexpected error", tt.msg)
+		assert.Equal(t, tt.want, got, "%v: unexpected timeout", tt.msg)
+	}
+}
+
+func TestRunWithOptionsSpecTimeout(t *testing.T) {
+	timeouts := make(chan time.Duration, 1)
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("Simple::slow", methods.timeout(timeouts))
+	s.register(fooMethod, methods.timeout(timeouts))
+
+	tests := []struct {
+		msg     string
+		method  string
+		rOpts   RequestOptions
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			msg:     "timeout from the spec",
+			method:  "Simple::slow",
+			rOpts:   RequestOptions{ThriftSpecTimeout: true, Timeout: timeMillisFlag(time.Second)},
+			wantMax: 50 * time.Millisecond,
+		},
+		{
+			msg:     "explicit timeout takes precedence",
+			method:  "Simple::slow",
+			rOpts:   RequestOptions{ThriftSpecTimeout: true, Timeout: timeMillisFlag(time.Second), explicitTimeout: true},
+			wantMin: 500 * time.Millisecond,
+		},
+		{
+			msg:     "spec timeouts disabled",
+			method:  "Simple::slow",
+			rOpts:   RequestOptions{Timeout: timeMillisFlag(time.Second)},
+			wantMin: 500 * time.Millisecond,
+		},
+		{
+			msg:     "no annotation uses the default",
+			method:  fooMethod,
+			rOpts:   RequestOptions{ThriftSpecTimeout: true, Timeout: timeMillisFlag(time.Second)},
+			wantMin: 500 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			buf, _, out := getOutput(t)
+			rOpts := tt.rOpts
+			rOpts.ThriftFile = validThrift
+			rOpts.Procedure = tt.method
+			runWithOptions(Options{ROpts: rOpts, TOpts: s.transportOpts()}, out, _testLogger)
+			assert.Contains(t, buf.String(), "{}", "Expected successful response")
+
+			timeout := <-timeouts
+			if tt.wantMax > 0 {
+				assert.True(t, timeout <= tt.wantMax, "Timeout %v should be at most %v", timeout, tt.wantMax)
+			}
+			if tt.wantMin > 0 {
+				assert.True(t, timeout >= tt.wantMin, "Timeout %v should be at least %v", timeout, tt.wantMin)
+			}
+		})
+	}
+}
+
+func TestRunWithOptionsBodyTemplate(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("echo", methods.echo())
+
+	outBuf, _, out := getOutput(t)
+	opts := Options{
+		ROpts: RequestOptions{
+			Encoding:     encoding.JSON,
+			Procedure:    "echo",
+			RequestJSON:  `{"index": {{.Index}}, "name": "{{randString 4}}"}`,
+			BodyTemplate: true,
+			OutputFormat: outputFormatJSON,
+			Count:        3,
+		},
+		TOpts: s.transportOpts(),
+	}
+
+	runComplete := make(chan struct{})
+	go func() {
+		defer close(runComplete)
+		runWithOptions(opts, out, _testLogger)
+	}()
+	<-runComplete
+
+	lines := strings.Split(strings.TrimSpace(outBuf.String()), "\n")
+	require.Len(t, lines, 3, "Expected a line per response")
+	for i, line := range lines {
+		var got struct {
+			Body map[string]interface{} `json:"body"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &got), "Failed to unmarshal line %v", i)
+		assert.EqualValues(t, i, got.Body["index"], "Each request should render a fresh body, line %v", i)
+		assert.Len(t, got.Body["name"], 4, "Unexpected random string for line %v", i)
+	}
+}
+
+func TestRunWithOptionsThriftNoValidate(t *testing.T) {
+	thriftFile := writeFile(t, "legacy", `
+		struct Legacy {
+			1: Missing m
+		}
+
+		service Simple {
+			void foo()
+		}
+	`)
+	defer os.Remove(thriftFile)
+
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	_, warnBuf, out := getOutput(t)
+	opts := Options{
+		ROpts: RequestOptions{
+			ThriftFile:       thriftFile,
+			Procedure:        fooMethod,
+			ThriftNoValidate: true,
+		},
+		TOpts: s.transportOpts(),
+	}
+
+	runComplete := make(chan struct{})
+	go func() {
+		defer close(runComplete)
+		runWithOptions(opts, out, _testLogger)
+	}()
+	<-runComplete
+
+	assert.Contains(t, warnBuf.String(), `WARNING: ignoring "Legacy" since it failed to compile`, "Missing warning for the ignored definition")
+}
+
+func TestRunWithOptionsListMethodsRemote(t *testing.T) {
+	thriftIDLs := encodeThriftIDLsResponse(map[string]string{
+		"idl/kv/kv.thrift":         `include "../shared/shared.thrift" service KeyValue extends shared.Base { string get(1: string key) }`,
+		"idl/shared/shared.thrift": "service Base { void ping() }",
+	}, "idl/kv/kv.thrift")
+
+	tests := []struct {
+		msg     string
+		handler handler
+		want    string
+		wantErr string
+	}{
+		{
+			msg:     "server returns IDL",
+			handler: methods.customArg3(thriftIDLs),
+			want: "service KeyValue extends Base\n" +
+				"  string get(1: string key)\n",
+		},
+		{
+			msg:     "server does not support introspection",
+			wantErr: "the server may not support introspection using Meta::thriftIDL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			s := newServer(t)
+			defer s.shutdown()
+			if tt.handler != nil {
+				s.register("Meta::thriftIDL", tt.handler)
+			}
+
+			var errBuf, outBuf bytes.Buffer
+			out := testOutput{
+				Buffer: &outBuf,
+				fatalf: func(format string, args ...interface{}) {
+					errBuf.WriteString(fmt.Sprintf(format, args...))
+				},
+			}
+			opts := Options{
+				ROpts: RequestOptions{ThriftRemoteMethods: true},
+				TOpts: s.transportOpts(),
+			}
+
+			runComplete := make(chan struct{})
+			go func() {
+				defer close(runComplete)
+				runWithOptions(opts, out, _testLogger)
+			}()
+			<-runComplete
+
+			if tt.wantErr != "" {
+				assert.Contains(t, errBuf.String(), tt.wantErr, "Unexpected error")
+				return
+			}
+			assert.Empty(t, errBuf.String(), "Unexpected error")
+			assert.Equal(t, tt.want, outBuf.String(), "Unexpected methods")
+		})
+	}
+}
+
+// encodeThriftIDLsResponse encodes a Meta::thriftIDL response without an
+// envelope, as used over TChannel.
+func encodeThriftIDLsResponse(idls map[string]string, entryPoint string) []byte {
+	items := make([]wire.MapItem, 0, len(idls))
+	for name, contents := range idls {
+		items = append(items, wire.MapItem{
+			Key:   wire.NewValueString(name),
+			Value: wire.NewValueString(contents),
+		})
+	}
+
+	result := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+		{ID: 1, Value: wire.NewValueMap(wire.MapItemListFromSlice(wire.TBinary, wire.TBinary, items))},
+		{ID: 2, Value: wire.NewValueString(entryPoint)},
+	}})
+	response := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{{ID: 0, Value: result}}})
+
+	buf := &bytes.Buffer{}
+	if err := protocol.Binary.Encode(response, buf); err != nil {
+		panic(fmt.Errorf("Binary.Encode(%v) failed: %v", response, err))
+	}
+	return buf.Bytes()
+}
+
+func TestRunWithOptionsOutputFile(t *testing.T) {
+	tests := []struct {
+		msg       string
+		rawOutput bool
+		quiet     bool
+		want      string
+	}{
+		{
+			msg:  "pretty",
+			want: `"body": "hello"`,
+		},
+		{
+			msg:   "quiet",
+			quiet: true,
+			want:  `"body": "hello"`,
+		},
+		{
+			msg:       "raw output",
+			rawOutput: true,
+			want:      "hello",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "output")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("echo", methods.echo())
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprint(i), "response")
+		outBuf, _, out := getOutput(t)
+		opts := Options{
+			ROpts: RequestOptions{
+				Encoding:               encoding.Raw,
+				Procedure:              "echo",
+				RequestJSON:            "hello",
+				ThriftDisableEnvelopes: true,
+				RawOutput:              tt.rawOutput,
+				Quiet:                  tt.quiet,
+				OutputFile:             path,
+			},
+			TOpts: s.transportOpts(),
+		}
+
+		runComplete := make(chan struct{})
+		go func() {
+			defer close(runComplete)
+			runWithOptions(opts, out, _testLogger)
+		}()
+		<-runComplete
+
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err, "%v: failed to read output file", tt.msg)
+		if tt.rawOutput {
+			assert.Equal(t, tt.want, string(contents), "%v: unexpected response in file", tt.msg)
+		} else {
+			assert.Contains(t, string(contents), tt.want, "%v: unexpected response in file", tt.msg)
+		}
+		assert.Empty(t, outBuf.String(), "%v: response should not be printed to stdout", tt.msg)
+	}
+}
+
+func TestRunWithOptionsDryRun(t *testing.T) {
+	tests := []struct {
+		msg       string
+		format    string
+		authToken string
+		showAuth  bool
+		want      []string
+		notWant   []string
+	}{
+		{
+			msg: "pretty",
+			want: []string{
+				"Service: foo\n",
+				"Method:  echo\n",
+				"Headers:\n  auth: token\n",
+				"Body:    5 bytes\n",
+				"Base64:  aGVsbG8=\n",
+				"68 65 6c 6c 6f",
+			},
+		},
+		{
+			msg:    "json",
+			format: outputFormatJSON,
+			want: []string{
+				`"method":"echo"`,
+				`"headers":{"auth":"token"}`,
+				`"body":"aGVsbG8="`,
+			},
+		},
+		{
+			msg:       "auth token",
+			authToken: "secret",
+			want: []string{
+				"Headers:\n  Authorization: <redacted>\n  auth: token\n",
+			},
+			notWant: []string{"secret"},
+		},
+		{
+			msg:       "auth token json",
+			format:    outputFormatJSON,
+			authToken: "secret",
+			want: []string{
+				`"headers":{"Authorization":"\u003credacted\u003e","auth":"token"}`,
+			},
+			notWant: []string{"secret"},
+		},
+		{
+			msg:       "show auth token",
+			authToken: "secret",
+			showAuth:  true,
+			want: []string{
+				"Headers:\n  Authorization: secret\n  auth: token\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s := newServer(t)
+		defer s.shutdown()
+		counter, handler := methods.counter()
+		s.register("echo", handler)
+
+		outBuf, _, out := getOutput(t)
+		opts := Options{
+			ROpts: RequestOptions{
+				Encoding:               encoding.Raw,
+				Procedure:              "echo",
+				RequestJSON:            "hello",
+				Headers:                map[string]string{"auth": "token"},
+				ThriftDisableEnvelopes: true,
+				DryRun:                 true,
+				DryRunShowAuth:         tt.showAuth,
+				OutputFormat:           tt.format,
+			},
+			TOpts: s.transportOpts(),
+		}
+		opts.TOpts.AuthToken = tt.authToken
+		opts.TOpts.AuthHeader = "Authorization"
+
+		runComplete := make(chan struct{})
+		go func() {
+			defer close(runComplete)
+			runWithOptions(opts, out, _testLogger)
+		}()
+		<-runComplete
+
+		for _, want := range tt.want {
+			assert.Contains(t, outBuf.String(), want, "%v: missing output", tt.msg)
+		}
+		for _, notWant := range tt.notWant {
+			assert.NotContains(t, outBuf.String(), notWant, "%v: unexpected output", tt.msg)
+		}
+		assert.EqualValues(t, 0, counter.Load(), "%v: dry run should not make a call", tt.msg)
+	}
+}
+
+func TestRunWithOptionsRawWithoutIDL(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("echo", methods.echo())
+
+	outBuf, _, out := getOutput(t)
+	opts := Options{
+		ROpts: RequestOptions{
+			Encoding:               encoding.Raw,
+			Procedure:              "echo",
+			RequestJSON:            "hello",
+			RawOutput:              true,
+			ThriftDisableEnvelopes: true,
+		},
+		TOpts: s.transportOpts(),
+	}
+
+	runComplete := make(chan struct{})
+	go func() {
+		defer close(runComplete)
+		runWithOptions(opts, out, _testLogger)
+	}()
+	<-runComplete
+
+	assert.Equal(t, "hello", outBuf.String(), "Raw requests should not need a Thrift file")
+}
+
+func TestRunWithOptionsSelect(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("echo", methods.echo())
+
+	tests := []struct {
+		msg     string
+		format  string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{
+			msg:  "string is printed as is",
+			path: "user.names[1]",
+			want: "bob\n",
+		},
+		{
+			msg:  "object is printed as indented JSON",
+			path: "user",
+			want: "{\n  \"id\": 1,\n  \"names\": [\n    \"alice\",\n    \"bob\"\n  ]\n}\n",
+		},
+		{
+			msg:    "object is printed on one line for JSON format",
+			format: outputFormatJSON,
+			path:   "user.names",
+			want:   "[\"alice\",\"bob\"]\n",
+		},
+		{
+			msg:     "missing path",
+			path:    "user.email",
+			wantErr: `Failed to select from response: "user.email" not found in response`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			var errBuf, outBuf bytes.Buffer
+			out := testOutput{
+				Buffer: &outBuf,
+				fatalf: func(format string, args ...interface{}) {
+					errBuf.WriteString(fmt.Sprintf(format, args...))
+				},
+			}
+			opts := Options{
+				ROpts: RequestOptions{
+					Encoding:     encoding.JSON,
+					Procedure:    "echo",
+					RequestJSON:  `{"user": {"id": 1, "names": ["alice", "bob"]}}`,
+					Select:       tt.path,
+					OutputFormat: tt.format,
+				},
+				TOpts: s.transportOpts(),
+			}
+
+			runComplete := make(chan struct{})
+			go func() {
+				defer close(runComplete)
+				runWithOptions(opts, out, _testLogger)
+			}()
+			<-runComplete
+
+			if tt.wantErr != "" {
+				assert.Empty(t, outBuf.String(), "Nothing should be printed for a missing path")
+				assert.Contains(t, errBuf.String(), tt.wantErr, "Unexpected error")
+				return
+			}
+			assert.Equal(t, tt.want, outBuf.String(), "Unexpected selected output")
+		})
+	}
+}
+
+func TestRunWithOptionsExpect(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("echo", methods.echo())
+
+	tests := []struct {
+		msg     string
+		expect  []string
+		rOpts   RequestOptions
+		wantErr string
+	}{
+		{
+			msg:    "expectations met",
+			expect: []string{"user.id=1", "user.names[1]=bob"},
 		},
 		{
-			desc: "Fail to convert response, bar is non-void",
-			opts: Options{
-				ROpts: validRequestOpts,
-				TOpts: TransportOptions{
-					ServiceName: "foo",
-					Peers:       []string{echoServer(t, fooMethod, []byte{1, 1})},
-				},
-			},
-			errMsg: "Failed while parsing response",
+			msg:     "expectation not met",
+			expect:  []string{"user.id=1", "user.names[0]=bob"},
+			wantErr: "Response did not meet expectations:\n  user.names[0]: expected bob, got \"alice\"\n",
 		},
 		{
-			desc: "Fail due to timeout",
-			opts: Options{
-				ROpts: RequestOptions{
-					ThriftFile: validThrift,
-					Procedure:  fooMethod,
-					Timeout:    timeMillisFlag(time.Nanosecond),
-				},
-				TOpts: TransportOptions{
-					ServiceName: "foo",
-					Peers:       []string{echoServer(t, fooMethod, nil)},
-				},
-			},
-			errMsg: "timeout",
+			msg:     "invalid expectation",
+			expect:  []string{"user.id"},
+			wantErr: `invalid expectation "user.id", must be of the form path=value`,
 		},
 		{
-			desc: "Success",
-			opts: Options{
-				ROpts: validRequestOpts,
-				TOpts: TransportOptions{
-					ServiceName: "foo",
-					Peers:       []string{echoServer(t, fooMethod, nil)},
+			msg:     "raw output",
+			expect:  []string{"user.id=1"},
+			rOpts:   RequestOptions{RawOutput: true},
+			wantErr: errExpectAndRaw.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			var errBuf, outBuf bytes.Buffer
+			out := testOutput{
+				Buffer: &outBuf,
+				fatalf: func(format string, args ...interface{}) {
+					errBuf.WriteString(fmt.Sprintf(format, args...))
 				},
-			},
-			wants: []string{
-				"{}",
-				`"ok": true`,
-				`"trace": "`,
-			},
+			}
+
+			rOpts := tt.rOpts
+			rOpts.Encoding = encoding.JSON
+			rOpts.Procedure = "echo"
+			rOpts.RequestJSON = `{"user": {"id": 1, "names": ["alice", "bob"]}}`
+			rOpts.Expect = tt.expect
+			opts := Options{
+				ROpts: rOpts,
+				TOpts: s.transportOpts(),
+			}
+
+			runComplete := make(chan struct{})
+			go func() {
+				defer close(runComplete)
+				runWithOptions(opts, out, _testLogger)
+			}()
+			<-runComplete
+
+			if tt.wantErr != "" {
+				assert.Contains(t, errBuf.String(), tt.wantErr, "Unexpected error")
+				return
+			}
+			assert.Empty(t, errBuf.String(), "Expectations should be met")
+			assert.Contains(t, outBuf.String(), `"alice"`, "Response should be printed")
+		})
+	}
+}
+
+func TestRunWithOptionsCheckOnly(t *testing.T) {
+	mismatchedBytes := []byte{
+		11,   /* binary */
+		0, 0, /* field ID */
+		0, 0, 0, 1, /* length */
+		'x',
+		0, /* STOP */
+	}
+	thriftExBytes := []byte{
+		12,   /* struct */
+		0, 1, /* field ID */
+		0, /* STOP */
+		0, /* STOP */
+	}
+
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("Simple::bar", methods.customArg3(mismatchedBytes))
+	s.register("Simple::thriftEx", methods.customArg3(thriftExBytes))
+
+	tests := []struct {
+		msg       string
+		procedure string
+		checkOnly bool
+		wantErr   string
+	}{
+		{
+			msg:       "response that fails to decode",
+			procedure: "Simple::bar",
+			wantErr:   "Failed while parsing response",
 		},
 		{
-			desc: "No errors or warnings with a valid callername",
-			opts: Options{
-				ROpts: validRequestOpts,
-				TOpts: TransportOptions{
-					ServiceName: "foo",
-					Peers:       []string{echoServer(t, fooMethod, nil)},
-					CallerName:  "valid-caller-name",
-				},
-			},
-			wants: []string{
-				"{}",
-				`"ok": true`,
-				`"trace": "`,
-			},
+			msg:       "response that fails to decode with check only",
+			procedure: "Simple::bar",
+			checkOnly: true,
 		},
 		{
-			desc: "Warn on caller names from the warning map",
-			opts: Options{
-				ROpts: validRequestOpts,
-				TOpts: TransportOptions{
-					ServiceName: "foo",
-					Peers:       []string{echoServer(t, fooMethod, nil)},
-					CallerName:  "testWarningCallerName",
-				},
+			msg:       "exception with check only",
+			procedure: "Simple::thriftEx",
+			checkOnly: true,
+			wantErr:   "Response contains an exception",
+		},
+	}
+
+	for _, tt := range tests {
+		var errBuf, outBuf bytes.Buffer
+		out := testOutput{
+			Buffer: &outBuf,
+			fatalf: func(format string, args ...interface{}) {
+				errBuf.WriteString(fmt.Sprintf(format, args...))
 			},
-			warnMsg: "WARNING: Deprecated caller name: \"testWarningCallerName\" Please change the caller name",
-			wants: []string{
-				"{}",
-				`"ok": true`,
-				`"trace": "`,
+		}
+
+		opts := Options{
+			ROpts: RequestOptions{
+				ThriftFile: validThrift,
+				Procedure:  tt.procedure,
+				CheckOnly:  tt.checkOnly,
+			},
+			TOpts: s.transportOpts(),
+		}
+
+		runComplete := make(chan struct{})
+		go func() {
+			defer close(runComplete)
+			runWithOptions(opts, out, _testLogger)
+		}()
+		<-runComplete
+
+		if tt.wantErr != "" {
+			assert.Contains(t, errBuf.String(), tt.wantErr, "%v: unexpected error", tt.msg)
+		} else {
+			assert.Empty(t, errBuf.String(), "%v: should not fail", tt.msg)
+		}
+		if tt.checkOnly {
+			assert.Empty(t, outBuf.String(), "%v: nothing should be printed", tt.msg)
+		}
+	}
+}
+
+func TestPrintTable(t *testing.T) {
+	tests := []struct {
+		msg      string
+		response interface{}
+		want     string
+	}{
+		{
+			msg: "flat and nested fields",
+			response: map[string]interface{}{
+				"name":     "alice",
+				"id":       1,
+				"tags":     []interface{}{"a", "b"},
+				"verified": true,
+				"address":  map[string]interface{}{"city": "SF"},
 			},
+			want: "address   {\"city\":\"SF\"}\n" +
+				"id        1\n" +
+				"name      alice\n" +
+				"tags      [\"a\",\"b\"]\n" +
+				"verified  true\n",
 		},
 		{
-			desc: "Fail on caller names from the blocking map",
-			opts: Options{
-				ROpts: validRequestOpts,
-				TOpts: TransportOptions{
-					ServiceName: "foo",
-					Peers:       []string{echoServer(t, fooMethod, nil)},
-					CallerName:  "testBlockedCallerName",
-				},
+			msg: "Thrift struct result",
+			response: map[string]interface{}{
+				"result": map[string]interface{}{"id": 1, "name": "alice"},
 			},
-			errMsg: "Disallowed caller name: testBlockedCallerName",
+			want: "id    1\nname  alice\n",
+		},
+		{
+			msg:      "Thrift scalar result",
+			response: map[string]interface{}{"result": 5},
+			want:     "result  5\n",
+		},
+		{
+			msg:      "empty response",
+			response: map[string]interface{}{},
+			want:     "",
+		},
+		{
+			msg:      "response without fields",
+			response: []interface{}{1, 2},
+			want:     "[1,2]\n",
 		},
 	}
 
-	var errBuf bytes.Buffer
-	var warnBuf bytes.Buffer
-	var outBuf bytes.Buffer
-	out := testOutput{
-		Buffer: &outBuf,
-		warnf: func(format string, args ...interface{}) {
-			warnBuf.WriteString(fmt.Sprintf(format, args...))
+	for _, tt := range tests {
+		buf, _, out := getOutput(t)
+		printTable(out, tt.response)
+		assert.Equal(t, tt.want, buf.String(), tt.msg)
+	}
+}
+
+func TestRunWithOptionsQuiet(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	counter, handler := methods.counter()
+	s.register(fooMethod, handler)
+
+	outBuf, warnBuf, out := getOutput(t)
+	opts := Options{
+		ROpts: RequestOptions{
+			ThriftFile: validThrift,
+			Procedure:  fooMethod,
+			Quiet:      true,
 		},
-		fatalf: func(format string, args ...interface{}) {
-			errBuf.WriteString(fmt.Sprintf(format, args...))
+		TOpts: s.transportOpts(),
+	}
+
+	runComplete := make(chan struct{})
+	go func() {
+		defer close(runComplete)
+		runWithOptions(opts, out, _testLogger)
+	}()
+	<-runComplete
+
+	assert.EqualValues(t, 1, counter.Load(), "quiet should still make the call")
+	assert.Empty(t, outBuf.String(), "quiet should not print the response")
+	assert.Empty(t, warnBuf.String(), "unexpected warnings")
+}
+
+func TestRunWithOptionsThriftException(t *testing.T) {
+	thriftExBytes := []byte{
+		12,   /* struct */
+		0, 1, /* field ID */
+		0, /* STOP */
+		0, /* STOP */
+	}
+
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("Simple::thriftEx", methods.customArg3(thriftExBytes))
+
+	tests := []struct {
+		ignoreExceptions bool
+		wantErr          string
+	}{
+		{
+			wantErr: "Response contains an exception: void method got exception: ex ThriftException {}",
+		},
+		{
+			ignoreExceptions: true,
 		},
 	}
 
 	for _, tt := range tests {
-		errBuf.Reset()
-		warnBuf.Reset()
-		outBuf.Reset()
+		var errBuf, outBuf bytes.Buffer
+		out := testOutput{
+			Buffer: &outBuf,
+			fatalf: func(format string, args ...interface{}) {
+				errBuf.WriteString(fmt.Sprintf(format, args...))
+			},
+		}
+
+		opts := Options{
+			ROpts: RequestOptions{
+				ThriftFile:       validThrift,
+				Procedure:        "Simple::thriftEx",
+				IgnoreExceptions: tt.ignoreExceptions,
+			},
+			TOpts: s.transportOpts(),
+		}
 
 		runComplete := make(chan struct{})
-		// runWithOptions expects Fatalf to kill the process, so we run it in a
-		// new goroutine and testoutput.Fatalf will only exit the goroutine.
 		go func() {
 			defer close(runComplete)
-			runWithOptions(tt.opts, out, _testLogger)
+			runWithOptions(opts, out, _testLogger)
 		}()
-
 		<-runComplete
 
-		if tt.errMsg != "" {
-			assert.Empty(t, outBuf.String(), "%v: should have no output", tt.desc)
-			assert.Contains(t, errBuf.String(), tt.errMsg, "%v: Invalid error", tt.desc)
-			continue
-		}
-
-		if tt.warnMsg != "" {
-			assert.Contains(t, warnBuf.String(), tt.warnMsg)
+		assert.Contains(t, outBuf.String(), `"ex": {}`, "exception should be printed as the response body")
+		if tt.wantErr != "" {
+			assert.Contains(t, errBuf.String(), tt.wantErr, "expected exception to fail")
 		} else {
-			assert.Empty(t, warnBuf.String(), "%v: should have no warnings", tt.desc)
-		}
-
-		assert.Empty(t, errBuf.String(), "%v: should not error", tt.desc)
-		for _, want := range tt.wants {
-			assert.Contains(t, outBuf.String(), want, "%v: expected output", tt.desc)
+			assert.Empty(t, errBuf.String(), "exception should be ignored")
 		}
 	}
 }
@@ -372,6 +1883,14 @@ func TestHelpOutput(t *testing.T) {
 		// Make sure we didn't leak any groff from the man-page output.
 		assert.NotContains(t, buf.String(), ".PP")
 		assert.NotContains(t, buf.String(), "~/.config/yab/defaults.ini")
+
+		// Top-level flags, such as --version, are listed together.
+		appOpts := strings.Index(buf.String(), "Application Options:")
+		reqOpts := strings.Index(buf.String(), "Request Options:")
+		require.True(t, appOpts >= 0 && appOpts < reqOpts, "Expected Application Options before Request Options")
+		for _, flag := range []string{"--version", "--config", "--profile"} {
+			assert.Contains(t, buf.String()[appOpts:reqOpts], flag, "Expected %v in the Application Options", flag)
+		}
 	}
 }
 
@@ -398,7 +1917,9 @@ func TestVersion(t *testing.T) {
 
 	buf, _, out := getOutput(t)
 	parseAndRun(out)
-	assert.Equal(t, "yab version "+versionString+"\n", buf.String(), "Version output mismatch")
+	assert.Equal(t, buildInfo(), buf.String(), "Version output mismatch")
+	assert.True(t, strings.HasPrefix(buf.String(), "yab version "+versionString+"\n"), "Version should be the first line")
+	assert.Contains(t, buf.String(), "go version: "+runtime.Version(), "Missing Go version")
 }
 
 func TestGetOptionsAlias(t *testing.T) {
@@ -504,6 +2025,17 @@ func TestAlises(t *testing.T) {
 				assert.Equal(t, "m", opts.ROpts.Procedure, "Args: %v", args)
 			},
 		},
+		{
+			args: []cmdArgs{
+				{"--rk", "rk", "--rd", "rd", "--sk", "sk"},
+				{"--routing-key", "rk", "--routing-delegate", "rd", "--shard-key", "sk"},
+			},
+			validate: func(args cmdArgs, opts *Options) {
+				assert.Equal(t, "rk", opts.TOpts.RoutingKey, "Args: %v", args)
+				assert.Equal(t, "rd", opts.TOpts.RoutingDelegate, "Args: %v", args)
+				assert.Equal(t, "sk", opts.TOpts.ShardKey, "Args: %v", args)
+			},
+		},
 		{
 			args: []cmdArgs{
 				{"--headers", "{}"},
@@ -526,32 +2058,123 @@ func TestAlises(t *testing.T) {
 			},
 		},
 		{
-			args: []cmdArgs{
-				{"-e", "json"},
-				{"--json"},
-			},
-			validate: func(args cmdArgs, opts *Options) {
-				assert.Equal(t, encoding.JSON, opts.ROpts.Encoding, "Args: %v", args)
-			},
+			args: []cmdArgs{
+				{"-e", "json"},
+				{"--json"},
+			},
+			validate: func(args cmdArgs, opts *Options) {
+				assert.Equal(t, encoding.JSON, opts.ROpts.Encoding, "Args: %v", args)
+			},
+		},
+		{
+			args: []cmdArgs{
+				{"-e", "raw"},
+				{"--raw"},
+			},
+			validate: func(args cmdArgs, opts *Options) {
+				assert.Equal(t, encoding.Raw, opts.ROpts.Encoding, "Args: %v", args)
+			},
+		},
+		{
+			args: []cmdArgs{
+				{"-d", "5s"},
+				{"--max-duration", "5s"},
+				{"--duration", "5s"},
+			},
+			validate: func(args cmdArgs, opts *Options) {
+				assert.Equal(t, 5*time.Second, opts.BOpts.MaxDuration, "Args: %v", args)
+			},
+		},
+		{
+			args: []cmdArgs{
+				{"--format", "json"},
+				{"--ndjson"},
+			},
+			validate: func(args cmdArgs, opts *Options) {
+				assert.Equal(t, outputFormatJSON, opts.ROpts.OutputFormat, "Args: %v", args)
+			},
+		},
+	}
+
+	_, _, out := getOutput(t)
+	for _, tt := range tests {
+		for _, args := range tt.args {
+			opts, err := getOptions([]string(args), out)
+			if assert.NoError(t, err, "Args: %v", args) {
+				tt.validate(args, opts)
+			}
+		}
+	}
+}
+
+func TestGetOptionsHeadersLastWins(t *testing.T) {
+	_, _, out := getOutput(t)
+	opts, err := getOptions([]string{"-H", "k:v1", "-H", "other:v", "-H", "k:v2"}, out)
+	require.NoError(t, err, "getOptions failed")
+
+	assert.Equal(t, map[string]string{
+		"k":     "v2",
+		"other": "v",
+	}, opts.ROpts.Headers, "Headers mismatch")
+}
+
+func TestGetOptionsBodyFragments(t *testing.T) {
+	tests := []struct {
+		args            []string
+		wantRequestJSON string
+		wantHeadersJSON string
+		wantErr         string
+	}{
+		{
+			args:            []string{"svc", "method", `{"name": "me"}`},
+			wantRequestJSON: `{"name": "me"}`,
+		},
+		{
+			args:            []string{"svc", "method", `{"k": "v"}`, `{"name": "me"}`},
+			wantRequestJSON: `{"name": "me"}`,
+			wantHeadersJSON: `{"k": "v"}`,
+		},
+		{
+			args:            []string{"svc", "method", "name: me"},
+			wantRequestJSON: "name: me",
+		},
+		{
+			args:            []string{"svc", "Svc::method", "name:me", "count:5"},
+			wantRequestJSON: `{"name":"me","count":5}`,
+		},
+		{
+			args:            []string{"svc", "method", "-t", "foo.thrift", "name:me"},
+			wantRequestJSON: `{"name":"me"}`,
 		},
 		{
-			args: []cmdArgs{
-				{"-e", "raw"},
-				{"--raw"},
-			},
-			validate: func(args cmdArgs, opts *Options) {
-				assert.Equal(t, encoding.Raw, opts.ROpts.Encoding, "Args: %v", args)
-			},
+			args:    []string{"svc", "Svc::method", "name:me", `{"name": "me"}`},
+			wantErr: "not a key:value body fragment",
+		},
+		{
+			args:            []string{"svc", "method", "name:me"},
+			wantRequestJSON: "name:me",
+		},
+		{
+			args:            []string{"svc", "Svc::method", "-e", "raw", "name:me"},
+			wantRequestJSON: "name:me",
 		},
 	}
 
 	_, _, out := getOutput(t)
 	for _, tt := range tests {
-		for _, args := range tt.args {
-			opts, err := getOptions([]string(args), out)
-			if assert.NoError(t, err, "Args: %v", args) {
-				tt.validate(args, opts)
+		opts, err := getOptions(tt.args, out)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "Args: %v", tt.args) {
+				assert.Contains(t, err.Error(), tt.wantErr, "Args: %v", tt.args)
 			}
+			continue
+		}
+
+		if assert.NoError(t, err, "Args: %v", tt.args) {
+			assert.Equal(t, tt.wantRequestJSON, opts.ROpts.RequestJSON,
+				"RequestJSON mismatch for %v", tt.args)
+			assert.Equal(t, tt.wantHeadersJSON, opts.ROpts.HeadersJSON,
+				"HeadersJSON mismatch for %v", tt.args)
 		}
 	}
 }
@@ -633,6 +2256,38 @@ func TestParseIniFile(t *testing.T) {
 	}
 }
 
+func TestConfigFileFlag(t *testing.T) {
+	originalConfigHome := os.Getenv(_configHomeEnv)
+	defer os.Setenv(_configHomeEnv, originalConfigHome)
+	os.Setenv(_configHomeEnv, path.Join("testdata", "ini", "invalid"))
+
+	_, _, out := getOutput(t)
+	opts, err := getOptions([]string{"--config", "testdata/ini/valid/yab/defaults.ini", "foo", "bar"}, out)
+	if assert.NoError(t, err, "--config should override the invalid default config") {
+		assert.Equal(t, 2*time.Second, opts.ROpts.Timeout.Duration(), "timeout should be read from --config")
+	}
+
+	_, err = getOptions([]string{"--config", "testdata/ini/missing.ini", "foo", "bar"}, out)
+	if assert.Error(t, err, "missing --config file should fail") {
+		assert.Contains(t, err.Error(), "couldn't read testdata/ini/missing.ini", "unexpected error")
+	}
+}
+
+const _testProfilesConfig = `
+	peer-list = "/hosts.json"
+	caller = dev-caller
+	timeout = 2s
+
+	[profiles.prod]
+	peer = 1.1.1.1:1
+	caller = prod-caller
+	tls = true
+	header = env:prod
+
+	[profiles.staging]
+	caller = staging-caller
+`
+
 func TestConfigOverride(t *testing.T) {
 	originalConfigHome := os.Getenv(_configHomeEnv)
 	defer os.Setenv(_configHomeEnv, originalConfigHome)
@@ -705,6 +2360,140 @@ func TestConfigOverride(t *testing.T) {
 				assert.Empty(t, opts.TOpts.Peers, msg)
 			},
 		},
+		{
+			msg:            "timeout in config",
+			configContents: `timeout = 2s`,
+			args:           []string{"foo", "bar"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, 2*time.Second, opts.ROpts.Timeout.Duration(), msg)
+				assert.False(t, opts.ROpts.explicitTimeout, "%v: config timeout is not explicit", msg)
+			},
+		},
+		{
+			msg:            "timeout in config and args",
+			configContents: `timeout = 2s`,
+			args:           []string{"foo", "bar", "--timeout", "3s"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, 3*time.Second, opts.ROpts.Timeout.Duration(), msg)
+				assert.True(t, opts.ROpts.explicitTimeout, "%v: args timeout is explicit", msg)
+			},
+		},
+		{
+			msg:            "no warmup in config",
+			configContents: `timeout = 2s`,
+			args:           []string{"foo", "bar"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, _defaultWarmupRequests, opts.BOpts.WarmupRequests, msg)
+				assert.False(t, opts.BOpts.explicitWarmup, "%v: default warmup is not explicit", msg)
+			},
+		},
+		{
+			msg:            "warmup in config",
+			configContents: `warmup = 5`,
+			args:           []string{"foo", "bar"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, 5, opts.BOpts.WarmupRequests, msg)
+				assert.True(t, opts.BOpts.explicitWarmup, "%v: config warmup is explicit", msg)
+			},
+		},
+		{
+			msg: "warmup in profile",
+			configContents: `
+				[profiles.slow]
+				warmup = 0
+			`,
+			args: []string{"foo", "bar", "--profile", "slow"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, 0, opts.BOpts.WarmupRequests, msg)
+				assert.True(t, opts.BOpts.explicitWarmup, "%v: profile warmup is explicit", msg)
+			},
+		},
+		{
+			msg:            "warmup 0 in args",
+			configContents: `warmup = 5`,
+			args:           []string{"foo", "bar", "--warmup", "0"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, 0, opts.BOpts.WarmupRequests, msg)
+				assert.True(t, opts.BOpts.explicitWarmup, "%v: args warmup is explicit", msg)
+			},
+		},
+		{
+			msg:            "thrift in config",
+			configContents: `thrift = /foo.thrift`,
+			args:           []string{"foo", "bar"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, "/foo.thrift", opts.ROpts.ThriftFile, msg)
+				assert.False(t, opts.ROpts.explicitThrift, "%v: config Thrift file is not explicit", msg)
+			},
+		},
+		{
+			msg:            "thrift in config and args",
+			configContents: `thrift = /foo.thrift`,
+			args:           []string{"foo", "bar", "--thrift", "/bar.thrift"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, "/bar.thrift", opts.ROpts.ThriftFile, msg)
+				assert.True(t, opts.ROpts.explicitThrift, "%v: args Thrift file is explicit", msg)
+			},
+		},
+		{
+			msg:            "profiles in config without profile",
+			configContents: _testProfilesConfig,
+			args:           []string{"foo", "bar"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, "/hosts.json", opts.TOpts.PeerList, msg)
+				assert.Empty(t, opts.TOpts.Peers, msg)
+				assert.Equal(t, "dev-caller", opts.TOpts.CallerName, msg)
+				assert.Equal(t, 2*time.Second, opts.ROpts.Timeout.Duration(), msg)
+				assert.False(t, opts.TOpts.TLS, msg)
+			},
+		},
+		{
+			msg:            "profile overrides config",
+			configContents: _testProfilesConfig,
+			args:           []string{"foo", "bar", "--profile", "prod"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Empty(t, opts.TOpts.PeerList, "%v: hosts file should be cleared", msg)
+				assert.Equal(t, []string{"1.1.1.1:1"}, opts.TOpts.Peers, msg)
+				assert.Equal(t, "prod-caller", opts.TOpts.CallerName, msg)
+				assert.True(t, opts.TOpts.TLS, msg)
+				assert.Equal(t, map[string]string{"env": "prod"}, opts.ROpts.Headers, msg)
+				assert.Equal(t, 2*time.Second, opts.ROpts.Timeout.Duration(), "%v: options not in the profile should be kept", msg)
+			},
+		},
+		{
+			msg:            "args override profile",
+			configContents: _testProfilesConfig,
+			args:           []string{"foo", "bar", "--profile", "prod", "--caller", "me", "-p", "2.2.2.2:2"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, []string{"2.2.2.2:2"}, opts.TOpts.Peers, msg)
+				assert.Equal(t, "me", opts.TOpts.CallerName, msg)
+				assert.True(t, opts.TOpts.TLS, msg)
+			},
+		},
+		{
+			msg:            "profile without peers keeps the config peers",
+			configContents: _testProfilesConfig,
+			args:           []string{"foo", "bar", "--profile", "staging"},
+			validateFn: func(opts *Options, msg string) {
+				assert.Equal(t, "/hosts.json", opts.TOpts.PeerList, msg)
+				assert.Equal(t, "staging-caller", opts.TOpts.CallerName, msg)
+			},
+		},
+		{
+			msg:            "unknown profile",
+			configContents: _testProfilesConfig,
+			args:           []string{"foo", "bar", "--profile", "test"},
+			wantErr:        `profile "test" not found in`,
+		},
+		{
+			msg: "invalid option in profile",
+			configContents: `
+				[profiles.prod]
+				timeout = 3foo
+			`,
+			args:    []string{"foo", "bar", "--profile", "prod"},
+			wantErr: `couldn't read profile "prod"`,
+		},
 	}
 
 	tempDir, err := ioutil.TempDir("", "config")
@@ -849,6 +2638,16 @@ func TestWithTransportSerializer(t *testing.T) {
 			rOpts:    noEnvelopeOpts,
 			want:     []byte{0},
 		},
+		{
+			protocol: transport.HTTP,
+			rOpts:    RequestOptions{Encoding: encoding.Raw, Procedure: "echo", ThriftDisableEnvelopes: true},
+			want:     nil,
+		},
+		{
+			protocol: transport.TChannel,
+			rOpts:    RequestOptions{Encoding: encoding.JSON, Procedure: "echo", ThriftDisableEnvelopes: true},
+			want:     []byte("null"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -865,6 +2664,52 @@ func TestWithTransportSerializer(t *testing.T) {
 	}
 }
 
+func TestWithTransportSerializerI64AsString(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i64")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	thriftFile := filepath.Join(dir, "counter.thrift")
+	require.NoError(t, ioutil.WriteFile(thriftFile, []byte("service Counter { i64 count() }"), 0644), "Failed to write Thrift file")
+
+	// 2^53 + 1 can't be represented exactly as a JSON number.
+	const count = int64(1<<53 + 1)
+	response := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{{ID: 0, Value: wire.NewValueI64(count)}}})
+	buf := &bytes.Buffer{}
+	require.NoError(t, protocol.Binary.Encode(response, buf), "Failed to encode response")
+
+	tests := []struct {
+		msg           string
+		noI64AsString bool
+		want          interface{}
+	}{
+		{
+			msg:  "default",
+			want: "9007199254740993",
+		},
+		{
+			msg:           "no i64 as string",
+			noI64AsString: true,
+			want:          count,
+		},
+	}
+
+	for _, tt := range tests {
+		rOpts := RequestOptions{
+			ThriftFile:          thriftFile,
+			Procedure:           "Counter::count",
+			ThriftNoI64AsString: tt.noI64AsString,
+		}
+		serializer, err := NewSerializer(rOpts)
+		require.NoError(t, err, "%v: failed to create serializer", tt.msg)
+
+		serializer = withTransportSerializer(transport.TChannel, serializer, rOpts)
+		got, err := serializer.Response(&transport.Response{Body: buf.Bytes()})
+		require.NoError(t, err, "%v: failed to decode response", tt.msg)
+		assert.Equal(t, map[string]interface{}{"result": tt.want}, got, "%v: unexpected result", tt.msg)
+	}
+}
+
 func cleanEnv(key, val string, wasSet bool) {
 	if wasSet {
 		os.Setenv(key, val)
@@ -918,6 +2763,19 @@ func TestFindBestConfigFile(t *testing.T) {
 	// now remove the sys file and ensure we get nothing
 	os.Setenv(_configSysEnv, "/now-you-see-me-now-you-dont")
 	assert.Equal(t, "", findBestConfigFile(), "expected to use no file")
+
+	// a config file in the current directory takes precedence over the home file
+	os.Setenv(_configHomeEnv, userDir)
+	localDir, err := ioutil.TempDir("", "local")
+	require.NoError(t, err, "Failed to create temporary directory")
+	defer os.RemoveAll(localDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(localDir, _localConfigFile), nil, 0644))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err, "Failed to get working directory")
+	require.NoError(t, os.Chdir(localDir), "Failed to change working directory")
+	defer os.Chdir(wd)
+	assert.Equal(t, _localConfigFile, findBestConfigFile(), "expected local config to take precedence")
 }
 
 func encodeEnveloped(e wire.Envelope) []byte {
@@ -950,8 +2808,145 @@ func TestNoWarmupBenchmark(t *testing.T) {
 			Concurrency:    2,
 		},
 	}, out, _testLogger)
-	assert.Contains(t, buf.String(), "Total errors: 100")
-	assert.Contains(t, buf.String(), "Error rate: 100")
+	assert.Contains(t, buf.String(), "Total errors:      100")
+	assert.Contains(t, buf.String(), "Error rate:        100")
+}
+
+// flakyTimeoutError is a retryable timeout error returned by flakyTransport.
+type flakyTimeoutError struct {
+	call int
+}
+
+func (e flakyTimeoutError) Error() string   { return fmt.Sprintf("call %v failed", e.call) }
+func (e flakyTimeoutError) Timeout() bool   { return true }
+func (e flakyTimeoutError) Temporary() bool { return true }
+
+// flakyTransport fails the first failures calls with a transport error, which
+// is a retryable timeout unless nonRetryable is set.
+type flakyTransport struct {
+	transport.Transport
+
+	failures     int
+	nonRetryable bool
+	calls        int
+	timeouts     []time.Duration
+}
+
+func (t *flakyTransport) Call(ctx context.Context, request *transport.Request) (*transport.Response, error) {
+	t.calls++
+	t.timeouts = append(t.timeouts, request.Timeout)
+	if t.calls <= t.failures {
+		if t.nonRetryable {
+			return nil, fmt.Errorf("call %v failed", t.calls)
+		}
+		return nil, flakyTimeoutError{t.calls}
+	}
+	return &transport.Response{Body: request.Body}, nil
+}
+
+func (t *flakyTransport) Tracer() opentracing.Tracer {
+	return nil
+}
+
+func TestMakeRequestWithRetries(t *testing.T) {
+	tests := []struct {
+		msg          string
+		failures     int
+		nonRetryable bool
+		rOpts        RequestOptions
+		wantCalls    int
+		wantErr      string
+	}{
+		{
+			msg:       "no retries on success",
+			rOpts:     RequestOptions{RetryLimit: 3},
+			wantCalls: 1,
+		},
+		{
+			msg:       "no retries by default",
+			failures:  1,
+			wantCalls: 1,
+			wantErr:   "call 1 failed",
+		},
+		{
+			msg:       "succeeds after retries",
+			failures:  2,
+			rOpts:     RequestOptions{RetryLimit: 2, RetryBackoff: time.Millisecond},
+			wantCalls: 3,
+		},
+		{
+			msg:       "returns last error after retry limit",
+			failures:  5,
+			rOpts:     RequestOptions{RetryLimit: 2},
+			wantCalls: 3,
+			wantErr:   "call 3 failed",
+		},
+		{
+			msg:          "errors that are not retryable are not retried",
+			failures:     2,
+			nonRetryable: true,
+			rOpts:        RequestOptions{RetryLimit: 2},
+			wantCalls:    1,
+			wantErr:      "call 1 failed",
+		},
+		{
+			msg:       "overall timeout does not affect a successful call",
+			rOpts:     RequestOptions{OverallTimeout: time.Second},
+			wantCalls: 1,
+		},
+		{
+			msg:       "overall timeout stops retries before a backoff past the deadline",
+			failures:  10,
+			rOpts:     RequestOptions{RetryLimit: 10, RetryBackoff: 50 * time.Millisecond, OverallTimeout: 120 * time.Millisecond},
+			wantCalls: 2,
+			wantErr:   "overall timeout of 120ms exceeded after 2 attempt(s), last error: call 2 failed",
+		},
+		{
+			msg:       "retry limit is reached before overall timeout",
+			failures:  10,
+			rOpts:     RequestOptions{RetryLimit: 1, OverallTimeout: time.Second},
+			wantCalls: 2,
+			wantErr:   "call 2 failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			ft := &flakyTransport{failures: tt.failures, nonRetryable: tt.nonRetryable}
+			res, err := makeRequestWithRetries(ft, &transport.Request{Body: []byte("body"), Timeout: 10 * time.Second}, tt.rOpts, _testLogger)
+			assert.Equal(t, tt.wantCalls, ft.calls, "Number of calls mismatch")
+			if tt.rOpts.OverallTimeout > 0 {
+				for _, timeout := range ft.timeouts {
+					assert.True(t, timeout <= tt.rOpts.OverallTimeout, "Attempt timeout %v should be capped to the overall timeout", timeout)
+				}
+			}
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err, "makeRequestWithRetries failed")
+			assert.Equal(t, []byte("body"), res.Body, "Response body mismatch")
+		})
+	}
+}
+
+func TestMakeRequestWithRetriesLogging(t *testing.T) {
+	var logs bytes.Buffer
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.AddSync(&logs),
+		zap.InfoLevel,
+	))
+
+	ft := &flakyTransport{failures: 1}
+	_, err := makeRequestWithRetries(ft, &transport.Request{Method: "method", Timeout: time.Second}, RequestOptions{RetryLimit: 1}, logger)
+	require.NoError(t, err, "makeRequestWithRetries failed")
+
+	assert.Contains(t, logs.String(), `"Call failed."`, "failed attempt should be logged")
+	assert.Contains(t, logs.String(), "call 1 failed", "failed attempt should include the error")
+	assert.Contains(t, logs.String(), `"Retrying call."`, "retry should be logged")
+	assert.NotContains(t, logs.String(), `"Call succeeded."`, "successful calls are only logged at the debug level")
 }
 
 func TestTemplates(t *testing.T) {
@@ -997,12 +2992,65 @@ func TestGetTracer(t *testing.T) {
 			wantNoop: true,
 		},
 		{
+			opts: Options{
+				TOpts: TransportOptions{
+					CallerName: "test",
+					Trace:      true,
+				},
+			},
+		},
+		{
+			opts: Options{
+				TOpts: TransportOptions{
+					CallerName:  "test",
+					JaegerAgent: "127.0.0.1:6831",
+				},
+			},
+		},
+		{
+			opts: Options{
+				TOpts: TransportOptions{
+					CallerName:  "test",
+					JaegerAgent: "not a host port",
+				},
+			},
+			wantFatal: "Failed to create Jaeger agent reporter",
+		},
+		{
+			opts: Options{
+				TOpts: TransportOptions{
+					CallerName:      "test",
+					TracingEndpoint: "127.0.0.1:14268",
+				},
+			},
+		},
+		{
+			opts: Options{
+				TOpts: TransportOptions{
+					CallerName:      "test",
+					TracingEndpoint: "not a host port",
+				},
+			},
+			wantFatal: "Failed to create tracing endpoint reporter",
+		},
+		{
+			opts: Options{
+				TOpts: TransportOptions{
+					CallerName:      "test",
+					JaegerAgent:     "127.0.0.1:6831",
+					TracingEndpoint: "127.0.0.1:14268",
+				},
+			},
+			wantFatal: "Cannot use both --jaeger-agent and --tracing-endpoint",
+		},
+		{
+			// Without a tracing client, baggage is sent as headers by the transport.
 			opts: Options{
 				ROpts: RequestOptions{
 					Baggage: map[string]string{"k": "v"},
 				},
 			},
-			wantFatal: "propagate baggage",
+			wantNoop: true,
 		},
 	}
 
@@ -1035,6 +3083,81 @@ func TestGetTracer(t *testing.T) {
 	}
 }
 
+func TestTracingEndpointURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  string
+	}{
+		{
+			endpoint: "localhost:14268",
+			want:     "http://localhost:14268/api/traces",
+		},
+		{
+			endpoint: "https://collector.example.com/api/traces",
+			want:     "https://collector.example.com/api/traces",
+		},
+		{
+			endpoint: "localhost",
+			wantErr:  `invalid tracing endpoint "localhost"`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := tracingEndpointURL(tt.endpoint)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "tracingEndpointURL(%v) should fail", tt.endpoint) {
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error for %v", tt.endpoint)
+			}
+			continue
+		}
+
+		assert.NoError(t, err, "tracingEndpointURL(%v) failed", tt.endpoint)
+		assert.Equal(t, tt.want, got, "Unexpected URL for %v", tt.endpoint)
+	}
+}
+
+func TestTracingEndpointFlush(t *testing.T) {
+	received := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- r.URL.Path:
+		default:
+		}
+	}))
+	defer collector.Close()
+
+	_, _, out := getOutput(t)
+	tracer, closer := getTracer(Options{
+		TOpts: TransportOptions{
+			CallerName:      "test",
+			TracingEndpoint: strings.TrimPrefix(collector.URL, "http://"),
+		},
+	}, out)
+	require.NotNil(t, closer, "Expected closer for the tracer")
+
+	tracer.StartSpan("method").Finish()
+	require.NoError(t, closer.Close(), "Failed to close tracer")
+
+	select {
+	case path := <-received:
+		assert.Equal(t, _jaegerCollectorPath, path, "Spans sent to unexpected path")
+	case <-time.After(time.Second):
+		t.Fatal("Spans were not flushed to the tracing endpoint")
+	}
+}
+
+func TestDefaultCallerName(t *testing.T) {
+	origUser := os.Getenv("USER")
+	defer os.Setenv("USER", origUser)
+
+	os.Setenv("USER", "alice")
+	assert.Equal(t, "yab-alice", defaultCallerName(), "Unexpected caller name with USER set")
+
+	os.Setenv("USER", "")
+	assert.Equal(t, "yab", defaultCallerName(), "Unexpected caller name without USER")
+}
+
 func TestMainSupportedPeerProviderSchemes(t *testing.T) {
 	origArgs := os.Args
 	defer func() { os.Args = origArgs }()
@@ -1045,6 +3168,7 @@ func TestMainSupportedPeerProviderSchemes(t *testing.T) {
 	parseAndRun(out)
 	contents := buf.String()
 	assert.Contains(t, contents, "file\n", "Expected file protocol support")
+	assert.Contains(t, contents, "srv\n", "Expected SRV protocol support")
 	assert.NotContains(t, contents, "\n\n", "Expected no blank lines")
 }
 
@@ -1055,6 +3179,34 @@ func TestOverrideDefaultsFails(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to read yaml template")
 }
 
+func TestOverrideDefaultsRequestFile(t *testing.T) {
+	_, _, out := getOutput(t)
+	opts, err := getOptions([]string{"-y", exampleTemplate, "-f", "testdata/valid.json"}, out)
+	require.NoError(t, err, "getOptions failed")
+
+	assert.Empty(t, opts.ROpts.RequestJSON, "Template request body should be cleared")
+	assert.Equal(t, "testdata/valid.json", opts.ROpts.RequestFile, "Request file mismatch")
+}
+
+func TestOverrideDefaultsJSONRequestFile(t *testing.T) {
+	requestFile := writeFile(t, "request", `{
+		"service": "foo",
+		"method": "Simple::foo",
+		"headers": {"from": "file", "other": "file"},
+		"request": {"key": "value"}
+	}`)
+	defer os.Remove(requestFile)
+
+	_, _, out := getOutput(t)
+	opts, err := getOptions([]string{"--request-file", requestFile, "--service", "bar", "-H", "from:flag"}, out)
+	require.NoError(t, err, "getOptions failed")
+
+	assert.Equal(t, "Simple::foo", opts.ROpts.Procedure, "Procedure should be read from the request file")
+	assert.Equal(t, "bar", opts.TOpts.ServiceName, "Service flag should override the request file")
+	assert.Equal(t, map[string]string{"from": "flag", "other": "file"}, opts.ROpts.Headers, "Header flags should override the request file")
+	assert.Equal(t, "key: value\n", opts.ROpts.RequestJSON, "Request body mismatch")
+}
+
 func TestOptionsInheritance(t *testing.T) {
 	originalConfigHome := os.Getenv(_configHomeEnv)
 	defer os.Setenv(_configHomeEnv, originalConfigHome)
@@ -22,10 +22,14 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/yarpc/yab/encoding"
+
+	"github.com/opentracing/opentracing-go"
 )
 
 // Options are parsed from flags using go-flags.
@@ -34,30 +38,86 @@ type Options struct {
 	TOpts          TransportOptions `group:"transport"`
 	BOpts          BenchmarkOptions `group:"benchmark"`
 	Verbosity      []bool           `short:"v" description:"Enable more detailed logging. Repeats increase the verbosity, ie. -vvv"`
-	DisplayVersion bool             `long:"version" description:"Displays the application version"`
+	DisplayVersion bool             `long:"version" description:"Displays the application version, git commit and Go version"`
 	ManPage        bool             `long:"man-page" hidden:"yes" description:"Print yab's man page to stdout"`
+	ConfigFile     string           `long:"config" description:"Path of an ini file to read default options from, instead of .yab.ini in the current directory or defaults.ini in the user's config directory"`
+	Profile        string           `long:"profile" no-ini:"true" description:"The name of a profile in the config file, defined in a [profiles.<name>] section, whose options, such as peers, caller, TLS and headers, override the defaults. Flags override the profile's options"`
 }
 
 // RequestOptions are request related options
 type RequestOptions struct {
-	Encoding     encoding.Encoding `short:"e" long:"encoding" description:"The encoding of the data, options are: Thrift, JSON, raw. Defaults to Thrift if the method contains '::' or a Thrift file is specified"`
-	ThriftFile   string            `short:"t" long:"thrift" description:"Path of the .thrift file"`
-	Procedure    string            `long:"procedure" description:"The full Thrift method name (Svc::Method) to invoke"`
-	MethodName   stringAlias       `short:"m" long:"method" description:"Alias for procedure"`
-	RequestJSON  string            `short:"r" long:"request" unquote:"false" description:"The request body, in JSON or YAML format"`
-	RequestFile  string            `short:"f" long:"file" description:"Path of a file containing the request body in JSON or YAML"`
-	Headers      map[string]string `short:"H" long:"header" description:"Individual application header as a key:value pair per flag"`
-	HeadersJSON  string            `long:"headers" unquote:"false" description:"The headers in JSON or YAML format"`
-	HeadersFile  string            `long:"headers-file" description:"Path of a file containing the headers in JSON or YAML"`
-	Baggage      map[string]string `short:"B" long:"baggage" description:"Individual context baggage header as a key:value pair per flag"`
-	Health       bool              `long:"health" description:"Hit the health endpoint, Meta::health"`
-	Timeout      timeMillisFlag    `long:"timeout" default-mask:"1s" description:"The timeout for each request. E.g., 100ms, 0.5s, 1s. If no unit is specified, milliseconds are assumed."`
-	YamlTemplate string            `short:"y" long:"yaml-template" description:"Send a tchannel request specified by a YAML template"`
-	TemplateArgs map[string]string `short:"A" long:"arg" description:"A list of key-value template arguments, specified as -A foo:bar -A user:me"`
+	Encoding        encoding.Encoding `short:"e" long:"encoding" description:"The encoding of the data, options are: Thrift, JSON, raw, proto. Defaults to proto if a proto file is specified, or Thrift if the method contains '::' or a Thrift file is specified"`
+	ThriftFile      string            `short:"t" long:"thrift" description:"Path of the .thrift file"`
+	NoThrift        bool              `long:"no-thrift" description:"Call a service that has no IDL, such as a JSON over TChannel service. The encoding defaults to JSON, so the body is validated as JSON and the response is printed as generic JSON, and any Thrift file, such as one in the config file, is ignored"`
+	Procedure       string            `long:"procedure" description:"The full Thrift method name (Svc::Method) to invoke"`
+	MethodName      stringAlias       `short:"m" long:"method" description:"Alias for procedure"`
+	RequestJSON     string            `short:"r" long:"request" unquote:"false" description:"The request body, in JSON or YAML format"`
+	RequestFile     string            `short:"f" long:"file" description:"Path of a file containing the request body in JSON or YAML"`
+	Fields          []string          `long:"field" description:"A field of the request body, specified as name=value. Can be repeated to build a flat request without JSON. Values are converted to the type of the Thrift argument, e.g., count=5 is sent as an integer"`
+	RequestRaw      string            `long:"request-raw" description:"Path of a file containing a pre-serialized Thrift request body, which is sent as is, e.g., to replay a captured request. The Thrift file is only used to decode the response"`
+	RequestList     string            `long:"request-list" description:"Path of a file containing multiple request bodies, as a JSON array or a request body per line. Benchmarks cycle through the requests"`
+	BodyTemplate    bool              `long:"template" description:"Treat the request body as a Go text/template that is rendered for each request. The template can use {{.Index}}, and the functions randInt, randString and uuid"`
+	AllowMissingEnv bool              `long:"allow-missing-env" description:"Replace references to unset environment variables in the request body with an empty string instead of failing"`
+	Headers         map[string]string `short:"H" long:"header" description:"Individual application header as a key:value pair per flag. If a key is repeated, the last value is used"`
+	HeadersJSON     string            `long:"headers" unquote:"false" description:"The headers in JSON or YAML format"`
+	HeadersFile     string            `long:"headers-file" description:"Path of a file containing the headers in JSON or YAML"`
+	Baggage         map[string]string
+	// explicitTimeout is set if the timeout is specified in the args or a
+	// template, rather than the defaults.
+	explicitTimeout bool
+	// explicitThrift is set if the Thrift file is specified in the args or a
+	// template, rather than the defaults.
+	explicitThrift  bool
+	BaggageFlag     keyValueAlias     `short:"B" long:"baggage" description:"Individual context baggage header as a key:value or key=value pair per flag. Without a tracing client, baggage is sent as Jaeger baggage headers"`
+	Health          bool              `long:"health" description:"Hit the health endpoint, Meta::health"`
+	Timeout         timeMillisFlag    `long:"timeout" default-mask:"1s" description:"The timeout for each request. E.g., 100ms, 0.5s, 1s. If no unit is specified, milliseconds are assumed."`
+	RetryLimit      int               `long:"retry-limit" description:"The number of times to retry a call that fails with a connection failure, timeout or busy server. Application errors, such as Thrift exceptions, and HTTP status errors are not retried. Benchmark requests are never retried"`
+	RetryBackoff    time.Duration     `long:"retry-backoff" description:"The time to wait before the first retry, which doubles for each subsequent retry. E.g., 100ms, 1s"`
+	OverallTimeout  time.Duration     `long:"overall-timeout" description:"The maximum total time for a call, including all retries and backoffs. Each attempt is still limited by --timeout. E.g., 5s"`
+	Count           int               `long:"count" description:"The number of sequential requests to make, printing each response. Cannot be combined with benchmark options, which make concurrent requests"`
+	RequestIDPrefix string            `long:"request-id-prefix" description:"Send an x-request-id header of the form <prefix>-<n> with each call, where n is incremented for each call, including benchmark calls. The request ID is included in the output and logs"`
+	YamlTemplate    string            `short:"y" long:"yaml-template" description:"Send a request specified by a YAML or JSON file, which bundles the service, method, headers and request body. Flags override the values in the file"`
+	TemplateAlias   stringAlias       `long:"request-file" description:"Alias for yaml-template"`
+	TemplateArgs    map[string]string `short:"A" long:"arg" description:"A list of key-value template arguments, specified as -A foo:bar -A user:me"`
+	Validate        bool              `long:"validate" description:"Validate the request against the method spec and exit without making a call. Exits with a non-zero status if the request is invalid"`
+	Describe        bool              `long:"describe" description:"Print a JSON template of the request for the method, with a placeholder describing the type of each field, and exit without making a call"`
+	DryRunShowAuth  bool              `long:"dry-run-show-auth" description:"Print the auth token in the --dry-run output, which is redacted by default so tokens don't end up in terminals and logs"`
+	DryRun          bool              `long:"dry-run" description:"Print the method, headers and serialized body of the request that would be sent, and exit without making a call"`
 
 	// Thrift options
-	ThriftDisableEnvelopes bool `long:"disable-thrift-envelope" description:"Disables Thrift envelopes (disabled by default for TChannel and gRPC)"`
-	ThriftMultiplexed      bool `long:"multiplexed-thrift" description:"Enables the Thrift TMultiplexedProtocol used by services that host multiple Thrift services on a single endpoint."`
+	ThriftDisableEnvelopes bool     `long:"disable-thrift-envelope" description:"Disables Thrift envelopes (disabled by default for TChannel and gRPC)"`
+	ThriftMultiplexed      bool     `long:"multiplexed-thrift" description:"Enables the Thrift TMultiplexedProtocol used by services that host multiple Thrift services on a single endpoint."`
+	ThriftIncludePaths     []string `long:"thrift-path" description:"A directory used to search for Thrift includes that are not found relative to the including file. Can be repeated"`
+	ThriftMethodArgs       string   `long:"thrift-method-args" unquote:"false" description:"The method arguments as a JSON or YAML array, in the order they are declared in the Thrift file, rather than an object of argument names. Trailing arguments can be omitted, and null arguments are unset"`
+	ThriftSpecTimeout      bool     `long:"method-timeout-from-spec" description:"Use the timeout annotation of the Thrift method, e.g., (timeout = \"500ms\"), as the timeout for each request, unless --timeout is specified"`
+	ThriftMethodList       bool     `long:"method-list" description:"List the services and methods, with their signatures, in the Thrift file and exit"`
+	ThriftRemoteMethods    bool     `long:"list-methods-remote" description:"List the services and methods, with their signatures, advertised by the server's Meta::thriftIDL endpoint and exit. Does not require a Thrift file"`
+	ThriftNumericEnums     bool     `long:"numeric-enums" description:"Print enums in Thrift responses as integers rather than the names of the enum values"`
+	ThriftNoI64AsString    bool     `long:"no-i64-as-string" description:"Print i64 values in Thrift responses as JSON numbers rather than strings, though JSON numbers lose precision above 2^53. i64 values in requests can always be specified as either"`
+	ThriftLenientDecode    bool     `long:"lenient-decode" description:"Decode as much of Thrift responses as possible, replacing fields that fail to decode, e.g., due to differences between the client and server Thrift files, with a note describing the error"`
+	ThriftConstRefs        bool     `long:"thrift-const-refs" description:"Replace strings of the form \"@Name\" in Thrift requests with the value of the constant Name from the Thrift file. A leading \"@@\" is sent as a single \"@\""`
+	ThriftNoValidate       bool     `long:"thrift-no-validate" description:"Ignore definitions in the Thrift file and its includes that fail to compile, printing a warning for each, so methods that only use valid definitions can be called"`
+
+	// Protobuf options
+	ProtoFile        string   `long:"proto" description:"Path of the .proto file. The method is specified as package.Service::Method"`
+	ProtoImportPaths []string `long:"proto-import-path" description:"Additional paths used to resolve imports in the .proto file"`
+
+	// Output options
+	OutputFormat     string `long:"format" choice:"pretty" choice:"json" choice:"table" description:"The output format. pretty prints indented JSON responses and plain errors, json prints a single line JSON object for both responses and errors, table prints the top-level response fields as an aligned table"`
+	NDJSON           bool   `long:"ndjson" description:"Alias for --format json, which prints each response as a single line JSON object"`
+	RawOutput        bool   `long:"raw-output" description:"Write the response body to stdout as is, without decoding it"`
+	RawOutputHex     bool   `long:"raw-output-hex" description:"Write the response body to stdout hex-encoded, without decoding it"`
+	OutputFile       string `long:"output" description:"Write the response to the given file instead of stdout, creating any parent directories. Applies to all output formats, including --raw-output. Failures and benchmark results are still printed to stdout"`
+	Select           string `long:"select" description:"Print only the part of the response body at the given path, e.g., result.items[0].name. Exits with a non-zero status if the path does not exist"`
+	ShowSize         bool   `long:"show-size" description:"Include the size of the response body in the output, in KiB or MiB when large"`
+	BytesRaw         bool   `long:"bytes-raw" description:"Print response sizes, with --show-size and in the benchmark summary, as an exact number of bytes rather than in KiB or MiB"`
+	Quiet            bool   `long:"quiet" description:"Suppress the response output. When benchmarking, only the benchmark results are printed"`
+	NoColor          bool   `long:"no-color" description:"Disable colored warnings and errors. Colors are also disabled if the output is not a terminal, or NO_COLOR is set"`
+	IgnoreExceptions bool   `long:"ignore-exceptions" description:"Exit successfully when the response is an exception declared by the method, instead of reporting a failure"`
+	CheckOnly        bool   `long:"check-only" description:"Only check whether the call succeeded, without decoding or printing the response. Exits with a non-zero status if the call fails"`
+
+	// Assertion options
+	Expect []string `long:"expect" description:"Exit with a non-zero status unless the response body field at the given path equals the value, e.g., status=OK or result.items[0].count=3. Can be repeated, and all expectations must be met"`
 
 	// These are aliases for tcurl compatibility.
 	Aliases struct {
@@ -69,19 +129,47 @@ type RequestOptions struct {
 		JSON     bool        `long:"json" hidden:"true"`
 		Raw      bool        `long:"raw" hidden:"true"`
 	}
+
+	// requestIDs generates the request ID of each call if RequestIDPrefix
+	// is set. It's shared by all calls so that request IDs are unique.
+	requestIDs *requestIDs
 }
 
 // TransportOptions are transport related options.
 type TransportOptions struct {
-	ServiceName      string            `short:"s" long:"service" description:"The TChannel/Hyperbahn service name"`
-	Peers            []string          `short:"p" long:"peer" description:"The host:port of the service to call"`
-	PeerList         string            `short:"P" long:"peer-list" description:"Path or URL of a JSON, YAML, or flat file containing a list of host:ports. -P? for supported protocols."`
-	CallerName       string            `long:"caller" description:"Caller will override the default caller name (which is yab-$USER)."`
-	RoutingKey       string            `long:"rk" description:"The routing key overrides the service name traffic group for proxies."`
-	RoutingDelegate  string            `long:"rd" description:"The routing delegate overrides the routing key traffic group for proxies."`
-	ShardKey         string            `long:"sk" description:"The shard key is a transport header that clues where to send a request within a clustered traffic group."`
-	Jaeger           bool              `long:"jaeger" description:"Use the Jaeger tracing client to send Uber style traces and baggage headers"`
-	TransportHeaders map[string]string `short:"T" long:"topt" description:"Transport options for TChannel, protocol headers for HTTP"`
+	ServiceName          string            `short:"s" long:"service" description:"The TChannel/Hyperbahn service name"`
+	Peers                []string          `short:"p" long:"peer" description:"The host:port of the service to call, or srv://name to use the targets of DNS SRV records"`
+	PeerList             string            `short:"P" long:"peer-list" description:"Path or URL of a JSON, YAML, or flat file containing a list of host:ports. -P? for supported protocols."`
+	Relays               []string          `long:"relay" description:"The host:port of a TChannel relay, such as Hyperbahn, that routes calls by service name. Used if no peers are specified. Can be repeated, or set in defaults.ini"`
+	CallerName           string            `long:"caller" description:"Caller will override the default caller name (which is yab-$USER, or yab if $USER is not set)."`
+	RoutingKey           string            `long:"rk" description:"The routing key overrides the service name traffic group for proxies."`
+	RoutingKeyAlias      stringAlias       `long:"routing-key" description:"Alias for rk"`
+	RoutingDelegate      string            `long:"rd" description:"The routing delegate overrides the routing key traffic group for proxies."`
+	RoutingDelegateAlias stringAlias       `long:"routing-delegate" description:"Alias for rd"`
+	ShardKey             string            `long:"sk" description:"The shard key is a transport header that clues where to send a request within a clustered traffic group."`
+	ShardKeyAlias        stringAlias       `long:"shard-key" description:"Alias for sk"`
+	Jaeger               bool              `long:"jaeger" description:"Use the Jaeger tracing client to send Uber style traces and baggage headers"`
+	Trace                bool              `long:"trace" description:"Alias for jaeger"`
+	JaegerAgent          string            `long:"jaeger-agent" description:"The host:port of a Jaeger agent to report spans to, so calls show up in the tracing backend. Implies --jaeger"`
+	TracingEndpoint      string            `long:"tracing-endpoint" description:"The host:port of a Jaeger collector to send spans to over HTTP, for hosts without a Jaeger agent, e.g., outside the service mesh. A full URL can also be specified. Implies --jaeger"`
+	TransportHeaders     map[string]string `short:"T" long:"topt" description:"Transport options for TChannel, protocol headers for HTTP"`
+	ArgScheme            string            `long:"arg-scheme" description:"Overrides the arg scheme (\"as\" header) sent on TChannel calls, e.g., thrift. Equivalent to -T as=<scheme>. Defaults to the arg scheme for the encoding"`
+	PeerStrategy         string            `long:"peer-strategy" description:"How calls are distributed across multiple peers: random, roundrobin, or fanout, which sends each call to every peer. Defaults to the transport's own peer selection"`
+	Seed                 int64             `long:"seed" description:"The seed used for random peer selection, which allows peer selection to be reproduced. Defaults to a seed based on the current time."`
+	TLS                  bool              `long:"tls" description:"Use TLS for TChannel connections. Enabled automatically if any other TLS option is specified"`
+	TLSCA                string            `long:"tls-ca" description:"Path of a PEM file containing the CA certificates used to verify the server. Defaults to the system CA certificates"`
+	TLSCert              string            `long:"tls-cert" description:"Path of a PEM file containing the client certificate"`
+	TLSKey               string            `long:"tls-key" description:"Path of a PEM file containing the client private key"`
+	TLSNoVerify          bool              `long:"tls-no-verify" description:"Skip verification of the server certificate. This should only be used in development environments"`
+	DialTimeout          time.Duration     `long:"dial-timeout" description:"The maximum time to wait when connecting to a TChannel or HTTP peer. Defaults to the call timeout"`
+	Resolve              []string          `long:"resolve" description:"Connect to a host using the given IP address instead of resolving it using DNS, specified as host=ip, e.g., to debug DNS issues. The host is still used for TLS and HTTP Host headers. Can be repeated"`
+	BindAddress          string            `long:"bind-address" description:"The local IP address that connections to TChannel and HTTP peers are made from, for hosts with multiple network interfaces"`
+	NoDeadlineHeader     bool              `long:"no-deadline-header" description:"Don't send the Context-TTL-MS header with the call deadline on HTTP calls, for servers that reject it. TChannel and gRPC always send the deadline"`
+	Compress             bool              `long:"compress" description:"Request gzip compressed responses from HTTP peers, which are decompressed before decoding. Responses that are not compressed are used as is"`
+	AuthToken            string            `long:"auth-token" description:"An auth token to send in the --auth-header header of each call, for services that authenticate callers"`
+	AuthCmd              string            `long:"auth-cmd" description:"A shell command whose output is sent as the auth token in the --auth-header header of each call, e.g., a command that signs a token for the caller"`
+	AuthHeader           string            `long:"auth-header" default:"Authorization" description:"The header used to send the auth token"`
+	AuthRefresh          time.Duration     `long:"auth-refresh" description:"Re-run --auth-cmd to get a new token once the previous token is older than this duration, e.g., 5m for long benchmarks. By default, the command is only run once"`
 
 	// This is a hack to work around go-flags not allowing disabling flags:
 	// https://github.com/jessevdk/go-flags/issues/191
@@ -89,33 +177,73 @@ type TransportOptions struct {
 	// to enable Jaeger via CLI.
 	// Our plan is to change go-flags to support "--no-FLAG" and remove this hack.
 	NoJaeger bool `long:"no-jaeger" hidden:"true"`
+
+	// authTokens are shared by all transports, so the auth command isn't
+	// run for each connection. They're set from the auth options.
+	authTokens *authTokens
+
+	// tracer is used by benchmark transports, so a sample of the benchmark
+	// requests can be reported. If it's nil, benchmarks are not traced.
+	tracer opentracing.Tracer
+
+	// rand is used for random peer selection, and is seeded using Seed.
+	// If it's nil, the global source is used.
+	rand *transport.Rand
 }
 
 // BenchmarkOptions are benchmark-specific options
 type BenchmarkOptions struct {
 	MaxRequests int           `short:"n" long:"max-requests" default:"0" description:"The maximum number of requests to make. 0 implies no limit."`
 	MaxDuration time.Duration `short:"d" long:"max-duration" default:"0s" description:"The maximum amount of time to run the benchmark for. 0 implies no duration limit."`
+	Duration    time.Duration `long:"duration" description:"Alias for max-duration"`
 
 	// NumCPUs is the value for GOMAXPROCS. The default value of 0 will not update GOMAXPROCS.
 	NumCPUs int `long:"cpus" description:"The number of OS threads"`
 
-	Connections    int `long:"connections" description:"The number of TCP connections to use"`
-	WarmupRequests int `long:"warmup" description:"The number of requests to make to warmup each connection" default:"10"`
-	Concurrency    int `long:"concurrency" default:"1" description:"The number of concurrent calls per connection"`
-	RPS            int `long:"rps" default:"0" description:"Limit on the number of requests per second. The default (0) is no limit."`
+	Connections    int           `long:"connections" description:"The number of TCP connections to use"`
+	WarmupRequests int           `long:"warmup" description:"The number of requests to make to warmup each connection" default-mask:"10"`
+	WarmupDuration time.Duration `long:"warmup-duration" description:"Warm up each connection by making requests for this duration, e.g. 5s, instead of a number of requests. If --warmup is also specified, the warmup ends early once that many requests are made. Warmup requests are excluded from the results"`
+	// explicitWarmup is set if the number of warmup requests is specified in
+	// the args or config, rather than the built-in default.
+	explicitWarmup bool
+	Concurrency    int           `long:"concurrency" default:"1" description:"The number of concurrent calls per connection"`
+	RampUp         time.Duration `long:"ramp-up" description:"Start the benchmark workers gradually over this period, e.g. 10s, rather than all at once. The number of active workers increases linearly until all connections and concurrent calls are in use"`
+	ReuseConns     optionalBool  `long:"reuse-connection" optional:"yes" optional-value:"true" description:"Whether benchmark calls reuse the warmed up connections. Use --reuse-connection=false to dial a new connection for each call, to measure the overhead of connection setup (default: true)"`
+	RPS            int           `long:"rps" default:"0" description:"Limit on the number of requests per second. The default (0) is no limit."`
+	Interval       time.Duration `long:"interval" description:"Print the benchmark progress to stderr every interval, e.g. 5s. The default (0) disables progress updates."`
+	MetricsOut     string        `long:"metrics-out" description:"Path of a file to write the benchmark results to in the Prometheus text format, e.g. for a node_exporter textfile collector"`
+	SummaryJSON    string        `long:"summary-json" description:"Path of a file to write the complete benchmark summary to as versioned JSON, including the configuration, latency quantiles, RPS and errors"`
+	LatenciesOut   string        `long:"latencies-out" description:"Path of a CSV file to write the timestamp, latency and result of every benchmark request to"`
+	MaxErrorRate   *float64      `long:"max-error-rate" description:"Fail with a non-zero exit status if the fraction of failed requests exceeds this threshold, e.g. 0.01. Use 0 to fail on any error"`
+	MaxP99         time.Duration `long:"max-p99" description:"Fail with a non-zero exit status if the p99 latency exceeds this threshold, e.g. 50ms"`
+	Mix            string        `long:"mix" description:"Path of a YAML or JSON file with a list of methods, weights and request bodies. Each benchmark call picks a method based on the weights"`
+	BaselineOut    string        `long:"baseline-out" description:"Path of a file to write the benchmark summary to as JSON, which can be used as the --baseline of later benchmarks"`
+	Baseline       string        `long:"baseline" description:"Path of a baseline file written by --baseline-out. The RPS and p99 latency are compared against the baseline, failing with a non-zero exit status if either regresses by more than --baseline-tolerance"`
+	BaselineTol    float64       `long:"baseline-tolerance" default:"0.1" description:"The fraction by which the RPS and p99 latency may regress compared to the --baseline before failing, e.g. 0.1 allows a 10% regression"`
+	TraceSampling  float64       `long:"trace-sample-rate" default:"0.01" description:"The fraction of benchmark requests that are reported as child spans of a span for the benchmark, when spans are reported using --jaeger-agent or --tracing-endpoint"`
 
 	// Benchmark metrics can optionally be reported via statsd.
 	StatsdHostPort string `long:"statsd" description:"Optional host:port of a StatsD server to report metrics"`
 }
 
+// _defaultWarmupRequests is the number of warmup requests if neither the config
+// nor the args specify --warmup.
+const _defaultWarmupRequests = 10
+
 func newOptions() *Options {
 	var opts Options
 
 	// Defaults
 	opts.ROpts.Timeout = timeMillisFlag(time.Second)
+	opts.BOpts.WarmupRequests = _defaultWarmupRequests
 
 	// Set flag aliases
 	opts.ROpts.MethodName.dest = &opts.ROpts.Procedure
+	opts.ROpts.TemplateAlias.dest = &opts.ROpts.YamlTemplate
+	opts.ROpts.BaggageFlag.dest = &opts.ROpts.Baggage
+	opts.TOpts.RoutingKeyAlias.dest = &opts.TOpts.RoutingKey
+	opts.TOpts.RoutingDelegateAlias.dest = &opts.TOpts.RoutingDelegate
+	opts.TOpts.ShardKeyAlias.dest = &opts.TOpts.ShardKey
 	aliases := &opts.ROpts.Aliases
 	aliases.Arg1.dest = &opts.ROpts.Procedure
 	aliases.Endpoint.dest = &opts.ROpts.Procedure
@@ -140,23 +268,60 @@ func (t timeMillisFlag) String() string {
 }
 
 func (t *timeMillisFlag) UnmarshalFlag(value string) error {
+	d, err := parseDurationMillis(value)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return errNonPositiveDuration
+	}
+
+	t.setDuration(d)
+	return nil
+}
+
+// parseDurationMillis parses a duration, treating values without a unit
+// as milliseconds.
+func parseDurationMillis(value string) (time.Duration, error) {
 	valueInt, err := strconv.Atoi(value)
 	if err == nil {
 		// We received a number without a unit, assume milliseconds.
-		t.setDuration(time.Duration(valueInt) * time.Millisecond)
-		return nil
+		return time.Duration(valueInt) * time.Millisecond, nil
 	}
 
-	d, err := time.ParseDuration(value)
-	if err != nil {
-		return err
+	return time.ParseDuration(value)
+}
+
+// optionalBool is a bool flag that accepts an explicit value, such as
+// --flag=false, so that it can be used for options that default to true.
+type optionalBool struct {
+	set   bool
+	value bool
+}
+
+// valueOr returns the value of the flag, or def if the flag was not set.
+func (b optionalBool) valueOr(def bool) bool {
+	if !b.set {
+		return def
 	}
+	return b.value
+}
 
-	t.setDuration(d)
+func (b *optionalBool) UnmarshalFlag(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid bool value %q", value)
+	}
+	b.set = true
+	b.value = v
 	return nil
 }
 
-var errStringAliasMissing = errors.New("string alias missing destination")
+var (
+	errStringAliasMissing   = errors.New("string alias missing destination")
+	errKeyValueAliasMissing = errors.New("key-value alias missing destination")
+	errNonPositiveDuration  = errors.New("duration must be positive")
+)
 
 type stringAlias struct {
 	dest *string
@@ -174,6 +339,43 @@ func (s *stringAlias) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return unmarshal(s.dest)
 }
 
+// keyValueAlias is a flag that adds a key-value pair to the destination map,
+// and accepts pairs specified as either key:value or key=value.
+type keyValueAlias struct {
+	dest *map[string]string
+}
+
+func (a *keyValueAlias) UnmarshalFlag(value string) error {
+	if a.dest == nil {
+		return errKeyValueAliasMissing
+	}
+
+	i := strings.IndexAny(value, ":=")
+	if i < 0 {
+		return fmt.Errorf("expected key:value or key=value, got %q", value)
+	}
+
+	if *a.dest == nil {
+		*a.dest = make(map[string]string)
+	}
+	(*a.dest)[value[:i]] = value[i+1:]
+	return nil
+}
+
+// setDurationOptions applies --duration, which can't write to MaxDuration
+// directly since MaxDuration has a default value.
+func setDurationOptions(opts *Options) {
+	if opts.BOpts.Duration != 0 {
+		opts.BOpts.MaxDuration = opts.BOpts.Duration
+	}
+}
+
+func setOutputOptions(opts *Options) {
+	if opts.ROpts.NDJSON {
+		opts.ROpts.OutputFormat = outputFormatJSON
+	}
+}
+
 func setEncodingOptions(opts *Options) {
 	if opts.ROpts.Aliases.JSON {
 		opts.ROpts.Encoding = encoding.JSON
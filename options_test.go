@@ -50,6 +50,14 @@ func TestTimeMillisFlag(t *testing.T) {
 			value:   "notanumber",
 			wantErr: true,
 		},
+		{
+			value:   "0",
+			wantErr: true,
+		},
+		{
+			value:   "-1s",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -66,3 +74,62 @@ func TestTimeMillisFlag(t *testing.T) {
 		assert.Equal(t, tt.want.String(), timeMillis.String(), "String mismatch")
 	}
 }
+
+func TestOptionalBoolFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    bool
+		wantErr bool
+	}{
+		{
+			args: nil,
+			want: true,
+		},
+		{
+			args: []string{"--reuse-connection"},
+			want: true,
+		},
+		{
+			args: []string{"--reuse-connection=true"},
+			want: true,
+		},
+		{
+			args: []string{"--reuse-connection=false"},
+			want: false,
+		},
+		{
+			args:    []string{"--reuse-connection=maybe"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		parser, opts := newParser()
+		_, err := parser.ParseArgs(tt.args)
+		if tt.wantErr {
+			assert.Error(t, err, "ParseArgs(%v) should fail", tt.args)
+			continue
+		}
+
+		assert.NoError(t, err, "ParseArgs(%v) should not fail", tt.args)
+		assert.Equal(t, tt.want, opts.BOpts.ReuseConns.valueOr(true), "ParseArgs(%v) unexpected value", tt.args)
+	}
+}
+
+func TestKeyValueAlias(t *testing.T) {
+	parser, opts := newParser()
+	_, err := parser.ParseArgs([]string{"-B", "k1:v1", "--baggage", "k2=v2", "--baggage", "url=http://host:80"})
+	assert.NoError(t, err, "ParseArgs should not fail")
+	assert.Equal(t, map[string]string{
+		"k1":  "v1",
+		"k2":  "v2",
+		"url": "http://host:80",
+	}, opts.ROpts.Baggage, "Baggage mismatch")
+
+	parser, _ = newParser()
+	_, err = parser.ParseArgs([]string{"--baggage", "novalue"})
+	assert.Error(t, err, "ParseArgs should fail without a delimiter")
+
+	var alias keyValueAlias
+	assert.Equal(t, errKeyValueAliasMissing, alias.UnmarshalFlag("k:v"), "alias without a destination should fail")
+}
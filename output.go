@@ -21,10 +21,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 type output interface {
@@ -50,3 +53,212 @@ func (consoleOutput) Printf(format string, args ...interface{}) {
 func (consoleOutput) Warnf(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, format, args...)
 }
+
+// IsTerminal returns whether warnings and failures, which are written to
+// stderr, are written to a terminal.
+func (consoleOutput) IsTerminal() bool {
+	fi, err := os.Stderr.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// ANSI escape codes used to color output.
+const (
+	_colorRed    = "\x1b[31m"
+	_colorYellow = "\x1b[33m"
+	_colorReset  = "\x1b[0m"
+)
+
+type terminalOutput interface {
+	IsTerminal() bool
+}
+
+// colorEnabled returns whether failures and warnings should be colored. Colors
+// are only used for terminals, and can be disabled using --no-color or by
+// setting the NO_COLOR environment variable.
+func colorEnabled(out output, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	t, ok := out.(terminalOutput)
+	return ok && t.IsTerminal()
+}
+
+// colorOutput wraps an output to print failures in red, and warnings in yellow.
+type colorOutput struct {
+	output
+}
+
+// colorize wraps the message in the given color, keeping any trailing
+// newlines outside of the color codes.
+func colorize(color, msg string) string {
+	text := strings.TrimRight(msg, "\n")
+	return color + text + _colorReset + msg[len(text):]
+}
+
+func (o colorOutput) Fatalf(format string, args ...interface{}) {
+	o.output.Fatalf("%s", colorize(_colorRed, fmt.Sprintf(format, args...)))
+}
+
+func (o colorOutput) Warnf(format string, args ...interface{}) {
+	o.output.Warnf("%s", colorize(_colorYellow, fmt.Sprintf(format, args...)))
+}
+
+// The list of supported output formats.
+const (
+	outputFormatPretty = "pretty"
+	outputFormatJSON   = "json"
+	outputFormatTable  = "table"
+)
+
+// failureStage is the stage of a call that a failure occurred in.
+type failureStage string
+
+const (
+	stageParsing       failureStage = "parsing"
+	stageTransport     failureStage = "transport"
+	stageSerialization failureStage = "serialization"
+	stageApplication   failureStage = "application"
+	stageOutput        failureStage = "output"
+)
+
+// stagedOutput is an output that can report the stage of a failure.
+type stagedOutput interface {
+	StageFatalf(stage failureStage, format string, args ...interface{})
+}
+
+// stageFatalf is like out.Fatalf, but reports the stage of the failure
+// if the output supports it.
+func stageFatalf(out output, stage failureStage, format string, args ...interface{}) {
+	if so, ok := out.(stagedOutput); ok {
+		so.StageFatalf(stage, format, args...)
+		return
+	}
+	out.Fatalf(format, args...)
+}
+
+// flushOutput wraps an output to flush any buffered state, such as spans that
+// have not been reported, before exiting on a failure.
+type flushOutput struct {
+	output
+
+	flush func()
+}
+
+func (o flushOutput) Fatalf(format string, args ...interface{}) {
+	o.flush()
+	o.output.Fatalf(format, args...)
+}
+
+func (o flushOutput) StageFatalf(stage failureStage, format string, args ...interface{}) {
+	o.flush()
+	stageFatalf(o.output, stage, format, args...)
+}
+
+// jsonOutput wraps an output so that failures are reported as a single
+// JSON object, which is easier to consume in automation.
+type jsonOutput struct {
+	output
+
+	method string
+}
+
+type jsonFailure struct {
+	Error  string       `json:"error"`
+	Stage  failureStage `json:"stage,omitempty"`
+	Method string       `json:"method,omitempty"`
+}
+
+func (o jsonOutput) Fatalf(format string, args ...interface{}) {
+	o.StageFatalf("", format, args...)
+}
+
+func (o jsonOutput) StageFatalf(stage failureStage, format string, args ...interface{}) {
+	bs, err := json.Marshal(jsonFailure{
+		Error:  strings.TrimSpace(fmt.Sprintf(format, args...)),
+		Stage:  stage,
+		Method: o.method,
+	})
+	if err != nil {
+		o.output.Fatalf(format, args...)
+		return
+	}
+	o.output.Fatalf("%s\n", bs)
+}
+
+// quietOutput wraps an output to discard anything that is printed, while
+// still reporting warnings and failures.
+type quietOutput struct {
+	output
+}
+
+func (quietOutput) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (quietOutput) Printf(format string, args ...interface{}) {}
+
+func (o quietOutput) StageFatalf(stage failureStage, format string, args ...interface{}) {
+	stageFatalf(o.output, stage, format, args...)
+}
+
+// fileOutput wraps an output to write anything that is printed to a file,
+// while still reporting warnings and failures to the wrapped output.
+type fileOutput struct {
+	output
+
+	f *os.File
+}
+
+// newFileOutput creates the file at path, along with any missing parent
+// directories, and returns an output that prints to it.
+func newFileOutput(out output, path string) (fileOutput, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fileOutput{}, fmt.Errorf("failed to create directory for output file: %v", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fileOutput{}, fmt.Errorf("failed to create output file: %v", err)
+	}
+	return fileOutput{out, f}, nil
+}
+
+func (o fileOutput) Write(p []byte) (int, error) {
+	return o.f.Write(p)
+}
+
+func (o fileOutput) Printf(format string, args ...interface{}) {
+	if _, err := fmt.Fprintf(o.f, format, args...); err != nil {
+		stageFatalf(o.output, stageOutput, "Failed to write response to %v: %v\n", o.f.Name(), err)
+	}
+}
+
+func (o fileOutput) StageFatalf(stage failureStage, format string, args ...interface{}) {
+	stageFatalf(o.output, stage, format, args...)
+}
+
+// Close closes the output file.
+func (o fileOutput) Close() error {
+	return o.f.Close()
+}
+
+// formatBytes formats a size in bytes using binary units such as KiB and MiB,
+// or as an exact number of bytes if raw is set.
+func formatBytes(n int64, raw bool) string {
+	const (
+		kib = 1024
+		mib = 1024 * kib
+	)
+
+	switch {
+	case raw, n < kib:
+		return fmt.Sprintf("%v B", n)
+	case float64(n)/kib < 1023.95:
+		// Sizes just under 1 MiB would round up to 1024.0 KiB, so they're
+		// shown as 1.0 MiB instead.
+		return fmt.Sprintf("%.1f KiB", float64(n)/kib)
+	default:
+		return fmt.Sprintf("%.1f MiB", float64(n)/mib)
+	}
+}
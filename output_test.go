@@ -0,0 +1,273 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONOutputFatalf(t *testing.T) {
+	tests := []struct {
+		msg    string
+		stage  failureStage
+		method string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{
+			msg:    "no stage or method",
+			format: "Failed: %v\n",
+			args:   []interface{}{"bad"},
+			want:   `{"error":"Failed: bad"}` + "\n",
+		},
+		{
+			msg:    "stage and method",
+			stage:  stageTransport,
+			method: "Simple::foo",
+			format: "Failed while making call: %v\n",
+			args:   []interface{}{"timeout"},
+			want:   `{"error":"Failed while making call: timeout","stage":"transport","method":"Simple::foo"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			var got string
+			out := jsonOutput{
+				output: testOutput{
+					fatalf: func(format string, args ...interface{}) {
+						got = fmt.Sprintf(format, args...)
+					},
+				},
+				method: tt.method,
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				stageFatalf(out, tt.stage, tt.format, tt.args...)
+			}()
+			<-done
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFileOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "output")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	outBuf, warnBuf, out := getOutput(t)
+	path := filepath.Join(dir, "nested", "dir", "response.json")
+	fileOut, err := newFileOutput(out, path)
+	require.NoError(t, err, "Failed to create file output")
+
+	fileOut.Printf("hello %v\n", "world")
+	_, err = fileOut.Write([]byte("response"))
+	assert.NoError(t, err, "Write should not fail")
+	fileOut.Warnf("warning\n")
+	require.NoError(t, fileOut.Close(), "Failed to close file output")
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read output file")
+	assert.Equal(t, "hello world\nresponse", string(contents), "printed output should be written to the file")
+	assert.Empty(t, outBuf.String(), "file output should not print to the wrapped output")
+	assert.Equal(t, "warning\n", warnBuf.String(), "file output should keep warnings")
+
+	// The parent of the output file is a file, so the directory can't be created.
+	_, err = newFileOutput(out, filepath.Join(path, "response.json"))
+	assert.Error(t, err, "newFileOutput should fail if the parent is a file")
+}
+
+func TestQuietOutput(t *testing.T) {
+	outBuf, warnBuf, out := getOutput(t)
+	quiet := quietOutput{out}
+
+	quiet.Printf("hello %v\n", "world")
+	_, err := quiet.Write([]byte("response"))
+	assert.NoError(t, err, "Write should not fail")
+	quiet.Warnf("warning\n")
+
+	assert.Empty(t, outBuf.String(), "quiet output should discard printed output")
+	assert.Equal(t, "warning\n", warnBuf.String(), "quiet output should keep warnings")
+
+	var got string
+	quiet = quietOutput{jsonOutput{
+		output: testOutput{
+			fatalf: func(format string, args ...interface{}) {
+				got = fmt.Sprintf(format, args...)
+			},
+		},
+	}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stageFatalf(quiet, stageTransport, "Failed while making call: %v\n", "timeout")
+	}()
+	<-done
+
+	assert.Equal(t, `{"error":"Failed while making call: timeout","stage":"transport"}`+"\n", got,
+		"quiet output should keep the failure stage")
+}
+
+func TestFlushOutput(t *testing.T) {
+	var (
+		flushes int
+		got     string
+	)
+	jsonOut := jsonOutput{
+		output: testOutput{
+			fatalf: func(format string, args ...interface{}) {
+				got = fmt.Sprintf(format, args...)
+			},
+		},
+	}
+	out := flushOutput{jsonOut, func() { flushes++ }}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stageFatalf(out, stageTransport, "Failed while making call: %v\n", "timeout")
+	}()
+	<-done
+
+	assert.Equal(t, 1, flushes, "Output should be flushed before a failure")
+	assert.Equal(t, `{"error":"Failed while making call: timeout","stage":"transport"}`+"\n", got,
+		"flush output should keep the failure stage")
+
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		out.Fatalf("failed\n")
+	}()
+	<-done
+	assert.Equal(t, 2, flushes, "Output should be flushed before a failure")
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		raw  bool
+		want string
+	}{
+		{n: 0, want: "0 B"},
+		{n: 1, want: "1 B"},
+		{n: 1023, want: "1023 B"},
+		{n: 1024, want: "1.0 KiB"},
+		{n: 1075, want: "1.0 KiB"},
+		{n: 1536, want: "1.5 KiB"},
+		{n: 1024*1024 - 52, want: "1023.9 KiB"},
+		{n: 1024*1024 - 51, want: "1.0 MiB"},
+		{n: 1024*1024 - 1, want: "1.0 MiB"},
+		{n: 1024 * 1024, want: "1.0 MiB"},
+		{n: 3 * 1024 * 1024, want: "3.0 MiB"},
+		{n: 1024 * 1024 * 1024, want: "1024.0 MiB"},
+		{n: 1023, raw: true, want: "1023 B"},
+		{n: 1024, raw: true, want: "1024 B"},
+		{n: 3 * 1024 * 1024, raw: true, want: "3145728 B"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatBytes(tt.n, tt.raw), "formatBytes(%v, %v)", tt.n, tt.raw)
+	}
+}
+
+type terminalTestOutput struct {
+	output
+}
+
+func (terminalTestOutput) IsTerminal() bool { return true }
+
+func TestColorEnabled(t *testing.T) {
+	_, _, out := getOutput(t)
+	terminal := terminalTestOutput{out}
+
+	tests := []struct {
+		msg     string
+		out     output
+		noColor bool
+		envVar  string
+		want    bool
+	}{
+		{
+			msg:  "not a terminal",
+			out:  out,
+			want: false,
+		},
+		{
+			msg:  "terminal",
+			out:  terminal,
+			want: true,
+		},
+		{
+			msg:     "terminal with --no-color",
+			out:     terminal,
+			noColor: true,
+			want:    false,
+		},
+		{
+			msg:    "terminal with NO_COLOR",
+			out:    terminal,
+			envVar: "1",
+			want:   false,
+		},
+	}
+
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+	for _, tt := range tests {
+		os.Setenv("NO_COLOR", tt.envVar)
+		assert.Equal(t, tt.want, colorEnabled(tt.out, tt.noColor), tt.msg)
+	}
+}
+
+func TestColorOutput(t *testing.T) {
+	var warnings, got string
+	out := colorOutput{testOutput{
+		warnf: func(format string, args ...interface{}) {
+			warnings += fmt.Sprintf(format, args...)
+		},
+		fatalf: func(format string, args ...interface{}) {
+			got = fmt.Sprintf(format, args...)
+		},
+	}}
+
+	out.Warnf("careful %v\n", "now")
+	assert.Equal(t, "\x1b[33mcareful now\x1b[0m\n", warnings, "Warning should be yellow")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stageFatalf(out, stageTransport, "Failed while making call: %v\n", "timeout")
+	}()
+	<-done
+	assert.Equal(t, "\x1b[31mFailed while making call: timeout\x1b[0m\n", got, "Failure should be red")
+}
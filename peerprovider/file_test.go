@@ -1,6 +1,7 @@
 package peerprovider
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,6 +29,10 @@ func TestParseHostFile(t *testing.T) {
 			filename: "valid_peerlist.txt",
 			want:     []string{"1.1.1.1:1", "2.2.2.2:2"},
 		},
+		{
+			filename: "valid_peerlist_comments.txt",
+			want:     []string{"1.1.1.1:1", "2.2.2.2:2"},
+		},
 		{
 			filename: "invalid_peerlist.json",
 			errMsg:   errPeerListFile.Error(),
@@ -52,3 +57,30 @@ func TestParseHostFile(t *testing.T) {
 		}
 	}
 }
+
+func TestParseNewlineDelimitedPeersComments(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     []string
+	}{
+		{
+			contents: "# comment\n1.1.1.1:1\n",
+			want:     []string{"1.1.1.1:1"},
+		},
+		{
+			contents: "1.1.1.1:1 # comment\n\t# indented comment\n2.2.2.2:2\t# tab comment",
+			want:     []string{"1.1.1.1:1", "2.2.2.2:2"},
+		},
+		{
+			contents: "1.1.1.1:1#frag\n",
+			want:     []string{"1.1.1.1:1#frag"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := parseNewlineDelimitedPeers(strings.NewReader(tt.contents))
+		if assert.NoError(t, err, "parseNewlineDelimitedPeers(%q) should not fail", tt.contents) {
+			assert.Equal(t, tt.want, got, "parseNewlineDelimitedPeers(%q) mismatch", tt.contents)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package peerprovider
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/url"
 )
 
@@ -13,6 +14,7 @@ func init() {
 	RegisterPeerProvider("file", filePeerProvider{})
 	RegisterPeerProvider("http", httpPeerProvider{})
 	RegisterPeerProvider("https", httpPeerProvider{})
+	RegisterPeerProvider("srv", srvPeerProvider{lookupSRV: net.DefaultResolver.LookupSRV})
 }
 
 // Schemes returns supported peer provider protocol schemes.
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net"
 	"strings"
+	"unicode"
 
 	"gopkg.in/yaml.v2"
 )
@@ -14,7 +15,9 @@ import (
 var errPeerListFile = errors.New("peer list should be YAML, JSON, or newline delimited strings")
 
 // parsePeers accepts a file in YAML, JSON, or newline-delimited format,
-// containing host:port peer addresses.
+// containing host:port peer addresses. Newline-delimited files may contain
+// blank lines and comments starting with a "#" at the start of a line or after
+// whitespace.
 func parsePeers(contents []byte) ([]string, error) {
 	// Try as JSON.
 	hosts, err := parseYAMLPeers(contents)
@@ -45,7 +48,7 @@ func parseNewlineDelimitedPeers(r io.Reader) ([]string, error) {
 			return nil, err
 		}
 
-		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(stripComment(line))
 		if line == "" {
 			continue
 		}
@@ -59,3 +62,15 @@ func parseNewlineDelimitedPeers(r io.Reader) ([]string, error) {
 
 	return hosts, nil
 }
+
+// stripComment removes a trailing comment from the line. A "#" only starts a
+// comment at the start of the line or after whitespace, so that peers such as
+// URLs with fragments are not cut short.
+func stripComment(line string) string {
+	for i, c := range line {
+		if c == '#' && (i == 0 || unicode.IsSpace(rune(line[i-1]))) {
+			return line[:i]
+		}
+	}
+	return line
+}
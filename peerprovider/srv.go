@@ -0,0 +1,44 @@
+package peerprovider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// srvPeerProvider resolves peers using DNS SRV records, e.g.,
+// srv://_thrift._tcp.service.consul. By default, the peers are TChannel
+// host:ports, but a scheme can be specified using the "scheme" query
+// parameter, e.g., srv://_http._tcp.service.consul?scheme=http.
+type srvPeerProvider struct {
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+func (p srvPeerProvider) Resolve(ctx context.Context, url *url.URL) ([]string, error) {
+	name := url.Host
+	if name == "" {
+		return nil, fmt.Errorf("SRV peer provider URL %q must specify a name, e.g., srv://_thrift._tcp.service.consul", url.String())
+	}
+
+	_, addrs, err := p.lookupSRV(ctx, "" /* service */, "" /* proto */, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up SRV records for %q: %v", name, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("SRV records for %q have no targets", name)
+	}
+
+	scheme := url.Query().Get("scheme")
+	peers := make([]string, len(addrs))
+	for i, addr := range addrs {
+		peer := net.JoinHostPort(strings.TrimSuffix(addr.Target, "."), strconv.Itoa(int(addr.Port)))
+		if scheme != "" {
+			peer = scheme + "://" + peer
+		}
+		peers[i] = peer
+	}
+	return peers, nil
+}
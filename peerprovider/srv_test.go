@@ -0,0 +1,83 @@
+package peerprovider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSRVResolve(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "host1.service.consul.", Port: 21300},
+		{Target: "host2.service.consul.", Port: 21301},
+	}
+
+	tests := []struct {
+		msg       string
+		url       string
+		records   []*net.SRV
+		lookupErr error
+		wantName  string
+		want      []string
+		wantErr   string
+	}{
+		{
+			msg:      "host:port peers",
+			url:      "srv://_thrift._tcp.service.consul",
+			records:  records,
+			wantName: "_thrift._tcp.service.consul",
+			want:     []string{"host1.service.consul:21300", "host2.service.consul:21301"},
+		},
+		{
+			msg:      "peers with scheme",
+			url:      "srv://_http._tcp.service.consul?scheme=http",
+			records:  records,
+			wantName: "_http._tcp.service.consul",
+			want:     []string{"http://host1.service.consul:21300", "http://host2.service.consul:21301"},
+		},
+		{
+			msg:     "missing name",
+			url:     "srv:///",
+			wantErr: "must specify a name",
+		},
+		{
+			msg:       "lookup fails",
+			url:       "srv://_thrift._tcp.service.consul",
+			lookupErr: errors.New("no such host"),
+			wantName:  "_thrift._tcp.service.consul",
+			wantErr:   `failed to look up SRV records for "_thrift._tcp.service.consul": no such host`,
+		},
+		{
+			msg:      "no targets",
+			url:      "srv://_thrift._tcp.service.consul",
+			wantName: "_thrift._tcp.service.consul",
+			wantErr:  `SRV records for "_thrift._tcp.service.consul" have no targets`,
+		},
+	}
+
+	for _, tt := range tests {
+		var gotName string
+		pp := srvPeerProvider{
+			lookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				gotName = name
+				return name, tt.records, tt.lookupErr
+			},
+		}
+
+		peers, err := pp.Resolve(context.Background(), mustParseURL(tt.url))
+		assert.Equal(t, tt.wantName, gotName, "%v: unexpected SRV name", tt.msg)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "%v: expected error", tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, "%v: error resolving peers", tt.msg) {
+			assert.Equal(t, tt.want, peers, "%v: unexpected peers", tt.msg)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// _profileSectionPrefix is the prefix of config file sections that define a
+// named profile, e.g., [profiles.prod].
+const _profileSectionPrefix = "profiles."
+
+// configProfiles is a config file split into the defaults, and the options
+// for each named profile.
+type configProfiles struct {
+	defaults []byte
+	profiles map[string][]byte
+}
+
+// splitProfiles splits the contents of a config file into the defaults and
+// the profile sections. The options of each profile are not in a section,
+// so they are matched against all option groups. Lines that are removed are
+// replaced with blank lines, so errors report the line in the config file.
+func splitProfiles(contents []byte) configProfiles {
+	lines := strings.Split(string(contents), "\n")
+
+	// owners has the profile of each line, with "" for the defaults.
+	owners := make([]string, len(lines))
+	var profile string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			profile = ""
+			section := strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			if strings.HasPrefix(section, _profileSectionPrefix) {
+				// The section header is not part of the profile's options.
+				profile = strings.TrimPrefix(section, _profileSectionPrefix)
+				owners[i] = profile
+				lines[i] = ""
+				continue
+			}
+		}
+		owners[i] = profile
+	}
+
+	filter := func(owner string) []byte {
+		var buf bytes.Buffer
+		for i, line := range lines {
+			if owners[i] == owner {
+				buf.WriteString(line)
+			}
+			buf.WriteString("\n")
+		}
+		return buf.Bytes()
+	}
+
+	split := configProfiles{
+		defaults: filter(""),
+		profiles: make(map[string][]byte),
+	}
+	for _, owner := range owners {
+		if _, ok := split.profiles[owner]; owner != "" && !ok {
+			split.profiles[owner] = filter(owner)
+		}
+	}
+	return split
+}
+
+// applyProfile parses the options of the given profile on top of the
+// defaults already read by the parser. Peers in the profile replace any
+// peers in the defaults, so a profile never calls the peers of another
+// environment.
+func applyProfile(parser *flags.Parser, opts *Options, configFile string, contents []byte) error {
+	profileParser, profileOnly := newParser()
+	if err := parseIni(profileParser, configFile, contents); err != nil {
+		return err
+	}
+
+	if len(profileOnly.TOpts.Peers) > 0 || profileOnly.TOpts.PeerList != "" {
+		opts.TOpts.Peers = nil
+		opts.TOpts.PeerList = ""
+	}
+	return parseIni(parser, configFile, contents)
+}
+
+// parseIni parses the contents of the given config file.
+func parseIni(parser *flags.Parser, configFile string, contents []byte) error {
+	if err := flags.NewIniParser(parser).Parse(bytes.NewReader(contents)); err != nil {
+		if iniErr, ok := err.(*flags.IniError); ok {
+			// The contents are not read from the file, so the error does not
+			// include the file name.
+			iniErr.File = configFile
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitProfiles(t *testing.T) {
+	contents := `timeout = 1s
+[transport]
+caller = dev
+[profiles.prod]
+peer = 1.1.1.1:1
+  [ profiles.staging ]
+peer = 2.2.2.2:2
+[benchmark]
+warmup = 5`
+
+	split := splitProfiles([]byte(contents))
+	assert.Equal(t, "timeout = 1s\n[transport]\ncaller = dev\n\n\n\n\n[benchmark]\nwarmup = 5\n", string(split.defaults), "Unexpected defaults")
+	assert.Equal(t, map[string][]byte{
+		"prod":    []byte("\n\n\n\npeer = 1.1.1.1:1\n\n\n\n\n"),
+		"staging": []byte("\n\n\n\n\n\npeer = 2.2.2.2:2\n\n\n"),
+	}, split.profiles, "Unexpected profiles")
+}
+
+func TestSplitProfilesNoProfiles(t *testing.T) {
+	contents := "[request]\ntimeout = 2s"
+	split := splitProfiles([]byte(contents))
+	assert.Equal(t, contents+"\n", string(split.defaults), "Defaults should be unchanged")
+	assert.Empty(t, split.profiles, "Expected no profiles")
+}
@@ -21,14 +21,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"strings"
-	"time"
 
+	"github.com/yarpc/yab/client"
 	"github.com/yarpc/yab/encoding"
 	"github.com/yarpc/yab/transport"
 
@@ -36,14 +39,36 @@ import (
 )
 
 var (
-	errUnrecognizedEncoding = errors.New("unrecognized encoding, must be one of: json, thrift, raw")
+	errUnrecognizedEncoding = errors.New("unrecognized encoding, must be one of: json, thrift, raw, proto")
 	errMissingProcedure     = errors.New("no procedure specified, specify --procedure [procedure]")
+	errInlineAndFile        = errors.New("cannot specify both inline input and a file")
+	errEmptyStdin           = errors.New(`no input read from stdin, "-" requires input to be piped to yab`)
+	errEmptyRequestList     = errors.New("request list does not contain any requests")
+	errRequestAndList       = errors.New("cannot specify both a request body and a request list")
+	errFieldsAndBody        = errors.New("cannot specify both --field and a request body or request list")
+	errTemplateAndList      = errors.New("cannot use --template with a request list or --mix")
+	errMethodArgsAndBody    = errors.New("cannot specify both --thrift-method-args and a request body, --field or request list")
+	errNoThriftEncoding     = errors.New("--no-thrift can only be used with the json or raw encodings")
+
+	// _envVarRegex matches environment variable references in the form ${VAR}.
+	_envVarRegex = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
 )
 
 // getRequestInput gets the byte body passed in by the user via flags or through a file.
 func getRequestInput(inline, file string) ([]byte, error) {
+	if inline != "" && file != "" {
+		return nil, errInlineAndFile
+	}
+
 	if file == "-" || inline == "-" {
-		return ioutil.ReadAll(os.Stdin)
+		bs, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from stdin: %v", err)
+		}
+		if len(bs) == 0 {
+			return nil, errEmptyStdin
+		}
+		return bs, nil
 	}
 
 	if file != "" {
@@ -62,6 +87,104 @@ func getRequestInput(inline, file string) ([]byte, error) {
 	return nil, nil
 }
 
+type fieldValuer interface {
+	FieldValue(name, value string) (interface{}, error)
+}
+
+// getFieldsInput builds a flat JSON request body from name=value fields.
+// Serializers with a spec, such as Thrift, convert values to the type of the
+// field. Otherwise, values are parsed as YAML scalars, so numbers and
+// booleans keep their type.
+func getFieldsInput(fields []string, serializer encoding.Serializer) ([]byte, error) {
+	valuer, _ := serializer.(fieldValuer)
+
+	body := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid field %q, fields must be specified as name=value", field)
+		}
+
+		name, value := parts[0], parts[1]
+		if _, ok := body[name]; ok {
+			return nil, fmt.Errorf("field %q is specified multiple times", name)
+		}
+
+		if valuer != nil {
+			v, err := valuer.FieldValue(name, value)
+			if err != nil {
+				return nil, err
+			}
+			body[name] = v
+			continue
+		}
+
+		body[name] = value
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+			continue
+		}
+		switch parsed.(type) {
+		case bool, int, int64, uint64, float64:
+			body[name] = parsed
+		}
+	}
+
+	return json.Marshal(body)
+}
+
+// getRequestList reads multiple request bodies from a file, which contains
+// either a JSON array of request bodies, or a request body per line.
+func getRequestList(file string) ([][]byte, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open request list: %v", err)
+	}
+
+	var bodies [][]byte
+	contents = bytes.TrimSpace(contents)
+	if bytes.HasPrefix(contents, []byte("[")) {
+		var list []json.RawMessage
+		if err := json.Unmarshal(contents, &list); err != nil {
+			return nil, fmt.Errorf("failed to parse request list: %v", err)
+		}
+		for _, body := range list {
+			bodies = append(bodies, []byte(body))
+		}
+	} else {
+		for _, line := range bytes.Split(contents, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				bodies = append(bodies, line)
+			}
+		}
+	}
+
+	if len(bodies) == 0 {
+		return nil, errEmptyRequestList
+	}
+	return bodies, nil
+}
+
+// expandEnv replaces ${VAR} references in the input with the value of the
+// environment variable VAR. Unset variables are an error, unless allowMissing
+// is set, in which case they are replaced with an empty string.
+func expandEnv(input []byte, allowMissing bool) ([]byte, error) {
+	var missing []string
+	expanded := _envVarRegex.ReplaceAllFunc(input, func(ref []byte) []byte {
+		name := string(_envVarRegex.FindSubmatch(ref)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok && !allowMissing {
+			missing = append(missing, name)
+		}
+		return []byte(value)
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables are not set: %v", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
 func getHeaders(inline, file string, override map[string]string) (map[string]string, error) {
 	contents, err := getRequestInput(inline, file)
 	if err != nil {
@@ -84,28 +207,72 @@ func getHeaders(inline, file string, override map[string]string) (map[string]str
 	return headers, nil
 }
 
+type onewayer interface {
+	IsOneway() bool
+}
+
+// newThriftSerializer creates a Thrift serializer. With --thrift-no-validate,
+// definitions that fail to compile are ignored.
+func newThriftSerializer(opts RequestOptions) (encoding.Serializer, error) {
+	if opts.ThriftNoValidate {
+		return encoding.NewLenientThrift(opts.ThriftFile, opts.Procedure, opts.ThriftMultiplexed, opts.ThriftIncludePaths...)
+	}
+	return encoding.NewThrift(opts.ThriftFile, opts.Procedure, opts.ThriftMultiplexed, opts.ThriftIncludePaths...)
+}
+
 // NewSerializer creates a Serializer for the specific encoding.
 func NewSerializer(opts RequestOptions) (encoding.Serializer, error) {
+	if opts.ThriftRemoteMethods {
+		if opts.Procedure != "" || opts.Health {
+			return nil, errRemoteAndProcedure
+		}
+		return opts.Encoding.GetThriftIDL()
+	}
+
 	if opts.Health {
-		if opts.Procedure != "" {
-			return nil, errHealthAndProcedure
+		if opts.Procedure == "" {
+			return opts.Encoding.GetHealth()
 		}
 
-		return opts.Encoding.GetHealth()
+		// The procedure's serializer is only used to give a more specific
+		// error for oneway methods.
+		serializer, err := newProcedureSerializer(opts)
+		if o, ok := serializer.(onewayer); ok && err == nil && o.IsOneway() {
+			return nil, errHealthAndOneway
+		}
+		return nil, errHealthAndProcedure
 	}
 
-	// Thrift returns available methods if one is not specified, while the other
-	// encodings will just return an error, so only do the empty procedure check
-	// for non-Thrift encodings.
+	return newProcedureSerializer(opts)
+}
+
+// newProcedureSerializer creates a Serializer for the procedure, using the
+// specified or detected encoding.
+func newProcedureSerializer(opts RequestOptions) (encoding.Serializer, error) {
+	// Thrift and Protobuf return available methods if one is not specified,
+	// while the other encodings will just return an error, so only do the empty
+	// procedure check for the other encodings.
 	e := detectEncoding(opts)
-	if e == encoding.Thrift {
-		return encoding.NewThrift(opts.ThriftFile, opts.Procedure, opts.ThriftMultiplexed)
+	switch e {
+	case encoding.Thrift:
+		return newThriftSerializer(opts)
+	case encoding.Protobuf:
+		return encoding.NewProtobuf(opts.ProtoFile, opts.ProtoImportPaths, opts.Procedure)
 	}
 
 	if opts.Procedure == "" {
 		return nil, errMissingProcedure
 	}
 
+	// JSON and raw requests don't use an IDL, so a Thrift or Protobuf file
+	// would be silently ignored, which is usually a mistake.
+	if opts.ThriftFile != "" {
+		return nil, fmt.Errorf("cannot use a Thrift file with the %v encoding, use --encoding thrift or remove --thrift", e)
+	}
+	if opts.ProtoFile != "" {
+		return nil, fmt.Errorf("cannot use a .proto file with the %v encoding, use --encoding proto or remove --proto", e)
+	}
+
 	switch e {
 	case encoding.JSON:
 		return encoding.NewJSON(opts.Procedure), nil
@@ -116,11 +283,48 @@ func NewSerializer(opts RequestOptions) (encoding.Serializer, error) {
 	return nil, errUnrecognizedEncoding
 }
 
+// withoutThrift returns the options used with --no-thrift, which calls a
+// service without an IDL. The encoding defaults to JSON, and any Thrift file,
+// such as one set in a config file or a profile, is ignored.
+func withoutThrift(opts RequestOptions) (RequestOptions, error) {
+	if !opts.NoThrift {
+		return opts, nil
+	}
+
+	switch opts.Encoding {
+	case encoding.UnspecifiedEncoding:
+		opts.Encoding = encoding.JSON
+	case encoding.Thrift, encoding.Protobuf:
+		return opts, errNoThriftEncoding
+	}
+	opts.ThriftFile = ""
+	return opts, nil
+}
+
+// withoutDefaultThrift ignores a Thrift file set by the defaults, such as a
+// config file, when the encoding doesn't use an IDL, printing a warning rather
+// than failing the request. A Thrift file passed using --thrift is not ignored.
+func withoutDefaultThrift(opts RequestOptions, out output) RequestOptions {
+	if opts.ThriftFile == "" || opts.explicitThrift {
+		return opts
+	}
+
+	if e := detectEncoding(opts); e == encoding.JSON || e == encoding.Raw {
+		out.Warnf("WARNING: Ignoring the Thrift file %v from the defaults, since it cannot be used with the %v encoding\n", opts.ThriftFile, e)
+		opts.ThriftFile = ""
+	}
+	return opts
+}
+
 func detectEncoding(opts RequestOptions) encoding.Encoding {
 	if opts.Encoding != encoding.UnspecifiedEncoding {
 		return opts.Encoding
 	}
 
+	if opts.ProtoFile != "" {
+		return encoding.Protobuf
+	}
+
 	if strings.Contains(opts.Procedure, "::") || opts.ThriftFile != "" {
 		return encoding.Thrift
 	}
@@ -128,25 +332,15 @@ func detectEncoding(opts RequestOptions) encoding.Encoding {
 	return encoding.JSON
 }
 
-// prepares the request by injecting metadata, applying plugin-based transport middleware,
-// before finally adding any user-provided override values
+// prepareRequest prepares the request using the command line options, the same
+// way as the client package, including applying plugin-based transport middleware.
 func prepareRequest(req *transport.Request, headers map[string]string, opts Options) (*transport.Request, error) {
-	// Apply command line arguments
-	timeout := opts.ROpts.Timeout.Duration()
-	if timeout == 0 {
-		timeout = time.Second
-	}
-	req.Headers = headers
-	req.TransportHeaders = opts.TOpts.TransportHeaders
-	req.Baggage = opts.ROpts.Baggage
-	req.Timeout = timeout
-
-	// Add request metadata
-	req.TargetService = opts.TOpts.ServiceName
-	req.ShardKey = opts.TOpts.ShardKey
-
-	// Apply middleware
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	return transport.ApplyInterceptor(ctx, req)
+	return client.Prepare(context.Background(), req, client.Options{
+		TargetService:    opts.TOpts.ServiceName,
+		ShardKey:         opts.TOpts.ShardKey,
+		Headers:          headers,
+		TransportHeaders: opts.TOpts.TransportHeaders,
+		Baggage:          opts.ROpts.Baggage,
+		Timeout:          opts.ROpts.Timeout.Duration(),
+	})
 }
@@ -0,0 +1,85 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/yarpc/yab/transport"
+
+	"go.uber.org/atomic"
+)
+
+const (
+	// _requestIDHeader is the application header used to send the request ID
+	// of each call when --request-id-prefix is set.
+	_requestIDHeader = "x-request-id"
+
+	// _httpRequestIDHeader is the HTTP header used to send the request ID,
+	// since application headers are prefixed with Rpc-Header- over HTTP.
+	_httpRequestIDHeader = "X-Request-Id"
+)
+
+// requestIDs generates request IDs of the form <prefix>-<n>, where n is
+// incremented for each call, so calls can be found in server logs.
+type requestIDs struct {
+	prefix   string
+	protocol transport.Protocol
+	last     *atomic.Int64
+}
+
+// newRequestIDs returns a generator for request IDs with the given prefix,
+// for calls made using the given protocol. It returns nil if the prefix is
+// empty, since request IDs are not sent.
+func newRequestIDs(prefix string, protocol transport.Protocol) *requestIDs {
+	if prefix == "" {
+		return nil
+	}
+	return &requestIDs{prefix: prefix, protocol: protocol, last: atomic.NewInt64(0)}
+}
+
+// withRequestID returns a copy of the request with the next request ID set
+// in its headers, along with the ID. HTTP calls send the ID as a transport
+// header. The request's headers may be shared by concurrent calls, so they
+// are copied rather than modified.
+func (r *requestIDs) withRequestID(req *transport.Request) (*transport.Request, string) {
+	if r == nil {
+		return req, ""
+	}
+
+	id := fmt.Sprintf("%v-%v", r.prefix, r.last.Inc())
+	withID := *req
+	if r.protocol == transport.HTTP {
+		withID.TransportHeaders = withHeader(req.TransportHeaders, _httpRequestIDHeader, id)
+	} else {
+		withID.Headers = withHeader(req.Headers, _requestIDHeader, id)
+	}
+	return &withID, id
+}
+
+// requestIDOf returns the request ID sent with the request, if any.
+func requestIDOf(req *transport.Request) (string, bool) {
+	if id, ok := req.TransportHeaders[_httpRequestIDHeader]; ok {
+		return id, true
+	}
+	id, ok := req.Headers[_requestIDHeader]
+	return id, ok
+}
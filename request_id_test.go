@@ -0,0 +1,102 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDsDisabled(t *testing.T) {
+	r := newRequestIDs("", transport.TChannel)
+	assert.Nil(t, r, "No request IDs should be generated without a prefix")
+
+	req := &transport.Request{Headers: map[string]string{"k": "v"}}
+	got, id := r.withRequestID(req)
+	assert.True(t, req == got, "Request should be returned unchanged")
+	assert.Empty(t, id, "Request ID should be empty")
+}
+
+func TestRequestIDsIncrement(t *testing.T) {
+	r := newRequestIDs("foo", transport.TChannel)
+	headers := map[string]string{"k": "v"}
+	req := &transport.Request{Method: "Simple::foo", Headers: headers}
+
+	for _, want := range []string{"foo-1", "foo-2", "foo-3"} {
+		got, id := r.withRequestID(req)
+		assert.Equal(t, want, id, "Unexpected request ID")
+		assert.Equal(t, "Simple::foo", got.Method, "Request should be copied")
+		assert.Equal(t, map[string]string{
+			"k":              "v",
+			_requestIDHeader: want,
+		}, got.Headers, "Unexpected headers")
+	}
+
+	assert.Equal(t, map[string]string{"k": "v"}, headers, "Original headers should not be modified")
+}
+
+func TestRequestIDsHTTP(t *testing.T) {
+	r := newRequestIDs("foo", transport.HTTP)
+	headers := map[string]string{"k": "v"}
+	req := &transport.Request{Headers: headers}
+
+	got, id := r.withRequestID(req)
+	assert.Equal(t, "foo-1", id, "Unexpected request ID")
+	assert.Equal(t, map[string]string{_httpRequestIDHeader: "foo-1"}, got.TransportHeaders, "Request ID should be sent as a transport header")
+	assert.Equal(t, headers, got.Headers, "Request ID should not be sent as an application header")
+
+	gotID, ok := requestIDOf(got)
+	assert.True(t, ok, "Request ID should be found")
+	assert.Equal(t, "foo-1", gotID, "Unexpected request ID found")
+}
+
+func TestRequestIDsConcurrent(t *testing.T) {
+	const numCalls = 100
+
+	r := newRequestIDs("foo", transport.TChannel)
+	req := &transport.Request{}
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = make(map[string]struct{})
+	)
+	for i := 0; i < numCalls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, id := r.withRequestID(req)
+
+			mu.Lock()
+			ids[id] = struct{}{}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, ids, numCalls, "Request IDs should be unique")
+	assert.Contains(t, ids, "foo-100", "Request IDs should be incremented for each call")
+}
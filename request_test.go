@@ -90,6 +90,16 @@ func TestGetRequestInput(t *testing.T) {
 			inline: "{",
 			want:   []byte("{"),
 		},
+		{
+			inline: "{}",
+			file:   "testdata/valid.json",
+			errMsg: errInlineAndFile.Error(),
+		},
+		{
+			inline: "-",
+			file:   "-",
+			errMsg: errInlineAndFile.Error(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -117,6 +127,262 @@ func TestGetRequestInput(t *testing.T) {
 	}
 }
 
+func TestGetRequestInputEmptyStdin(t *testing.T) {
+	origStdin := os.Stdin
+	defer func() {
+		os.Stdin = origStdin
+	}()
+
+	filename := writeFile(t, "stdin", "")
+	defer os.Remove(filename)
+
+	f, err := os.Open(filename)
+	require.NoError(t, err, "Open failed")
+	defer f.Close()
+	os.Stdin = f
+
+	_, err = getRequestInput("-", "")
+	assert.Equal(t, errEmptyStdin, err, "Expected error for empty stdin")
+}
+
+func TestGetRequestList(t *testing.T) {
+	tests := []struct {
+		msg      string
+		contents string
+		want     []string
+		wantErr  string
+	}{
+		{
+			msg:      "JSON array",
+			contents: `[{"key": "a"}, {"key": "b"}]`,
+			want:     []string{`{"key": "a"}`, `{"key": "b"}`},
+		},
+		{
+			msg:      "request per line",
+			contents: "{\"key\": \"a\"}\n\n  {\"key\": \"b\"}  \n",
+			want:     []string{`{"key": "a"}`, `{"key": "b"}`},
+		},
+		{
+			msg:      "invalid JSON array",
+			contents: `[{"key": "a"}`,
+			wantErr:  "failed to parse request list",
+		},
+		{
+			msg:      "empty file",
+			contents: "\n\n",
+			wantErr:  errEmptyRequestList.Error(),
+		},
+		{
+			msg:      "empty JSON array",
+			contents: "[]",
+			wantErr:  errEmptyRequestList.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		file := writeFile(t, "requests", tt.contents)
+		defer os.Remove(file)
+
+		got, err := getRequestList(file)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, tt.msg)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, tt.msg) {
+			var gotStrs []string
+			for _, body := range got {
+				gotStrs = append(gotStrs, string(body))
+			}
+			assert.Equal(t, tt.want, gotStrs, tt.msg)
+		}
+	}
+
+	_, err := getRequestList("/fake/file")
+	assert.Contains(t, err.Error(), "failed to open request list", "Missing file should fail")
+}
+
+func TestGetFieldsInput(t *testing.T) {
+	thriftFile := writeFile(t, "fields", `
+    service Svc {
+      void method(
+        1: i32 count,
+        2: string name,
+        3: bool flag,
+        4: binary data,
+        5: double ratio,
+        6: i8 small,
+        7: i64 id,
+        8: Color color,
+        9: list<i32> ids,
+        10: Point point,
+        11: ID typedefID
+      )
+    }
+
+    enum Color { RED, GREEN }
+    typedef i64 ID
+    struct Point { 1: i32 x, 2: i32 y }
+  `)
+	defer os.Remove(thriftFile)
+
+	thriftSerializer, err := encoding.NewThrift(thriftFile, "Svc::method", false /* multiplexed */)
+	require.NoError(t, err, "Failed to create Thrift serializer")
+	jsonSerializer := encoding.NewJSON("method")
+
+	tests := []struct {
+		msg        string
+		serializer encoding.Serializer
+		fields     []string
+		want       string
+		wantErr    string
+	}{
+		{
+			msg:        "Thrift types",
+			serializer: thriftSerializer,
+			fields:     []string{"count=5", "name=5", "flag=true", "data=123", "ratio=0.5"},
+			want:       `{"count":5,"data":"123","flag":true,"name":"5","ratio":0.5}`,
+		},
+		{
+			msg:        "Thrift strings that look like other types",
+			serializer: thriftSerializer,
+			fields:     []string{"name=123", "data=true"},
+			want:       `{"data":"true","name":"123"}`,
+		},
+		{
+			msg:        "Thrift integers, enums and typedefs",
+			serializer: thriftSerializer,
+			fields:     []string{"small=-128", "id=9007199254740993", "color=GREEN", "typedefID=5"},
+			want:       `{"color":"GREEN","id":9007199254740993,"small":-128,"typedefID":5}`,
+		},
+		{
+			msg:        "Thrift containers and structs as JSON",
+			serializer: thriftSerializer,
+			fields:     []string{"ids=[1, 2]", `point={"x": 1, "y": 2}`},
+			want:       `{"ids":[1,2],"point":{"x":1,"y":2}}`,
+		},
+		{
+			msg:        "unknown Thrift field is used as is",
+			serializer: thriftSerializer,
+			fields:     []string{"unknown=5"},
+			want:       `{"unknown":"5"}`,
+		},
+		{
+			msg:        "invalid Thrift integer",
+			serializer: thriftSerializer,
+			fields:     []string{"count=five"},
+			wantErr:    `invalid value "five" for i32 argument "count"`,
+		},
+		{
+			msg:        "Thrift integer out of range",
+			serializer: thriftSerializer,
+			fields:     []string{"small=128"},
+			wantErr:    `invalid value "128" for i8 argument "small"`,
+		},
+		{
+			msg:        "invalid Thrift bool",
+			serializer: thriftSerializer,
+			fields:     []string{"flag=maybe"},
+			wantErr:    `invalid value "maybe" for bool argument "flag"`,
+		},
+		{
+			msg:        "invalid Thrift list",
+			serializer: thriftSerializer,
+			fields:     []string{"ids=1,2"},
+			wantErr:    `invalid value "1,2" for list<i32> argument "ids"`,
+		},
+		{
+			msg:        "value containing =",
+			serializer: thriftSerializer,
+			fields:     []string{"name=a=b"},
+			want:       `{"name":"a=b"}`,
+		},
+		{
+			msg:        "JSON falls back to YAML scalars",
+			serializer: jsonSerializer,
+			fields:     []string{"count=5", "name=foo", "list=[1, 2]"},
+			want:       `{"count":5,"list":"[1, 2]","name":"foo"}`,
+		},
+		{
+			msg:        "missing value",
+			serializer: thriftSerializer,
+			fields:     []string{"count"},
+			wantErr:    `invalid field "count"`,
+		},
+		{
+			msg:        "missing name",
+			serializer: thriftSerializer,
+			fields:     []string{"=5"},
+			wantErr:    `invalid field "=5"`,
+		},
+		{
+			msg:        "duplicate field",
+			serializer: thriftSerializer,
+			fields:     []string{"count=1", "count=2"},
+			wantErr:    `field "count" is specified multiple times`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := getFieldsInput(tt.fields, tt.serializer)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, tt.msg)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, tt.msg) {
+			assert.JSONEq(t, tt.want, string(got), tt.msg)
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("YAB_TEST_TENANT", "tenant1")
+	defer os.Unsetenv("YAB_TEST_TENANT")
+	os.Unsetenv("YAB_TEST_MISSING")
+
+	tests := []struct {
+		input        string
+		allowMissing bool
+		want         string
+		wantErr      string
+	}{
+		{
+			input: `{"tenant": "${YAB_TEST_TENANT}"}`,
+			want:  `{"tenant": "tenant1"}`,
+		},
+		{
+			input: `{"s": "\"$YAB_TEST_TENANT\" costs $5"}`,
+			want:  `{"s": "\"$YAB_TEST_TENANT\" costs $5"}`,
+		},
+		{
+			input:   `{"tenant": "${YAB_TEST_MISSING}", "other": "${YAB_TEST_MISSING}"}`,
+			wantErr: "environment variables are not set: YAB_TEST_MISSING, YAB_TEST_MISSING",
+		},
+		{
+			input:        `{"tenant": "${YAB_TEST_MISSING}"}`,
+			allowMissing: true,
+			want:         `{"tenant": ""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := expandEnv([]byte(tt.input), tt.allowMissing)
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr, "expandEnv(%s)", tt.input)
+			continue
+		}
+
+		if assert.NoError(t, err, "expandEnv(%s)", tt.input) {
+			assert.Equal(t, tt.want, string(got), "expandEnv(%s)", tt.input)
+		}
+	}
+}
+
 func TestGetHeaders(t *testing.T) {
 	tests := []struct {
 		inline   string
@@ -211,6 +477,15 @@ func TestNewSerializer(t *testing.T) {
 			},
 			wantErr: errHealthAndProcedure.Error(),
 		},
+		{
+			encoding: encoding.Thrift,
+			opts: RequestOptions{
+				Health:     true,
+				ThriftFile: validThrift,
+				Procedure:  "Simple::fire",
+			},
+			wantErr: errHealthAndOneway.Error(),
+		},
 		{
 			encoding: encoding.Encoding("asd"),
 			opts:     RequestOptions{Procedure: "procedure"},
@@ -253,6 +528,20 @@ func TestNewSerializer(t *testing.T) {
 			opts:     RequestOptions{ThriftFile: validThrift},
 			wantErr:  "available services",
 		},
+		{
+			encoding: encoding.Protobuf,
+			wantErr:  encoding.ErrSpecifyProtoFile.Error(),
+		},
+		{
+			encoding: encoding.UnspecifiedEncoding,
+			opts:     RequestOptions{ProtoFile: validProto},
+			wantErr:  "available services",
+		},
+		{
+			encoding: encoding.UnspecifiedEncoding,
+			opts:     RequestOptions{ProtoFile: validProto, Procedure: "yab.test.Simple::Foo"},
+			want:     encoding.Protobuf,
+		},
 		{
 			encoding: encoding.JSON,
 			opts:     RequestOptions{Procedure: "procedure"},
@@ -263,6 +552,16 @@ func TestNewSerializer(t *testing.T) {
 			opts:     RequestOptions{Procedure: "procedure"},
 			want:     encoding.Raw,
 		},
+		{
+			encoding: encoding.JSON,
+			opts:     RequestOptions{ThriftFile: validThrift, Procedure: "Simple::foo"},
+			wantErr:  "cannot use a Thrift file with the json encoding",
+		},
+		{
+			encoding: encoding.Raw,
+			opts:     RequestOptions{ProtoFile: validProto, Procedure: "procedure"},
+			wantErr:  "cannot use a .proto file with the raw encoding",
+		},
 	}
 
 	for _, tt := range tests {
@@ -303,6 +602,10 @@ func TestDetectEncoding(t *testing.T) {
 			opts: RequestOptions{ThriftFile: validThrift, Procedure: "procedure"},
 			want: encoding.Thrift,
 		},
+		{
+			opts: RequestOptions{ProtoFile: validProto, Procedure: "yab.test.Simple::Foo"},
+			want: encoding.Protobuf,
+		},
 	}
 
 	for _, tt := range tests {
@@ -311,6 +614,96 @@ func TestDetectEncoding(t *testing.T) {
 	}
 }
 
+func TestWithoutThrift(t *testing.T) {
+	tests := []struct {
+		msg     string
+		opts    RequestOptions
+		want    RequestOptions
+		wantErr error
+	}{
+		{
+			msg:  "disabled",
+			opts: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod},
+			want: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod},
+		},
+		{
+			msg:  "defaults to JSON and ignores the Thrift file",
+			opts: RequestOptions{NoThrift: true, ThriftFile: validThrift, Procedure: fooMethod},
+			want: RequestOptions{NoThrift: true, Encoding: encoding.JSON, Procedure: fooMethod},
+		},
+		{
+			msg:  "raw encoding",
+			opts: RequestOptions{NoThrift: true, Encoding: encoding.Raw, Procedure: "procedure"},
+			want: RequestOptions{NoThrift: true, Encoding: encoding.Raw, Procedure: "procedure"},
+		},
+		{
+			msg:     "Thrift encoding",
+			opts:    RequestOptions{NoThrift: true, Encoding: encoding.Thrift, Procedure: fooMethod},
+			wantErr: errNoThriftEncoding,
+		},
+		{
+			msg:     "Protobuf encoding",
+			opts:    RequestOptions{NoThrift: true, Encoding: encoding.Protobuf, Procedure: fooMethod},
+			wantErr: errNoThriftEncoding,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := withoutThrift(tt.opts)
+		if tt.wantErr != nil {
+			assert.Equal(t, tt.wantErr, err, "Unexpected error for %v", tt.msg)
+			continue
+		}
+
+		if assert.NoError(t, err, "withoutThrift failed for %v", tt.msg) {
+			assert.Equal(t, tt.want, got, "Unexpected options for %v", tt.msg)
+		}
+	}
+}
+
+func TestWithoutDefaultThrift(t *testing.T) {
+	tests := []struct {
+		msg      string
+		opts     RequestOptions
+		want     RequestOptions
+		wantWarn string
+	}{
+		{
+			msg:  "Thrift encoding",
+			opts: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod},
+			want: RequestOptions{ThriftFile: validThrift, Procedure: fooMethod},
+		},
+		{
+			msg:  "explicit Thrift file with JSON",
+			opts: RequestOptions{Encoding: encoding.JSON, ThriftFile: validThrift, Procedure: fooMethod, explicitThrift: true},
+			want: RequestOptions{Encoding: encoding.JSON, ThriftFile: validThrift, Procedure: fooMethod, explicitThrift: true},
+		},
+		{
+			msg:      "default Thrift file with JSON",
+			opts:     RequestOptions{Encoding: encoding.JSON, ThriftFile: validThrift, Procedure: fooMethod},
+			want:     RequestOptions{Encoding: encoding.JSON, Procedure: fooMethod},
+			wantWarn: "Ignoring the Thrift file " + validThrift + " from the defaults",
+		},
+		{
+			msg:      "default Thrift file with raw",
+			opts:     RequestOptions{Encoding: encoding.Raw, ThriftFile: validThrift, Procedure: "procedure"},
+			want:     RequestOptions{Encoding: encoding.Raw, Procedure: "procedure"},
+			wantWarn: "cannot be used with the raw encoding",
+		},
+	}
+
+	for _, tt := range tests {
+		_, warnBuf, out := getOutput(t)
+		got := withoutDefaultThrift(tt.opts, out)
+		assert.Equal(t, tt.want, got, "Unexpected options for %v", tt.msg)
+		if tt.wantWarn == "" {
+			assert.Empty(t, warnBuf.String(), "Unexpected warning for %v", tt.msg)
+		} else {
+			assert.Contains(t, warnBuf.String(), tt.wantWarn, "Missing warning for %v", tt.msg)
+		}
+	}
+}
+
 func TestNewRequestWithMetadata(t *testing.T) {
 	req := &transport.Request{Method: "foo"}
 	topts := TransportOptions{ServiceName: "bar", ShardKey: "baz"}
@@ -6,6 +6,8 @@ if [ -z "$VERSION" ]; then
   exit 1
 fi
 
+LDFLAGS="-X main.gitCommit=$(git rev-parse --short HEAD)"
+
 OSs=(linux darwin)
 ARCHs=(amd64)
 
@@ -13,7 +15,7 @@ for OS in "${OSs[@]}"; do
   for ARCH in "${ARCHs[@]}"; do
     echo Building "$OS/$ARCH"
     mkdir -p "build/$OS-$ARCH" .
-    GOOS=$OS GOARCH=$ARCH go build -o "build/$OS-$ARCH/yab" .
+    GOOS=$OS GOARCH=$ARCH go build -ldflags "$LDFLAGS" -o "build/$OS-$ARCH/yab" .
     (cd "build/$OS-$ARCH" && zip "../yab-$VERSION-$OS-$ARCH.zip" yab)
   done
 done
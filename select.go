@@ -0,0 +1,97 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errEmptySelectPath = errors.New("select path cannot be empty")
+
+// splitSelectPath splits a path such as "results[0].name" or "results.0.name"
+// into its map keys and list indexes.
+func splitSelectPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, errEmptySelectPath
+	}
+
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []string
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key = part[:i]
+			for rest := part[i:]; rest != ""; {
+				end := strings.IndexByte(rest, ']')
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("invalid select path %q: unbalanced brackets in %q", path, part)
+				}
+				indexes = append(indexes, rest[1:end])
+				rest = rest[end+1:]
+			}
+		}
+
+		if key != "" {
+			segments = append(segments, key)
+		} else if len(indexes) == 0 {
+			return nil, fmt.Errorf("invalid select path %q: empty field name", path)
+		}
+		segments = append(segments, indexes...)
+	}
+	return segments, nil
+}
+
+// selectPath returns the value at the given path in a decoded response. The
+// path is a list of map keys and list indexes separated by dots, and list
+// indexes can also be written in brackets.
+func selectPath(v interface{}, path string) (interface{}, error) {
+	segments, err := splitSelectPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, segment := range segments {
+		prefix := strings.Join(segments[:i+1], ".")
+		switch typed := v.(type) {
+		case map[string]interface{}:
+			child, ok := typed[segment]
+			if !ok {
+				return nil, fmt.Errorf("%q not found in response", prefix)
+			}
+			v = child
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a valid list index", prefix)
+			}
+			if index < 0 || index >= len(typed) {
+				return nil, fmt.Errorf("%q is out of range, list has %v items", prefix, len(typed))
+			}
+			v = typed[index]
+		default:
+			return nil, fmt.Errorf("%q not found in response, cannot select from %T", prefix, v)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectPath(t *testing.T) {
+	response := map[string]interface{}{
+		"result": map[string]interface{}{
+			"name":  "foo",
+			"items": []interface{}{"a", map[string]interface{}{"id": 2}},
+		},
+		"count": 3,
+	}
+
+	tests := []struct {
+		path    string
+		want    interface{}
+		wantErr string
+	}{
+		{path: "count", want: 3},
+		{path: "result.name", want: "foo"},
+		{path: "result.items.0", want: "a"},
+		{path: "result.items[1].id", want: 2},
+		{path: "result.items[1]", want: map[string]interface{}{"id": 2}},
+		{path: "", wantErr: errEmptySelectPath.Error()},
+		{path: "result..name", wantErr: `invalid select path "result..name": empty field name`},
+		{path: "result.items[0", wantErr: `invalid select path "result.items[0": unbalanced brackets in "items[0"`},
+		{path: "result.missing", wantErr: `"result.missing" not found in response`},
+		{path: "result.items[2]", wantErr: `"result.items.2" is out of range, list has 2 items`},
+		{path: "result.items.x", wantErr: `"result.items.x" is not a valid list index`},
+		{path: "result.name.first", wantErr: `"result.name.first" not found in response, cannot select from string`},
+	}
+
+	for _, tt := range tests {
+		got, err := selectPath(response, tt.path)
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr, "selectPath(%q) error mismatch", tt.path)
+			continue
+		}
+		if assert.NoError(t, err, "selectPath(%q) failed", tt.path) {
+			assert.Equal(t, tt.want, got, "selectPath(%q) mismatch", tt.path)
+		}
+	}
+}
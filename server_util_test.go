@@ -23,6 +23,7 @@ package main
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/uber/jaeger-client-go"
@@ -117,6 +118,19 @@ func (methodsT) customArg3(arg3 []byte) handler {
 	}
 }
 
+// timeout sends the remaining time until the deadline of each call to the
+// given channel, and echoes the request.
+func (methodsT) timeout(timeouts chan<- time.Duration) handler {
+	return func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		deadline, _ := ctx.Deadline()
+		timeouts <- deadline.Sub(time.Now())
+		return &raw.Res{
+			Arg2: args.Arg2,
+			Arg3: args.Arg3,
+		}, nil
+	}
+}
+
 func (methodsT) errorIf(f func() bool) handler {
 	return func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
 		if f() {
@@ -21,6 +21,7 @@
 package main
 
 import (
+	"errors"
 	"io/ioutil"
 	"net/url"
 	"path/filepath"
@@ -32,6 +33,8 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+var errNegativeTemplateTimeout = errors.New("timeout in the template must be positive")
+
 type template struct {
 	Peers                     []string    `yaml:"peers"`
 	Peer                      string      `yaml:"peer"`
@@ -140,6 +143,7 @@ func readYAMLRequest(base string, contents []byte, templateArgs map[string]strin
 			return err
 		}
 		opts.ROpts.ThriftFile = thriftFileURL.Path
+		opts.ROpts.explicitThrift = true
 	}
 
 	overrideParam(&opts.TOpts.CallerName, t.Caller)
@@ -154,8 +158,14 @@ func readYAMLRequest(base string, contents []byte, templateArgs map[string]strin
 		opts.ROpts.ThriftDisableEnvelopes = *t.DisableThriftEnvelope
 	}
 
+	// As with --timeout, the timeout must be positive, while a zero timeout
+	// means the template doesn't specify one.
+	if t.Timeout < 0 {
+		return errNegativeTemplateTimeout
+	}
 	if t.Timeout != 0 {
 		opts.ROpts.Timeout = timeMillisFlag(t.Timeout)
+		opts.ROpts.explicitTimeout = true
 	}
 	return nil
 }
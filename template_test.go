@@ -77,6 +77,7 @@ func TestTemplate(t *testing.T) {
 	assert.Equal(t, true, opts.TOpts.Jaeger)
 	assert.Equal(t, "location:\n  cityId: 1\n  latitude: 37.7\n  longitude: -122.4\n  message: true\n", opts.ROpts.RequestJSON)
 	assert.Equal(t, timeMillisFlag(4500*time.Millisecond), opts.ROpts.Timeout)
+	assert.True(t, opts.ROpts.explicitTimeout, "template timeout should take precedence over the Thrift spec")
 	assert.True(t, opts.ROpts.ThriftDisableEnvelopes)
 }
 
@@ -359,6 +360,10 @@ func TestReadYAMLRequestFails(t *testing.T) {
 			yamlTemplate: "testdata/templates/bad-arg.yaml",
 			wantErr:      "cannot parse",
 		},
+		{
+			yamlTemplate: "testdata/templates/negative-timeout.yaml",
+			wantErr:      errNegativeTemplateTimeout.Error(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1 @@
+typedef string ID
@@ -0,0 +1,9 @@
+include "common.thrift"
+
+struct Request {
+  1: common.ID id
+}
+
+struct Result {
+  1: string value
+}
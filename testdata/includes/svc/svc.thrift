@@ -0,0 +1,5 @@
+include "shared.thrift"
+
+service Svc {
+  shared.Result call(1: shared.Request req)
+}
@@ -1,9 +1,21 @@
 exception ThriftException {}
 
+enum Status {
+  INACTIVE = 1,
+  ACTIVE = 2,
+}
+
 service Simple {
   void foo()
   i32 bar()
   void thriftEx() throws (1: ThriftException ex)
 
   void withDefault(1: set<i32> values = [1, 2, 3])
+
+  oneway void fire()
+
+  Status getStatus()
+
+  void slow() (timeout = "50ms")
+  void badTimeout() (timeout = "soon")
 }
@@ -22,10 +22,14 @@ package thrift
 
 import (
 	"fmt"
+	"strings"
 
 	"go.uber.org/thriftrw/compile"
 )
 
+// _constRefPrefix is the prefix used to reference a constant in a request.
+const _constRefPrefix = "@"
+
 func constToRequest(v compile.ConstantValue) interface{} {
 	switch v := v.(type) {
 	case compile.ConstantBool:
@@ -70,3 +74,57 @@ func constValueListToRequest(v []compile.ConstantValue) interface{} {
 	}
 	return result
 }
+
+// lookupConst finds the constant with the given name in the module, where
+// constants in included files are named include.Name.
+func lookupConst(module *compile.Module, name string) (*compile.Constant, bool) {
+	if c, ok := module.Constants[name]; ok {
+		return c, true
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if included, ok := module.Includes[name[:i]]; ok {
+			return lookupConst(included.Module, name[i+1:])
+		}
+	}
+	return nil, false
+}
+
+// expandConstRefs returns a copy of the request where strings of the form
+// "@Name" are replaced with the value of the constant Name. Strings that don't
+// reference a constant are left as is, and a leading "@@" is replaced with
+// "@" so that a string matching a constant name can still be used.
+func expandConstRefs(module *compile.Module, v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if strings.HasPrefix(v, _constRefPrefix+_constRefPrefix) {
+			return v[len(_constRefPrefix):]
+		}
+		if !strings.HasPrefix(v, _constRefPrefix) {
+			return v
+		}
+		if c, ok := lookupConst(module, v[len(_constRefPrefix):]); ok {
+			return constToRequest(c.Value)
+		}
+		return v
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = expandConstRefs(module, item)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for k, item := range v {
+			result[k] = expandConstRefs(module, item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = expandConstRefs(module, item)
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -23,7 +23,10 @@ package thrift
 import (
 	"testing"
 
+	"github.com/yarpc/yab/internal/thrifttest"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/thriftrw/compile"
 )
 
@@ -103,3 +106,78 @@ func TestConstToRequest(t *testing.T) {
 		assert.Equal(t, tt.want, got, "Result mismatch for %v", tt.v)
 	}
 }
+
+func TestExpandConstRefs(t *testing.T) {
+	fs := thrifttest.DummyFS{
+		"shared.thrift": []byte(`const string Tenant = "shared-tenant"`),
+		"main.thrift": []byte(`
+			include "./shared.thrift"
+
+			struct User {
+				1: string name
+				2: i32 age
+			}
+
+			const User DefaultUser = {"name": "alice", "age": 30}
+			const list<i32> Ages = [1, 2]
+		`),
+	}
+	module, err := compile.Compile("main.thrift", compile.Filesystem(fs))
+	require.NoError(t, err, "Failed to compile Thrift files")
+
+	tests := []struct {
+		msg  string
+		v    interface{}
+		want interface{}
+	}{
+		{
+			msg:  "struct constant",
+			v:    "@DefaultUser",
+			want: map[string]interface{}{"name": "alice", "age": int64(30)},
+		},
+		{
+			msg:  "included constant",
+			v:    "@shared.Tenant",
+			want: "shared-tenant",
+		},
+		{
+			msg: "nested references",
+			v: map[string]interface{}{
+				"ages":  "@Ages",
+				"users": []interface{}{"@DefaultUser", map[interface{}]interface{}{"tenant": "@shared.Tenant"}},
+			},
+			want: map[string]interface{}{
+				"ages": []interface{}{int64(1), int64(2)},
+				"users": []interface{}{
+					map[string]interface{}{"name": "alice", "age": int64(30)},
+					map[interface{}]interface{}{"tenant": "shared-tenant"},
+				},
+			},
+		},
+		{
+			msg:  "unknown constant is left as is",
+			v:    "@unknown",
+			want: "@unknown",
+		},
+		{
+			msg:  "escaped reference",
+			v:    "@@DefaultUser",
+			want: "@DefaultUser",
+		},
+		{
+			msg:  "plain string",
+			v:    "DefaultUser",
+			want: "DefaultUser",
+		},
+		{
+			msg:  "non-string value",
+			v:    int64(1),
+			want: int64(1),
+		},
+	}
+
+	for _, tt := range tests {
+		got := expandConstRefs(module, tt.v)
+		assert.Equal(t, tt.want, got, "%v: result mismatch", tt.msg)
+	}
+}
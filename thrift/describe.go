@@ -0,0 +1,108 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/thriftrw/compile"
+)
+
+// _templateTypeKey is the field added to nested structs in a request template
+// to describe the struct, since the struct's fields only describe themselves.
+// It's not a valid Thrift field name, so it's ignored in requests, which
+// allows filled in templates to be used as is.
+const _templateTypeKey = "$type"
+
+// RequestTemplate returns a template for the arguments of the given function
+// that can be filled in and used as the request body. Each value is a
+// placeholder describing its type, and whether the field is required or
+// optional, e.g., "<i32, required>". Nested structs have a "$type" field
+// describing the struct. Lists and sets contain a single element, and maps
+// contain a single item.
+func RequestTemplate(spec *compile.FunctionSpec) map[string]interface{} {
+	return structTemplate(compile.FieldGroup(spec.ArgsSpec), make(map[*compile.StructSpec]bool))
+}
+
+func structTemplate(fields compile.FieldGroup, seen map[*compile.StructSpec]bool) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		result[f.Name] = typeTemplate(f.Type, fieldAnnotation(f), seen)
+	}
+	return result
+}
+
+// fieldAnnotation returns whether the field is required or optional.
+func fieldAnnotation(f *compile.FieldSpec) string {
+	if f.Required {
+		return "required"
+	}
+	return "optional"
+}
+
+// typeTemplate returns the template for a value of the given type. The
+// annotation is added to placeholders, and is empty for values that are not
+// fields, such as list elements. seen contains the structs that are being
+// expanded, so recursive structs are described using a placeholder rather than
+// expanded forever.
+func typeTemplate(spec compile.TypeSpec, annotation string, seen map[*compile.StructSpec]bool) interface{} {
+	switch spec := compile.RootTypeSpec(spec).(type) {
+	case *compile.StructSpec:
+		if seen[spec] {
+			return placeholder(spec.Name, annotation)
+		}
+		seen[spec] = true
+		defer delete(seen, spec)
+		result := structTemplate(spec.Fields, seen)
+		result[_templateTypeKey] = placeholder(spec.Name, annotation)
+		return result
+	case *compile.ListSpec:
+		return []interface{}{typeTemplate(spec.ValueSpec, "", seen)}
+	case *compile.SetSpec:
+		return []interface{}{typeTemplate(spec.ValueSpec, "", seen)}
+	case *compile.MapSpec:
+		key := typeTemplate(spec.KeySpec, "", seen)
+		value := typeTemplate(spec.ValueSpec, "", seen)
+		if isContainerKey(spec.KeySpec) {
+			return []interface{}{map[string]interface{}{
+				_mapItemKey:   key,
+				_mapItemValue: value,
+			}}
+		}
+		return map[string]interface{}{fmt.Sprint(key): value}
+	case *compile.EnumSpec:
+		names := make([]string, len(spec.Items))
+		for i, item := range spec.Items {
+			names[i] = item.Name
+		}
+		return placeholder(spec.Name+": "+strings.Join(names, "|"), annotation)
+	default:
+		return placeholder(spec.ThriftName(), annotation)
+	}
+}
+
+func placeholder(desc, annotation string) string {
+	if annotation != "" {
+		desc += ", " + annotation
+	}
+	return "<" + desc + ">"
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTemplate(t *testing.T) {
+	funcSpecs := getFuncSpecs(t, `
+    enum Color { RED, GREEN }
+
+    typedef i64 Timestamp
+
+    struct Point {
+      1: required i32 x
+      2: optional i32 y
+    }
+
+    struct Node {
+      1: string name
+      2: Node next
+    }
+
+    service Test {
+      void noArgs()
+      void scalars(1: required string s, 2: Timestamp ts, 3: Color c, 4: binary b)
+      void nested(1: Point p, 2: list<Point> points, 3: set<bool> flags)
+      void requiredStruct(1: required Point p)
+      void maps(1: map<string, i32> counts, 2: map<Point, string> names)
+      void recursive(1: Node node)
+    }
+  `)
+
+	tests := []struct {
+		method string
+		want   map[string]interface{}
+	}{
+		{
+			method: "noArgs",
+			want:   map[string]interface{}{},
+		},
+		{
+			method: "scalars",
+			want: map[string]interface{}{
+				"s":  "<string, required>",
+				"ts": "<i64, optional>",
+				"c":  "<Color: RED|GREEN, optional>",
+				"b":  "<binary, optional>",
+			},
+		},
+		{
+			method: "nested",
+			want: map[string]interface{}{
+				"p": map[string]interface{}{
+					"$type": "<Point, optional>",
+					"x":     "<i32, required>",
+					"y":     "<i32, optional>",
+				},
+				"points": []interface{}{
+					map[string]interface{}{
+						"$type": "<Point>",
+						"x":     "<i32, required>",
+						"y":     "<i32, optional>",
+					},
+				},
+				"flags": []interface{}{"<bool>"},
+			},
+		},
+		{
+			method: "requiredStruct",
+			want: map[string]interface{}{
+				"p": map[string]interface{}{
+					"$type": "<Point, required>",
+					"x":     "<i32, required>",
+					"y":     "<i32, optional>",
+				},
+			},
+		},
+		{
+			method: "maps",
+			want: map[string]interface{}{
+				"counts": map[string]interface{}{"<string>": "<i32>"},
+				"names": []interface{}{
+					map[string]interface{}{
+						"key": map[string]interface{}{
+							"$type": "<Point>",
+							"x":     "<i32, required>",
+							"y":     "<i32, optional>",
+						},
+						"value": "<string>",
+					},
+				},
+			},
+		},
+		{
+			method: "recursive",
+			want: map[string]interface{}{
+				"node": map[string]interface{}{
+					"$type": "<Node, optional>",
+					"name":  "<string, optional>",
+					"next":  "<Node, optional>",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, RequestTemplate(funcSpecs[tt.method]), "Template mismatch for %v", tt.method)
+	}
+}
+
+func TestRequestTemplateTypeIgnored(t *testing.T) {
+	funcSpecs := getFuncSpecs(t, `
+    struct Point {
+      1: required i32 x
+      2: optional i32 y
+    }
+
+    service Test {
+      void nested(1: Point p, 2: list<Point> points)
+    }
+  `)
+
+	// A filled in template can be used as the request without removing the
+	// struct types.
+	request := map[string]interface{}{
+		"p": map[string]interface{}{
+			"$type": "<Point, optional>",
+			"x":     1,
+		},
+		"points": []interface{}{
+			map[string]interface{}{
+				"$type": "<Point>",
+				"x":     2,
+				"y":     3,
+			},
+		},
+	}
+	_, err := RequestToBytes(funcSpecs["nested"], request, Options{})
+	assert.NoError(t, err, "Struct types in the template should be ignored")
+}
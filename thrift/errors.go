@@ -66,6 +66,23 @@ func (e fieldGroupError) asError() error {
 	return e
 }
 
+// unionFieldsError is returned when a union value doesn't have exactly one
+// field set.
+type unionFieldsError struct {
+	union     string
+	set       []string
+	available []string
+}
+
+func (e unionFieldsError) Error() string {
+	if len(e.set) == 0 {
+		return fmt.Sprintf("union %v must have exactly one field set, got none, set one of: %v",
+			e.union, strings.Join(e.available, ", "))
+	}
+	return fmt.Sprintf("union %v must have exactly one field set, got %v: %v",
+		e.union, len(e.set), strings.Join(e.set, ", "))
+}
+
 type specTypeMismatch struct {
 	specified wire.Type
 	got       wire.Type
@@ -85,6 +85,10 @@ func fieldGroupToValue(fieldsList compile.FieldGroup, request map[string]interfa
 	)
 
 	for k, v := range request {
+		if k == _templateTypeKey {
+			continue
+		}
+
 		field, ok := fields.getField(k)
 		if !ok {
 			err.addNotFound(k)
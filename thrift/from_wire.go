@@ -23,7 +23,9 @@ package thrift
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 
+	"go.uber.org/thriftrw/ast"
 	"go.uber.org/thriftrw/compile"
 	"go.uber.org/thriftrw/wire"
 )
@@ -37,7 +39,17 @@ func getFieldMap(fields compile.FieldGroup) map[int16]*compile.FieldSpec {
 	return specs
 }
 
-func valueFromWireStruct(spec *compile.StructSpec, w wire.Struct) (map[string]interface{}, error) {
+// decodeErrorNote returns the value used in place of a field that failed to
+// decode when LenientDecode is set.
+func decodeErrorNote(err error) string {
+	return fmt.Sprintf("<failed to decode: %v>", err)
+}
+
+// valueFromWireStruct converts a struct to a map keyed by the field names in
+// the spec, recursing into nested values. Unset fields without a default are
+// left out of the map rather than set to nil. With LenientDecode, fields
+// that fail to decode are set to a note describing the error.
+func valueFromWireStruct(spec *compile.StructSpec, w wire.Struct, opts Options) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 	specs := getFieldMap(spec.Fields)
 	for _, f := range w.Fields {
@@ -48,12 +60,21 @@ func valueFromWireStruct(spec *compile.StructSpec, w wire.Struct) (map[string]in
 		}
 
 		var err error
-		result[fSpec.Name], err = valueFromWire(fSpec.Type, f.Value)
+		result[fSpec.Name], err = valueFromWire(fSpec.Type, f.Value, opts)
 		if err != nil {
-			return nil, specStructFieldMismatch{fSpec.Name, err}
+			if !opts.LenientDecode {
+				return nil, specStructFieldMismatch{fSpec.Name, err}
+			}
+			result[fSpec.Name] = decodeErrorNote(err)
 		}
 	}
 
+	// Only the field that is set is returned for unions, since setting the
+	// defaults of other fields would make the value ambiguous.
+	if spec.Type == ast.UnionType {
+		return result, nil
+	}
+
 	for _, fSpec := range specs {
 		if _, ok := result[fSpec.Name]; ok {
 			continue
@@ -71,12 +92,12 @@ func valueFromWireStruct(spec *compile.StructSpec, w wire.Struct) (map[string]in
 	return result, nil
 }
 
-func valueFromWireList(spec *compile.ListSpec, w wire.ValueList) ([]interface{}, error) {
+func valueFromWireList(spec *compile.ListSpec, w wire.ValueList, opts Options) ([]interface{}, error) {
 	result := make([]interface{}, w.Size())
 	values := wire.ValueListToSlice(w)
 	for i, v := range values {
 		var err error
-		result[i], err = valueFromWire(spec.ValueSpec, v)
+		result[i], err = valueFromWire(spec.ValueSpec, v, opts)
 		if err != nil {
 			return nil, specListItemMismatch{i, err}
 		}
@@ -84,29 +105,50 @@ func valueFromWireList(spec *compile.ListSpec, w wire.ValueList) ([]interface{},
 	return result, nil
 }
 
-func valueFromWireSet(spec *compile.SetSpec, w wire.ValueList) ([]interface{}, error) {
+func valueFromWireSet(spec *compile.SetSpec, w wire.ValueList, opts Options) ([]interface{}, error) {
 	// Since wire.Set and wire.List are exactly the same type, we can cast one to the other.
 	return valueFromWireList(&compile.ListSpec{
 		ValueSpec: spec.ValueSpec,
-	}, w)
+	}, w, opts)
 }
 
-func valueFromWireMap(spec *compile.MapSpec, w wire.MapItemList) (map[string]interface{}, error) {
-	result := make(map[string]interface{}, w.Size())
+// isContainerKey returns whether map keys of the given type are containers
+// or structs, which cannot be used as JSON object keys.
+func isContainerKey(keySpec compile.TypeSpec) bool {
+	switch compile.RootTypeSpec(keySpec).TypeCode() {
+	case wire.TStruct, wire.TList, wire.TSet, wire.TMap:
+		return true
+	}
+	return false
+}
+
+// valueFromWireMap converts a map to a JSON object. Scalar keys such as ints and
+// enums are converted to strings, while container and struct keys are
+// returned as a list of {"key": k, "value": v} items.
+func valueFromWireMap(spec *compile.MapSpec, w wire.MapItemList, opts Options) (interface{}, error) {
 	values := wire.MapItemListToSlice(w)
-	for _, v := range values {
-		key, err := valueFromWire(spec.KeySpec, v.Key)
-		if err != nil {
-			return nil, specMapItemMismatch{"key", err}
+	if isContainerKey(spec.KeySpec) {
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			key, value, err := valueFromWireMapItem(spec, v, opts)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = map[string]interface{}{
+				_mapItemKey:   key,
+				_mapItemValue: value,
+			}
 		}
+		return items, nil
+	}
 
-		value, err := valueFromWire(spec.ValueSpec, v.Value)
+	result := make(map[string]interface{}, w.Size())
+	for _, v := range values {
+		key, value, err := valueFromWireMapItem(spec, v, opts)
 		if err != nil {
-			return nil, specMapItemMismatch{"value", err}
+			return nil, err
 		}
 
-		// Note: If the key is not hashable, we marshal it to a string and use that.
-		// We might want to use a []MapItem instead to represent the map.
 		if keyS, ok := key.(string); ok {
 			result[keyS] = value
 			continue
@@ -122,6 +164,19 @@ func valueFromWireMap(spec *compile.MapSpec, w wire.MapItemList) (map[string]int
 	return result, nil
 }
 
+func valueFromWireMapItem(spec *compile.MapSpec, item wire.MapItem, opts Options) (key, value interface{}, err error) {
+	key, err = valueFromWire(spec.KeySpec, item.Key, opts)
+	if err != nil {
+		return nil, nil, specMapItemMismatch{"key", err}
+	}
+
+	value, err = valueFromWire(spec.ValueSpec, item.Value, opts)
+	if err != nil {
+		return nil, nil, specMapItemMismatch{"value", err}
+	}
+	return key, value, nil
+}
+
 func mapEnumValueToName(enumSpec *compile.EnumSpec, result int32) interface{} {
 	for _, item := range enumSpec.Items {
 		if item.Value == result {
@@ -132,7 +187,7 @@ func mapEnumValueToName(enumSpec *compile.EnumSpec, result int32) interface{} {
 }
 
 // valueFromWire converts the wire.Value to the specific type it represents.
-func valueFromWire(spec compile.TypeSpec, w wire.Value) (interface{}, error) {
+func valueFromWire(spec compile.TypeSpec, w wire.Value, opts Options) (interface{}, error) {
 	if spec.TypeCode() != w.Type() {
 		return nil, specTypeMismatch{specified: spec.TypeCode(), got: w.Type()}
 	}
@@ -149,13 +204,17 @@ func valueFromWire(spec compile.TypeSpec, w wire.Value) (interface{}, error) {
 	case wire.TI16:
 		result = w.GetI16()
 	case wire.TI32:
-		if enumSpec, ok := spec.(*compile.EnumSpec); ok {
+		if enumSpec, ok := spec.(*compile.EnumSpec); ok && !opts.NumericEnums {
 			result = mapEnumValueToName(enumSpec, w.GetI32())
 		} else {
 			result = w.GetI32()
 		}
 	case wire.TI64:
-		result = w.GetI64()
+		if opts.I64AsString {
+			result = strconv.FormatInt(w.GetI64(), 10)
+		} else {
+			result = w.GetI64()
+		}
 	case wire.TDouble:
 		result = w.GetDouble()
 	case wire.TBinary:
@@ -166,13 +225,13 @@ func valueFromWire(spec compile.TypeSpec, w wire.Value) (interface{}, error) {
 			result = w.GetBinary()
 		}
 	case wire.TStruct:
-		result, err = valueFromWireStruct(spec.(*compile.StructSpec), w.GetStruct())
+		result, err = valueFromWireStruct(spec.(*compile.StructSpec), w.GetStruct(), opts)
 	case wire.TList:
-		result, err = valueFromWireList(spec.(*compile.ListSpec), w.GetList())
+		result, err = valueFromWireList(spec.(*compile.ListSpec), w.GetList(), opts)
 	case wire.TSet:
-		result, err = valueFromWireSet(spec.(*compile.SetSpec), w.GetSet())
+		result, err = valueFromWireSet(spec.(*compile.SetSpec), w.GetSet(), opts)
 	case wire.TMap:
-		result, err = valueFromWireMap(spec.(*compile.MapSpec), w.GetMap())
+		result, err = valueFromWireMap(spec.(*compile.MapSpec), w.GetMap(), opts)
 	default:
 		panic(fmt.Sprintf("valueFromWire got an unknown type: %v", spec))
 	}
@@ -57,6 +57,15 @@ func makeWireMap(keyType, valueType wire.Type, num int, f func(i int) (key, valu
 }
 
 func TestValueFromWireSuccess(t *testing.T) {
+	innerStructSpec := &compile.StructSpec{
+		Name: "Inner",
+		Type: ast.StructType,
+		Fields: compile.FieldGroup{
+			{ID: 1, Name: "s", Type: &compile.StringSpec{}},
+			{ID: 2, Name: "i", Type: &compile.I32Spec{}},
+		},
+	}
+
 	i32s := func(nums ...int) []interface{} {
 		result := make([]interface{}, len(nums))
 		for i, num := range nums {
@@ -194,11 +203,26 @@ func TestValueFromWireSuccess(t *testing.T) {
 				KeySpec:   &compile.ListSpec{ValueSpec: &compile.StringSpec{}},
 				ValueSpec: &compile.StringSpec{},
 			},
-			// The key is JSON serialized if it's not a string.
+			// Container keys can't be JSON object keys, so the map is a list of items.
+			v: []interface{}{
+				map[string]interface{}{"key": []interface{}{}, "value": "0-v"},
+				map[string]interface{}{"key": []interface{}{"k0"}, "value": "1-v"},
+				map[string]interface{}{"key": []interface{}{"k0", "k1"}, "value": "2-v"},
+			},
+		},
+		{
+			// map<i32,string>
+			w: makeWireMap(wire.TI32, wire.TBinary, 2, func(i int) (key, value wire.Value) {
+				return wire.NewValueI32(int32(i)), wire.NewValueString(fmt.Sprintf("%v-v", i))
+			}),
+			spec: &compile.MapSpec{
+				KeySpec:   &compile.I32Spec{},
+				ValueSpec: &compile.StringSpec{},
+			},
+			// Scalar keys are JSON serialized into the object key.
 			v: map[string]interface{}{
-				"[]":          "0-v",
-				`["k0"]`:      "1-v",
-				`["k0","k1"]`: "2-v",
+				"0": "0-v",
+				"1": "1-v",
 			},
 		},
 		{
@@ -263,6 +287,45 @@ func TestValueFromWireSuccess(t *testing.T) {
 				"s": "foo",
 			},
 		},
+		{
+			// struct Inner {1: optional string s, 2: optional i32 i}
+			// struct Outer {1: list<Inner> inners, 2: optional Inner unset}
+			w: wire.NewValueStruct(wire.Struct{
+				Fields: []wire.Field{{
+					ID: 1,
+					Value: wire.NewValueList(wire.ValueListFromSlice(wire.TStruct, []wire.Value{
+						wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+							{ID: 1, Value: wire.NewValueString("foo")},
+						}}),
+						wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+							{ID: 2, Value: wire.NewValueI32(1)},
+						}}),
+					})),
+				}},
+			}),
+			spec: &compile.StructSpec{
+				Name: "Outer",
+				Type: ast.StructType,
+				Fields: compile.FieldGroup{
+					{
+						ID:   1,
+						Name: "inners",
+						Type: &compile.ListSpec{ValueSpec: innerStructSpec},
+					},
+					{
+						ID:   2,
+						Name: "unset",
+						Type: innerStructSpec,
+					},
+				},
+			},
+			v: map[string]interface{}{
+				"inners": []interface{}{
+					map[string]interface{}{"s": "foo"},
+					map[string]interface{}{"i": int32(1)},
+				},
+			},
+		},
 		{
 			// struct S {}, unknown field shouldn't cause an error.
 			// TODO: should we add unknown fields to the result with a special _unknown_field_1 key?
@@ -298,6 +361,28 @@ func TestValueFromWireSuccess(t *testing.T) {
 			},
 			skipToWire: true, // reason: default value
 		},
+		{
+			// union U {1: string s = 'foo', 2: i32 i}, only the set field is returned.
+			w: wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+				{ID: 2, Value: wire.NewValueI32(1)},
+			}}),
+			spec: &compile.StructSpec{
+				Name: "U",
+				Type: ast.UnionType,
+				Fields: compile.FieldGroup{
+					{
+						ID:      1,
+						Name:    "s",
+						Type:    &compile.StringSpec{},
+						Default: compile.ConstantString("foo"),
+					},
+					{ID: 2, Name: "i", Type: &compile.I32Spec{}},
+				},
+			},
+			v: map[string]interface{}{
+				"i": int32(1),
+			},
+		},
 		{
 			// Enum with recognized value.
 			w: wire.NewValueI32(1),
@@ -361,7 +446,7 @@ func TestValueFromWireSuccess(t *testing.T) {
 		spec, err := tt.spec.Link(compile.EmptyScope("fake"))
 		require.NoError(t, err, "Failed to link %v", tt.spec)
 
-		got, err := valueFromWire(spec, tt.w)
+		got, err := valueFromWire(spec, tt.w, Options{})
 		if assert.NoError(t, err, "Failed for valueFromWire(%v, %v)", spec, tt.w) {
 			assert.Equal(t, tt.v, got, "Unexpected value for valueFromWire(%v, %v)", tt.spec, tt.w)
 		}
@@ -381,6 +466,129 @@ func TestValueFromWireSuccess(t *testing.T) {
 	}
 }
 
+func TestValueFromWireNumericEnums(t *testing.T) {
+	spec, err := (&compile.ListSpec{
+		ValueSpec: &compile.EnumSpec{
+			Name: "Op",
+			Items: []compile.EnumItem{{
+				Name:  "Add",
+				Value: 1,
+			}},
+		},
+	}).Link(compile.EmptyScope("fake"))
+	require.NoError(t, err, "Failed to link spec")
+
+	w := wire.NewValueList(wire.ValueListFromSlice(wire.TI32, []wire.Value{
+		wire.NewValueI32(1),
+		wire.NewValueI32(2),
+	}))
+
+	tests := []struct {
+		opts Options
+		want []interface{}
+	}{
+		{
+			opts: Options{},
+			want: []interface{}{"Add", "Op(2)"},
+		},
+		{
+			opts: Options{NumericEnums: true},
+			want: []interface{}{int32(1), int32(2)},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := valueFromWire(spec, w, tt.opts)
+		if assert.NoError(t, err, "valueFromWire failed for %+v", tt.opts) {
+			assert.Equal(t, tt.want, got, "Unexpected value for %+v", tt.opts)
+		}
+	}
+}
+
+func TestValueFromWireLenientDecode(t *testing.T) {
+	inner := &compile.StructSpec{
+		Name: "Inner",
+		Type: ast.StructType,
+		Fields: compile.FieldGroup{
+			{ID: 1, Name: "name", Type: &compile.StringSpec{}},
+			{ID: 2, Name: "count", Type: &compile.I32Spec{}},
+		},
+	}
+	spec := &compile.StructSpec{
+		Name: "Outer",
+		Type: ast.StructType,
+		Fields: compile.FieldGroup{
+			{ID: 1, Name: "id", Type: &compile.I64Spec{}},
+			{ID: 2, Name: "inner", Type: inner},
+			{ID: 3, Name: "ids", Type: &compile.ListSpec{ValueSpec: &compile.I16Spec{}}},
+			{ID: 4, Name: "flag", Type: &compile.BoolSpec{}, Default: compile.ConstantBool(true)},
+		},
+	}
+
+	w := wire.NewValueStruct(wire.Struct{
+		Fields: []wire.Field{
+			{ID: 1, Value: wire.NewValueI64(1)},
+			{ID: 2, Value: wire.NewValueStruct(wire.Struct{
+				Fields: []wire.Field{
+					{ID: 1, Value: wire.NewValueString("foo")},
+					{ID: 2, Value: wire.NewValueString("bar")},
+				},
+			})},
+			{ID: 3, Value: makeWireList(wire.TI32, 1, func(i int) wire.Value {
+				return wire.NewValueI32(0)
+			})},
+			{ID: 4, Value: wire.NewValueI32(0)},
+		},
+	})
+
+	_, err := valueFromWire(spec, w, Options{})
+	assert.Error(t, err, "valueFromWire should fail without LenientDecode")
+
+	got, err := valueFromWire(spec, w, Options{LenientDecode: true})
+	require.NoError(t, err, "valueFromWire should not fail with LenientDecode")
+	assert.Equal(t, map[string]interface{}{
+		"id": int64(1),
+		"inner": map[string]interface{}{
+			"name":  "foo",
+			"count": decodeErrorNote(specTypeMismatch{specified: wire.TI32, got: wire.TBinary}),
+		},
+		"ids": decodeErrorNote(specValueMismatch{"list<i16>",
+			specListItemMismatch{index: 0,
+				underlying: specTypeMismatch{specified: wire.TI16, got: wire.TI32},
+			},
+		}),
+		"flag": decodeErrorNote(specTypeMismatch{specified: wire.TBool, got: wire.TI32}),
+	}, got, "Unexpected lenient decode result")
+}
+
+func TestI64AsStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		input interface{}
+		want  int64
+	}{
+		{input: "9007199254740992", want: 1 << 53},
+		{input: "9007199254740993", want: 1<<53 + 1},
+		{input: 9007199254740993, want: 1<<53 + 1},
+		{input: "1234567890123456789", want: 1234567890123456789},
+		{input: "9223372036854775807", want: 9223372036854775807},
+		{input: "-9223372036854775808", want: -9223372036854775808},
+	}
+
+	for _, tt := range tests {
+		w, err := toWireValue(&compile.I64Spec{}, tt.input)
+		require.NoError(t, err, "toWireValue(%v) failed", tt.input)
+		assert.Equal(t, tt.want, w.GetI64(), "Unexpected wire value for %v", tt.input)
+
+		got, err := valueFromWire(&compile.I64Spec{}, w, Options{I64AsString: true})
+		require.NoError(t, err, "valueFromWire(%v) failed", tt.input)
+		assert.Equal(t, fmt.Sprint(tt.want), got, "i64 should round trip as a string for %v", tt.input)
+
+		got, err = valueFromWire(&compile.I64Spec{}, w, Options{})
+		require.NoError(t, err, "valueFromWire(%v) failed", tt.input)
+		assert.Equal(t, tt.want, got, "i64 should be returned as a number by default for %v", tt.input)
+	}
+}
+
 func TestValueFromWireError(t *testing.T) {
 	tests := []struct {
 		w    wire.Value
@@ -468,7 +676,7 @@ func TestValueFromWireError(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got, err := valueFromWire(tt.spec, tt.w)
+		got, err := valueFromWire(tt.spec, tt.w, Options{})
 		if !assert.Error(t, err, "Expected error for %v", tt.msg) {
 			continue
 		}
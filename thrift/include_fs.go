@@ -0,0 +1,183 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/thriftrw/ast"
+	"go.uber.org/thriftrw/idl"
+)
+
+// includeFS is a compile.FS that resolves includes relative to the including
+// file, and if they're not found there, relative to each of the include paths.
+type includeFS struct {
+	includePaths []string
+
+	// resolved maps the path the compiler uses for an include to the path
+	// of the file that was found.
+	resolved map[string]string
+
+	// missing maps the path the compiler uses for an include that could not
+	// be found to an error describing the paths that were searched.
+	missing map[string]error
+
+	// inMemory maps the path of files that are not on the filesystem, such
+	// as an IDL read from stdin, to their contents.
+	inMemory map[string][]byte
+
+	// overrides maps the path of files on the filesystem to contents that
+	// are used instead of the file, such as when definitions that fail to
+	// compile are removed.
+	overrides map[string][]byte
+}
+
+// memoryFS is a compile.FS for a set of files held in memory. Paths are
+// resolved against a virtual root, so the files can't refer to files on
+// the filesystem.
+type memoryFS struct {
+	files map[string][]byte
+}
+
+func newMemoryFS(files map[string]string) memoryFS {
+	fs := memoryFS{make(map[string][]byte, len(files))}
+	for p, contents := range files {
+		abs, _ := fs.Abs(p)
+		fs.files[abs] = []byte(contents)
+	}
+	return fs
+}
+
+func (memoryFS) Abs(p string) (string, error) {
+	return filepath.Join(string(filepath.Separator), p), nil
+}
+
+func (fs memoryFS) Read(p string) ([]byte, error) {
+	contents, ok := fs.files[p]
+	if !ok {
+		return nil, fmt.Errorf("file %q not found", strings.TrimPrefix(p, string(filepath.Separator)))
+	}
+	return contents, nil
+}
+
+func newIncludeFS(includePaths []string) *includeFS {
+	return &includeFS{
+		includePaths: includePaths,
+		resolved:     make(map[string]string),
+		missing:      make(map[string]error),
+		inMemory:     make(map[string][]byte),
+		overrides:    make(map[string][]byte),
+	}
+}
+
+func (fs *includeFS) Abs(p string) (string, error) {
+	return filepath.Abs(p)
+}
+
+func (fs *includeFS) Read(p string) ([]byte, error) {
+	if err, ok := fs.missing[p]; ok {
+		return nil, err
+	}
+
+	if contents, ok := fs.inMemory[p]; ok {
+		fs.resolveIncludes(p, "", contents)
+		return contents, nil
+	}
+
+	found := p
+	if resolved, ok := fs.resolved[p]; ok {
+		found = resolved
+	}
+
+	contents, ok := fs.overrides[p]
+	if !ok {
+		var err error
+		if contents, err = ioutil.ReadFile(found); err != nil {
+			return nil, err
+		}
+	}
+
+	fs.resolveIncludes(p, found, contents)
+	return contents, nil
+}
+
+// override replaces the contents of the file at the given path for
+// subsequent reads.
+func (fs *includeFS) override(p string, contents []byte) {
+	if _, ok := fs.inMemory[p]; ok {
+		fs.inMemory[p] = contents
+		return
+	}
+	fs.overrides[p] = contents
+}
+
+// resolveIncludes finds the files for all includes in the given file, so
+// they can be read when the compiler requests them. If found is empty, the
+// file is not on the filesystem, so includes are only searched for in the
+// include paths.
+func (fs *includeFS) resolveIncludes(p, found string, contents []byte) {
+	program, err := idl.Parse(contents)
+	if err != nil {
+		// The compiler will report the parse error.
+		return
+	}
+
+	for _, header := range program.Headers {
+		include, ok := header.(*ast.Include)
+		if !ok {
+			continue
+		}
+
+		// The compiler resolves includes relative to the path it used for
+		// the including file.
+		includePath, err := fs.Abs(filepath.Join(filepath.Dir(p), include.Path))
+		if err != nil {
+			continue
+		}
+
+		var searched []string
+		if found != "" {
+			searched = append(searched, filepath.Join(filepath.Dir(found), include.Path))
+		}
+		for _, dir := range fs.includePaths {
+			searched = append(searched, filepath.Join(dir, include.Path))
+		}
+
+		if len(searched) == 0 {
+			fs.missing[includePath] = fmt.Errorf("could not find include %q, no include paths specified", include.Path)
+			continue
+		}
+
+		fs.missing[includePath] = fmt.Errorf("could not find include %q, searched: %v",
+			include.Path, strings.Join(searched, ", "))
+		for _, candidate := range searched {
+			if _, err := os.Stat(candidate); err == nil {
+				fs.resolved[includePath] = candidate
+				delete(fs.missing, includePath)
+				break
+			}
+		}
+	}
+}
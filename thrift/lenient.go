@@ -0,0 +1,93 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+
+	"go.uber.org/thriftrw/ast"
+	"go.uber.org/thriftrw/idl"
+)
+
+// _compileErrorRegex matches the file, and the name and line of the definition
+// that failed to compile in a compile error. The line is not always present.
+var _compileErrorRegex = regexp.MustCompile(`could not compile file "([^"]+)": cannot compile "([^"]*)"(?: on line (\d+))?`)
+
+// removeFailedDefinition removes the definition that caused the compile error
+// from the file that contains it, and returns the name of the definition.
+// The lines of the definition are blanked out, so line numbers in any later
+// errors still match the file. ok is false if the definition can't be found.
+func (fs *includeFS) removeFailedDefinition(compileErr error) (name string, ok bool) {
+	matches := _compileErrorRegex.FindAllStringSubmatch(compileErr.Error(), -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	// Errors for included files are nested, so the innermost file is last.
+	match := matches[len(matches)-1]
+	path, target := match[1], match[2]
+
+	contents, err := fs.Read(path)
+	if err != nil {
+		return "", false
+	}
+	program, err := idl.Parse(contents)
+	if err != nil {
+		return "", false
+	}
+
+	// The failed definition is the last one that starts at or before the
+	// line, and it ends where the next definition starts. If there's no line,
+	// the definition is found by name.
+	var failed ast.Definition
+	for _, d := range program.Definitions {
+		if match[3] == "" {
+			if d.Info().Name == target {
+				failed = d
+			}
+			continue
+		}
+
+		line, _ := strconv.Atoi(match[3])
+		if start := d.Info().Line; start <= line && (failed == nil || start > failed.Info().Line) {
+			failed = d
+		}
+	}
+	if failed == nil {
+		return "", false
+	}
+
+	lines := bytes.Split(contents, []byte("\n"))
+	start, end := failed.Info().Line, len(lines)+1
+	for _, d := range program.Definitions {
+		if next := d.Info().Line; next > start && next < end {
+			end = next
+		}
+	}
+
+	for i := start - 1; i < end-1; i++ {
+		lines[i] = nil
+	}
+	fs.override(path, bytes.Join(lines, []byte("\n")))
+	return failed.Info().Name, true
+}
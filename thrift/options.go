@@ -20,8 +20,26 @@
 
 package thrift
 
+import "go.uber.org/thriftrw/compile"
+
 // Options controls the serialization of the Thrift request/response.
 type Options struct {
 	UseEnvelopes         bool
 	EnvelopeMethodPrefix string
+
+	// NumericEnums returns enum values in responses as integers rather
+	// than as the name of the enum value.
+	NumericEnums bool
+
+	// I64AsString returns i64 values in responses as strings, since JSON
+	// numbers lose precision above 2^53.
+	I64AsString bool
+
+	// LenientDecode replaces fields in responses that fail to decode with
+	// a note describing the error, rather than failing the whole response.
+	LenientDecode bool
+
+	// Module is used to resolve references to constants in requests, such
+	// as "@DefaultUser". If nil, references are not expanded.
+	Module *compile.Module
 }
@@ -25,27 +25,123 @@ package thrift
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"strings"
+	"sync"
 
 	"go.uber.org/thriftrw/compile"
 	"go.uber.org/thriftrw/protocol"
 	"go.uber.org/thriftrw/wire"
 )
 
-// Parse parses the given Thrift file.
-func Parse(file string) (*compile.Module, error) {
-	module, err := compile.Compile(file, compile.NonStrict())
+// StdinFile is the file name used to read the Thrift IDL from stdin.
+const StdinFile = "-"
+
+// _stdinIDLFile is the path used for the IDL read from stdin. The file name
+// is used by the compiler as the module name.
+const _stdinIDLFile = "stdin.thrift"
+
+// stdin caches the IDL read from stdin, since stdin can only be read once,
+// but the IDL may be parsed multiple times.
+var stdin struct {
+	sync.Once
+	contents []byte
+	err      error
+}
+
+// Parse parses the given Thrift file. Includes are resolved relative to the
+// including file, falling back to the given include paths. If the file is
+// StdinFile, the IDL is read from stdin.
+func Parse(file string, includePaths ...string) (*compile.Module, error) {
+	module, _, err := parse(file, false /* lenient */, includePaths)
+	return module, err
+}
+
+// ParseLenient is like Parse, but definitions that fail to compile, in the
+// file or its includes, are ignored so the rest of the IDL can be used. The
+// errors for the ignored definitions are returned as warnings.
+func ParseLenient(file string, includePaths ...string) (*compile.Module, []error, error) {
+	return parse(file, true /* lenient */, includePaths)
+}
+
+func parse(file string, lenient bool, includePaths []string) (*compile.Module, []error, error) {
+	if file == StdinFile {
+		stdin.Do(func() {
+			stdin.contents, stdin.err = ioutil.ReadAll(os.Stdin)
+		})
+		if stdin.err != nil {
+			return nil, nil, fmt.Errorf("failed to read Thrift IDL from stdin: %v", stdin.err)
+		}
+		return parseReader(bytes.NewReader(stdin.contents), lenient, includePaths)
+	}
+
+	module, warnings, err := compileFile(file, newIncludeFS(includePaths), lenient)
 	// thriftrw wraps errors, so we can't use os.IsNotExist here.
 	if err != nil {
 		// The user may have left off the ".thrift", so try appending .thrift
-		if appendedModule, err2 := compile.Compile(file+".thrift", compile.NonStrict()); err2 == nil {
-			module = appendedModule
-			err = nil
+		if appendedModule, appendedWarnings, err2 := compileFile(file+".thrift", newIncludeFS(includePaths), lenient); err2 == nil {
+			return appendedModule, appendedWarnings, nil
 		}
 	}
+	return module, warnings, err
+}
+
+// ParseReader parses the Thrift IDL read from r. Since the IDL has no path,
+// includes are only resolved relative to the given include paths.
+func ParseReader(r io.Reader, includePaths ...string) (*compile.Module, error) {
+	module, _, err := parseReader(r, false /* lenient */, includePaths)
 	return module, err
 }
 
+func parseReader(r io.Reader, lenient bool, includePaths []string) (*compile.Module, []error, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fs := newIncludeFS(includePaths)
+	path, err := fs.Abs(_stdinIDLFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	fs.inMemory[path] = contents
+	return compileFile(path, fs, lenient)
+}
+
+// ParseIDLs parses a set of Thrift IDL files held in memory, such as the IDLs
+// returned by a server's Meta::thriftIDL endpoint. idls maps the path of each
+// file to its contents, and includes are resolved relative to the including
+// file within the set.
+func ParseIDLs(idls map[string]string, entryPoint string) (*compile.Module, error) {
+	fs := newMemoryFS(idls)
+	path, err := fs.Abs(entryPoint)
+	if err != nil {
+		return nil, err
+	}
+	return compile.Compile(path, compile.NonStrict(), compile.Filesystem(fs))
+}
+
+// compileFile compiles the Thrift file at path. If lenient is set, any
+// definition that fails to compile is removed and the file is compiled again,
+// and the errors for the removed definitions are returned as warnings.
+func compileFile(path string, fs *includeFS, lenient bool) (*compile.Module, []error, error) {
+	var warnings []error
+	for {
+		module, err := compile.Compile(path, compile.NonStrict(), compile.Filesystem(fs))
+		if err == nil || !lenient {
+			return module, warnings, err
+		}
+
+		name, ok := fs.removeFailedDefinition(err)
+		if !ok {
+			return nil, warnings, err
+		}
+		warnings = append(warnings, fmt.Errorf("ignoring %q since it failed to compile: %v", name, err))
+	}
+}
+
 // SplitMethod takes a method name like Service::Method and splits it
 // into Service and Method.
 func SplitMethod(fullMethod string) (svc, method string, err error) {
@@ -61,8 +157,20 @@ func SplitMethod(fullMethod string) (svc, method string, err error) {
 }
 
 // RequestToBytes takes a user request and converts it to the Thrift binary payload.
-// It uses the method spec to convert the user request.
-func RequestToBytes(method *compile.FunctionSpec, request map[string]interface{}, opts Options) ([]byte, error) {
+// It uses the method spec to convert the user request, which is either a map
+// from argument names to values, or a list of the arguments in the order they
+// are declared.
+func RequestToBytes(method *compile.FunctionSpec, request interface{}, opts Options) ([]byte, error) {
+	if args, ok := request.([]interface{}); ok {
+		var err error
+		if request, err = positionalArgs(method, args); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Module != nil {
+		request = expandConstRefs(opts.Module, request)
+	}
+
 	w, err := structToValue(compile.FieldGroup(method.ArgsSpec), request)
 	if err != nil {
 		return nil, err
@@ -70,10 +178,15 @@ func RequestToBytes(method *compile.FunctionSpec, request map[string]interface{}
 
 	buf := &bytes.Buffer{}
 	if opts.UseEnvelopes {
+		envelopeType := wire.Call
+		if method.OneWay {
+			envelopeType = wire.OneWay
+		}
+
 		// Sequence IDs are unused, so use the default, 0.
 		enveloped := wire.Envelope{
 			Name:  opts.EnvelopeMethodPrefix + method.Name,
-			Type:  wire.Call,
+			Type:  envelopeType,
 			Value: wire.NewValueStruct(w),
 		}
 		err = protocol.Binary.EncodeEnveloped(enveloped, buf)
@@ -86,3 +199,21 @@ func RequestToBytes(method *compile.FunctionSpec, request map[string]interface{}
 
 	return buf.Bytes(), nil
 }
+
+// positionalArgs maps arguments specified in declaration order to the names
+// of the method's arguments. Trailing arguments can be omitted, and null
+// arguments are treated as unset.
+func positionalArgs(method *compile.FunctionSpec, args []interface{}) (map[string]interface{}, error) {
+	if len(args) > len(method.ArgsSpec) {
+		return nil, fmt.Errorf("%v accepts %v arguments, got %v", method.Name, len(method.ArgsSpec), len(args))
+	}
+
+	request := make(map[string]interface{}, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			continue
+		}
+		request[method.ArgsSpec[i].ThriftName()] = arg
+	}
+	return request, nil
+}
@@ -23,9 +23,12 @@ package thrift
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/yarpc/yab/internal/thrifttest"
+	"github.com/yarpc/yab/sorted"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -222,12 +225,37 @@ func TestParseRequest(t *testing.T) {
 				})},
 			},
 		},
+		{
+			// union fields set to null are ignored.
+			request: map[string]interface{}{
+				"u": map[string]interface{}{
+					"s": nil,
+					"i": 1,
+				},
+			},
+			want: []wire.Field{
+				{ID: 7, Value: wire.NewValueStruct(wire.Struct{
+					Fields: []wire.Field{
+						{ID: 2, Value: wire.NewValueI32(1)},
+					},
+				})},
+			},
+		},
 		{
 			// union cannot have 0 fields.
 			request: map[string]interface{}{
 				"u": map[string]interface{}{},
 			},
-			errMsg: errUsingSingleField.Error(),
+			errMsg: "union U must have exactly one field set, got none, set one of: i, s",
+		},
+		{
+			// union cannot have only null fields.
+			request: map[string]interface{}{
+				"u": map[string]interface{}{
+					"s": nil,
+				},
+			},
+			errMsg: "union U must have exactly one field set, got none, set one of: i, s",
 		},
 		{
 			// union cannot have 2 fields.
@@ -237,7 +265,23 @@ func TestParseRequest(t *testing.T) {
 					"i": 1,
 				},
 			},
-			errMsg: errUsingSingleField.Error(),
+			errMsg: "union U must have exactly one field set, got 2: i, s",
+		},
+		{
+			// union field must exist.
+			request: map[string]interface{}{
+				"u": map[string]interface{}{
+					"x": 1,
+				},
+			},
+			errMsg: fieldGroupError{available: []string{"i", "s"}, notFound: []string{"x"}}.Error(),
+		},
+		{
+			// union must be specified as a map.
+			request: map[string]interface{}{
+				"u": "foo",
+			},
+			errMsg: errStructUseMapString.Error(),
 		},
 		{
 			// sWrap has a required field with a default.
@@ -365,6 +409,26 @@ func TestParseRequest(t *testing.T) {
 				)),
 			}},
 		},
+		{
+			// maps can be specified as a list of key-value items.
+			request: map[string]interface{}{
+				"i_i_map": []interface{}{
+					map[string]interface{}{"key": 2, "value": 1},
+					map[interface{}]interface{}{"key": 1, "value": 2},
+				},
+			},
+			want: []wire.Field{{
+				ID: 16,
+				Value: wire.NewValueMap(wire.MapItemListFromSlice(
+					wire.TI32,
+					wire.TI32,
+					[]wire.MapItem{
+						{wire.NewValueI32(2), wire.NewValueI32(1)},
+						{wire.NewValueI32(1), wire.NewValueI32(2)},
+					},
+				)),
+			}},
+		},
 		{
 			request: map[string]interface{}{
 				"op": 1,
@@ -422,6 +486,31 @@ func TestParseRequest(t *testing.T) {
 			},
 			errMsg: "map value (asd) for key (true) failed",
 		},
+		{
+			// map item is not an object.
+			request: map[string]interface{}{
+				"i_i_map": []interface{}{1},
+			},
+			errMsg: `map item 0 must be an object with "key" and "value" fields, got int`,
+		},
+		{
+			// map item is missing the value.
+			request: map[string]interface{}{
+				"i_i_map": []interface{}{
+					map[string]interface{}{"key": 1},
+				},
+			},
+			errMsg: `map item 0 must only have "key" and "value" fields`,
+		},
+		{
+			// map item key type is wrong.
+			request: map[string]interface{}{
+				"i_i_map": []interface{}{
+					map[string]interface{}{"key": "a", "value": 1},
+				},
+			},
+			errMsg: "map key (a) failed",
+		},
 		{
 			// use fuzzy matching to set fields.
 			request: map[string]interface{}{
@@ -523,6 +612,224 @@ func TestParseSuccess(t *testing.T) {
 	}
 }
 
+func TestParseIncludePaths(t *testing.T) {
+	const svcFile = "../testdata/includes/svc/svc.thrift"
+
+	tests := []struct {
+		msg          string
+		includePaths []string
+		wantErr      []string
+	}{
+		{
+			msg: "include not found",
+			wantErr: []string{
+				`could not find include "shared.thrift"`,
+				filepath.Join("testdata", "includes", "svc", "shared.thrift"),
+			},
+		},
+		{
+			msg:          "include not found in include paths",
+			includePaths: []string{"../testdata/includes/svc", "../testdata"},
+			wantErr: []string{
+				`could not find include "shared.thrift"`,
+				filepath.Join("../testdata", "shared.thrift"),
+			},
+		},
+		{
+			msg:          "include found in include path",
+			includePaths: []string{"../testdata", "../testdata/includes/shared"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			module, err := Parse(svcFile, tt.includePaths...)
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err, "Parse should fail")
+				for _, want := range tt.wantErr {
+					assert.Contains(t, err.Error(), want, "Unexpected error")
+				}
+				return
+			}
+
+			require.NoError(t, err, "Parse failed")
+			svc, err := module.LookupService("Svc")
+			require.NoError(t, err, "Failed to find service")
+			assert.Equal(t, "Request", svc.Functions["call"].ArgsSpec[0].Type.ThriftName(), "Argument type mismatch")
+		})
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	svcContents, err := ioutil.ReadFile("../testdata/includes/svc/svc.thrift")
+	require.NoError(t, err, "Failed to read Thrift file")
+
+	tests := []struct {
+		msg          string
+		contents     string
+		includePaths []string
+		wantErr      string
+	}{
+		{
+			msg:      "no includes",
+			contents: "service Svc { void call() }",
+		},
+		{
+			msg:      "includes without include paths",
+			contents: string(svcContents),
+			wantErr:  `could not find include "shared.thrift", no include paths specified`,
+		},
+		{
+			msg:          "includes found in include paths",
+			contents:     string(svcContents),
+			includePaths: []string{"../testdata/includes/shared"},
+		},
+		{
+			msg:      "invalid IDL",
+			contents: "service Svc {",
+			wantErr:  _stdinIDLFile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			module, err := ParseReader(strings.NewReader(tt.contents), tt.includePaths...)
+			if tt.wantErr != "" {
+				require.Error(t, err, "ParseReader should fail")
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error")
+				return
+			}
+
+			require.NoError(t, err, "ParseReader failed")
+			_, err = module.LookupService("Svc")
+			assert.NoError(t, err, "Failed to find service")
+		})
+	}
+}
+
+func TestParseIDLs(t *testing.T) {
+	tests := []struct {
+		msg        string
+		idls       map[string]string
+		entryPoint string
+		wantErr    string
+	}{
+		{
+			msg:        "single file",
+			idls:       map[string]string{"svc.thrift": "service Svc { void call() }"},
+			entryPoint: "svc.thrift",
+		},
+		{
+			msg: "includes relative to the including file",
+			idls: map[string]string{
+				"idl/svc/svc.thrift":       `include "../shared/shared.thrift" service Svc { shared.Result call() }`,
+				"idl/shared/shared.thrift": "struct Result {}",
+			},
+			entryPoint: "idl/svc/svc.thrift",
+		},
+		{
+			msg:        "missing include",
+			idls:       map[string]string{"svc.thrift": `include "shared.thrift" service Svc { shared.Result call() }`},
+			entryPoint: "svc.thrift",
+			wantErr:    `file "shared.thrift" not found`,
+		},
+		{
+			msg:        "missing entry point",
+			idls:       map[string]string{"svc.thrift": "service Svc { void call() }"},
+			entryPoint: "other.thrift",
+			wantErr:    `file "other.thrift" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			module, err := ParseIDLs(tt.idls, tt.entryPoint)
+			if tt.wantErr != "" {
+				require.Error(t, err, "ParseIDLs should fail")
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error")
+				return
+			}
+
+			require.NoError(t, err, "ParseIDLs failed")
+			_, err = module.LookupService("Svc")
+			assert.NoError(t, err, "Failed to find service")
+		})
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	tests := []struct {
+		msg          string
+		contents     string
+		wantServices []string
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			msg:          "valid IDL",
+			contents:     "service Svc { void call() }",
+			wantServices: []string{"Svc"},
+		},
+		{
+			msg: "invalid definitions are ignored",
+			contents: `
+				struct Request {
+					1: string key
+				}
+
+				struct Legacy {
+					1: Missing m
+				}
+
+				service Svc {
+					void call(1: Request r)
+				}
+
+				service LegacySvc {
+					void call(1: Legacy l)
+				}
+			`,
+			wantServices: []string{"Svc"},
+			wantWarnings: []string{`ignoring "Legacy"`, `ignoring "LegacySvc"`},
+		},
+		{
+			msg:      "syntax errors are not ignored",
+			contents: "service Svc {",
+			wantErr:  "could not compile file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "lenient")
+			require.NoError(t, err, "Failed to create temporary file")
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(tt.contents)
+			require.NoError(t, err, "Failed to write Thrift file")
+			require.NoError(t, f.Close(), "Failed to close Thrift file")
+
+			module, warnings, err := ParseLenient(f.Name())
+			if tt.wantErr != "" {
+				require.Error(t, err, "ParseLenient should fail")
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error")
+				return
+			}
+
+			require.NoError(t, err, "ParseLenient failed")
+			assert.Equal(t, tt.wantServices, sorted.MapKeys(module.Services), "Unexpected services")
+			require.Len(t, warnings, len(tt.wantWarnings), "Unexpected number of warnings")
+			for i, want := range tt.wantWarnings {
+				assert.Contains(t, warnings[i].Error(), want, "Unexpected warning %v", i)
+			}
+
+			if len(warnings) > 0 {
+				_, err := Parse(f.Name())
+				assert.Error(t, err, "Parse should fail without ignoring definitions")
+			}
+		})
+	}
+}
+
 func TestSplitMethod(t *testing.T) {
 	tests := []struct {
 		fullMethod string
@@ -607,3 +914,85 @@ func TestRequestToBytes(t *testing.T) {
 		assert.Equal(t, tt.wantErr, err != nil, "wantErr %v for %v", tt.wantErr, tt.request)
 	}
 }
+
+func TestRequestToBytesPositional(t *testing.T) {
+	funcSpec := getFuncSpecs(t, `
+		service Test {
+			void test(1: required string s, 2: i32 n, 3: bool b)
+		}
+	`)["test"]
+
+	tests := []struct {
+		msg     string
+		args    []interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			msg:  "all arguments",
+			args: []interface{}{"foo", 1, true},
+			want: map[string]interface{}{"s": "foo", "n": 1, "b": true},
+		},
+		{
+			msg:  "trailing arguments omitted",
+			args: []interface{}{"foo"},
+			want: map[string]interface{}{"s": "foo"},
+		},
+		{
+			msg:  "null arguments are unset",
+			args: []interface{}{"foo", nil, true},
+			want: map[string]interface{}{"s": "foo", "b": true},
+		},
+		{
+			msg:     "missing required argument",
+			args:    []interface{}{nil, 1},
+			wantErr: "the following fields are required but not specified\n\ts",
+		},
+		{
+			msg:     "too many arguments",
+			args:    []interface{}{"foo", 1, true, "bar"},
+			wantErr: "test accepts 3 arguments, got 4",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := RequestToBytes(funcSpec, tt.args, Options{})
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "%v: expected error", tt.msg) {
+				assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+			}
+			continue
+		}
+		require.NoError(t, err, "%v: RequestToBytes failed", tt.msg)
+
+		want, err := RequestToBytes(funcSpec, tt.want, Options{})
+		require.NoError(t, err, "%v: RequestToBytes with named arguments failed", tt.msg)
+		assert.Equal(t, want, got, "%v: positional arguments should match named arguments", tt.msg)
+	}
+}
+
+func TestRequestToBytesConstRefs(t *testing.T) {
+	module := thrifttest.Parse(t, `
+		typedef string UUID
+
+		const UUID DefaultID = "abc"
+
+		service Test {
+			void test(1: UUID id)
+		}
+	`)
+	svc, err := module.LookupService("Test")
+	require.NoError(t, err, "Failed to find service")
+	funcSpec := svc.Functions["test"]
+
+	want, err := RequestToBytes(funcSpec, map[string]interface{}{"id": "abc"}, Options{})
+	require.NoError(t, err, "RequestToBytes failed")
+
+	got, err := RequestToBytes(funcSpec, map[string]interface{}{"id": "@DefaultID"}, Options{Module: module})
+	require.NoError(t, err, "RequestToBytes with constant reference failed")
+	assert.Equal(t, want, got, "Constant reference should serialize to the constant's value")
+
+	literal, err := RequestToBytes(funcSpec, map[string]interface{}{"id": "@DefaultID"}, Options{})
+	require.NoError(t, err, "RequestToBytes without a module failed")
+	assert.NotEqual(t, want, literal, "References should not be expanded without a module")
+}
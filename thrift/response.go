@@ -22,6 +22,7 @@ package thrift
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 
 	"go.uber.org/thriftrw/compile"
@@ -35,7 +36,8 @@ type encodedException struct {
 }
 
 // ResponseBytesToMap takes the given response bytes and creates a map that
-// uses field name as keys.
+// uses field name as keys. If opts.LenientDecode is set, fields that fail to
+// decode are replaced with a note describing the error.
 func ResponseBytesToMap(spec *compile.FunctionSpec, responseBytes []byte, opts Options) (map[string]interface{}, error) {
 	w, err := responseBytesToWire(responseBytes, opts)
 	if err != nil {
@@ -49,37 +51,57 @@ func ResponseBytesToMap(spec *compile.FunctionSpec, responseBytes []byte, opts O
 
 	result := make(map[string]interface{})
 	for _, f := range w.Fields {
+		var name string
 		err = nil
 		if f.ID == 0 {
 			// Field ID 0 is always the result.
 			if spec.ResultSpec == nil || spec.ResultSpec.ReturnType == nil {
 				return nil, fmt.Errorf("got unexpected result for void method: %v", f.Value)
 			}
-			result["result"], err = valueFromWire(spec.ResultSpec.ReturnType, f.Value)
+			name = "result"
+			result[name], err = valueFromWire(spec.ResultSpec.ReturnType, f.Value, opts)
 		} else {
 			exSpec, ok := specs[f.ID]
 			if !ok {
 				return nil, fmt.Errorf("got unknown exception with ID %v: %v", f.ID, f.Value)
 			}
 
-			result[exSpec.Name], err = valueFromWire(exSpec.Type, f.Value)
+			name = exSpec.Name
+			result[name], err = valueFromWire(exSpec.Type, f.Value, opts)
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse result field %v: %v", f.ID, err)
+			if !opts.LenientDecode {
+				return nil, fmt.Errorf("failed to parse result field %v: %v", f.ID, err)
+			}
+			result[name] = decodeErrorNote(err)
 		}
 	}
 
 	return result, nil
 }
 
-func checkException(spec *compile.FunctionSpec, fieldID int16) string {
+// checkException describes the exception in the given result field using the
+// declared exceptions of the method. Known exceptions are decoded using their
+// typed spec so that the exception's fields are included in the description.
+func checkException(spec *compile.FunctionSpec, f wire.Field, opts Options) string {
 	if spec.ResultSpec == nil || len(spec.ResultSpec.Exceptions) == 0 {
 		return "unknown, method has no exceptions"
 	}
 	for _, ex := range spec.ResultSpec.Exceptions {
-		if ex.ID == fieldID {
-			return ex.ThriftName() + " " + ex.Type.ThriftName()
+		if ex.ID != f.ID {
+			continue
+		}
+
+		desc := ex.ThriftName() + " " + ex.Type.ThriftName()
+		value, err := valueFromWire(ex.Type, f.Value, opts)
+		if err != nil {
+			return desc
+		}
+		bs, err := json.Marshal(value)
+		if err != nil {
+			return desc
 		}
+		return desc + " " + string(bs)
 	}
 
 	return "unknown"
@@ -102,7 +124,7 @@ func CheckSuccess(spec *compile.FunctionSpec, responseBytes []byte, opts Options
 		if w.Fields[0].ID == 0 {
 			return fmt.Errorf("void method got unexpected result, fields: %+v", w.Fields)
 		}
-		return fmt.Errorf("void method got exception: %s", checkException(spec, w.Fields[0].ID))
+		return fmt.Errorf("void method got exception: %s", checkException(spec, w.Fields[0], opts))
 	}
 
 	if len(w.Fields) != 1 {
@@ -110,7 +132,7 @@ func CheckSuccess(spec *compile.FunctionSpec, responseBytes []byte, opts Options
 	}
 
 	if w.Fields[0].ID != 0 {
-		return fmt.Errorf("method with return got exception: %s", checkException(spec, w.Fields[0].ID))
+		return fmt.Errorf("method with return got exception: %s", checkException(spec, w.Fields[0], opts))
 	}
 
 	return nil
@@ -130,6 +130,36 @@ func TestResponseBytesToMap(t *testing.T) {
 			})),
 			errMsg: "failed to parse result field 0",
 		},
+		{
+			msg:  "fStr with invalid result type and lenient decode",
+			spec: funcSpecs["fStr"],
+			bs: encodeWire(wire.NewValueStruct(wire.Struct{
+				Fields: []wire.Field{
+					{ID: 0, Value: wire.NewValueBool(true)},
+				},
+			})),
+			opts: Options{LenientDecode: true},
+			want: map[string]interface{}{
+				"result": "<failed to decode: type specified in Thrift field as TBinary, got TBool>",
+			},
+		},
+		{
+			msg:  "fEx with invalid exception field and lenient decode",
+			spec: funcSpecs["fEx"],
+			bs: encodeWire(wire.NewValueStruct(wire.Struct{
+				Fields: []wire.Field{
+					{ID: 1, Value: wire.NewValueStruct(wire.Struct{
+						Fields: []wire.Field{{ID: 1, Value: wire.NewValueI32(1)}},
+					})},
+				},
+			})),
+			opts: Options{LenientDecode: true},
+			want: map[string]interface{}{
+				"e": map[string]interface{}{
+					"reason": "<failed to decode: type specified in Thrift field as TBinary, got TI32>",
+				},
+			},
+		},
 		{
 			msg:  "fEx with unknown exception",
 			spec: funcSpecs["fStr"],
@@ -241,6 +271,11 @@ func TestCheckSuccess(t *testing.T) {
 	ex2 := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
 		{ID: 2, Value: wire.NewValueStruct(wire.Struct{})},
 	}})
+	exWithReason := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+		{ID: 1, Value: wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+			{ID: 1, Value: wire.NewValueString("bad input")},
+		}})},
+	}})
 	resultAndEx := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
 		{ID: 0, Value: wire.NewValueI32(0)},
 		{ID: 1, Value: wire.NewValueStruct(wire.Struct{})},
@@ -335,7 +370,13 @@ func TestCheckSuccess(t *testing.T) {
 			msg:    "void with exception got exception",
 			method: "m1Ex",
 			bs:     encodeWire(onlyEx),
-			errMsg: "void method got exception: e E",
+			errMsg: "void method got exception: e E {}",
+		},
+		{
+			msg:    "void with exception got exception with fields",
+			method: "m1Ex",
+			bs:     encodeWire(exWithReason),
+			errMsg: `void method got exception: e E {"reason":"bad input"}`,
 		},
 		{
 			msg:    "void with exception got result",
@@ -366,6 +407,12 @@ func TestCheckSuccess(t *testing.T) {
 			bs:     encodeWire(ex2),
 			errMsg: "method with return got exception: unknown",
 		},
+		{
+			msg:    "i32 return with exception got exception with fields",
+			method: "m2Ex",
+			bs:     encodeWire(exWithReason),
+			errMsg: `method with return got exception: e E {"reason":"bad input"}`,
+		},
 		{
 			msg:    "i32 return with exception got both",
 			method: "m2Ex",
@@ -0,0 +1,62 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/thriftrw/compile"
+)
+
+// FunctionSignature returns the signature of the given function in the Thrift
+// IDL format, e.g., "i32 add(1: i32 x, 2: i32 y) throws (1: Overflow ex)".
+func FunctionSignature(f *compile.FunctionSpec) string {
+	returnType := "void"
+	var exceptions compile.FieldGroup
+	if f.ResultSpec != nil {
+		if f.ResultSpec.ReturnType != nil {
+			returnType = f.ResultSpec.ReturnType.ThriftName()
+		}
+		exceptions = f.ResultSpec.Exceptions
+	}
+
+	sig := fmt.Sprintf("%v %v(%v)", returnType, f.Name, fieldsSignature(compile.FieldGroup(f.ArgsSpec)))
+	if f.OneWay {
+		sig = "oneway " + sig
+	}
+	if len(exceptions) > 0 {
+		sig += fmt.Sprintf(" throws (%v)", fieldsSignature(exceptions))
+	}
+	return sig
+}
+
+func fieldsSignature(fields compile.FieldGroup) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		required := ""
+		if f.Required {
+			required = "required "
+		}
+		parts[i] = fmt.Sprintf("%v: %v%v %v", f.ID, required, f.Type.ThriftName(), f.Name)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionSignature(t *testing.T) {
+	funcSpecs := getFuncSpecs(t, `
+    exception Ex {}
+
+    struct S {}
+
+    service Test {
+      void noArgs()
+      i32 withArgs(1: i32 i, 2: required list<string> l, 3: S s)
+      map<string, i64> withException(1: string s) throws (1: Ex ex, 2: Ex ex2)
+      oneway void fire(1: string s)
+    }
+  `)
+
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{
+			method: "noArgs",
+			want:   "void noArgs()",
+		},
+		{
+			method: "withArgs",
+			want:   "i32 withArgs(1: i32 i, 2: required list<string> l, 3: S s)",
+		},
+		{
+			method: "withException",
+			want:   "map<string, i64> withException(1: string s) throws (1: Ex ex, 2: Ex ex2)",
+		},
+		{
+			method: "fire",
+			want:   "oneway void fire(1: string s)",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FunctionSignature(funcSpecs[tt.method]), "Signature mismatch for %v", tt.method)
+	}
+}
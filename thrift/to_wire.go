@@ -26,6 +26,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/yarpc/yab/sorted"
+
 	"go.uber.org/thriftrw/ast"
 	"go.uber.org/thriftrw/compile"
 	"go.uber.org/thriftrw/wire"
@@ -35,7 +37,14 @@ import (
 var (
 	errUsingSingleField   = errors.New("union value must only have a single value")
 	errStructUseMapString = errors.New("struct must be specified using map[string]*")
-	errMapUnknownType     = errors.New("map must be specified as a mapping")
+	errMapUnknownType     = errors.New("map must be specified as a mapping or a list of key-value items")
+)
+
+// Maps with keys that can't be used as JSON object keys can be specified as
+// a list of items with these fields.
+const (
+	_mapItemKey   = "key"
+	_mapItemValue = "value"
 )
 
 func structValueMap(value interface{}) (map[string]interface{}, bool) {
@@ -73,6 +82,48 @@ func structToValue(fieldGroup compile.FieldGroup, value interface{}) (wire.Struc
 	return wire.Struct{Fields: fields}, nil
 }
 
+// unionToValue converts a union from JSON to a wire.Struct. Exactly one field
+// must be set, where fields set to null are treated as unset. Unlike structs,
+// defaults are not set for the other fields, since only one field can be set.
+func unionToValue(spec *compile.StructSpec, value interface{}) (wire.Struct, error) {
+	mapValue, ok := structValueMap(value)
+	if !ok {
+		return wire.Struct{}, errStructUseMapString
+	}
+
+	setFields := make(map[string]interface{}, len(mapValue))
+	for k, v := range mapValue {
+		if v != nil {
+			setFields[k] = v
+		}
+	}
+
+	fields := getFields(spec.Fields)
+	available := sorted.MapKeys(fields.exact)
+	if len(setFields) != 1 {
+		return wire.Struct{}, unionFieldsError{
+			union:     spec.Name,
+			set:       sorted.MapKeys(setFields),
+			available: available,
+		}
+	}
+
+	userFields := make(map[string]interface{}, 1)
+	for k, v := range setFields {
+		field, ok := fields.getField(k)
+		if !ok {
+			return wire.Struct{}, fieldGroupError{available: available, notFound: []string{k}}
+		}
+		userFields[field.ThriftName()] = v
+	}
+
+	wireFields, err := fieldsMapToValue(fields.exact, userFields)
+	if err != nil {
+		return wire.Struct{}, err
+	}
+	return wire.Struct{Fields: wireFields}, nil
+}
+
 func listToValue(t string, spec compile.TypeSpec, value interface{}) (wire.ValueList, error) {
 	valueList, ok := value.([]interface{})
 	if !ok {
@@ -122,36 +173,72 @@ func convertMapKey(keySpec compile.TypeSpec, value interface{}) interface{} {
 	return value
 }
 
-// mapToValue converts a map from JSON to a wire.Map.
-// TODO: Allow specifying maps using a []MapItem form so the user
-// can cleanly use non-string/int keys.
+// mapItemsToValue converts a list of {"key": k, "value": v} items, which is
+// used to specify maps with keys that are not valid JSON object keys.
+func mapItemsToValue(keySpec, valueSpec compile.TypeSpec, list []interface{}) ([]wire.MapItem, error) {
+	items := make([]wire.MapItem, 0, len(list))
+	for i, item := range list {
+		itemMap, ok := structValueMap(item)
+		if !ok {
+			return nil, fmt.Errorf("map item %v must be an object with %q and %q fields, got %T", i, _mapItemKey, _mapItemValue, item)
+		}
+
+		k, hasKey := itemMap[_mapItemKey]
+		v, hasValue := itemMap[_mapItemValue]
+		if !hasKey || !hasValue || len(itemMap) != 2 {
+			return nil, fmt.Errorf("map item %v must only have %q and %q fields", i, _mapItemKey, _mapItemValue)
+		}
+
+		mapItem, err := mapItemToValue(keySpec, valueSpec, k, v)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, mapItem)
+	}
+	return items, nil
+}
+
+func mapItemToValue(keySpec, valueSpec compile.TypeSpec, k, v interface{}) (wire.MapItem, error) {
+	kw, err := toWireValue(keySpec, k)
+	if err != nil {
+		return wire.MapItem{}, fmt.Errorf("map key (%v) failed: %v", k, err)
+	}
+
+	vw, err := toWireValue(valueSpec, v)
+	if err != nil {
+		return wire.MapItem{}, fmt.Errorf("map value (%v) for key (%v) failed: %v", v, k, err)
+	}
+
+	return wire.MapItem{Key: kw, Value: vw}, nil
+}
+
+// mapToValue converts a map from JSON to a wire.Map. The map can be specified
+// as an object, where keys that are not strings are parsed from the key
+// string, or as a list of {"key": k, "value": v} items.
 func mapToValue(keySpec, valueSpec compile.TypeSpec, value interface{}) (wire.MapItemList, error) {
 	var valueMap map[interface{}]interface{}
-	if vm, ok := value.(map[interface{}]interface{}); ok {
+	switch vm := value.(type) {
+	case map[interface{}]interface{}:
 		valueMap = vm
-	} else if vm, ok := value.(map[string]interface{}); ok {
+	case map[string]interface{}:
 		valueMap = convertStringMap(vm)
-	} else {
+	case []interface{}:
+		items, err := mapItemsToValue(keySpec, valueSpec, vm)
+		if err != nil {
+			return nil, err
+		}
+		return wire.MapItemListFromSlice(keySpec.TypeCode(), valueSpec.TypeCode(), items), nil
+	default:
 		return nil, errMapUnknownType
 	}
 
 	items := make([]wire.MapItem, 0, len(valueMap))
 	for k, v := range valueMap {
-		keyValue := convertMapKey(keySpec, k)
-		kw, err := toWireValue(keySpec, keyValue)
+		item, err := mapItemToValue(keySpec, valueSpec, convertMapKey(keySpec, k), v)
 		if err != nil {
-			return nil, fmt.Errorf("map key (%v) failed: %v", k, err)
+			return nil, err
 		}
-
-		vw, err := toWireValue(valueSpec, v)
-		if err != nil {
-			return nil, fmt.Errorf("map value (%v) for key (%v) failed: %v", v, k, err)
-		}
-
-		items = append(items, wire.MapItem{
-			Key:   kw,
-			Value: vw,
-		})
+		items = append(items, item)
 	}
 
 	return wire.MapItemListFromSlice(
@@ -227,7 +314,7 @@ func toWireValue(spec compile.TypeSpec, value interface{}) (w wire.Value, err er
 		w = wire.NewValueI32(int32(intValue))
 	case wire.TI64:
 		var intValue int64
-		intValue, err = parseInt(value, 64)
+		intValue, err = parseI64(value)
 		w = wire.NewValueI64(int64(intValue))
 	case wire.TDouble:
 		var doubleValue float64
@@ -240,7 +327,11 @@ func toWireValue(spec compile.TypeSpec, value interface{}) (w wire.Value, err er
 	case wire.TStruct:
 		sspec := spec.(*compile.StructSpec)
 		var structValue wire.Struct
-		structValue, err = structToValue(sspec.Fields, value)
+		if sspec.Type == ast.UnionType {
+			structValue, err = unionToValue(sspec, value)
+		} else {
+			structValue, err = structToValue(sspec.Fields, value)
+		}
 		if err == nil {
 			err = checkStructValue(sspec, structValue)
 		}
@@ -93,6 +93,19 @@ func parseInt(v interface{}, bits int) (int64, error) {
 	return v64, nil
 }
 
+// parseI64 parses an int64, which may also be specified as a string, since
+// JSON numbers lose precision above 2^53.
+func parseI64(v interface{}) (int64, error) {
+	if s, ok := v.(string); ok {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse int64 from string: %v", s)
+		}
+		return i, nil
+	}
+	return parseInt(v, 64)
+}
+
 // parseDouble parses a float64 from an integer or a float.
 func parseDouble(v interface{}) (float64, error) {
 	switch v := v.(type) {
@@ -142,7 +155,11 @@ func parseBinaryMap(v map[interface{}]interface{}) ([]byte, error) {
 		// Since we don't know whether the user's input is padded or not, we strip
 		// all "=" characters out, and use RawStdEncoding (which does not need padding).
 		str = strings.TrimRight(str, "=")
-		return base64.RawStdEncoding.DecodeString(str)
+		bs, err := base64.RawStdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 for binary value: %v", err)
+		}
+		return bs, nil
 	}
 
 	if v, ok := v["file"]; ok {
@@ -198,6 +198,32 @@ func TestParseInt(t *testing.T) {
 	}
 }
 
+func TestParseI64(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    int64
+		wantErr string
+	}{
+		{value: 5, want: 5},
+		{value: "5", want: 5},
+		{value: "-9007199254740993", want: -9007199254740993},
+		{value: "9223372036854775808", wantErr: "cannot parse int64 from string: 9223372036854775808"},
+		{value: "1e3", wantErr: "cannot parse int64 from string: 1e3"},
+		{value: 1.5, wantErr: "cannot parse int64 from float64: 1.5"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseI64(tt.value)
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr, "parseI64(%v) should fail", tt.value)
+			continue
+		}
+		if assert.NoError(t, err, "parseI64(%v) should not fail", tt.value) {
+			assert.Equal(t, tt.want, got, "parseI64(%v) result mismatch", tt.value)
+		}
+	}
+}
+
 func TestParseDouble(t *testing.T) {
 	tests := []struct {
 		value   interface{}
@@ -302,7 +328,7 @@ func TestParseBinary(t *testing.T) {
 		},
 		{
 			value:  map[interface{}]interface{}{"base64": "a_b"},
-			errMsg: "illegal base64 data",
+			errMsg: "invalid base64 for binary value: illegal base64 data",
 		},
 		{
 			value:  map[interface{}]interface{}{"unsupported": "ab"},
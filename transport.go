@@ -22,10 +22,14 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
@@ -35,16 +39,145 @@ import (
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/uber/tchannel-go"
+	"go.uber.org/zap"
 )
 
 var (
 	errServiceRequired = errors.New("specify a target service using --service")
 	errCallerRequired  = errors.New("caller name is required")
 	errTracerRequired  = errors.New("tracer is required, or explicit NoopTracer")
-	errPeerRequired    = errors.New("specify at least one peer using --peer or using --peer-list")
-	errPeerOptions     = errors.New("do not specify peers using --peer and --peer-list")
+	errPeerRequired    = errors.New("specify at least one peer using --peer or using --peer-list, or a relay using --relay")
+	errTLSCertAndKey   = errors.New("specify both --tls-cert and --tls-key to use a client certificate")
+	errTLSTChannelOnly = errors.New("TLS options are only supported for TChannel peers")
+	errArgSchemeOnly   = errors.New("--arg-scheme is only supported for TChannel peers")
+	errArgSchemeTOpt   = errors.New("--arg-scheme conflicts with the arg scheme set using -T as=<scheme>")
+	errNoDeadlineOnly  = errors.New("--no-deadline-header is only supported for HTTP peers, TChannel and gRPC always send the deadline as part of the call")
+	errCompressOnly    = errors.New("--compress is only supported for HTTP peers")
+	errBindAddressOnly = errors.New("--bind-address is only supported for TChannel and HTTP peers")
+	errResolveOnly     = errors.New("--resolve is only supported for TChannel and HTTP peers")
 )
 
+func unsupportedProtocolError(protocol string) error {
+	return fmt.Errorf("unsupported protocol %q, peers must be host:port, or use a tchannel, grpc, http, https or unix scheme", protocol)
+}
+
+// checkUnixSockets verifies that the socket for each unix:// peer exists.
+func checkUnixSockets(peers []string) error {
+	for _, peer := range peers {
+		path := strings.TrimPrefix(peer, transport.UnixScheme+"://")
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("unix socket %q does not exist", path)
+		}
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("peer %q is not a unix socket", path)
+		}
+	}
+	return nil
+}
+
+// getSeed returns the seed to use for random peer selection.
+func (o TransportOptions) getSeed() int64 {
+	if o.Seed != 0 {
+		return o.Seed
+	}
+	return time.Now().UnixNano()
+}
+
+// tlsEnabled returns whether any TLS options were specified.
+func (o TransportOptions) tlsEnabled() bool {
+	return o.TLS || o.TLSCA != "" || o.TLSCert != "" || o.TLSKey != "" || o.TLSNoVerify
+}
+
+// reportsSpans returns whether spans are reported to a tracing backend.
+func (o TransportOptions) reportsSpans() bool {
+	return o.JaegerAgent != "" || o.TracingEndpoint != ""
+}
+
+// getResolved returns a map from host names to the IP addresses specified
+// using --resolve, or nil if no hosts are resolved.
+func (o TransportOptions) getResolved() (map[string]net.IP, error) {
+	if len(o.Resolve) == 0 {
+		return nil, nil
+	}
+
+	resolved := make(map[string]net.IP, len(o.Resolve))
+	for _, r := range o.Resolve {
+		parts := strings.SplitN(r, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid resolve %q, must be specified as host=ip", r)
+		}
+
+		ip := net.ParseIP(parts[1])
+		if ip == nil {
+			return nil, fmt.Errorf("invalid resolve %q: %q is not an IP address", r, parts[1])
+		}
+		resolved[parts[0]] = ip
+	}
+	return resolved, nil
+}
+
+// benchmarkTracer returns the tracer used by benchmark transports, which is
+// a no-op tracer unless benchmarks are traced.
+func (o TransportOptions) benchmarkTracer() opentracing.Tracer {
+	if o.tracer == nil {
+		return opentracing.NoopTracer{}
+	}
+	return o.tracer
+}
+
+// getTLSConfig returns the TLS configuration specified by the TLS options,
+// or nil if TLS is not enabled.
+func (o TransportOptions) getTLSConfig() (*tls.Config, error) {
+	if !o.tlsEnabled() {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: o.TLSNoVerify,
+	}
+
+	if o.TLSCA != "" {
+		caPEM, err := ioutil.ReadFile(o.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA file: %v", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in TLS CA file: %v", o.TLSCA)
+		}
+	}
+
+	if o.TLSCert != "" || o.TLSKey != "" {
+		if o.TLSCert == "" || o.TLSKey == "" {
+			return nil, errTLSCertAndKey
+		}
+
+		cert, err := tls.LoadX509KeyPair(o.TLSCert, o.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// getBindAddress returns the local IP address that connections should be
+// made from, or nil if no bind address is specified.
+func (o TransportOptions) getBindAddress() (net.IP, error) {
+	if o.BindAddress == "" {
+		return nil, nil
+	}
+
+	ip := net.ParseIP(o.BindAddress)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid bind address %q: must be an IP address", o.BindAddress)
+	}
+	return ip, nil
+}
+
 func remapLocalHost(hostPorts []string) {
 	ip, err := tchannel.ListenIP()
 	if err != nil {
@@ -52,10 +185,24 @@ func remapLocalHost(hostPorts []string) {
 	}
 
 	for i, hp := range hostPorts {
-		if strings.HasPrefix(hp, "localhost:") {
-			hostPorts[i] = ip.String() + ":" + strings.TrimPrefix(hp, "localhost:")
+		if host, port, err := net.SplitHostPort(hp); err == nil && host == "localhost" {
+			hostPorts[i] = net.JoinHostPort(ip.String(), port)
+		}
+	}
+}
+
+// checkIPv6Brackets returns an error for IPv6 peers that are missing the
+// brackets around the address, since the port can't be separated from it.
+func checkIPv6Brackets(peers []string) error {
+	for _, peer := range peers {
+		if strings.Contains(peer, "://") || strings.HasPrefix(peer, "[") {
+			continue
+		}
+		if strings.Count(peer, ":") > 1 {
+			return fmt.Errorf("IPv6 peer %q must use brackets around the address, e.g. [::1]:8080", peer)
 		}
 	}
+	return nil
 }
 
 func parsePeer(peer string) (protocol, host string) {
@@ -91,29 +238,72 @@ func getHosts(peers []string) []string {
 	return hosts
 }
 
-func loadTransportPeers(opts TransportOptions) (TransportOptions, error) {
-	peers := opts.Peers
-	if opts.PeerList != "" {
-		if len(peers) > 0 {
-			return opts, errPeerOptions
+// resolvePeerProvider resolves the peers for the given peer provider URL.
+func resolvePeerProvider(peerList string) ([]string, error) {
+	u, err := url.Parse(peerList)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse peer provider URL: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	peers, err := peerprovider.Resolve(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("specified peer list is empty: %q", peerList)
+	}
+	return peers, nil
+}
+
+// resolveSRVPeers returns the peers with any srv:// peers replaced by the
+// targets of their DNS SRV records.
+func resolveSRVPeers(peers []string) ([]string, error) {
+	resolved := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		if protocol, _ := parsePeer(peer); protocol != "srv" {
+			resolved = append(resolved, peer)
+			continue
 		}
 
-		u, err := url.Parse(opts.PeerList)
+		srvPeers, err := resolvePeerProvider(peer)
 		if err != nil {
-			return opts, fmt.Errorf("could not parse peer provider URL: %v", err)
+			return nil, err
 		}
+		resolved = append(resolved, srvPeers...)
+	}
+	return resolved, nil
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
+// loadTransportPeers resolves the peer list and any srv:// peers, and merges
+// the resolved peers with any peers specified using --peer.
+func loadTransportPeers(opts TransportOptions) (TransportOptions, error) {
+	peers, err := resolveSRVPeers(opts.Peers)
+	if err != nil {
+		return opts, err
+	}
 
-		peers, err = peerprovider.Resolve(ctx, u)
+	if opts.PeerList != "" {
+		listPeers, err := resolvePeerProvider(opts.PeerList)
 		if err != nil {
 			return opts, err
 		}
 
-		if len(peers) == 0 {
-			return opts, fmt.Errorf("specified peer list is empty: %q", opts.PeerList)
+		// Copy the peers so we don't modify the caller's slice.
+		peers = append(append([]string(nil), peers...), listPeers...)
+	}
+
+	// Without any peers, calls are routed by service name through the relays.
+	if len(peers) == 0 {
+		for _, relay := range opts.Relays {
+			if protocol, _ := parsePeer(relay); protocol != "tchannel" {
+				return opts, fmt.Errorf("relay %q must be a TChannel host:port", relay)
+			}
 		}
+		peers = opts.Relays
 	}
 
 	if len(peers) == 0 {
@@ -125,7 +315,38 @@ func loadTransportPeers(opts TransportOptions) (TransportOptions, error) {
 	return opts, nil
 }
 
-func getTransport(opts TransportOptions, encoding encoding.Encoding, tracer opentracing.Tracer) (transport.Transport, error) {
+// getMultiPeerTransport returns a transport that uses a separate transport for
+// each peer, and distributes calls across them using the given strategy.
+func getMultiPeerTransport(opts TransportOptions, strategy transport.PeerStrategy, encoding encoding.Encoding, tracer opentracing.Tracer, logger *zap.Logger) (transport.Transport, error) {
+	transports := make([]transport.Transport, 0, len(opts.Peers))
+	for _, peer := range opts.Peers {
+		peerOpts := opts
+		peerOpts.Peers = []string{peer}
+		peerOpts.PeerStrategy = ""
+
+		t, err := getPeersTransport(peerOpts, encoding, tracer, logger)
+		if err != nil {
+			return nil, err
+		}
+		transports = append(transports, t)
+	}
+
+	return transport.NewMultiPeer(strategy, transports, opts.rand)
+}
+
+// getTransport returns a transport for the peers in opts. Peer selection and
+// connection attempts are logged to logger. If an auth token is configured,
+// it is added to the headers of each call.
+func getTransport(opts TransportOptions, encoding encoding.Encoding, tracer opentracing.Tracer, logger *zap.Logger) (transport.Transport, error) {
+	t, err := getPeersTransport(opts, encoding, tracer, logger)
+	if err != nil || opts.authTokens == nil {
+		return t, err
+	}
+	return authTransport{Transport: t, tokens: opts.authTokens}, nil
+}
+
+// getPeersTransport is like getTransport, but doesn't add auth tokens.
+func getPeersTransport(opts TransportOptions, encoding encoding.Encoding, tracer opentracing.Tracer, logger *zap.Logger) (transport.Transport, error) {
 	if opts.ServiceName == "" {
 		return nil, errServiceRequired
 	}
@@ -138,19 +359,89 @@ func getTransport(opts TransportOptions, encoding encoding.Encoding, tracer open
 		return nil, errTracerRequired
 	}
 
+	var strategy transport.PeerStrategy
+	if opts.PeerStrategy != "" {
+		var err error
+		if strategy, err = transport.ParsePeerStrategy(opts.PeerStrategy); err != nil {
+			return nil, err
+		}
+	}
+
 	opts, err := loadTransportPeers(opts)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkIPv6Brackets(opts.Peers); err != nil {
+		return nil, err
+	}
+
 	protocol, err := ensureSameProtocol(opts.Peers)
 	if err != nil {
 		return nil, err
 	}
 
+	tlsConfig, err := opts.getTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil && protocol != "tchannel" {
+		return nil, errTLSTChannelOnly
+	}
+	if opts.ArgScheme != "" && protocol != "tchannel" {
+		return nil, errArgSchemeOnly
+	}
+	// The "as" transport option also sets the arg scheme, and overrides the
+	// arg scheme from the options, so reject conflicting values.
+	if as, ok := opts.TransportHeaders["as"]; ok && opts.ArgScheme != "" && as != opts.ArgScheme {
+		return nil, errArgSchemeTOpt
+	}
+
+	if opts.NoDeadlineHeader && (protocol == "tchannel" || protocol == "grpc") {
+		return nil, errNoDeadlineOnly
+	}
+	if opts.Compress && (protocol == "tchannel" || protocol == "grpc") {
+		return nil, errCompressOnly
+	}
+
+	bindAddr, err := opts.getBindAddress()
+	if err != nil {
+		return nil, err
+	}
+	if bindAddr != nil && (protocol == "grpc" || protocol == transport.UnixScheme) {
+		return nil, errBindAddressOnly
+	}
+
+	resolved, err := opts.getResolved()
+	if err != nil {
+		return nil, err
+	}
+	if resolved != nil && (protocol == "grpc" || protocol == transport.UnixScheme) {
+		return nil, errResolveOnly
+	}
+
+	logger.Info("Selected peers.",
+		zap.String("protocol", protocol),
+		zap.Strings("peers", opts.Peers),
+		zap.String("peerStrategy", string(strategy)),
+	)
+
+	if strategy != "" && len(opts.Peers) > 1 {
+		return getMultiPeerTransport(opts, strategy, encoding, tracer, logger)
+	}
+
 	if protocol == "tchannel" {
 		hostPorts := getHosts(opts.Peers)
-		remapLocalHost(hostPorts)
+		if tlsConfig == nil {
+			// With TLS, the host name is used to verify the server certificate,
+			// so it can't be remapped.
+			remapLocalHost(hostPorts)
+		}
+
+		argScheme := encoding.String()
+		if opts.ArgScheme != "" {
+			argScheme = opts.ArgScheme
+		}
 
 		topts := transport.TChannelOptions{
 			SourceService:   opts.CallerName,
@@ -159,9 +450,14 @@ func getTransport(opts TransportOptions, encoding encoding.Encoding, tracer open
 			RoutingKey:      opts.RoutingKey,
 			ShardKey:        opts.ShardKey,
 			Peers:           hostPorts,
-			Encoding:        encoding.String(),
+			Encoding:        argScheme,
 			TransportOpts:   opts.TransportHeaders,
 			Tracer:          tracer,
+			TLSConfig:       tlsConfig,
+			DialTimeout:     opts.DialTimeout,
+			LocalAddr:       bindAddr,
+			Resolved:        resolved,
+			Logger:          logger,
 		}
 		return transport.NewTChannel(topts)
 	}
@@ -177,15 +473,31 @@ func getTransport(opts TransportOptions, encoding encoding.Encoding, tracer open
 		})
 	}
 
+	if protocol == transport.UnixScheme {
+		// Unix sockets are called using HTTP.
+		if err := checkUnixSockets(opts.Peers); err != nil {
+			return nil, err
+		}
+	} else if protocol != "http" && protocol != "https" {
+		return nil, unsupportedProtocolError(protocol)
+	}
+
 	hopts := transport.HTTPOptions{
-		SourceService:   opts.CallerName,
-		TargetService:   opts.ServiceName,
-		RoutingDelegate: opts.RoutingDelegate,
-		RoutingKey:      opts.RoutingKey,
-		ShardKey:        opts.ShardKey,
-		Encoding:        encoding.String(),
-		URLs:            opts.Peers,
-		Tracer:          tracer,
+		SourceService:    opts.CallerName,
+		TargetService:    opts.ServiceName,
+		RoutingDelegate:  opts.RoutingDelegate,
+		RoutingKey:       opts.RoutingKey,
+		ShardKey:         opts.ShardKey,
+		Encoding:         encoding.String(),
+		URLs:             opts.Peers,
+		Tracer:           tracer,
+		DialTimeout:      opts.DialTimeout,
+		LocalAddr:        bindAddr,
+		Resolved:         resolved,
+		NoDeadlineHeader: opts.NoDeadlineHeader,
+		Compress:         opts.Compress,
+		Rand:             opts.rand,
+		Logger:           logger,
 	}
 	return transport.NewHTTP(hopts)
 }
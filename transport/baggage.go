@@ -0,0 +1,62 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import "github.com/opentracing/opentracing-go"
+
+// _baggageHeaderPrefix is the prefix used by Jaeger for baggage headers.
+const _baggageHeaderPrefix = "uberctx-"
+
+// _tchannelTracingPrefix is the prefix used by TChannel for tracing headers
+// sent as application headers.
+const _tchannelTracingPrefix = "$tracing$"
+
+// baggageHeaders returns the baggage of the request as headers in the format
+// used by Jaeger, with the given prefix, so baggage can be propagated without
+// a tracer. If the tracer propagates the baggage, no headers are returned.
+func baggageHeaders(tracer opentracing.Tracer, r *Request, prefix string) map[string]string {
+	if _, noop := tracer.(opentracing.NoopTracer); tracer != nil && !noop {
+		return nil
+	}
+
+	headers := make(map[string]string, len(r.Baggage))
+	for k, v := range r.Baggage {
+		headers[prefix+_baggageHeaderPrefix+k] = v
+	}
+	return headers
+}
+
+// withBaggageHeaders returns a copy of headers with the baggage headers added,
+// or headers as is if there are no baggage headers.
+func withBaggageHeaders(headers, baggage map[string]string) map[string]string {
+	if len(baggage) == 0 {
+		return headers
+	}
+
+	merged := make(map[string]string, len(headers)+len(baggage))
+	for k, v := range headers {
+		merged[k] = v
+	}
+	for k, v := range baggage {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+// DialError is returned when a connection to a peer could not be established,
+// so that connection failures can be reported separately from call failures.
+type DialError struct {
+	Addr string
+	Err  error
+}
+
+func (e *DialError) Error() string {
+	return fmt.Sprintf("failed to connect to %v: %v", e.Addr, e.Err)
+}
+
+// dialer establishes connections to peers.
+type dialer struct {
+	// timeout limits the time spent connecting, if it is non-zero.
+	timeout time.Duration
+
+	// localAddr is the local IP address that connections are made from,
+	// if it is non-nil.
+	localAddr net.IP
+
+	// resolved maps host names to the IP addresses that are used instead of
+	// resolving the host names using DNS.
+	resolved map[string]net.IP
+
+	// logger logs each connection attempt. It may be nil.
+	logger *zap.Logger
+}
+
+// dialContext dials the given address. Failures are returned as a *DialError.
+func (d dialer) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	logger := d.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	dialAddr := d.resolve(addr)
+	logger.Info("Connecting to peer.", zap.String("network", network), zap.String("addr", addr), zap.String("dialAddr", dialAddr))
+
+	start := time.Now()
+	netDialer := &net.Dialer{Timeout: d.timeout}
+	if d.localAddr != nil {
+		netDialer.LocalAddr = &net.TCPAddr{IP: d.localAddr}
+	}
+	conn, err := netDialer.DialContext(ctx, network, dialAddr)
+	if err != nil {
+		logger.Info("Failed to connect to peer.", zap.String("addr", addr), zap.Duration("duration", time.Since(start)), zap.Error(err))
+		return nil, &DialError{Addr: addr, Err: err}
+	}
+
+	logger.Info("Connected to peer.", zap.String("addr", addr), zap.Duration("duration", time.Since(start)))
+	return conn, nil
+}
+
+// resolve returns the address to dial for the given host:port, which uses
+// the resolved IP address for the host if there is one.
+func (d dialer) resolve(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip, ok := d.resolved[host]; ok {
+		return net.JoinHostPort(ip.String(), port)
+	}
+	return addr
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialerResolve(t *testing.T) {
+	d := dialer{
+		resolved: map[string]net.IP{
+			"kv.example.com":   net.ParseIP("1.2.3.4"),
+			"ipv6.example.com": net.ParseIP("::1"),
+		},
+	}
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "kv.example.com:8080", want: "1.2.3.4:8080"},
+		{addr: "ipv6.example.com:8080", want: "[::1]:8080"},
+		{addr: "other.example.com:8080", want: "other.example.com:8080"},
+		{addr: "kv.example.com", want: "kv.example.com"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, d.resolve(tt.addr), "Unexpected address to dial for %v", tt.addr)
+	}
+}
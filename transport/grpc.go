@@ -141,7 +141,7 @@ func (t *grpcTransport) requestToYARPCRequest(request *Request) *transport.Reque
 		Service:         request.TargetService,
 		Encoding:        transport.Encoding(t.Encoding),
 		Procedure:       request.Method,
-		Headers:         transport.HeadersFromMap(request.Headers),
+		Headers:         transport.HeadersFromMap(withBaggageHeaders(request.Headers, baggageHeaders(t.tracer, request, "" /* prefix */))),
 		ShardKey:        request.ShardKey,
 		RoutingKey:      t.RoutingKey,
 		RoutingDelegate: t.RoutingDelegate,
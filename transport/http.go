@@ -22,18 +22,41 @@ package transport
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
 	"golang.org/x/net/context"
 )
 
+// UnixScheme is the URL scheme for peers that are Unix domain sockets, which
+// are called using HTTP, e.g., unix:///var/run/service.sock.
+const UnixScheme = "unix"
+
+// CompressedSizeField is the transport field set to the size of the response
+// body before it was decompressed, if the response was compressed.
+const CompressedSizeField = "compressedSize"
+
+// StatusError is returned when a HTTP call gets a non-success status code,
+// which means the server responded to the call.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP call got non-success response code: %v, body: %s", e.StatusCode, e.Body)
+}
+
 type httpTransport struct {
 	opts   HTTPOptions
 	client *http.Client
@@ -50,6 +73,37 @@ type HTTPOptions struct {
 	ShardKey        string
 	Encoding        string
 	Tracer          opentracing.Tracer
+
+	// DialTimeout limits how long connecting to a peer may take.
+	// If it is zero, connecting is only limited by the call timeout.
+	DialTimeout time.Duration
+
+	// LocalAddr is the local IP address that connections are made from.
+	// If it is nil, the address is chosen by the operating system. It is not
+	// used for Unix sockets.
+	LocalAddr net.IP
+
+	// Resolved maps host names to the IP addresses that are used to connect
+	// to them, instead of resolving the host names using DNS. The host name
+	// is still used for the Host header and to verify TLS certificates.
+	Resolved map[string]net.IP
+
+	// NoDeadlineHeader disables the Context-TTL-MS header, which propagates
+	// the deadline of the call, for servers that reject it.
+	NoDeadlineHeader bool
+
+	// Compress requests gzip compressed responses, which are decompressed
+	// before they are returned. Responses that are not compressed are
+	// returned as is.
+	Compress bool
+
+	// Rand is used to pick the URL for each call. If it is nil, the global
+	// source is used.
+	Rand *Rand
+
+	// Logger is used to log connection attempts. If it is nil, nothing is
+	// logged.
+	Logger *zap.Logger
 }
 
 var (
@@ -66,22 +120,65 @@ func NewHTTP(opts HTTPOptions) (Transport, error) {
 		return nil, errMissingTarget
 	}
 
+	var sockets map[string]string
+	opts.URLs, sockets = unixSocketURLs(opts.URLs)
+
 	return &httpTransport{
 		opts: opts,
 		// Use independent HTTP clients for each transport.
 		client: &http.Client{
-			Transport: &http.Transport{},
+			Transport: newRoundTripper(sockets, dialer{
+				timeout:   opts.DialTimeout,
+				localAddr: opts.LocalAddr,
+				resolved:  opts.Resolved,
+				logger:    opts.Logger,
+			}),
 		},
 		tracer: opts.Tracer,
 	}, nil
 }
 
+// unixSocketURLs replaces Unix socket URLs with HTTP URLs that use a
+// placeholder host for each socket, and returns a map from the placeholder
+// host:port to the socket path.
+func unixSocketURLs(urls []string) ([]string, map[string]string) {
+	prefix := UnixScheme + "://"
+	rewritten := make([]string, len(urls))
+	sockets := make(map[string]string)
+	for i, u := range urls {
+		if !strings.HasPrefix(u, prefix) {
+			rewritten[i] = u
+			continue
+		}
+
+		host := fmt.Sprintf("unix-socket-%v", i)
+		sockets[host+":80"] = strings.TrimPrefix(u, prefix)
+		rewritten[i] = "http://" + host + "/"
+	}
+	return rewritten, sockets
+}
+
+// newRoundTripper returns a HTTP transport that dials the Unix socket for
+// any placeholder hosts in sockets, and dials other hosts using d. Unix
+// sockets are dialed with the same timeout, but without a local address.
+func newRoundTripper(sockets map[string]string, d dialer) *http.Transport {
+	unixDialer := dialer{timeout: d.timeout, logger: d.logger}
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if path, ok := sockets[addr]; ok {
+				return unixDialer.dialContext(ctx, "unix", path)
+			}
+			return d.dialContext(ctx, network, addr)
+		},
+	}
+}
+
 func (h *httpTransport) Tracer() opentracing.Tracer {
 	return h.tracer
 }
 
 func (h *httpTransport) newReq(ctx context.Context, r *Request) (*http.Request, error) {
-	url := h.opts.URLs[rand.Intn(len(h.opts.URLs))]
+	url := h.opts.URLs[h.opts.Rand.Intn(len(h.opts.URLs))]
 
 	// TODO: We should envelope Thrift paylods here.
 	req, err := http.NewRequest("POST", url, bytes.NewReader(r.Body))
@@ -100,6 +197,14 @@ func (h *httpTransport) newReq(ctx context.Context, r *Request) (*http.Request,
 	for key, val := range r.TransportHeaders {
 		req.Header.Add(key, val)
 	}
+	if h.opts.Compress {
+		// Setting the header explicitly disables the transparent decompression
+		// done by net/http, so the compressed size can be reported.
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	for key, val := range baggageHeaders(h.tracer, r, "" /* prefix */) {
+		req.Header.Set(key, val)
+	}
 
 	span := opentracing.SpanFromContext(ctx)
 	if span != nil && h.tracer != nil {
@@ -127,7 +232,9 @@ func (h *httpTransport) applyRPCHeaders(headers http.Header, r *Request, req *ht
 	if h.opts.ShardKey != "" {
 		req.Header.Add("RPC-Shard-Key", h.opts.ShardKey)
 	}
-	req.Header.Add("Context-TTL-MS", strconv.Itoa(int(timeout/time.Millisecond)))
+	if !h.opts.NoDeadlineHeader {
+		req.Header.Add("Context-TTL-MS", strconv.Itoa(int(timeout/time.Millisecond)))
+	}
 
 	for key, val := range r.Headers {
 		req.Header.Add("Rpc-Header-"+key, val)
@@ -138,6 +245,15 @@ func (h *httpTransport) Protocol() Protocol {
 	return HTTP
 }
 
+// Close closes any idle connections, which includes the connections used by
+// calls that have completed.
+func (h *httpTransport) Close() error {
+	if rt, ok := h.client.Transport.(*http.Transport); ok {
+		rt.CloseIdleConnections()
+	}
+	return nil
+}
+
 func (h *httpTransport) Call(ctx context.Context, r *Request) (*Response, error) {
 	req, err := h.newReq(ctx, r)
 	if err != nil {
@@ -146,28 +262,56 @@ func (h *httpTransport) Call(ctx context.Context, r *Request) (*Response, error)
 
 	resp, err := h.client.Do(req.WithContext(ctx))
 	if err != nil {
+		// Return connection failures as is, without the URL wrapping.
+		if urlErr, ok := err.(*url.Error); ok {
+			if dialErr, ok := urlErr.Err.(*DialError); ok {
+				return nil, dialErr
+			}
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("HTTP call got non-success response code: %v, body: %s", resp.StatusCode, body)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: body}
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read HTTP response body: %v", err)
 	}
 
+	transportFields := map[string]interface{}{
+		"statusCode": resp.StatusCode,
+	}
+	if h.opts.Compress && resp.Header.Get("Content-Encoding") == "gzip" {
+		transportFields[CompressedSizeField] = len(body)
+		if body, err = gunzip(body); err != nil {
+			return nil, fmt.Errorf("failed to decompress HTTP response body: %v", err)
+		}
+
+		// The headers should describe the decompressed body.
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+	}
+
 	headers := make(map[string]string)
 	for headerKey := range resp.Header {
 		headers[headerKey] = resp.Header.Get(headerKey)
 	}
 
 	return &Response{
-		Headers: headers,
-		Body:    body,
-		TransportFields: map[string]interface{}{
-			"statusCode": resp.StatusCode,
-		},
+		Headers:         headers,
+		Body:            body,
+		TransportFields: transportFields,
 	}, nil
 }
+
+func gunzip(compressed []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
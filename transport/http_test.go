@@ -21,19 +21,29 @@
 package transport
 
 import (
+	"bytes"
+	"compress/gzip"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/opentracing/opentracing-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestHTTPConstructor(t *testing.T) {
@@ -244,3 +254,316 @@ func TestHTTPCall(t *testing.T) {
 		assert.Equal(t, lastReq.body, tt.r.Body, "Body mismatch")
 	}
 }
+
+func TestHTTPUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yab-unix")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "svc.sock")
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err, "Failed to listen on unix socket")
+
+	svr := &httptest.Server{
+		Listener: ln,
+		Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Service", r.Header.Get("RPC-Service"))
+			io.Copy(w, r.Body)
+		})},
+	}
+	svr.Start()
+	defer svr.Close()
+
+	transport, err := NewHTTP(HTTPOptions{
+		URLs:          []string{"unix://" + socket},
+		SourceService: "source",
+		TargetService: "target",
+	})
+	require.NoError(t, err, "Failed to create HTTP transport")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := transport.Call(ctx, &Request{Method: "method", Body: []byte("body")})
+	require.NoError(t, err, "Call over unix socket failed")
+	assert.Equal(t, []byte("body"), res.Body, "Response body mismatch")
+	assert.Equal(t, "target", res.Headers["Service"], "Service header mismatch")
+}
+
+func TestUnixSocketURLs(t *testing.T) {
+	urls, sockets := unixSocketURLs([]string{"http://1.1.1.1", "unix:///tmp/a.sock", "unix://b.sock"})
+	assert.Equal(t, []string{"http://1.1.1.1", "http://unix-socket-1/", "http://unix-socket-2/"}, urls, "URLs mismatch")
+	assert.Equal(t, map[string]string{
+		"unix-socket-1:80": "/tmp/a.sock",
+		"unix-socket-2:80": "b.sock",
+	}, sockets, "Sockets mismatch")
+}
+
+func TestHTTPNoDeadlineHeader(t *testing.T) {
+	tests := []struct {
+		noDeadlineHeader bool
+		wantHeader       bool
+	}{
+		{noDeadlineHeader: false, wantHeader: true},
+		{noDeadlineHeader: true, wantHeader: false},
+	}
+
+	for _, tt := range tests {
+		var gotTTL string
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotTTL = r.Header.Get("Context-TTL-MS")
+		}))
+
+		transport, err := NewHTTP(HTTPOptions{
+			URLs:             []string{svr.URL},
+			SourceService:    "source",
+			TargetService:    "target",
+			NoDeadlineHeader: tt.noDeadlineHeader,
+		})
+		require.NoError(t, err, "Failed to create HTTP transport")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err = transport.Call(ctx, &Request{Method: "method"})
+		cancel()
+		svr.Close()
+
+		require.NoError(t, err, "Call failed")
+		assert.Equal(t, tt.wantHeader, gotTTL != "", "Unexpected deadline header %q with NoDeadlineHeader %v", gotTTL, tt.noDeadlineHeader)
+	}
+}
+
+func TestHTTPBaggageHeaders(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("source", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+
+	tests := []struct {
+		msg    string
+		tracer opentracing.Tracer
+		want   string
+	}{
+		{
+			msg:  "no tracer",
+			want: "v",
+		},
+		{
+			msg:    "noop tracer",
+			tracer: opentracing.NoopTracer{},
+			want:   "v",
+		},
+		{
+			// The tracer only injects baggage for calls with a span.
+			msg:    "tracer propagates baggage",
+			tracer: tracer,
+		},
+	}
+
+	for _, tt := range tests {
+		var got string
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get("uberctx-k")
+		}))
+
+		transport, err := NewHTTP(HTTPOptions{
+			URLs:          []string{svr.URL},
+			SourceService: "source",
+			TargetService: "target",
+			Tracer:        tt.tracer,
+		})
+		require.NoError(t, err, "%v: failed to create HTTP transport", tt.msg)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err = transport.Call(ctx, &Request{Method: "method", Baggage: map[string]string{"k": "v"}})
+		cancel()
+		svr.Close()
+
+		require.NoError(t, err, "%v: call failed", tt.msg)
+		assert.Equal(t, tt.want, got, "%v: unexpected baggage header", tt.msg)
+	}
+}
+
+func TestHTTPCompress(t *testing.T) {
+	body := bytes.Repeat([]byte("compressible "), 100)
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, err := gw.Write(body)
+	require.NoError(t, err, "failed to compress body")
+	require.NoError(t, gw.Close(), "failed to close gzip writer")
+
+	tests := []struct {
+		msg            string
+		compress       bool
+		serverCompress bool
+		wantAccept     string
+		wantCompressed bool
+	}{
+		{
+			msg:            "compressed response is decompressed",
+			compress:       true,
+			serverCompress: true,
+			wantAccept:     "gzip",
+			wantCompressed: true,
+		},
+		{
+			msg:        "server does not compress",
+			compress:   true,
+			wantAccept: "gzip",
+		},
+		{
+			// net/http requests and decompresses gzip transparently by default.
+			msg:            "compress disabled",
+			serverCompress: true,
+			wantAccept:     "gzip",
+		},
+	}
+
+	for _, tt := range tests {
+		var gotAccept string
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotAccept = r.Header.Get("Accept-Encoding")
+			if tt.serverCompress {
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Write(compressed.Bytes())
+				return
+			}
+			w.Write(body)
+		}))
+
+		transport, err := NewHTTP(HTTPOptions{
+			URLs:          []string{svr.URL},
+			SourceService: "source",
+			TargetService: "target",
+			Compress:      tt.compress,
+		})
+		require.NoError(t, err, "%v: failed to create HTTP transport", tt.msg)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		res, err := transport.Call(ctx, &Request{Method: "method"})
+		cancel()
+		svr.Close()
+
+		require.NoError(t, err, "%v: call failed", tt.msg)
+		assert.Equal(t, tt.wantAccept, gotAccept, "%v: unexpected Accept-Encoding", tt.msg)
+		assert.Equal(t, body, res.Body, "%v: unexpected body", tt.msg)
+		assert.Empty(t, res.Headers["Content-Encoding"], "%v: Content-Encoding should be removed", tt.msg)
+		if tt.wantCompressed {
+			assert.Equal(t, compressed.Len(), res.TransportFields[CompressedSizeField], "%v: unexpected compressed size", tt.msg)
+		} else {
+			assert.NotContains(t, res.TransportFields, CompressedSizeField, "%v: unexpected compressed size", tt.msg)
+		}
+	}
+}
+
+func TestHTTPLocalAddr(t *testing.T) {
+	var gotRemote string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRemote, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}))
+	defer svr.Close()
+
+	tests := []struct {
+		msg       string
+		localAddr net.IP
+		wantErr   bool
+	}{
+		{
+			msg:       "loopback address",
+			localAddr: net.ParseIP("127.0.0.1"),
+		},
+		{
+			// 192.0.2.0/24 is reserved for documentation, so it's not assigned
+			// to any local interface.
+			msg:       "unassigned address",
+			localAddr: net.ParseIP("192.0.2.1"),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		gotRemote = ""
+		transport, err := NewHTTP(HTTPOptions{
+			URLs:          []string{svr.URL},
+			SourceService: "source",
+			TargetService: "target",
+			LocalAddr:     tt.localAddr,
+		})
+		require.NoError(t, err, "%v: failed to create HTTP transport", tt.msg)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err = transport.Call(ctx, &Request{Method: "method"})
+		cancel()
+
+		if tt.wantErr {
+			require.Error(t, err, "%v: call should fail", tt.msg)
+			assert.IsType(t, &DialError{}, err, "%v: expected DialError", tt.msg)
+			continue
+		}
+
+		require.NoError(t, err, "%v: call failed", tt.msg)
+		assert.Equal(t, tt.localAddr.String(), gotRemote, "%v: unexpected source address", tt.msg)
+	}
+}
+
+func TestHTTPResolved(t *testing.T) {
+	var gotHost string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+	}))
+	defer svr.Close()
+
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(svr.URL, "http://"))
+	require.NoError(t, err, "Failed to parse server URL")
+
+	// The .invalid TLD is reserved, so the host can only be dialed if it's
+	// resolved to the server's address.
+	host := net.JoinHostPort("yab.invalid", port)
+	transport, err := NewHTTP(HTTPOptions{
+		URLs:          []string{"http://" + host},
+		SourceService: "source",
+		TargetService: "target",
+		Resolved:      map[string]net.IP{"yab.invalid": net.ParseIP("127.0.0.1")},
+	})
+	require.NoError(t, err, "Failed to create HTTP transport")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = transport.Call(ctx, &Request{Method: "method"})
+	require.NoError(t, err, "Call to resolved host failed")
+	assert.Equal(t, host, gotHost, "Host header should use the original host")
+}
+
+func TestHTTPDialError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var logs bytes.Buffer
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.AddSync(&logs),
+		zap.InfoLevel,
+	))
+
+	transport, err := NewHTTP(HTTPOptions{
+		URLs:          []string{"http://" + addr},
+		SourceService: "source",
+		TargetService: "target",
+		DialTimeout:   time.Second,
+		Logger:        logger,
+	})
+	require.NoError(t, err, "Failed to create HTTP transport")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = transport.Call(ctx, &Request{Method: "method"})
+	require.Error(t, err, "Call to closed port should fail")
+	dialErr, ok := err.(*DialError)
+	require.True(t, ok, "Expected DialError, got %T: %v", err, err)
+	assert.Equal(t, addr, dialErr.Addr, "DialError address mismatch")
+
+	assert.Contains(t, logs.String(), "Connecting to peer.", "connection attempt should be logged")
+	assert.Contains(t, logs.String(), "Failed to connect to peer.", "connection failure should be logged")
+	assert.Contains(t, logs.String(), addr, "logs should include the peer address")
+}
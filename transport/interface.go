@@ -38,6 +38,9 @@ type Request struct {
 	TransportHeaders map[string]string
 	ShardKey         string
 	Body             []byte
+
+	// Oneway requests are sent without waiting for a response.
+	Oneway bool
 }
 
 // Response represents the result of an RPC.
@@ -0,0 +1,153 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
+	"golang.org/x/net/context"
+)
+
+// PeerStrategy is how calls are distributed across multiple peers.
+type PeerStrategy string
+
+// The list of peer strategies supported by YAB.
+const (
+	// PeerRandom sends each call to a random peer.
+	PeerRandom PeerStrategy = "random"
+
+	// PeerRoundRobin sends each call to the next peer in order.
+	PeerRoundRobin PeerStrategy = "roundrobin"
+
+	// PeerFanout sends each call to every peer, and waits for all peers to
+	// respond. The first successful response is returned, and the call only
+	// fails if every peer fails.
+	PeerFanout PeerStrategy = "fanout"
+)
+
+var errNoPeerTransports = errors.New("specify at least one peer transport")
+
+// ParsePeerStrategy returns the PeerStrategy for the given name.
+func ParsePeerStrategy(s string) (PeerStrategy, error) {
+	switch strategy := PeerStrategy(s); strategy {
+	case PeerRandom, PeerRoundRobin, PeerFanout:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown peer strategy %q, must be one of: %v, %v, %v",
+		s, PeerRandom, PeerRoundRobin, PeerFanout)
+}
+
+type multiPeer struct {
+	strategy   PeerStrategy
+	transports []Transport
+	rand       *Rand
+	next       atomic.Int64
+}
+
+// NewMultiPeer returns a transport that distributes calls across the given
+// transports, each of which calls a single peer, using the given strategy.
+// The random strategy picks peers using r, or the global source if r is nil.
+func NewMultiPeer(strategy PeerStrategy, transports []Transport, r *Rand) (TransportCloser, error) {
+	if len(transports) == 0 {
+		return nil, errNoPeerTransports
+	}
+	if _, err := ParsePeerStrategy(string(strategy)); err != nil {
+		return nil, err
+	}
+
+	return &multiPeer{
+		strategy:   strategy,
+		transports: transports,
+		rand:       r,
+	}, nil
+}
+
+func (m *multiPeer) Protocol() Protocol {
+	return m.transports[0].Protocol()
+}
+
+func (m *multiPeer) Tracer() opentracing.Tracer {
+	return m.transports[0].Tracer()
+}
+
+func (m *multiPeer) Close() error {
+	var err error
+	for _, t := range m.transports {
+		if closer, ok := t.(TransportCloser); ok {
+			err = multierr.Append(err, closer.Close())
+		}
+	}
+	return err
+}
+
+func (m *multiPeer) Call(ctx context.Context, r *Request) (*Response, error) {
+	switch m.strategy {
+	case PeerRoundRobin:
+		i := (m.next.Inc() - 1) % int64(len(m.transports))
+		return m.transports[i].Call(ctx, r)
+	case PeerFanout:
+		return m.fanout(ctx, r)
+	default:
+		return m.transports[m.rand.Intn(len(m.transports))].Call(ctx, r)
+	}
+}
+
+// fanout calls every peer concurrently, and returns the first successful
+// response once all peers have responded.
+func (m *multiPeer) fanout(ctx context.Context, r *Request) (*Response, error) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		res  *Response
+		errs error
+	)
+
+	for _, t := range m.transports {
+		wg.Add(1)
+		go func(t Transport) {
+			defer wg.Done()
+
+			// The request is shared across peers, so transports must not modify it.
+			peerRes, err := t.Call(ctx, r)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = multierr.Append(errs, err)
+				return
+			}
+			if res == nil {
+				res = peerRes
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	if res == nil {
+		return nil, errs
+	}
+	return res, nil
+}
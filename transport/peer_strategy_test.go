@@ -0,0 +1,184 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// peerTransport is a fake transport for a single peer that counts calls.
+type peerTransport struct {
+	name   string
+	err    error
+	calls  atomic.Int32
+	closed atomic.Bool
+}
+
+func (p *peerTransport) Call(ctx context.Context, r *Request) (*Response, error) {
+	p.calls.Inc()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &Response{Body: []byte(p.name)}, nil
+}
+
+func (p *peerTransport) Protocol() Protocol         { return HTTP }
+func (p *peerTransport) Tracer() opentracing.Tracer { return opentracing.NoopTracer{} }
+
+func (p *peerTransport) Close() error {
+	p.closed.Store(true)
+	return nil
+}
+
+func newPeerTransports(errs ...error) ([]*peerTransport, []Transport) {
+	peers := make([]*peerTransport, len(errs))
+	transports := make([]Transport, len(errs))
+	for i, err := range errs {
+		peers[i] = &peerTransport{name: fmt.Sprintf("peer%v", i), err: err}
+		transports[i] = peers[i]
+	}
+	return peers, transports
+}
+
+func TestParsePeerStrategy(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    PeerStrategy
+		wantErr bool
+	}{
+		{s: "random", want: PeerRandom},
+		{s: "roundrobin", want: PeerRoundRobin},
+		{s: "fanout", want: PeerFanout},
+		{s: "", wantErr: true},
+		{s: "round-robin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePeerStrategy(tt.s)
+		if tt.wantErr {
+			assert.Error(t, err, "ParsePeerStrategy(%q) should fail", tt.s)
+			continue
+		}
+		if assert.NoError(t, err, "ParsePeerStrategy(%q) failed", tt.s) {
+			assert.Equal(t, tt.want, got, "ParsePeerStrategy(%q) mismatch", tt.s)
+		}
+	}
+}
+
+func TestNewMultiPeerErrors(t *testing.T) {
+	_, err := NewMultiPeer(PeerRandom, nil, nil /* rand */)
+	assert.Equal(t, errNoPeerTransports, err, "expected error without transports")
+
+	_, transports := newPeerTransports(nil)
+	_, err = NewMultiPeer("sticky", transports, nil /* rand */)
+	assert.Error(t, err, "expected error for unknown strategy")
+}
+
+func TestMultiPeerRoundRobin(t *testing.T) {
+	peers, transports := newPeerTransports(nil, nil, nil)
+	mp, err := NewMultiPeer(PeerRoundRobin, transports, nil /* rand */)
+	require.NoError(t, err, "NewMultiPeer failed")
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		res, err := mp.Call(context.Background(), &Request{})
+		require.NoError(t, err, "Call %v failed", i)
+		got = append(got, string(res.Body))
+	}
+	assert.Equal(t, []string{"peer0", "peer1", "peer2", "peer0", "peer1", "peer2"}, got, "unexpected peer order")
+
+	require.NoError(t, mp.Close(), "Close failed")
+	for _, p := range peers {
+		assert.True(t, p.closed.Load(), "%v should be closed", p.name)
+	}
+}
+
+func TestMultiPeerRandom(t *testing.T) {
+	peers, transports := newPeerTransports(nil, nil)
+	mp, err := NewMultiPeer(PeerRandom, transports, nil /* rand */)
+	require.NoError(t, err, "NewMultiPeer failed")
+
+	for i := 0; i < 100; i++ {
+		_, err := mp.Call(context.Background(), &Request{})
+		require.NoError(t, err, "Call %v failed", i)
+	}
+	assert.EqualValues(t, 100, peers[0].calls.Load()+peers[1].calls.Load(), "unexpected number of calls")
+	assert.NotZero(t, peers[0].calls.Load(), "peer0 should receive calls")
+	assert.NotZero(t, peers[1].calls.Load(), "peer1 should receive calls")
+}
+
+func TestMultiPeerFanout(t *testing.T) {
+	errPeer := errors.New("peer failed")
+
+	tests := []struct {
+		msg      string
+		errs     []error
+		wantBody string
+		wantErr  bool
+	}{
+		{
+			msg:      "all peers succeed",
+			errs:     []error{nil, nil, nil},
+			wantBody: "peer",
+		},
+		{
+			msg:      "some peers fail",
+			errs:     []error{errPeer, nil, errPeer},
+			wantBody: "peer1",
+		},
+		{
+			msg:     "all peers fail",
+			errs:    []error{errPeer, errPeer},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		peers, transports := newPeerTransports(tt.errs...)
+		mp, err := NewMultiPeer(PeerFanout, transports, nil /* rand */)
+		require.NoError(t, err, "%v: NewMultiPeer failed", tt.msg)
+
+		res, err := mp.Call(context.Background(), &Request{})
+		for _, p := range peers {
+			assert.EqualValues(t, 1, p.calls.Load(), "%v: %v should be called once", tt.msg, p.name)
+		}
+
+		if tt.wantErr {
+			if assert.Error(t, err, "%v: Call should fail", tt.msg) {
+				assert.Contains(t, err.Error(), errPeer.Error(), "%v: unexpected error", tt.msg)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, "%v: Call failed", tt.msg) {
+			assert.Contains(t, string(res.Body), tt.wantBody, "%v: unexpected response", tt.msg)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import (
+	"net"
+
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+// IsRetryable returns whether a call that failed with err may succeed if it
+// is retried. Only failures to reach the server, timeouts, and servers that
+// are too busy to handle the call are retryable. Errors such as HTTP status
+// codes or bad requests are returned by the server, and are not retried.
+func IsRetryable(err error) bool {
+	switch err := err.(type) {
+	case *DialError, beginCallError:
+		return true
+	case tchannel.SystemError:
+		switch err.Code() {
+		case tchannel.ErrCodeTimeout, tchannel.ErrCodeBusy, tchannel.ErrCodeDeclined, tchannel.ErrCodeNetwork:
+			return true
+		}
+		return false
+	case net.Error:
+		return err.Timeout()
+	}
+	return err == context.DeadlineExceeded
+}
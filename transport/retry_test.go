@@ -0,0 +1,104 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+type timeoutError struct{ timeout bool }
+
+func (e timeoutError) Error() string   { return "net error" }
+func (e timeoutError) Timeout() bool   { return e.timeout }
+func (e timeoutError) Temporary() bool { return false }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		msg  string
+		err  error
+		want bool
+	}{
+		{
+			msg:  "dial error",
+			err:  &DialError{Addr: "1.1.1.1:1", Err: errors.New("connection refused")},
+			want: true,
+		},
+		{
+			msg:  "begin call error",
+			err:  beginCallError{errors.New("no peers")},
+			want: true,
+		},
+		{
+			msg:  "TChannel timeout",
+			err:  tchannel.ErrTimeout,
+			want: true,
+		},
+		{
+			msg:  "TChannel busy",
+			err:  tchannel.NewSystemError(tchannel.ErrCodeBusy, "busy"),
+			want: true,
+		},
+		{
+			msg:  "TChannel bad request",
+			err:  tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "bad request"),
+			want: false,
+		},
+		{
+			msg:  "TChannel unexpected error",
+			err:  tchannel.NewSystemError(tchannel.ErrCodeUnexpected, "unexpected"),
+			want: false,
+		},
+		{
+			msg:  "HTTP status error",
+			err:  &StatusError{StatusCode: 500, Body: []byte("internal error")},
+			want: false,
+		},
+		{
+			msg:  "net timeout",
+			err:  timeoutError{timeout: true},
+			want: true,
+		},
+		{
+			msg:  "net error that is not a timeout",
+			err:  timeoutError{timeout: false},
+			want: false,
+		},
+		{
+			msg:  "context deadline exceeded",
+			err:  context.DeadlineExceeded,
+			want: true,
+		},
+		{
+			msg:  "other error",
+			err:  errors.New("failed to decode response"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, IsRetryable(tt.err), "IsRetryable(%v) for %v", tt.err, tt.msg)
+	}
+}
@@ -22,15 +22,20 @@ package transport
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/thrift"
+	"go.uber.org/zap"
 	"golang.org/x/net/context"
 )
 
@@ -38,7 +43,20 @@ import (
 // If this key is used, then the headers are sent as is.
 const rawHeadersKey = "_raw_"
 
+var errRawBaggage = errors.New("baggage is not supported for TChannel calls with the raw encoding, since raw calls have no application headers to send it in")
+
+// beginCallError is returned when a call could not be started, e.g., since
+// there was no connection to a peer, so no request was sent to the server.
+type beginCallError struct {
+	err error
+}
+
+func (e beginCallError) Error() string {
+	return fmt.Sprintf("begin call failed: %v", e.err)
+}
+
 type tchan struct {
+	ch          *tchannel.Channel
 	sc          *tchannel.SubChannel
 	callOptions *tchannel.CallOptions
 	tracer      opentracing.Tracer
@@ -84,6 +102,27 @@ type TChannelOptions struct {
 	// Tracer is an instance of an opentracing tracer for baggage propagation
 	// and/or span submission.
 	Tracer opentracing.Tracer
+
+	// TLSConfig is used to establish TLS connections to peers.
+	// If it is nil, TLS is not used.
+	TLSConfig *tls.Config
+
+	// DialTimeout limits how long connecting to a peer may take.
+	// If it is zero, connecting is only limited by the call timeout.
+	DialTimeout time.Duration
+
+	// LocalAddr is the local IP address that connections are made from.
+	// If it is nil, the address is chosen by the operating system.
+	LocalAddr net.IP
+
+	// Resolved maps host names to the IP addresses that are used to connect
+	// to them, instead of resolving the host names using DNS. The host name
+	// is still used to verify the server's TLS certificate.
+	Resolved map[string]net.IP
+
+	// Logger is used to log connection attempts. If it is nil, nothing is
+	// logged.
+	Logger *zap.Logger
 }
 
 // NewTChannel returns a Transport that calls a TChannel service.
@@ -104,11 +143,23 @@ func NewTChannel(opts TChannelOptions) (Transport, error) {
 	}
 	processName := fmt.Sprintf("%v@%v:%v[%v]", os.Getenv("USER"), hostname, os.Args[0], os.Getpid())
 
-	ch, err := tchannel.NewChannel(callerName, &tchannel.ChannelOptions{
+	d := dialer{
+		timeout:   opts.DialTimeout,
+		localAddr: opts.LocalAddr,
+		resolved:  opts.Resolved,
+		logger:    opts.Logger,
+	}
+	chOpts := &tchannel.ChannelOptions{
 		Logger:      tchannel.NewLevelLogger(tchannel.SimpleLogger, level),
 		ProcessName: processName,
 		Tracer:      opts.Tracer,
-	})
+		Dialer:      d.dialContext,
+	}
+	if opts.TLSConfig != nil {
+		chOpts.Dialer = tlsDialer(opts.TLSConfig, d)
+	}
+
+	ch, err := tchannel.NewChannel(callerName, chOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create TChannel: %v", err)
 	}
@@ -124,12 +175,46 @@ func NewTChannel(opts TChannelOptions) (Transport, error) {
 	applyTChanOptions(callOpts, opts.TransportOpts)
 
 	return &tchan{
+		ch:          ch,
 		sc:          ch.GetSubChannel(opts.TargetService),
 		callOptions: callOpts,
 		tracer:      opts.Tracer,
 	}, nil
 }
 
+// tlsDialer returns a dialer that establishes TLS connections using the
+// given configuration, over TCP connections established by d.
+func tlsDialer(config *tls.Config, d dialer) func(ctx context.Context, network, hostPort string) (net.Conn, error) {
+	return func(ctx context.Context, network, hostPort string) (net.Conn, error) {
+		conn, err := d.dialContext(ctx, network, hostPort)
+		if err != nil {
+			return nil, err
+		}
+
+		hostConfig := config
+		if hostConfig.ServerName == "" {
+			host, _, err := net.SplitHostPort(hostPort)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			hostConfig = config.Clone()
+			hostConfig.ServerName = host
+		}
+
+		tlsConn := tls.Client(conn, hostConfig)
+		if deadline, ok := ctx.Deadline(); ok {
+			tlsConn.SetDeadline(deadline)
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %v", err)
+		}
+		tlsConn.SetDeadline(time.Time{})
+		return tlsConn, nil
+	}
+}
+
 func (t *tchan) Tracer() opentracing.Tracer {
 	return t.tracer
 }
@@ -138,6 +223,12 @@ func (t *tchan) Protocol() Protocol {
 	return TChannel
 }
 
+// Close closes the channel, and the connections to any peers.
+func (t *tchan) Close() error {
+	t.ch.Close()
+	return nil
+}
+
 func (t *tchan) Call(ctx context.Context, r *Request) (*Response, error) {
 	// We must create a shallow copy of the request headers because, at time of
 	// writing, we cannot prepare the trace headers before obtaining a TChannel
@@ -147,21 +238,50 @@ func (t *tchan) Call(ctx context.Context, r *Request) (*Response, error) {
 	// introducing a data race.
 	req := *r
 
+	// The TTL sent to the server is derived from the context's deadline, so
+	// bound it by the request's timeout, in case the context has a later
+	// deadline or none.
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+
+	// Raw calls don't have application headers that servers can read
+	// baggage from, so fail rather than silently dropping the baggage.
+	if t.callOptions.Format == tchannel.Raw && len(req.Baggage) > 0 {
+		return nil, errRawBaggage
+	}
+
 	call, err := t.sc.BeginCall(ctx, req.Method, t.callOptions)
 
 	if err != nil {
-		return nil, fmt.Errorf("begin call failed: %v", err)
+		if dialErr, ok := err.(*DialError); ok {
+			return nil, dialErr
+		}
+		return nil, beginCallError{err}
 	}
 
 	req.Headers = tchannel.InjectOutboundSpan(call.Response(), req.Headers)
+	if t.callOptions.Format != tchannel.Raw {
+		// Raw calls don't have application headers that servers can read
+		// tracing headers from.
+		req.Headers = withBaggageHeaders(req.Headers, baggageHeaders(t.tracer, &req, _tchannelTracingPrefix))
+	}
 
 	if err := t.writeArgs(call, &req); err != nil {
 		return nil, err
 	}
 
-	res, err := t.readResponse(call)
-	if err != nil {
-		return nil, err
+	var res *Response
+	if req.Oneway {
+		// Oneway calls have no response, so don't wait for one.
+		res = &Response{TransportFields: make(map[string]interface{})}
+	} else {
+		res, err = t.readResponse(call)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	tchSpan := tchannel.CurrentSpan(ctx)
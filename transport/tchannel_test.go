@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 
@@ -210,6 +211,21 @@ func TestTChannelCallSuccessRaw(t *testing.T) {
 	}
 }
 
+func TestTChannelCallRawBaggage(t *testing.T) {
+	svr, transport := setupServerAndTransport(t)
+	defer svr.Close()
+
+	ctx, cancel := tchannel.NewContext(time.Second)
+	defer cancel()
+
+	_, err := transport.Call(ctx, &Request{
+		Method:  "echo",
+		Baggage: map[string]string{"k": "v"},
+		Body:    []byte{1, 2, 3, 4},
+	})
+	assert.Equal(t, errRawBaggage, err, "Raw calls with baggage should fail")
+}
+
 func TestTChannelCallError(t *testing.T) {
 	ctx, cancel := tchannel.NewContext(time.Second)
 	defer cancel()
@@ -341,3 +357,49 @@ func thriftEncodedHeaders(t *testing.T, headers map[string]string) []byte {
 	require.NoError(t, thrift.WriteHeaders(&buf, headers), "WriteHeaders failed")
 	return buf.Bytes()
 }
+
+func TestTChannelDialError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	transport, err := NewTChannel(TChannelOptions{
+		SourceService: "source",
+		TargetService: "target",
+		Peers:         []string{addr},
+		DialTimeout:   time.Second,
+	})
+	require.NoError(t, err, "Failed to create TChannel transport")
+
+	ctx, cancel := tchannel.NewContext(time.Second)
+	defer cancel()
+
+	_, err = transport.Call(ctx, &Request{Method: "method"})
+	require.Error(t, err, "Call to closed port should fail")
+	assert.IsType(t, &DialError{}, err, "Expected DialError")
+}
+
+func TestTChannelCallDeadline(t *testing.T) {
+	svr, transport := setupServerAndTransport(t)
+	defer svr.Close()
+
+	var ttl time.Duration
+	testutils.RegisterFunc(svr, "echo", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok, "Server context should have a deadline")
+		ttl = deadline.Sub(time.Now())
+		return &raw.Res{Arg2: args.Arg2, Arg3: args.Arg3}, nil
+	})
+
+	ctx, cancel := tchannel.NewContext(time.Minute)
+	defer cancel()
+
+	req := &Request{
+		Method:  "echo",
+		Timeout: time.Second,
+	}
+	_, err := transport.Call(ctx, req)
+	require.NoError(t, err, "Call failed")
+	assert.True(t, ttl > 0 && ttl <= time.Second, "Deadline should be bounded by the request timeout, got TTL %v", ttl)
+}
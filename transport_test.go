@@ -22,7 +22,10 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -46,12 +49,18 @@ func TestParsePeer(t *testing.T) {
 	}{
 		{"1.1.1.1:1", "tchannel", "1.1.1.1:1"},
 		{"some.host:1234", "tchannel", "some.host:1234"},
+		{"[::1]:8080", "tchannel", "[::1]:8080"},
+		{"[2001:db8::1]:8080", "tchannel", "[2001:db8::1]:8080"},
 		{"1.1.1.1", "unknown", ""},
+		{"::1:8080", "unknown", ""},
 		{"ftp://1.1.1.1", "ftp", "1.1.1.1"},
 		{"http://1.1.1.1", "http", "1.1.1.1"},
 		{"https://1.1.1.1", "https", "1.1.1.1"},
 		{"http://1.1.1.1:8080", "http", "1.1.1.1:8080"},
+		{"http://[::1]:8080", "http", "[::1]:8080"},
+		{"grpc://[2001:db8::1]:8080", "grpc", "[2001:db8::1]:8080"},
 		{"grpc://1.1.1.1:8080", "grpc", "1.1.1.1:8080"},
+		{"unix:///tmp/yab.sock", "unix", ""},
 		{"://asd", "unknown", ""},
 	}
 
@@ -62,6 +71,20 @@ func TestParsePeer(t *testing.T) {
 	}
 }
 
+func TestRemapLocalHost(t *testing.T) {
+	ip, err := tchannel.ListenIP()
+	require.NoError(t, err, "ListenIP failed")
+
+	hostPorts := []string{"localhost:1234", "1.1.1.1:1", "[::1]:8080", "localhost.example.com:80"}
+	remapLocalHost(hostPorts)
+	assert.Equal(t, []string{
+		net.JoinHostPort(ip.String(), "1234"),
+		"1.1.1.1:1",
+		"[::1]:8080",
+		"localhost.example.com:80",
+	}, hostPorts, "Only localhost peers should be remapped")
+}
+
 func TestEnsureSameProtocol(t *testing.T) {
 	tests := []struct {
 		peers []string
@@ -127,6 +150,19 @@ func TestGetTransport(t *testing.T) {
 		{
 			opts: TransportOptions{ServiceName: "svc", Peers: []string{"localhost:1234"}},
 		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"[::1]:1234", "[2001:db8::1]:1234"}},
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"http://[::1]:8080"}},
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Relays: []string{"1.1.1.1:21300"}},
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"::1:1234"}},
+			errMsg: `IPv6 peer "::1:1234" must use brackets around the address`,
+		},
 		{
 			opts: TransportOptions{ServiceName: "svc", PeerList: "testdata/valid_peerlist.json"},
 		},
@@ -139,21 +175,126 @@ func TestGetTransport(t *testing.T) {
 			errMsg: "specified peer list is empty",
 		},
 		{
-			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, PeerList: "testdata/valid_peerlist.json"},
-			errMsg: errPeerOptions.Error(),
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, PeerList: "testdata/valid_peerlist.json"},
 		},
 		{
 			opts: TransportOptions{ServiceName: "svc", Peers: []string{"http://1.1.1.1"}},
 		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"https://1.1.1.1"}},
+		},
 		{
 			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1", "http://1.1.1.1"}},
 			errMsg: "found mixed protocols",
 		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"ftp://1.1.1.1"}},
+			errMsg: `unsupported protocol "ftp"`,
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1"}},
+			errMsg: `unsupported protocol "unknown"`,
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"unix:///tmp/yab-not-found.sock"}},
+			errMsg: `unix socket "/tmp/yab-not-found.sock" does not exist`,
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"unix://testdata/valid_peerlist.json"}},
+			errMsg: `peer "testdata/valid_peerlist.json" is not a unix socket`,
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, TLS: true},
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"http://1.1.1.1"}, TLS: true},
+			errMsg: errTLSTChannelOnly.Error(),
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, ArgScheme: "thrift-struct"},
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"http://1.1.1.1"}, ArgScheme: "thrift"},
+			errMsg: errArgSchemeOnly.Error(),
+		},
+		{
+			opts: TransportOptions{
+				ServiceName:      "svc",
+				Peers:            []string{"1.1.1.1:1"},
+				ArgScheme:        "thrift",
+				TransportHeaders: map[string]string{"as": "thrift"},
+			},
+		},
+		{
+			opts: TransportOptions{
+				ServiceName:      "svc",
+				Peers:            []string{"1.1.1.1:1"},
+				ArgScheme:        "thrift-struct",
+				TransportHeaders: map[string]string{"as": "thrift"},
+			},
+			errMsg: errArgSchemeTOpt.Error(),
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"http://1.1.1.1"}, NoDeadlineHeader: true},
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, NoDeadlineHeader: true},
+			errMsg: errNoDeadlineOnly.Error(),
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"http://1.1.1.1"}, Compress: true},
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, Compress: true},
+			errMsg: errCompressOnly.Error(),
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, BindAddress: "127.0.0.1"},
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"http://1.1.1.1"}, BindAddress: "::1"},
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, BindAddress: "eth0"},
+			errMsg: `invalid bind address "eth0": must be an IP address`,
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"grpc://1.1.1.1:1"}, BindAddress: "127.0.0.1"},
+			errMsg: errBindAddressOnly.Error(),
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"kv.example.com:1"}, Resolve: []string{"kv.example.com=1.1.1.1"}},
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"https://kv.example.com"}, Resolve: []string{"kv.example.com=::1"}},
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"kv.example.com:1"}, Resolve: []string{"kv.example.com"}},
+			errMsg: `invalid resolve "kv.example.com", must be specified as host=ip`,
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"grpc://kv.example.com:1"}, Resolve: []string{"kv.example.com=1.1.1.1"}},
+			errMsg: errResolveOnly.Error(),
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, TLSCA: "testdata/notfound.pem"},
+			errMsg: "failed to read TLS CA file",
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1", "1.1.1.1:2"}, PeerStrategy: "fanout"},
+		},
+		{
+			opts: TransportOptions{ServiceName: "svc", Peers: []string{"http://1.1.1.1", "http://1.1.1.2"}, PeerStrategy: "roundrobin"},
+		},
+		{
+			opts:   TransportOptions{ServiceName: "svc", Peers: []string{"1.1.1.1:1"}, PeerStrategy: "sticky"},
+			errMsg: `unknown peer strategy "sticky"`,
+		},
 	}
 
 	for _, tt := range tests {
 		tt.opts.CallerName = "svc"
-		transport, err := getTransport(tt.opts, encoding.Thrift, opentracing.NoopTracer{})
+		transport, err := getTransport(tt.opts, encoding.Thrift, opentracing.NoopTracer{}, _testLogger)
 		if tt.errMsg != "" {
 			if assert.Error(t, err, "getTransport(%v) should fail", tt.opts) {
 				assert.Contains(t, err.Error(), tt.errMsg, "Unexpected error for getTransport(%v)", tt.opts)
@@ -167,6 +308,207 @@ func TestGetTransport(t *testing.T) {
 	}
 }
 
+func TestTransportOptionsGetResolved(t *testing.T) {
+	tests := []struct {
+		resolve []string
+		want    map[string]net.IP
+		wantErr string
+	}{
+		{
+			resolve: nil,
+			want:    nil,
+		},
+		{
+			resolve: []string{"kv.example.com=1.2.3.4", "ipv6.example.com=::1"},
+			want: map[string]net.IP{
+				"kv.example.com":   net.ParseIP("1.2.3.4"),
+				"ipv6.example.com": net.ParseIP("::1"),
+			},
+		},
+		{
+			resolve: []string{"=1.2.3.4"},
+			wantErr: `invalid resolve "=1.2.3.4", must be specified as host=ip`,
+		},
+		{
+			resolve: []string{"kv.example.com=kv2.example.com"},
+			wantErr: `invalid resolve "kv.example.com=kv2.example.com": "kv2.example.com" is not an IP address`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := TransportOptions{Resolve: tt.resolve}.getResolved()
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr, "Unexpected error for %v", tt.resolve)
+			continue
+		}
+
+		assert.NoError(t, err, "getResolved failed for %v", tt.resolve)
+		assert.Equal(t, tt.want, got, "Unexpected resolved hosts for %v", tt.resolve)
+	}
+}
+
+func TestTransportOptionsGetSeed(t *testing.T) {
+	assert.Equal(t, int64(42), TransportOptions{Seed: 42}.getSeed(), "Expected specified seed")
+	assert.NotZero(t, TransportOptions{}.getSeed(), "Expected time-based seed by default")
+}
+
+func TestTransportOptionsGetTLSConfig(t *testing.T) {
+	const (
+		certFile = "testdata/tls/cert.pem"
+		keyFile  = "testdata/tls/key.pem"
+	)
+
+	tests := []struct {
+		msg            string
+		opts           TransportOptions
+		wantNil        bool
+		wantRootCAs    bool
+		wantCerts      int
+		wantSkipVerify bool
+		wantErr        string
+	}{
+		{
+			msg:     "TLS disabled",
+			wantNil: true,
+		},
+		{
+			msg:  "TLS enabled",
+			opts: TransportOptions{TLS: true},
+		},
+		{
+			msg:            "no verify enables TLS",
+			opts:           TransportOptions{TLSNoVerify: true},
+			wantSkipVerify: true,
+		},
+		{
+			msg:         "CA file",
+			opts:        TransportOptions{TLSCA: certFile},
+			wantRootCAs: true,
+		},
+		{
+			msg:     "CA file without certificates",
+			opts:    TransportOptions{TLSCA: "testdata/valid.json"},
+			wantErr: "no certificates found in TLS CA file",
+		},
+		{
+			msg:       "client certificate",
+			opts:      TransportOptions{TLSCert: certFile, TLSKey: keyFile},
+			wantCerts: 1,
+		},
+		{
+			msg:     "client certificate without key",
+			opts:    TransportOptions{TLSCert: certFile},
+			wantErr: errTLSCertAndKey.Error(),
+		},
+		{
+			msg:     "invalid client certificate",
+			opts:    TransportOptions{TLSCert: keyFile, TLSKey: keyFile},
+			wantErr: "failed to load TLS client certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			config, err := tt.opts.getTLSConfig()
+			if tt.wantErr != "" {
+				require.Error(t, err, "getTLSConfig should fail")
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error")
+				return
+			}
+
+			require.NoError(t, err, "getTLSConfig failed")
+			if tt.wantNil {
+				assert.Nil(t, config, "Expected no TLS config")
+				return
+			}
+
+			require.NotNil(t, config, "Expected TLS config")
+			assert.Equal(t, tt.wantRootCAs, config.RootCAs != nil, "RootCAs mismatch")
+			assert.Len(t, config.Certificates, tt.wantCerts, "Certificates mismatch")
+			assert.Equal(t, tt.wantSkipVerify, config.InsecureSkipVerify, "InsecureSkipVerify mismatch")
+		})
+	}
+}
+
+func TestLoadTransportPeers(t *testing.T) {
+	tests := []struct {
+		msg       string
+		opts      TransportOptions
+		wantPeers []string
+		wantErr   string
+	}{
+		{
+			msg:       "peers only",
+			opts:      TransportOptions{Peers: []string{"3.3.3.3:3"}},
+			wantPeers: []string{"3.3.3.3:3"},
+		},
+		{
+			msg:       "peer list only",
+			opts:      TransportOptions{PeerList: "testdata/valid_peerlist_comments.txt"},
+			wantPeers: []string{"1.1.1.1:1", "2.2.2.2:2"},
+		},
+		{
+			msg:       "peers and peer list are merged",
+			opts:      TransportOptions{Peers: []string{"3.3.3.3:3"}, PeerList: "testdata/valid_peerlist.json"},
+			wantPeers: []string{"3.3.3.3:3", "1.1.1.1:1", "2.2.2.2:2"},
+		},
+		{
+			msg:       "relays are used without peers",
+			opts:      TransportOptions{Relays: []string{"4.4.4.4:4", "5.5.5.5:5"}},
+			wantPeers: []string{"4.4.4.4:4", "5.5.5.5:5"},
+		},
+		{
+			msg:       "peers take precedence over relays",
+			opts:      TransportOptions{Peers: []string{"3.3.3.3:3"}, Relays: []string{"4.4.4.4:4"}},
+			wantPeers: []string{"3.3.3.3:3"},
+		},
+		{
+			msg:     "relay must be a host:port",
+			opts:    TransportOptions{Relays: []string{"http://4.4.4.4:4"}},
+			wantErr: `relay "http://4.4.4.4:4" must be a TChannel host:port`,
+		},
+		{
+			msg:     "srv peer without a name",
+			opts:    TransportOptions{Peers: []string{"3.3.3.3:3", "srv:///"}},
+			wantErr: `SRV peer provider URL "srv:///" must specify a name, e.g., srv://_thrift._tcp.service.consul`,
+		},
+		{
+			msg:     "no peers",
+			wantErr: errPeerRequired.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := loadTransportPeers(tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err, "loadTransportPeers failed")
+			assert.Equal(t, tt.wantPeers, got.Peers, "Peers mismatch")
+			assert.Empty(t, got.PeerList, "PeerList should be cleared")
+		})
+	}
+}
+
+func TestGetTransportUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yab-unix")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "svc.sock")
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err, "Failed to listen on unix socket")
+	defer ln.Close()
+
+	opts := TransportOptions{ServiceName: "svc", CallerName: "caller", Peers: []string{"unix://" + socket}}
+	got, err := getTransport(opts, encoding.JSON, opentracing.NoopTracer{}, _testLogger)
+	require.NoError(t, err, "getTransport failed for unix socket")
+	assert.Equal(t, transport.HTTP, got.Protocol(), "Unix sockets should use HTTP")
+}
+
 func TestGetTransportCallerName(t *testing.T) {
 	tests := []struct {
 		caller    string
@@ -203,7 +545,7 @@ func TestGetTransportCallerName(t *testing.T) {
 			Peers:       []string{server.hostPort()},
 			CallerName:  tt.caller,
 		}
-		tchan, err := getTransport(opts, encoding.Raw, opentracing.NoopTracer{})
+		tchan, err := getTransport(opts, encoding.Raw, opentracing.NoopTracer{}, _testLogger)
 		if tt.wantErr {
 			assert.Error(t, err, fmt.Sprintf("Expect fail: %+v", tt))
 			continue
@@ -222,6 +564,49 @@ func TestGetTransportCallerName(t *testing.T) {
 	}
 }
 
+func TestGetTransportArgScheme(t *testing.T) {
+	tests := []struct {
+		argScheme string
+		want      tchannel.Format
+	}{
+		{
+			argScheme: "",
+			want:      tchannel.Raw,
+		},
+		{
+			argScheme: "thrift-struct",
+			want:      tchannel.Format("thrift-struct"),
+		},
+	}
+
+	for _, tt := range tests {
+		server := newServer(t)
+		defer server.shutdown()
+
+		server.register("test", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+			assert.Equal(t, tt.want, tchannel.CurrentCall(ctx).CallOptions().Format, "Arg scheme mismatch")
+			return &raw.Res{}, nil
+		})
+
+		opts := TransportOptions{
+			ServiceName: server.ch.ServiceName(),
+			Peers:       []string{server.hostPort()},
+			CallerName:  "yab",
+			ArgScheme:   tt.argScheme,
+		}
+		tchan, err := getTransport(opts, encoding.Raw, opentracing.NoopTracer{}, _testLogger)
+		require.NoError(t, err, "getTransport failed for arg scheme %q", tt.argScheme)
+
+		ctx, cancel := tchannel.NewContext(time.Second)
+		defer cancel()
+
+		_, err = tchan.Call(ctx, &transport.Request{
+			Method: "test",
+		})
+		assert.NoError(t, err, "Call failed for arg scheme %q", tt.argScheme)
+	}
+}
+
 func TestGetTransportTraceEnabled(t *testing.T) {
 	tracer, closer := getTestTracer("foo")
 	defer closer.Close()
@@ -254,7 +639,7 @@ func TestGetTransportTraceEnabled(t *testing.T) {
 			ctx = opentracing.ContextWithSpan(ctx, span)
 		}
 
-		tchan, err := getTransport(opts, encoding.Raw, tracer)
+		tchan, err := getTransport(opts, encoding.Raw, tracer, _testLogger)
 		require.NoError(t, err, "getTransport failed")
 		res, err := tchan.Call(ctx, &transport.Request{Method: "test"})
 		require.NoError(t, err, "transport.Call failed")
@@ -37,6 +37,7 @@ import (
 // Constants useful for tests
 const (
 	validThrift     = "testdata/simple.thrift"
+	validProto      = "testdata/protobuf/simple.proto"
 	fooMethod       = "Simple::foo"
 	exampleTemplate = "testdata/templates/foo.yab"
 )
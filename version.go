@@ -20,6 +20,21 @@
 
 package main
 
+import (
+	"fmt"
+	"runtime"
+)
+
 // versionString is the sem-ver version string for yab.
 // It will be bumped explicitly on releases.
 var versionString = "0.12.0"
+
+// gitCommit is the commit that yab was built from, which is set at build time
+// using -ldflags "-X main.gitCommit=<commit>". The Makefile and release
+// scripts set it automatically.
+var gitCommit = "unknown"
+
+// buildInfo returns the version and build details printed by --version.
+func buildInfo() string {
+	return fmt.Sprintf("yab version %v\ngit commit: %v\ngo version: %v\n", versionString, gitCommit, runtime.Version())
+}